@@ -0,0 +1,199 @@
+package walkman
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// Descend into zip and tar archives (optionally gzip compressed) as if
+// they were directories, hashing each of their members. The archive
+// itself is still hashed like any other file.
+//
+// Members are reported as path/to/archive.zip/member/path with
+// File.Archive set to the archive. Archives inside archives are
+// hashed as plain files. Plans never touch archive members.
+func WithArchives() Option {
+	return func(w *Walkman) {
+		w.config.archives = true
+	}
+}
+
+// Descend into container image layers, e.g the blobs of an OCI image
+// layout or the layer.tar files written by docker save, to find files
+// duplicated across layers. Layers are recognised by their content
+// since their names carry no extension, and are reported like the
+// archives of WithArchives. Uncompressed and gzipped layers are
+// supported, zstd compressed ones are hashed as plain files.
+func WithImageLayers() Option {
+	return func(w *Walkman) {
+		w.config.layers = true
+	}
+}
+
+// Reports whether the file at path should be descended into.
+// Archives on mounted file systems are hashed as plain files.
+func (wm *Walkman) descends(path string) bool {
+	if isRemote(path) {
+		return false
+	}
+	if wm.config.archives && isArchive(path) {
+		return true
+	}
+
+	if !wm.config.layers {
+		return false
+	}
+
+	defer wm.openSlot()()
+	return isTar(path)
+}
+
+// Reports whether the file at path is an archive WithArchives descends into.
+func isArchive(path string) bool {
+	name := strings.ToLower(path)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hashes the members of the archive at path.
+func (wm *Walkman) hashArchive(path string) {
+	defer wm.openSlot()()
+
+	var err error
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		err = wm.hashZip(path)
+	} else {
+		err = wm.hashTar(path)
+	}
+
+	if err != nil {
+		wm.addError(fmt.Errorf("%s: %w", path, err))
+	}
+}
+
+func (wm *Walkman) hashZip(archive string) error {
+	zr, err := zip.OpenReader(longPath(archive))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if wm.ctx.Err() != nil {
+			return nil
+		}
+		wm.hashMember(archive, f.Name, f.FileInfo(), f.Open)
+	}
+	return nil
+}
+
+func (wm *Walkman) hashTar(archive string) error {
+	file, err := wm.openFile(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r, err := decompress(file)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for wm.ctx.Err() == nil {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// members can only be read in order, while tr is at them
+		wm.hashMember(archive, hdr.Name, hdr.FileInfo(), func() (io.ReadCloser, error) {
+			return io.NopCloser(tr), nil
+		})
+	}
+	return nil
+}
+
+// Hashes a regular, non empty member of archive.
+func (wm *Walkman) hashMember(archive, name string, fi fs.FileInfo, open func() (io.ReadCloser, error)) {
+	if !fi.Mode().IsRegular() || fi.Size() == 0 {
+		return
+	}
+
+	// cleaning a rooted name keeps ../ from escaping the archive
+	member := filepath.Join(archive, filepath.FromSlash(path.Clean("/"+name)))
+	wm.hashChild(archive, member, fi, open)
+}
+
+// Hashes member, a file inside the file at parent
+// that is reported with File.Archive set to it.
+func (wm *Walkman) hashChild(parent, member string, fi fs.FileInfo, open func() (io.ReadCloser, error)) {
+	hash, mime, err := wm.hash(member, fi, open)
+	if err != nil {
+		wm.addError(err)
+		return
+	}
+
+	atomic.AddInt64(&wm.stats.FilesScanned, 1)
+	atomic.AddInt64(&wm.stats.BytesHashed, fi.Size())
+	wm.checkBudget()
+	wm.emit(Event{Kind: EventFileHashed, Path: member, Hash: hash})
+
+	known := wm.isKnown(member, hash)
+	if !known || wm.knownPolicy != KnownExclude {
+		wm.pairs <- pair{hash: hash, path: member, info: fi, archive: parent, mime: mime, known: known}
+	}
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Returns r, gunzipped if it starts like a gzip stream.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil || !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
+
+// Reports whether the file at path is a tar archive, gzipped or not,
+// by looking for the ustar magic of its first header.
+func isTar(path string) bool {
+	file, err := os.Open(longPath(path))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	r, err := decompress(file)
+	if err != nil {
+		return false
+	}
+
+	header := make([]byte, 263)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return false
+	}
+	return bytes.HasPrefix(header[257:], []byte("ustar"))
+}
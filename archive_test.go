@@ -0,0 +1,120 @@
+package walkman
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writes a tar archive with a single member to path, gzipped if compress is set.
+func writeTar(t *testing.T, path, name, content string, compress bool) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if compress {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithArchives(t *testing.T) {
+	dir := t.TempDir()
+	plain := writeFile(t, dir, "report.txt", "quarterly numbers", time.Now())
+
+	zf, err := os.Create(filepath.Join(dir, "old.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zw := zip.NewWriter(zf)
+	w, err := zw.Create("docs/report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("quarterly numbers"))
+	zw.Close()
+	zf.Close()
+
+	writeTar(t, filepath.Join(dir, "old.tar.gz"), "../../report.txt", "quarterly numbers", true)
+
+	hashes, err := New(WithContentHash(), WithArchives()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var group FileList
+	for _, files := range hashes {
+		for _, f := range files {
+			if f.Path == plain.Path {
+				group = files
+			}
+		}
+	}
+
+	if len(group) != 3 {
+		t.Fatalf("expected the file and both archive members in one group, got %v", group)
+	}
+
+	for _, f := range group {
+		if f.Path != plain.Path && !IsUnder(f.Path, f.Archive) {
+			t.Errorf("member %s escapes its archive %q", f.Path, f.Archive)
+		}
+	}
+
+	if plan := NewPlan(hashes, KeepOldest(), ActionDelete, ""); len(plan) != 0 {
+		t.Errorf("expected archive members to be left out of plans, got %v", plan)
+	}
+}
+
+func TestWithImageLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeTar(t, filepath.Join(dir, "blobs/sha256/aaaa"), "usr/lib/libc.so", "elf", true)
+	writeTar(t, filepath.Join(dir, "blobs/sha256/bbbb"), "usr/lib/libc.so", "elf", false)
+	writeFile(t, dir, "blobs/sha256/cccc", `{"config": {}}`, time.Now())
+
+	hashes, err := New(WithContentHash(), WithImageLayers()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	members := FileList{}
+	for _, files := range hashes {
+		for _, f := range files {
+			if f.Archive != "" {
+				members = append(members, f)
+			}
+		}
+	}
+
+	if len(members) != 2 || members[0].Archive == members[1].Archive {
+		t.Errorf("expected libc.so in both layers, got %v", members)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build linux || openbsd || dragonfly || solaris
+
+package walkman
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+func sysAccessTime(info fs.FileInfo) time.Time {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(st.Atim.Unix())
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !openbsd && !dragonfly && !solaris && !darwin && !freebsd && !netbsd && !windows
+
+package walkman
+
+import (
+	"io/fs"
+	"time"
+)
+
+// Access times are not known here.
+func sysAccessTime(info fs.FileInfo) time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,15 @@
+package walkman
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+func sysAccessTime(info fs.FileInfo) time.Time {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(0, data.LastAccessTime.Nanoseconds())
+}
@@ -0,0 +1,511 @@
+package walkman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Largest tag block read, cover art makes them big.
+const maxTagSize = 16 << 20
+
+// Extractor of the artist, title, album and duration of MP3 (ID3v1 and
+// ID3v2), FLAC and Ogg Vorbis or Opus files.
+//
+// The duration of MP3 files without a Xing or Info header is estimated
+// from the bitrate of their first frame.
+func ExtractTags(path string, open func() (io.ReadCloser, error), meta *Meta) error {
+	file, err := open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	magic, err := r.Peek(4)
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case string(magic) == "fLaC":
+		err = readFLAC(r, meta)
+	case string(magic) == "OggS":
+		err = readOgg(r, file, meta)
+	case string(magic[:3]) == "ID3" || isMPEGFrame(magic):
+		err = readMP3(r, file, meta)
+	}
+
+	// malformed tags only mean there is nothing to learn
+	if errors.Is(err, errBadTags) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+var errBadTags = errors.New("walkman: malformed audio tags")
+
+// Groups audio files by artist, title and duration rounded to two
+// seconds, so that copies of a track in other formats or bitrates end
+// up together. Files without artist, title or duration are left out.
+//
+//	tracks := hashes.GroupBy(walkman.ByTrack)
+func ByTrack(file File) string {
+	m := file.Meta
+	if m == nil || m.Artist == "" || m.Title == "" || m.Duration == 0 {
+		return ""
+	}
+
+	seconds := int64((m.Duration+time.Second)/(2*time.Second)) * 2
+	return fmt.Sprintf("track:%s - %s (%d:%02d)",
+		strings.ToLower(m.Artist), strings.ToLower(m.Title), seconds/60, seconds%60)
+}
+
+// Sets the fields of meta named by a Vorbis comment or ID3 frame.
+func setTag(meta *Meta, name, value string) {
+	value = strings.TrimSpace(strings.TrimRight(value, "\x00"))
+	if value == "" {
+		return
+	}
+
+	switch strings.ToUpper(name) {
+	case "ARTIST", "TPE1", "TP1":
+		meta.Artist = value
+	case "TITLE", "TIT2", "TT2":
+		meta.Title = value
+	case "ALBUM", "TALB", "TAL":
+		meta.Album = value
+	case "TLEN", "TLE": // milliseconds
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil && meta.Duration == 0 {
+			meta.Duration = time.Duration(ms) * time.Millisecond
+		}
+	}
+}
+
+// Reads the metadata blocks of a FLAC stream.
+func readFLAC(r *bufio.Reader, meta *Meta) error {
+	r.Discard(4)
+
+	for last := false; !last; {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return errBadTags
+		}
+
+		last = header[0]&0x80 != 0
+		kind := header[0] & 0x7f
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		switch kind {
+		case 0: // STREAMINFO
+			block := make([]byte, size)
+			if _, err := io.ReadFull(r, block); err != nil || size < 18 {
+				return errBadTags
+			}
+
+			rate := int64(block[10])<<12 | int64(block[11])<<4 | int64(block[12])>>4
+			samples := int64(block[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(block[14:]))
+			if rate > 0 {
+				meta.Duration = time.Duration(samples) * time.Second / time.Duration(rate)
+			}
+		case 4: // VORBIS_COMMENT
+			if size > maxTagSize {
+				return errBadTags
+			}
+
+			block := make([]byte, size)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return errBadTags
+			}
+			readVorbisComment(block, meta)
+		default:
+			if _, err := r.Discard(size); err != nil {
+				return errBadTags
+			}
+		}
+	}
+	return nil
+}
+
+// Reads a Vorbis comment block, little endian length prefixed
+// vendor string followed by NAME=value comments.
+func readVorbisComment(block []byte, meta *Meta) {
+	next := func() ([]byte, bool) {
+		if len(block) < 4 {
+			return nil, false
+		}
+
+		n := binary.LittleEndian.Uint32(block)
+		if uint64(n) > uint64(len(block)-4) {
+			return nil, false
+		}
+
+		field := block[4 : 4+n]
+		block = block[4+n:]
+		return field, true
+	}
+
+	if _, ok := next(); !ok { // vendor
+		return
+	}
+
+	if len(block) < 4 {
+		return
+	}
+	count := binary.LittleEndian.Uint32(block)
+	block = block[4:]
+
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+
+		if eq := bytes.IndexByte(comment, '='); eq > 0 {
+			setTag(meta, string(comment[:eq]), string(comment[eq+1:]))
+		}
+	}
+}
+
+// Reads the first two packets of an Ogg Vorbis or Opus stream, the
+// identification and comment headers, and the granule position of the
+// last page for the duration if file can seek.
+func readOgg(r *bufio.Reader, file io.Reader, meta *Meta) error {
+	packets := [][]byte{}
+	var packet []byte
+	read := 0
+
+	for len(packets) < 2 {
+		var header [27]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil || string(header[:4]) != "OggS" {
+			return errBadTags
+		}
+
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return errBadTags
+		}
+
+		for _, n := range segments {
+			read += int(n)
+			if read > maxTagSize {
+				return errBadTags
+			}
+
+			data := make([]byte, n)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return errBadTags
+			}
+			packet = append(packet, data...)
+
+			// a segment shorter than 255 ends the packet
+			if n < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+	}
+
+	id, comments := packets[0], packets[1]
+
+	var rate, preSkip int64
+	switch {
+	case bytes.HasPrefix(id, []byte("\x01vorbis")) && len(id) >= 16:
+		rate = int64(binary.LittleEndian.Uint32(id[12:]))
+		if bytes.HasPrefix(comments, []byte("\x03vorbis")) {
+			readVorbisComment(comments[7:], meta)
+		}
+	case bytes.HasPrefix(id, []byte("OpusHead")) && len(id) >= 12:
+		rate = 48000 // granule positions always count 48 kHz samples
+		preSkip = int64(binary.LittleEndian.Uint16(id[10:]))
+		if bytes.HasPrefix(comments, []byte("OpusTags")) {
+			readVorbisComment(comments[8:], meta)
+		}
+	default:
+		return nil
+	}
+
+	if granule, ok := lastGranule(file); ok && rate > 0 && granule > preSkip {
+		meta.Duration = time.Duration(granule-preSkip) * time.Second / time.Duration(rate)
+	}
+	return nil
+}
+
+// Returns the granule position of the last Ogg page of file,
+// found in its last 64 KiB if file can seek.
+func lastGranule(file io.Reader) (int64, bool) {
+	tail, ok := readTail(file, 64<<10)
+	if !ok {
+		return 0, false
+	}
+
+	i := bytes.LastIndex(tail, []byte("OggS"))
+	if i < 0 || len(tail) < i+14 {
+		return 0, false
+	}
+	return int64(binary.LittleEndian.Uint64(tail[i+6:])), true
+}
+
+// Reads the last n bytes of file if it can seek.
+func readTail(file io.Reader, n int64) ([]byte, bool) {
+	seeker, ok := file.(io.ReadSeeker)
+	if !ok {
+		return nil, false
+	}
+
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, false
+	}
+
+	if n > size {
+		n = size
+	}
+
+	if _, err := seeker.Seek(size-n, io.SeekStart); err != nil {
+		return nil, false
+	}
+
+	tail := make([]byte, n)
+	if _, err := io.ReadFull(seeker, tail); err != nil {
+		return nil, false
+	}
+	return tail, true
+}
+
+// Reads the ID3v2 tag and first frame of an MP3, falling back
+// to the ID3v1 tag at the end of file if it can seek.
+func readMP3(r *bufio.Reader, file io.Reader, meta *Meta) error {
+	tagSize := int64(0)
+
+	if head, _ := r.Peek(10); len(head) == 10 && string(head[:3]) == "ID3" {
+		tagSize = 10 + syncsafe(head[6:])
+		if head[5]&0x10 != 0 { // footer
+			tagSize += 10
+		}
+
+		if tagSize > maxTagSize {
+			return errBadTags
+		}
+
+		tag := make([]byte, tagSize)
+		if _, err := io.ReadFull(r, tag); err != nil {
+			return errBadTags
+		}
+		readID3v2(tag, meta)
+	}
+
+	if meta.Duration == 0 {
+		if size, ok := fileSize(file); ok {
+			meta.Duration = mp3Duration(r, size-tagSize)
+		}
+	}
+
+	if meta.Artist == "" && meta.Title == "" {
+		if tail, ok := readTail(file, 128); ok && bytes.HasPrefix(tail, []byte("TAG")) {
+			setTag(meta, "TITLE", latin1(tail[3:33]))
+			setTag(meta, "ARTIST", latin1(tail[33:63]))
+			setTag(meta, "ALBUM", latin1(tail[63:93]))
+		}
+	}
+	return nil
+}
+
+// Reads the text frames of an ID3v2.2, 2.3 or 2.4 tag.
+func readID3v2(tag []byte, meta *Meta) {
+	version, flags := tag[3], tag[5]
+	frames := tag[10:]
+
+	if flags&0x40 != 0 && len(frames) >= 4 { // extended header
+		n := int64(binary.BigEndian.Uint32(frames)) + 4
+		if version == 4 {
+			n = syncsafe(frames)
+		}
+
+		if n > int64(len(frames)) {
+			return
+		}
+		frames = frames[n:]
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	for len(frames) >= headerLen && frames[0] != 0 {
+		id := string(frames[:idLen])
+
+		var size int64
+		switch version {
+		case 2:
+			size = int64(frames[3])<<16 | int64(frames[4])<<8 | int64(frames[5])
+		case 3:
+			size = int64(binary.BigEndian.Uint32(frames[4:]))
+		default:
+			size = syncsafe(frames[4:])
+		}
+
+		if size > int64(len(frames)-headerLen) {
+			return
+		}
+
+		if body := frames[headerLen : int64(headerLen)+size]; len(body) > 1 && id[0] == 'T' {
+			setTag(meta, id, decodeID3Text(body[0], body[1:]))
+		}
+		frames = frames[int64(headerLen)+size:]
+	}
+}
+
+// Decodes the text of an ID3 frame in the given encoding.
+func decodeID3Text(encoding byte, text []byte) string {
+	switch encoding {
+	case 1, 2: // UTF-16 with a byte order mark, UTF-16BE
+		var order binary.ByteOrder = binary.BigEndian
+		if len(text) >= 2 && text[0] == 0xff && text[1] == 0xfe {
+			order, text = binary.LittleEndian, text[2:]
+		} else if len(text) >= 2 && text[0] == 0xfe && text[1] == 0xff {
+			text = text[2:]
+		}
+
+		units := make([]uint16, len(text)/2)
+		for i := range units {
+			units[i] = order.Uint16(text[2*i:])
+		}
+		return string(utf16.Decode(units))
+	case 3:
+		return string(text)
+	}
+	return latin1(text)
+}
+
+func latin1(text []byte) string {
+	runes := make([]rune, 0, len(text))
+	for _, c := range text {
+		if c == 0 {
+			break
+		}
+		runes = append(runes, rune(c))
+	}
+	return string(runes)
+}
+
+// Decodes the 28 bit integers of ID3v2 headers.
+func syncsafe(b []byte) int64 {
+	return int64(b[0]&0x7f)<<21 | int64(b[1]&0x7f)<<14 | int64(b[2]&0x7f)<<7 | int64(b[3]&0x7f)
+}
+
+// Returns the size of file if it can tell.
+func fileSize(file io.Reader) (int64, bool) {
+	stater, ok := file.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		return 0, false
+	}
+
+	info, err := stater.Stat()
+	if err != nil {
+		return 0, false
+	}
+	return info.Size(), true
+}
+
+// Reports whether b starts with the header of an MPEG audio layer III frame.
+func isMPEGFrame(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0xff && b[1]&0xe0 == 0xe0 && b[1]&0x06 == 0x02
+}
+
+// Bitrates of layer III in kbit/s by MPEG 1 or 2 and index.
+var mp3Bitrates = [2][15]int64{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// Sample rates by MPEG 1, 2 or 2.5 and index.
+var mp3SampleRates = [3][3]int64{
+	{44100, 48000, 32000},
+	{22050, 24000, 16000},
+	{11025, 12000, 8000},
+}
+
+// Returns the duration of the layer III stream r of size bytes from the
+// frame count of its Xing or Info header, or from the bitrate of its
+// first frame.
+func mp3Duration(r *bufio.Reader, size int64) time.Duration {
+	// skip padding up to the first frame
+	for i := 0; i < 64<<10; i++ {
+		b, err := r.Peek(2)
+		if err != nil {
+			return 0
+		}
+
+		if isMPEGFrame(b) {
+			break
+		}
+		r.Discard(1)
+	}
+
+	frame, _ := r.Peek(4 + 32 + 12)
+	if len(frame) < 4 || !isMPEGFrame(frame) {
+		return 0
+	}
+
+	var version int // 0 for MPEG 1, 1 for 2, 2 for 2.5
+	switch frame[1] >> 3 & 0x03 {
+	case 0:
+		version = 2
+	case 2:
+		version = 1
+	case 3:
+		version = 0
+	default:
+		return 0
+	}
+
+	bitrateIndex, rateIndex := frame[2]>>4, frame[2]>>2&0x03
+	if bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return 0
+	}
+
+	rate := mp3SampleRates[version][rateIndex]
+	bitrate := mp3Bitrates[0][bitrateIndex] * 1000
+	samples := int64(1152)
+	if version != 0 {
+		bitrate = mp3Bitrates[1][bitrateIndex] * 1000
+		samples = 576
+	}
+
+	// the Xing header follows the side information
+	mono := frame[3]>>6 == 3
+	side := 32
+	switch {
+	case version == 0 && mono:
+		side = 17
+	case version != 0 && !mono:
+		side = 17
+	case version != 0 && mono:
+		side = 9
+	}
+
+	if len(frame) >= 4+side+12 {
+		xing := frame[4+side:]
+		if (string(xing[:4]) == "Xing" || string(xing[:4]) == "Info") && xing[7]&0x01 != 0 {
+			frames := int64(binary.BigEndian.Uint32(xing[8:]))
+			return time.Duration(frames*samples) * time.Second / time.Duration(rate)
+		}
+	}
+
+	return time.Duration(size*8) * time.Second / time.Duration(bitrate)
+}
@@ -0,0 +1,152 @@
+package walkman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Builds a Vorbis comment block with the given NAME=value comments.
+func testVorbisComment(comments ...string) []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+
+	binary.Write(&b, le, uint32(len("walkman")))
+	b.WriteString("walkman")
+	binary.Write(&b, le, uint32(len(comments)))
+
+	for _, c := range comments {
+		binary.Write(&b, le, uint32(len(c)))
+		b.WriteString(c)
+	}
+	return b.Bytes()
+}
+
+// Builds an MP3 with an ID3v2.3 tag and a Xing header counting frames
+// of 1152 samples at 44.1 kHz.
+func testMP3(artist, title string, frames uint32) string {
+	var tags bytes.Buffer
+	for id, text := range map[string]string{"TPE1": artist, "TIT2": title} {
+		tags.WriteString(id)
+		binary.Write(&tags, binary.BigEndian, uint32(len(text)+1))
+		tags.Write([]byte{0, 0, 3}) // flags, UTF-8
+		tags.WriteString(text)
+	}
+
+	var mp3 bytes.Buffer
+	n := tags.Len()
+	mp3.WriteString("ID3\x03\x00\x00")
+	mp3.Write([]byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)})
+	mp3.Write(tags.Bytes())
+
+	// MPEG 1 layer III, 128 kbit/s, 44.1 kHz, joint stereo
+	mp3.Write([]byte{0xff, 0xfb, 0x90, 0x64})
+	mp3.Write(make([]byte, 32))
+	mp3.WriteString("Xing\x00\x00\x00\x01")
+	binary.Write(&mp3, binary.BigEndian, frames)
+	mp3.Write(make([]byte, 400))
+	return mp3.String()
+}
+
+// Builds a FLAC stream of the given number of samples at 44.1 kHz.
+func testFLAC(samples uint64, comments ...string) string {
+	var flac bytes.Buffer
+	flac.WriteString("fLaC")
+
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint64(info[10:], 44100<<44|1<<41|15<<36|samples)
+	flac.Write([]byte{0, 0, 0, byte(len(info))})
+	flac.Write(info)
+
+	block := testVorbisComment(comments...)
+	flac.Write([]byte{0x84, 0, byte(len(block) >> 8), byte(len(block))})
+	flac.Write(block)
+	return flac.String()
+}
+
+// Builds an Ogg Vorbis stream at 44.1 kHz ending at granule position granule.
+func testOgg(granule int64, comments ...string) string {
+	var ogg bytes.Buffer
+
+	page := func(granule int64, packet []byte) {
+		ogg.WriteString("OggS\x00\x00")
+		binary.Write(&ogg, binary.LittleEndian, granule)
+		ogg.Write(make([]byte, 12)) // serial, sequence and checksum
+
+		lacing := bytes.Repeat([]byte{255}, len(packet)/255)
+		lacing = append(lacing, byte(len(packet)%255))
+		ogg.WriteByte(byte(len(lacing)))
+		ogg.Write(lacing)
+		ogg.Write(packet)
+	}
+
+	id := make([]byte, 30)
+	copy(id, "\x01vorbis")
+	binary.LittleEndian.PutUint32(id[12:], 44100)
+	page(0, id)
+
+	page(0, append([]byte("\x03vorbis"), testVorbisComment(comments...)...))
+	page(granule, []byte("audio"))
+	return ogg.String()
+}
+
+func TestExtractTags(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writeFile(t, dir, "song.mp3", testMP3("Nina Simone", "Sinnerman", 7656), now)
+	writeFile(t, dir, "song.flac", testFLAC(44100*200, "ARTIST=Nina Simone", "title=Sinnerman", "ALBUM=Pastel Blues"), now)
+	writeFile(t, dir, "song.ogg", testOgg(44100*200+22050, "ARTIST=nina simone", "TITLE=Sinnerman"), now)
+	writeFile(t, dir, "other.ogg", testOgg(44100*60, "ARTIST=Nina Simone", "TITLE=Feeling Good"), now)
+	writeFile(t, dir, "old.mp3", "\xff\xfb\x90\x64"+strings.Repeat("\x00", 200)+
+		"TAG"+pad("Feeling Good", 30)+pad("Nina Simone", 30)+pad("", 30)+pad("", 35), now)
+
+	hashes, err := New(WithContentHash(), WithMetadata(ExtractTags)).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]File{}
+	for _, f := range hashes.ToSlice() {
+		files[filepath.Base(f.Path)] = f
+	}
+
+	want := Meta{Artist: "Nina Simone", Title: "Sinnerman", Album: "Pastel Blues", Duration: 200 * time.Second}
+	if m := files["song.flac"].Meta; m == nil || !reflect.DeepEqual(*m, want) {
+		t.Errorf("FLAC Meta = %+v, want %+v", m, want)
+	}
+
+	if m := files["song.mp3"].Meta; m == nil || m.Title != "Sinnerman" || m.Duration.Round(time.Second) != 200*time.Second {
+		t.Errorf("MP3 Meta = %+v, want Sinnerman of 3m20s", m)
+	}
+
+	if m := files["song.ogg"].Meta; m == nil || m.Duration != 200500*time.Millisecond {
+		t.Errorf("Ogg Meta = %+v, want a duration of 3m20.5s", m)
+	}
+
+	if m := files["old.mp3"].Meta; m == nil || m.Artist != "Nina Simone" || m.Title != "Feeling Good" {
+		t.Errorf("ID3v1 Meta = %+v, want Feeling Good by Nina Simone", m)
+	}
+
+	tracks := hashes.GroupBy(ByTrack)
+	if group := tracks["track:nina simone - sinnerman (3:20)"]; len(group) != 3 {
+		t.Errorf("expected the three copies of Sinnerman in a single group, got %v", tracks)
+	}
+
+	filter, err := ParseFilter(`title == "Feeling Good" && duration > 30s`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filtered := hashes.Filter(filter).ToSlice(); len(filtered) != 1 || filepath.Base(filtered[0].Path) != "other.ogg" {
+		t.Errorf("expected only other.ogg to match, got %v", filtered)
+	}
+}
+
+func pad(s string, n int) string {
+	return s + strings.Repeat("\x00", n-len(s))
+}
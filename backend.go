@@ -0,0 +1,304 @@
+package walkman
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// ResultBackend collects the files found by walks in place of the
+// in-memory Results, see WithResultBackend. Calls are never concurrent.
+type ResultBackend interface {
+	// Add records file under hash, it may be buffered until Flush.
+	Add(hash string, file File) error
+
+	// Flush writes out the files buffered since the previous call.
+	Flush() error
+
+	// Close flushes the backend and releases what it holds.
+	Close() error
+}
+
+// GroupedBackend is a ResultBackend that can read its files back,
+// like DiskBackend and MemoryBackend.
+type GroupedBackend interface {
+	ResultBackend
+
+	// Groups calls fn with every hash and all of its files, in any
+	// order, stopping at the first error fn returns.
+	Groups(fn func(hash string, files FileList) error) error
+}
+
+// Collect the files found by walks into backends rather than in memory,
+// e.g a DiskBackend for trees of tens of millions of files, or stream
+// them elsewhere with NDJSONBackend, CSVBackend or SQLBackend. Every file
+// is added to each backend, which is flushed as the walk ends, or more
+// often with WithFlushEvery. The caller remains responsible for closing
+// backends.
+//
+// Walks then only return the groups of duplicates, files sharing their
+// hash with another one, of the first GroupedBackend, and nothing without
+// one; every file can be read back with its Groups. Such walks do not
+// prune removed files from the index of WithIndex, as that needs every
+// path in memory, and fail if WithCheckpoint or WithDuplicatesOnly is
+// given too.
+func WithResultBackend(backends ...ResultBackend) Option {
+	return func(w *Walkman) {
+		w.backends = backends
+	}
+}
+
+// Returns an error if the walk combines backends with options
+// they do not support.
+func (wm *Walkman) checkBackends() error {
+	if len(wm.backends) == 0 {
+		return nil
+	}
+
+	if wm.checkpoint != "" {
+		return errors.New("walkman: WithCheckpoint can not be combined with WithResultBackend")
+	}
+
+	if wm.config.duplicatesOnly {
+		return errors.New("walkman: WithDuplicatesOnly can not be combined with WithResultBackend")
+	}
+	return nil
+}
+
+// Adds the files of the walk to the backends and returns the groups of
+// duplicates found in the first one that can list them.
+func (wm *Walkman) collectBackend() Results {
+	for p := range wm.pairs {
+		wm.addToBatch(p.hash, wm.newFile(p))
+	}
+	wm.flushBatch()
+
+	duplicates := make(Results)
+	for _, backend := range wm.backends {
+		grouped, ok := backend.(GroupedBackend)
+		if !ok {
+			continue
+		}
+
+		err := grouped.Groups(func(hash string, files FileList) error {
+			if len(files) > 1 {
+				duplicates[hash] = files
+			}
+			return nil
+		})
+
+		if err != nil {
+			wm.addError(err)
+		}
+		break
+	}
+	return duplicates
+}
+
+// Records kept in memory by a DiskBackend unless told otherwise.
+const defaultRunSize = 1 << 16
+
+// DiskBackend is a GroupedBackend keeping files on disk in sorted runs:
+// files are buffered in memory, sorted by hash and written out once
+// there are enough of them or on Flush. Groups merges the runs, holding
+// a single group in memory at a time.
+type DiskBackend struct {
+	dir     string // temporary directory of the runs
+	runSize int
+	buf     []diskRecord
+	runs    []string
+}
+
+// A file as written in runs.
+type diskRecord struct {
+	Hash string `json:"hash"`
+	snapshotFile
+}
+
+// Returns a DiskBackend writing its runs to a new temporary directory
+// below dir (os.TempDir if empty), with runSize files per run (65536 if
+// not positive). Close removes the runs.
+func NewDiskBackend(dir string, runSize int) (*DiskBackend, error) {
+	if runSize <= 0 {
+		runSize = defaultRunSize
+	}
+
+	tmp, err := os.MkdirTemp(dir, "walkman-results-*")
+	if err != nil {
+		return nil, err
+	}
+	return &DiskBackend{dir: tmp, runSize: runSize}, nil
+}
+
+func (b *DiskBackend) Add(hash string, file File) error {
+	b.buf = append(b.buf, diskRecord{Hash: hash, snapshotFile: newSnapshotFile(file)})
+
+	if len(b.buf) >= b.runSize {
+		return b.Flush()
+	}
+	return nil
+}
+
+// Writes the buffered files to a new run, sorted by hash then path.
+func (b *DiskBackend) Flush() error {
+	if len(b.buf) == 0 {
+		return nil
+	}
+
+	sort.Slice(b.buf, func(i, j int) bool {
+		if b.buf[i].Hash != b.buf[j].Hash {
+			return b.buf[i].Hash < b.buf[j].Hash
+		}
+		return b.buf[i].Path < b.buf[j].Path
+	})
+
+	path := filepath.Join(b.dir, "run-"+strconv.Itoa(len(b.runs)))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range b.buf {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	b.runs = append(b.runs, path)
+	b.buf = b.buf[:0]
+	return nil
+}
+
+func (b *DiskBackend) Groups(fn func(hash string, files FileList) error) error {
+	if err := b.Flush(); err != nil {
+		return err
+	}
+
+	merger := &runMerger{}
+	defer merger.close()
+
+	for _, path := range b.runs {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		r := &runReader{file: f, dec: json.NewDecoder(bufio.NewReader(f))}
+		merger.readers = append(merger.readers, r)
+
+		if err := r.next(); err != nil {
+			return err
+		}
+
+		if !r.done {
+			heap.Push(merger, r)
+		}
+	}
+
+	hash, files := "", FileList(nil)
+	for merger.Len() > 0 {
+		r := merger.heap[0]
+
+		if r.record.Hash != hash && len(files) > 0 {
+			if err := fn(hash, files); err != nil {
+				return err
+			}
+			files = nil
+		}
+
+		hash = r.record.Hash
+		files = append(files, r.record.file())
+
+		if err := r.next(); err != nil {
+			return err
+		}
+
+		if r.done {
+			heap.Pop(merger)
+		} else {
+			heap.Fix(merger, 0)
+		}
+	}
+
+	if len(files) > 0 {
+		return fn(hash, files)
+	}
+	return nil
+}
+
+// Removes the runs written so far.
+func (b *DiskBackend) Close() error {
+	return os.RemoveAll(b.dir)
+}
+
+// Reads the records of a run in order.
+type runReader struct {
+	file   *os.File
+	dec    *json.Decoder
+	record diskRecord
+	done   bool
+}
+
+func (r *runReader) next() error {
+	r.record = diskRecord{}
+	err := r.dec.Decode(&r.record)
+	if err == io.EOF {
+		r.done = true
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("walkman: reading %s: %w", r.file.Name(), err)
+	}
+	return nil
+}
+
+// Heap of runs ordered by their next record.
+type runMerger struct {
+	heap    []*runReader
+	readers []*runReader // all of them, to be closed
+}
+
+func (m *runMerger) Len() int { return len(m.heap) }
+
+func (m *runMerger) Less(i, j int) bool {
+	a, b := m.heap[i].record, m.heap[j].record
+	if a.Hash != b.Hash {
+		return a.Hash < b.Hash
+	}
+	return a.Path < b.Path
+}
+
+func (m *runMerger) Swap(i, j int)      { m.heap[i], m.heap[j] = m.heap[j], m.heap[i] }
+func (m *runMerger) Push(x interface{}) { m.heap = append(m.heap, x.(*runReader)) }
+
+func (m *runMerger) Pop() interface{} {
+	r := m.heap[len(m.heap)-1]
+	m.heap = m.heap[:len(m.heap)-1]
+	return r
+}
+
+func (m *runMerger) close() {
+	for _, r := range m.readers {
+		r.file.Close()
+	}
+}
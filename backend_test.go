@@ -0,0 +1,85 @@
+package walkman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskBackend(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	a := writeFile(t, dir, "a.txt", "hello", mtime)
+	b := writeFile(t, dir, "sub/b.txt", "hello", mtime)
+	c := writeFile(t, dir, "sub/c.txt", "hello", mtime)
+	writeFile(t, dir, "d.txt", "world", mtime)
+	writeFile(t, dir, "e.txt", "unique", mtime)
+
+	spill := t.TempDir()
+	backend, err := NewDiskBackend(spill, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashes, err := New(WithContentHash(), WithResultBackend(backend)).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashes) != 1 {
+		t.Fatalf("expected only the group of duplicates to be returned, got %v", hashes)
+	}
+
+	for _, files := range hashes {
+		got := paths(files)
+		if len(got) != 3 || got[0] != a.Path || got[1] != b.Path || got[2] != c.Path {
+			t.Errorf("expected %s, %s and %s, got %v", a.Path, b.Path, c.Path, got)
+		}
+	}
+
+	if len(backend.runs) < 2 {
+		t.Errorf("expected the files spilled to several runs, got %d", len(backend.runs))
+	}
+
+	count := 0
+	err = backend.Groups(func(hash string, files FileList) error {
+		count += len(files)
+
+		for _, f := range files {
+			content, err := os.ReadFile(f.Path)
+			if err != nil {
+				return err
+			}
+
+			if f.Stats.Size() != int64(len(content)) || !f.Stats.ModTime().Equal(mtime) || f.Stats.Name() != filepath.Base(f.Path) {
+				t.Errorf("%s read back with size %d and mtime %v", f.Path, f.Stats.Size(), f.Stats.ModTime())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 5 {
+		t.Errorf("expected every file to be read back, got %d", count)
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries, _ := os.ReadDir(spill); len(entries) != 0 {
+		t.Errorf("expected the runs to be removed, found %d entries", len(entries))
+	}
+}
+
+func paths(files FileList) []string {
+	p := make([]string, 0, len(files))
+	for _, f := range files {
+		p = append(p, f.Path)
+	}
+	return p
+}
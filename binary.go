@@ -0,0 +1,97 @@
+package walkman
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Written first by Results.WriteBinarySnapshot, followed by the version
+// of the format as a single byte.
+const binarySnapshotMagic = "WALKMAN\x00"
+
+// Version of the binary snapshot format written by Results.SaveBinary.
+const binarySnapshotVersion = 1
+
+// Comes after the version in a binary snapshot, followed by Groups
+// snapshotGroup values.
+type binaryHeader struct {
+	Algorithm string
+	Created   time.Time
+	Groups    int
+}
+
+// Saves the results to a binary snapshot at path, much smaller and quicker
+// to save and load than the JSON of Save for millions of files. LoadResults
+// reads both. Like Save, the snapshot is compressed if path ends in .gz.
+func (hashes Results) SaveBinary(path string) error {
+	return saveFile(path, hashes.WriteBinarySnapshot)
+}
+
+// Writes the results to w in the snapshot format of SaveBinary:
+// a header then every group encoded with encoding/gob, so that
+// field names are only written once.
+func (hashes Results) WriteBinarySnapshot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(binarySnapshotMagic); err != nil {
+		return err
+	}
+
+	if err := bw.WriteByte(binarySnapshotVersion); err != nil {
+		return err
+	}
+
+	enc := gob.NewEncoder(bw)
+	header := binaryHeader{Algorithm: hashes.Algorithm(), Created: time.Now(), Groups: len(hashes)}
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes.sortedKeys() {
+		if err := enc.Encode(hashes.snapshotGroup(hash)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Reads a snapshot written by WriteBinarySnapshot.
+func readBinarySnapshot(r *bufio.Reader) (Results, error) {
+	if _, err := r.Discard(len(binarySnapshotMagic)); err != nil {
+		return nil, err
+	}
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	if version != binarySnapshotVersion {
+		return nil, fmt.Errorf("unsupported binary snapshot version %d", version)
+	}
+
+	dec := gob.NewDecoder(r)
+
+	var header binaryHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+
+	// the count comes from the file, so it is not trusted to size anything
+	if header.Groups < 0 {
+		return nil, fmt.Errorf("invalid binary snapshot with %d groups", header.Groups)
+	}
+
+	hashes := make(Results)
+	for i := 0; i < header.Groups; i++ {
+		var group snapshotGroup
+		if err := dec.Decode(&group); err != nil {
+			return nil, err
+		}
+		hashes.addSnapshotGroup(group)
+	}
+
+	return hashes, hashes.checkAlgorithm(header.Algorithm)
+}
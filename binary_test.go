@@ -0,0 +1,95 @@
+package walkman
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBinarySnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Date(2023, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	for i := 0; i < 50; i++ {
+		writeFile(t, dir, fmt.Sprintf("a/%d.txt", i), fmt.Sprint(i), mtime)
+		writeFile(t, dir, fmt.Sprintf("b/%d.txt", i), fmt.Sprint(i), mtime)
+	}
+
+	hashes, err := New(WithContentHash(), WithMIME()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	binary, text := filepath.Join(out, "scan.gob"), filepath.Join(out, "scan.json")
+	if err := hashes.SaveBinary(binary); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hashes.Save(text); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadResults(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded) != len(hashes) {
+		t.Fatalf("expected %d groups, got %d", len(hashes), len(loaded))
+	}
+
+	for hash, files := range hashes {
+		if len(loaded[hash]) != len(files) {
+			t.Errorf("group %s: expected %d files, got %d", hash, len(files), len(loaded[hash]))
+			continue
+		}
+
+		f := loaded[hash][0]
+		if f.Stats.Size() != files[0].Stats.Size() || !f.Stats.ModTime().Equal(mtime) || f.MIME != files[0].MIME || f.RelPath == "" {
+			t.Errorf("%s was not preserved: %d %v %q %q", f.Path, f.Stats.Size(), f.Stats.ModTime(), f.MIME, f.RelPath)
+		}
+	}
+
+	bs, _ := os.Stat(binary)
+	ts, _ := os.Stat(text)
+	if bs.Size() >= ts.Size() {
+		t.Errorf("expected the binary snapshot to be smaller than JSON, got %d and %d bytes", bs.Size(), ts.Size())
+	}
+}
+
+func TestBinarySnapshotRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.gob")
+	if err := os.WriteFile(path, []byte(binarySnapshotMagic+"\x63"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadResults(path); err == nil {
+		t.Error("expected an error for an unknown binary snapshot version")
+	}
+}
+
+func TestBinarySnapshotRejectsBadGroupCount(t *testing.T) {
+	for _, groups := range []int{-1, math.MaxInt} {
+		var buf bytes.Buffer
+		buf.WriteString(binarySnapshotMagic)
+		buf.WriteByte(binarySnapshotVersion)
+		if err := gob.NewEncoder(&buf).Encode(binaryHeader{Algorithm: "md5", Groups: groups}); err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(t.TempDir(), "scan.gob")
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := LoadResults(path); err == nil {
+			t.Errorf("expected an error for a snapshot claiming %d groups", groups)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package walkman
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// Chunker splits the content read from r into blocks, calling emit for
+// each in order. The slice passed to emit is only valid during the call.
+type Chunker func(r io.Reader, emit func(block []byte)) error
+
+// Splits content into blocks of size bytes, the last one may be shorter.
+// This is what ZFS does with its recordsize, 128KiB by default.
+func FixedChunks(size int) Chunker {
+	return func(r io.Reader, emit func([]byte)) error {
+		buf := make([]byte, size)
+		for {
+			n, err := io.ReadFull(r, buf)
+			if n > 0 {
+				emit(buf[:n])
+			}
+
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Splits content where a rolling hash of the last bytes hits a pattern,
+// so that inserting a byte only changes the blocks around it. Blocks are
+// of avg bytes on average, rounded to a power of two, and never shorter
+// than avg/4 nor longer than avg*4 unless content ends.
+func ContentDefinedChunks(avg int) Chunker {
+	mask := uint64(1)<<(bits.Len(uint(avg))-1) - 1
+	min, max := avg/4, avg*4
+
+	return func(r io.Reader, emit func([]byte)) error {
+		br := bufio.NewReaderSize(r, 64*1024)
+		block := make([]byte, 0, max)
+
+		var hash uint64
+		for {
+			c, err := br.ReadByte()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return err
+			}
+
+			block = append(block, c)
+			hash = hash<<1 + gear[c]
+
+			if len(block) >= min && (hash&mask == 0 || len(block) == max) {
+				emit(block)
+				block, hash = block[:0], 0
+			}
+		}
+
+		if len(block) > 0 {
+			emit(block)
+		}
+		return nil
+	}
+}
+
+// Random values the rolling hash of ContentDefinedChunks adds up,
+// always the same so that estimates are reproducible.
+var gear = func() (table [256]uint64) {
+	state := uint64(0x5eed)
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		table[i] = z ^ z>>31
+	}
+	return table
+}()
+
+// BlockEstimate is the outcome of EstimateBlockDedup.
+type BlockEstimate struct {
+	Files        int   // files read
+	Bytes        int64 // bytes in those files
+	Blocks       int   // blocks the files were split into
+	UniqueBlocks int   // distinct blocks among them
+	UniqueBytes  int64 // bytes of the distinct blocks, what would be stored
+}
+
+// Bytes block-level deduplication would save.
+func (e BlockEstimate) Savings() int64 {
+	return e.Bytes - e.UniqueBytes
+}
+
+// Deduplication ratio as reported by ZFS, e.g 1.25 when a fifth of the
+// blocks are duplicates. 1 for no files at all.
+func (e BlockEstimate) Ratio() float64 {
+	if e.UniqueBytes == 0 {
+		return 1
+	}
+	return float64(e.Bytes) / float64(e.UniqueBytes)
+}
+
+// Estimates how much space block-level deduplication, as done by ZFS or
+// VDO, would save on the files of hashes, by reading them all and splitting
+// them with chunker. This tells whether turning it on pays for its memory
+// before doing so, and also counts what whole file deduplication would not,
+// e.g virtual machine images or backups sharing most of their content.
+//
+// Links, directories, archive members and remote files are left out.
+// Only one md5 digest per distinct block is kept in memory.
+func (hashes Results) EstimateBlockDedup(ctx context.Context, chunker Chunker) (BlockEstimate, error) {
+	var est BlockEstimate
+	seen := make(map[[md5.Size]byte]bool)
+
+	for hash, files := range hashes {
+		alg := algorithmOf(hash)
+		if alg == AlgorithmLink || alg == AlgorithmDeadLink {
+			continue
+		}
+
+		for _, f := range onDisk(files) {
+			if f.Stats != nil && f.Stats.IsDir() {
+				continue
+			}
+
+			if err := ctx.Err(); err != nil {
+				return est, err
+			}
+
+			file, err := os.Open(f.Path)
+			if err != nil {
+				return est, err
+			}
+
+			err = chunker(file, func(block []byte) {
+				sum := md5.Sum(block)
+				est.Blocks++
+				est.Bytes += int64(len(block))
+
+				if !seen[sum] {
+					seen[sum] = true
+					est.UniqueBlocks++
+					est.UniqueBytes += int64(len(block))
+				}
+			})
+			file.Close()
+
+			if err != nil {
+				return est, fmt.Errorf("%s: %w", f.Path, err)
+			}
+			est.Files++
+		}
+	}
+
+	return est, nil
+}
@@ -0,0 +1,71 @@
+package walkman
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateBlockDedup(t *testing.T) {
+	dir := t.TempDir()
+
+	data := make([]byte, 256*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	// the same image with a byte inserted at the start
+	for name, content := range map[string][]byte{
+		"a.img": data,
+		"b.img": append([]byte{'x'}, data...),
+		"c.txt": []byte("aaaabbbb"),
+		"d.txt": []byte("aaaacccc"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hashes, err := New().Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byExt := func(ext string) PathFilter {
+		return func(f File) bool { return filepath.Ext(f.Path) == ext }
+	}
+
+	fixed, err := hashes.Filter(byExt(".txt")).EstimateBlockDedup(context.Background(), FixedChunks(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := BlockEstimate{Files: 2, Bytes: 16, Blocks: 4, UniqueBlocks: 3, UniqueBytes: 12}
+	if fixed != want {
+		t.Errorf("expected %+v, got %+v", want, fixed)
+	}
+
+	if fixed.Savings() != 4 || fixed.Ratio() != 16.0/12 {
+		t.Errorf("expected 4 bytes saved, got %d at %.2f", fixed.Savings(), fixed.Ratio())
+	}
+
+	images := hashes.Filter(byExt(".img"))
+
+	shifted, err := images.EstimateBlockDedup(context.Background(), FixedChunks(4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if shifted.Savings() != 0 {
+		t.Errorf("expected fixed blocks thrown off by the inserted byte, got %d bytes saved", shifted.Savings())
+	}
+
+	cdc, err := images.EstimateBlockDedup(context.Background(), ContentDefinedChunks(4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cdc.Bytes != int64(2*len(data)+1) || cdc.Savings() < int64(len(data))*9/10 {
+		t.Errorf("expected most of the image found twice by content defined blocks, got %+v", cdc)
+	}
+}
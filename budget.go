@@ -0,0 +1,56 @@
+package walkman
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// Returned by walks stopped by WithMaxFiles or WithMaxBytesHashed, along
+// with the results found so far.
+var ErrBudgetExceeded = errors.New("walkman: scan budget exceeded")
+
+// Stops the walk once n files were hashed, reused from a previous walk or
+// listed for their unique size, so that a scheduled scan on a shared machine
+// has a predictable cost. Walks then return the results found so far with
+// ErrBudgetExceeded; files workers were already busy with are finished, so a
+// few more may be found. With WithCheckpoint, the next walk picks up from there.
+func WithMaxFiles(n int64) Option {
+	return func(w *Walkman) {
+		w.maxFiles = n
+	}
+}
+
+// Like WithMaxFiles, stopping the walk once n bytes were hashed.
+func WithMaxBytesHashed(n int64) Option {
+	return func(w *Walkman) {
+		w.maxBytes = n
+	}
+}
+
+// Stops the walk if its budget is spent, see WithMaxFiles.
+func (wm *Walkman) checkBudget() {
+	if wm.maxFiles <= 0 && wm.maxBytes <= 0 {
+		return
+	}
+
+	files := atomic.LoadInt64(&wm.stats.FilesScanned) + atomic.LoadInt64(&wm.stats.FilesReused) +
+		atomic.LoadInt64(&wm.stats.FilesUnique)
+	bytes := atomic.LoadInt64(&wm.stats.BytesHashed)
+
+	if (wm.maxFiles > 0 && files >= wm.maxFiles) || (wm.maxBytes > 0 && bytes >= wm.maxBytes) {
+		wm.stop(ErrBudgetExceeded)
+	}
+}
+
+// Returns why the walk of ctx ended early, nil if it did not.
+func walkErr(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+
+	if cause := context.Cause(ctx); errors.Is(cause, ErrBudgetExceeded) {
+		return cause
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,32 @@
+package walkman
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 50; i++ {
+		writeFile(t, dir, fmt.Sprintf("%02d.txt", i), strings.Repeat("x", 100+i), time.Now())
+	}
+
+	for _, option := range []Option{WithMaxFiles(5), WithMaxBytesHashed(500)} {
+		wm := New(WithContentHash(), WithWorkers(1), option)
+		hashes, err := wm.Walk(dir)
+		if !errors.Is(err, ErrBudgetExceeded) {
+			t.Fatalf("expected the budget to be exceeded, got %v", err)
+		}
+
+		if n := len(hashes.ToSlice()); n < 5 || n == 50 {
+			t.Errorf("expected partial results of at least 5 files, got %d", n)
+		}
+	}
+
+	if _, err := New(WithMaxFiles(100)).Walk(dir); err != nil {
+		t.Errorf("expected a walk within budget to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package walkman
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Copies a single instance of every distinct file of hashes into dst, named
+// by the md5 of its content like .git/objects: dst/objects/d4/1d8cd98f...
+//
+// Files are read whatever the hasher that grouped them, an md5 group is only
+// read once and objects already in dst are never written again, so that dst
+// can collect the exports of many trees. Links, directories, archive members
+// and remote files are left out.
+//
+// Every exported path is appended to dst/index as "md5:<hex>\t<path>" so that
+// the tree can be put back together. Returns the number of objects written.
+func (hashes Results) ExportCAS(dst string) (int, error) {
+	objects := filepath.Join(dst, "objects")
+	if err := os.MkdirAll(objects, 0755); err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, 0, len(hashes))
+	for hash := range hashes {
+		keys = append(keys, hash)
+	}
+	sort.Strings(keys)
+
+	var index []string
+	written := 0
+
+	for _, hash := range keys {
+		alg := algorithmOf(hash)
+		if alg == AlgorithmLink || alg == AlgorithmDeadLink {
+			continue
+		}
+
+		for _, f := range onDisk(hashes[hash]) {
+			if f.Stats != nil && f.Stats.IsDir() {
+				continue
+			}
+
+			sum := strings.TrimPrefix(hash, AlgorithmMD5+":")
+			if alg != AlgorithmMD5 || !pathExists(objectPath(objects, sum)) {
+				var isNew bool
+				var err error
+
+				sum, isNew, err = storeObject(objects, f.Path)
+				if err != nil {
+					return written, err
+				}
+
+				if isNew {
+					written++
+				}
+			}
+
+			index = append(index, fmt.Sprintf("%s:%s\t%s", AlgorithmMD5, sum, f.Path))
+		}
+	}
+
+	return written, appendIndex(filepath.Join(dst, "index"), index)
+}
+
+// Path of the object with the hex md5 sum below the objects directory.
+func objectPath(objects, sum string) string {
+	return filepath.Join(objects, sum[:2], sum[2:])
+}
+
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// Copies the file at path into the objects directory while hashing it.
+// Returns its md5 sum and whether the object was not there yet.
+func storeObject(objects, path string) (string, bool, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(objects, ".walkman-tmp")
+	if err != nil {
+		return "", false, err
+	}
+	defer os.Remove(tmp.Name()) // a no-op once renamed
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), in); err != nil {
+		tmp.Close()
+		return "", false, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return "", false, err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	obj := objectPath(objects, sum)
+	if pathExists(obj) {
+		return sum, false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(obj), 0755); err != nil {
+		return "", false, err
+	}
+
+	// Objects never change, as in git
+	if err := os.Chmod(tmp.Name(), 0444); err != nil {
+		return "", false, err
+	}
+
+	if err := os.Rename(tmp.Name(), obj); err != nil {
+		return "", false, err
+	}
+	return sum, true, nil
+}
+
+// Appends lines to the index file at path.
+func appendIndex(path string, lines []string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(f, line); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,59 @@
+package walkman
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportCAS(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "same", time.Now())
+	writeFile(t, dir, "sub/a.txt", "same", time.Now())
+	writeFile(t, dir, "b.txt", "other", time.Now())
+
+	dst := t.TempDir()
+	for _, opts := range [][]Option{{WithContentHash()}, {}} {
+		hashes, err := New(opts...).Walk(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := hashes.ExportCAS(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// by name, sub/a.txt and a.txt are read, but the object is already there
+		if n != 0 && len(opts) == 0 {
+			t.Errorf("expected nothing written twice, got %d objects", n)
+		}
+
+		if n != 2 && len(opts) == 1 {
+			t.Errorf("expected 2 objects written, got %d", n)
+		}
+	}
+
+	// md5("same")
+	data, err := os.ReadFile(filepath.Join(dst, "objects", "51", "037a4a37730f52c8732586d3aaa316"))
+	if err != nil || string(data) != "same" {
+		t.Errorf("expected the object of a.txt, got %q, %v", data, err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dst, "index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(index)), "\n")
+	if len(lines) != 6 {
+		t.Errorf("expected every path indexed on both exports, got %q", lines)
+	}
+
+	want := "md5:51037a4a37730f52c8732586d3aaa316\t" + filepath.Join(dir, "sub/a.txt")
+	if !strings.Contains(string(index), want) {
+		t.Errorf("expected %q in the index, got %q", want, lines)
+	}
+}
@@ -0,0 +1,90 @@
+package walkman
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Match names regardless of case with the default name and size
+// hasher, e.g Report.PDF with report.pdf, as case-insensitive file
+// systems do. It is the default when a root lies on such a file
+// system, like those of Windows and macOS. Hashes are then prefixed
+// with AlgorithmNameFolded.
+func WithCaseInsensitiveNames() Option {
+	return func(w *Walkman) {
+		w.config.foldNames = true
+	}
+}
+
+// Like NameSizeHasher, with names in lower case.
+func foldedNameHasher(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+	return fmt.Sprintf("%s:%s-%d", AlgorithmNameFolded, strings.ToLower(filepath.Base(path)), info.Size()), nil
+}
+
+// Switches the default name hasher to foldedNameHasher if asked
+// to or if any of roots is on a case-insensitive file system.
+func (wm *Walkman) foldNames(roots []string) {
+	if strings.TrimSuffix(wm.algorithm, "+xattr") != AlgorithmName {
+		return
+	}
+
+	fold := wm.config.foldNames
+	for _, root := range roots {
+		fold = fold || caseInsensitive(root)
+	}
+
+	if fold {
+		wm.hashFunc = foldedNameHasher
+		wm.algorithm = AlgorithmNameFolded
+
+		if wm.config.xattrHash {
+			wm.hashFunc = xattrHasher(wm.hashFunc)
+			wm.algorithm += "+xattr"
+		}
+	}
+}
+
+// Reports whether the local directory dir lies on a case-insensitive
+// file system, by looking it or one of its parents up with the case
+// of its name swapped.
+func caseInsensitive(dir string) bool {
+	if isRemote(dir) {
+		return false
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+
+	for path := dir; ; path = filepath.Dir(path) {
+		name := filepath.Base(path)
+		if swapped := swapCase(name); swapped != name {
+			want, err := os.Stat(longPath(path))
+			if err != nil {
+				return false
+			}
+
+			got, err := os.Stat(longPath(filepath.Join(filepath.Dir(path), swapped)))
+			return err == nil && os.SameFile(want, got)
+		}
+
+		if filepath.Dir(path) == path {
+			return false // no name has letters
+		}
+	}
+}
+
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
@@ -0,0 +1,34 @@
+package walkman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithCaseInsensitiveNames(t *testing.T) {
+	dir := t.TempDir()
+	upper := writeFile(t, dir, "Report.PDF", "pages", time.Now())
+	writeFile(t, dir, "sub/report.pdf", "pages", time.Now())
+
+	hashes, err := New(WithCaseInsensitiveNames()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if group := hashes["iname:report.pdf-5"]; len(group) != 2 {
+		t.Errorf("expected both reports in a group, got %v", hashes)
+	}
+
+	if caseInsensitive(dir) {
+		t.Skip("names are matched regardless of case on this file system")
+	}
+
+	hashes, err = New().Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashes) != 2 || hashOf(t, hashes, upper.Path) != "name:Report.PDF-5" {
+		t.Errorf("expected the reports apart on a case-sensitive file system, got %v", hashes)
+	}
+}
@@ -0,0 +1,126 @@
+package walkman
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Category is the broad kind of content of a file, see File.Category.
+type Category string
+
+const (
+	CategoryDocument Category = "document"
+	CategoryImage    Category = "image"
+	CategoryVideo    Category = "video"
+	CategoryAudio    Category = "audio"
+	CategoryArchive  Category = "archive"
+	CategoryCode     Category = "code"
+	CategoryOther    Category = "other"
+)
+
+// Categories of known extensions, lower case without the dot.
+var extCategories = map[string]Category{}
+
+func init() {
+	for c, exts := range map[Category]string{
+		CategoryDocument: "pdf doc docx odt rtf txt md epub xls xlsx ods csv ppt pptx odp pages numbers key tex",
+		CategoryImage:    "jpg jpeg png gif bmp tif tiff webp heic heif svg ico raw cr2 nef arw dng psd",
+		CategoryVideo:    "mp4 m4v mkv mov avi wmv flv webm mpg mpeg 3gp mts m2ts vob",
+		CategoryAudio:    "mp3 flac ogg oga opus wav aac m4a wma aiff aif alac mid midi",
+		CategoryArchive:  "zip tar gz tgz bz2 xz zst 7z rar iso dmg jar deb rpm apk cab",
+		CategoryCode:     "go c h cc cpp hpp rs py rb js ts tsx jsx java kt swift cs php sh pl lua sql html css scss json yaml yml toml xml",
+	} {
+		for _, ext := range strings.Fields(exts) {
+			extCategories[ext] = c
+		}
+	}
+}
+
+// Returns the category of the file by its content type where it is
+// known, see WithMIME, so that magic bytes win over a missing or
+// misleading extension, and by its extension otherwise.
+//
+// Office documents are zip archives inside, their extension decides.
+func (f File) Category() Category {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(f.Path), "."))
+	byExt, known := extCategories[ext]
+
+	switch c := mimeCategory(f.MIME); {
+	case c == CategoryArchive && known:
+		return byExt
+	case c != "":
+		return c
+	case known:
+		return byExt
+	}
+	return CategoryOther
+}
+
+// Returns the category of a content type, empty for those that say
+// little about content such as text/plain or application/octet-stream.
+func mimeCategory(mime string) Category {
+	mime = mediaType(mime)
+
+	switch {
+	case strings.HasPrefix(mime, "image/"):
+		return CategoryImage
+	case strings.HasPrefix(mime, "video/"):
+		return CategoryVideo
+	case strings.HasPrefix(mime, "audio/"):
+		return CategoryAudio
+	}
+
+	switch mime {
+	case "application/pdf", "application/postscript", "application/rtf":
+		return CategoryDocument
+	case "application/ogg":
+		return CategoryAudio
+	case "application/zip", "application/x-gzip", "application/x-rar-compressed", "application/x-7z-compressed",
+		"application/x-bzip2", "application/x-xz", "application/x-tar":
+		return CategoryArchive
+	}
+	return ""
+}
+
+// Keeps files of category c, see File.Category.
+func ByCategory(c Category) PathFilter {
+	return func(f File) bool {
+		return f.Category() == c
+	}
+}
+
+// CategoryStats sums the files of a category, see Results.ByCategory.
+type CategoryStats struct {
+	Category Category
+	Files    int
+	Bytes    int64
+}
+
+// Counts the files and their bytes by category, largest first, see
+// Histogram.
+func (hashes Results) ByCategory() []CategoryStats {
+	byCategory := make(map[Category]*CategoryStats)
+
+	hashes.summarize(func(f File) {
+		c := f.Category()
+		if byCategory[c] == nil {
+			byCategory[c] = &CategoryStats{Category: c}
+		}
+		byCategory[c].Files++
+		byCategory[c].Bytes += f.Stats.Size()
+	})
+
+	stats := make([]CategoryStats, 0, len(byCategory))
+	for _, s := range byCategory {
+		stats = append(stats, *s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Bytes != stats[j].Bytes {
+			return stats[i].Bytes > stats[j].Bytes
+		}
+		return stats[i].Category < stats[j].Category
+	})
+	return stats
+}
@@ -0,0 +1,49 @@
+package walkman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCategory(t *testing.T) {
+	tests := []struct {
+		path string
+		mime string
+		want Category
+	}{
+		{"/a/report.PDF", "", CategoryDocument},
+		{"/a/clip.dat", "video/mp4", CategoryVideo},
+		{"/a/photo.txt", "image/jpeg", CategoryImage},
+		{"/a/letter.docx", "application/zip", CategoryDocument},
+		{"/a/backup.bin", "application/zip", CategoryArchive},
+		{"/a/main.go", "text/plain; charset=utf-8", CategoryCode},
+		{"/a/blob", "application/octet-stream", CategoryOther},
+	}
+
+	for _, tt := range tests {
+		if got := (File{Path: tt.path, MIME: tt.mime}).Category(); got != tt.want {
+			t.Errorf("category of %s (%q) = %s, want %s", tt.path, tt.mime, got, tt.want)
+		}
+	}
+}
+
+func TestResultsByCategory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.mp4", "video", time.Now())
+	writeFile(t, dir, "b/a.mp4", "video", time.Now())
+	writeFile(t, dir, "c.pdf", "pdf", time.Now())
+
+	hashes, err := New().Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := hashes.ByCategory()
+	if len(stats) != 2 || stats[0] != (CategoryStats{CategoryVideo, 2, 10}) || stats[1] != (CategoryStats{CategoryDocument, 1, 3}) {
+		t.Errorf("expected videos then documents, got %+v", stats)
+	}
+
+	if videos := hashes.Filter(ByCategory(CategoryVideo)); len(videos) != 1 {
+		t.Errorf("expected only the videos kept, got %v", videos)
+	}
+}
@@ -0,0 +1,99 @@
+package walkman
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Checkpoints are saved every minute unless told otherwise.
+const defaultCheckpointInterval = time.Minute
+
+// Saves the files hashed so far to the snapshot at path every interval,
+// or every minute if it is not positive, while walking, so that a walk
+// of many hours interrupted by a reboot or SIGKILL can resume instead of
+// restarting: when path exists as a walk starts, its hashes are carried
+// over like WithPrevious and only the files not hashed yet are read.
+// Directories are listed again, which is cheap next to hashing their
+// files. A checkpoint that can not be read, e.g cut short by a crash, is
+// reported as an error of the walk, which starts afresh.
+//
+// The checkpoint is saved once more when the walk is cancelled and removed
+// once it completes. It can not be combined with WithResultBackend.
+func WithCheckpoint(path string, interval time.Duration) Option {
+	return func(w *Walkman) {
+		if interval <= 0 {
+			interval = defaultCheckpointInterval
+		}
+		w.checkpoint = path
+		w.checkpointInterval = interval
+	}
+}
+
+// Adds the hashes of an earlier, interrupted walk to the previous
+// results if there is a checkpoint to resume from.
+func (wm *Walkman) resumeCheckpoint() error {
+	if wm.checkpoint == "" {
+		return nil
+	}
+
+	saved, err := LoadResults(wm.checkpoint)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		wm.addError(fmt.Errorf("walkman: ignoring checkpoint %s: %w", wm.checkpoint, err))
+		return nil
+	}
+
+	wm.logger.Info("resuming from checkpoint", "path", wm.checkpoint, "groups", len(saved))
+
+	merged := make(Results, len(wm.previous)+len(saved))
+	for _, hashes := range []Results{wm.previous, saved} {
+		if err := merged.Merge(hashes); err != nil {
+			return fmt.Errorf("walkman: resuming from checkpoint: %w", err)
+		}
+	}
+	wm.previous = merged
+	return nil
+}
+
+// Returns a channel ticking when a checkpoint is due, nil without
+// WithCheckpoint, and a function stopping it.
+func (wm *Walkman) checkpointTicker() (<-chan time.Time, func()) {
+	if wm.checkpoint == "" {
+		return nil, func() {}
+	}
+
+	t := time.NewTicker(wm.checkpointInterval)
+	return t.C, t.Stop
+}
+
+// Saves hashes as the checkpoint. Failing to do so does not stop the walk.
+func (wm *Walkman) saveCheckpoint(hashes Results) {
+	if err := hashes.Save(wm.checkpoint); err != nil {
+		wm.addError(fmt.Errorf("walkman: saving checkpoint: %w", err))
+		return
+	}
+	wm.logger.Debug("saved checkpoint", "path", wm.checkpoint, "groups", len(hashes))
+}
+
+// Saves the checkpoint a last time if the walk was cancelled,
+// or removes it since there is nothing left to resume.
+func (wm *Walkman) endCheckpoint(hashes Results) {
+	if wm.checkpoint == "" {
+		return
+	}
+
+	if wm.ctx.Err() != nil {
+		wm.saveCheckpoint(hashes)
+		return
+	}
+
+	if err := os.Remove(wm.checkpoint); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		wm.addError(err)
+	}
+}
@@ -0,0 +1,90 @@
+package walkman
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "sub/c.txt"} {
+		writeFile(t, dir, name, name, time.Now())
+	}
+
+	checkpoint := filepath.Join(t.TempDir(), "scan.json")
+
+	// interrupted once the first file is hashed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wm := New(WithContentHash(), WithHashWorkers(1), WithCheckpoint(checkpoint, time.Hour), WithEventSink(func(e Event) {
+		if e.Kind == EventFileHashed {
+			cancel()
+		}
+	}))
+
+	if _, err := wm.WalkContext(ctx, dir); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the walk cancelled, got %v", err)
+	}
+
+	saved, err := LoadResults(checkpoint)
+	if err != nil {
+		t.Fatalf("expected a checkpoint of the cancelled walk, got %v", err)
+	}
+
+	hashed := 0
+	for _, files := range saved {
+		hashed += len(files)
+	}
+
+	if hashed == 0 {
+		t.Fatalf("expected the hashed file in the checkpoint, got %v", saved)
+	}
+
+	wm = New(WithContentHash(), WithCheckpoint(checkpoint, time.Hour))
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashes) != 3 {
+		t.Errorf("expected every file once resumed, got %v", hashes)
+	}
+
+	if reused := wm.LastRunStats().FilesReused; reused != int64(hashed) {
+		t.Errorf("expected the %d files of the checkpoint reused, got %d", hashed, reused)
+	}
+
+	if _, err := os.Stat(checkpoint); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the checkpoint removed once the walk completed, got %v", err)
+	}
+}
+
+func TestWithCheckpointCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "a", time.Now())
+
+	// cut short by a crash
+	checkpoint := filepath.Join(t.TempDir(), "scan.json")
+	if err := os.WriteFile(checkpoint, []byte(`{"version":1,"groups":[{"ha`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wm := New(WithContentHash(), WithCheckpoint(checkpoint, time.Hour))
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatalf("expected the walk to start afresh, got %v", err)
+	}
+
+	if len(hashes) != 1 || wm.LastRunStats().FilesReused != 0 {
+		t.Errorf("expected a.txt hashed afresh, got %v", hashes)
+	}
+
+	if errs := wm.Errors(); len(errs) != 1 {
+		t.Errorf("expected the checkpoint reported, got %v", errs)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the blocks subcommand.
+type blocksFlags struct {
+	block  int
+	cdc    bool
+	where  string
+	hidden bool
+	load   string
+}
+
+func (opts *blocksFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("blocks", flag.ExitOnError)
+	fs.IntVar(&opts.block, "block", 128*1024, "split files into blocks of `N` bytes, the ZFS recordsize or the VDO block size (4096)")
+	fs.BoolVar(&opts.cdc, "cdc", false, "split files where their content says so, blocks are then of --block bytes on average")
+	fs.StringVar(&opts.where, "where", "", "only read files matching `EXPR`, e.g. 'size > 10MB && ext in (pdf, docx)'")
+	fs.BoolVar(&opts.hidden, "hidden", false, "also walk hidden directories")
+	fs.StringVar(&opts.load, "load", "", "read the files to consider from a snapshot `FILE` instead of walking")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s blocks [flags] <dirname>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman blocks [flags] DIR...
+//
+// Reads every file below DIR in blocks and prints how much space block
+// level deduplication, as done by ZFS or VDO, would save.
+func runBlocks(args []string) {
+	var opts blocksFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() == 0 && opts.load == "" {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	if opts.block <= 0 {
+		fatalf("--block must be a positive number of bytes\n")
+	}
+
+	filter := parseWhere(opts.where)
+
+	roots, err := absPaths(fs.Args())
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	var options []walkman.Option
+	if opts.hidden {
+		options = append(options, walkman.WithIncludeHidden())
+	}
+
+	wm := walkman.New(options...)
+	hashes, interrupted := scan(ctx, wm, roots, opts.load, "")
+	hashes = hashes.Filter(filter)
+
+	chunker := walkman.FixedChunks(opts.block)
+	if opts.cdc {
+		chunker = walkman.ContentDefinedChunks(opts.block)
+	}
+
+	est, err := hashes.EstimateBlockDedup(ctx, chunker)
+	interrupted = checkInterrupted(err) || interrupted
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "files\t%d\n", est.Files)
+	fmt.Fprintf(tw, "bytes\t%s\n", formatBytes(est.Bytes))
+	fmt.Fprintf(tw, "blocks\t%d, %d distinct\n", est.Blocks, est.UniqueBlocks)
+	fmt.Fprintf(tw, "stored\t%s\n", formatBytes(est.UniqueBytes))
+	fmt.Fprintf(tw, "savings\t%s\n", formatBytes(est.Savings()))
+	fmt.Fprintf(tw, "ratio\t%.2fx\n", est.Ratio())
+	tw.Flush()
+
+	if reportErrors(wm) || interrupted {
+		os.Exit(exitError)
+	}
+}
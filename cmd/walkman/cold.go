@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the cold subcommand.
+type coldFlags struct {
+	days   int
+	atime  bool
+	human  bool
+	list   bool
+	hidden bool
+	load   string
+}
+
+func (opts *coldFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("cold", flag.ExitOnError)
+	fs.IntVar(&opts.days, "days", 365, "report files not modified for `N` days")
+	fs.BoolVar(&opts.atime, "atime", false, "leave out files read in that time too, where access times are kept")
+	fs.BoolVar(&opts.human, "human", false, "print sizes with binary units, e.g 1.5 GiB")
+	fs.BoolVar(&opts.list, "list", false, "also list the files of every directory")
+	fs.BoolVar(&opts.hidden, "hidden", false, "also walk hidden directories")
+	fs.StringVar(&opts.load, "load", "", "read a snapshot from `FILE` instead of walking, e.g one saved by dupes --save")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s cold [flags] <dirname>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman cold [flags] DIR...
+//
+// Reports the directories holding files nobody changed for --days,
+// largest first, with the bytes and number of those files: candidates
+// to move to cheaper storage.
+func runCold(args []string) {
+	var opts coldFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() == 0 && opts.load == "" {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	roots, err := absPaths(fs.Args())
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	options, unmount, err := mountRemotes(roots)
+	if err != nil {
+		fatal(err)
+	}
+	defer unmount()
+
+	if opts.hidden {
+		options = append(options, walkman.WithIncludeHidden())
+	}
+
+	wm := walkman.New(options...)
+	hashes, interrupted := scan(ctx, wm, roots, opts.load, "")
+
+	size := func(n int64) string {
+		if opts.human {
+			return formatBytes(n)
+		}
+		return strconv.FormatInt(n, 10)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -opts.days)
+	var total int64
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SIZE\tFILES\tDIRECTORY")
+	for _, d := range hashes.ColdDirs(cutoff, opts.atime) {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", size(d.Size), len(d.Files), d.Dir)
+		total += d.Size
+
+		if opts.list {
+			for _, f := range d.Files {
+				fmt.Fprintf(tw, "\t\t    %s\n", f.Path)
+			}
+		}
+	}
+	tw.Flush()
+
+	fmt.Printf("\n%s not modified for %d days\n", formatBytes(total), opts.days)
+
+	if reportErrors(wm) || interrupted {
+		os.Exit(exitError)
+	}
+}
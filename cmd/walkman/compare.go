@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the compare subcommand.
+type compareFlags struct {
+	names bool
+	same  bool
+}
+
+func (opts *compareFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.BoolVar(&opts.names, "names", false, "match files by name and size instead of content (faster)")
+	fs.BoolVar(&opts.same, "same", false, "also list files found at the same relative path in both trees")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s compare [flags] <dir_a> <dir_b>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman compare [flags] DIR_A DIR_B
+//
+// Lists files only in A (<), only in B (>) and files whose content is
+// in both trees under a different relative path (=). Like diff(1) the
+// exit status is 0 when the trees hold the same files and 1 otherwise.
+func runCompare(args []string) {
+	var opts compareFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	roots, err := absPaths(fs.Args())
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	trees := make([]walkman.Results, 2)
+	failed := false
+
+	for i, root := range roots {
+		options := []walkman.Option{walkman.WithContentHash()}
+		if opts.names {
+			options = nil
+		}
+
+		wm := walkman.New(options...)
+
+		hashes, err := wm.WalkContext(ctx, root)
+		if checkInterrupted(err) {
+			os.Exit(exitError)
+		}
+
+		failed = reportErrors(wm) || failed
+		trees[i] = hashes
+	}
+
+	// e.g names folded on a case-insensitive file system only
+	if a, b := trees[0].Algorithm(), trees[1].Algorithm(); len(trees[0]) > 0 && len(trees[1]) > 0 && a != b {
+		fatalf("can not compare %q hashes of %s with %q hashes of %s\n", a, roots[0], b, roots[1])
+	}
+
+	c := walkman.Compare(trees[0], trees[1])
+
+	printOnly(c.OnlyA, "<", roots[0])
+	printOnly(c.OnlyB, ">", roots[1])
+
+	moved := 0
+	for _, hash := range sortedMatches(c.Both) {
+		m := c.Both[hash]
+		a := relPaths(m.A, roots[0])
+		b := relPaths(m.B, roots[1])
+
+		same := strings.Join(a, "\x00") == strings.Join(b, "\x00")
+		if !same {
+			moved++
+		}
+
+		if !same || opts.same {
+			fmt.Printf("= %s -> %s\n", strings.Join(a, ", "), strings.Join(b, ", "))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%d only in %s, %d only in %s, %d in both (%d at a different path)\n",
+		countFiles(c.OnlyA), roots[0], countFiles(c.OnlyB), roots[1], len(c.Both), moved)
+
+	switch {
+	case failed:
+		os.Exit(exitError)
+	case !c.Equal():
+		os.Exit(exitDifferent)
+	}
+}
+
+// Prints every file in hashes relative to root, prefixed by marker.
+func printOnly(hashes walkman.Results, marker, root string) {
+	paths := []string{}
+	for _, files := range hashes {
+		paths = append(paths, relPaths(files, root)...)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		fmt.Println(marker, p)
+	}
+}
+
+func relPaths(files walkman.FileList, root string) []string {
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		rel, err := filepath.Rel(root, f.Path)
+		if err != nil {
+			rel = f.Path
+		}
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func sortedMatches(m map[string]walkman.Match) []string {
+	keys := make([]string, 0, len(m))
+	for hash := range m {
+		keys = append(keys, hash)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func countFiles(hashes walkman.Results) int {
+	n := 0
+	for _, files := range hashes {
+		n += len(files)
+	}
+	return n
+}
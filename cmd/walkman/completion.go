@@ -0,0 +1,306 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Fixed values offered when completing a flag, keyed by flag name.
+var flagValues = map[string][]string{
+	"keep": {"oldest", "newest", "shortest"},
+}
+
+// What the positional arguments of a subcommand complete to.
+// Subcommands not listed here complete directories.
+var argValues = map[string]completer{
+	"completion": {words: []string{"bash", "zsh", "fish"}},
+	"diff":       {files: true},
+	"undo":       {files: true},
+}
+
+// walkman completion bash|zsh|fish
+//
+// Prints a completion script generated from the flags of every subcommand,
+// so it never falls behind the CLI.
+//
+//	source <(walkman completion bash)
+//	walkman completion zsh > "${fpath[1]}/_walkman"
+//	walkman completion fish > ~/.config/fish/completions/walkman.fish
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		fatalf("Usage: %s completion bash|zsh|fish\n", os.Args[0])
+	}
+
+	switch args[0] {
+	case "bash":
+		bashCompletion(os.Stdout)
+	case "zsh":
+		zshCompletion(os.Stdout)
+	case "fish":
+		fishCompletion(os.Stdout)
+	default:
+		fatalf("unsupported shell %q, expected bash, zsh or fish\n", args[0])
+	}
+}
+
+// What a flag or argument completes to.
+type completer struct {
+	words []string // fixed values
+	dirs  bool     // directories
+	files bool     // any file
+}
+
+type flagInfo struct {
+	name   string
+	usage  string
+	isBool bool
+	values completer
+}
+
+// Describes the flags of fs. The placeholder in the usage string
+// (e.g. `DIR` or `FILE`) decides what the flag's value completes to.
+func describeFlags(fs *flag.FlagSet) []flagInfo {
+	flags := []flagInfo{}
+	if fs == nil {
+		return flags
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		placeholder, usage := flag.UnquoteUsage(f)
+		info := flagInfo{name: f.Name, usage: usage}
+
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			info.isBool = true
+		}
+
+		switch {
+		case flagValues[f.Name] != nil:
+			info.values.words = flagValues[f.Name]
+		case placeholder == "DIR":
+			info.values.dirs = true
+		case placeholder == "FILE":
+			info.values.files = true
+		}
+
+		flags = append(flags, info)
+	})
+
+	return flags
+}
+
+// The default listing command and every subcommand with their flags.
+type completionTarget struct {
+	name    string // empty for the listing command
+	summary string
+	flags   []flagInfo
+	args    completer
+}
+
+func completionTargets() []completionTarget {
+	targets := []completionTarget{{
+		flags: describeFlags(new(listFlags).flagSet()),
+		args:  completer{dirs: true},
+	}}
+
+	for _, cmd := range subcommands() {
+		t := completionTarget{name: cmd.name, summary: cmd.summary, args: completer{dirs: true}}
+		if cmd.flags != nil {
+			t.flags = describeFlags(cmd.flags())
+		}
+
+		if args, ok := argValues[cmd.name]; ok {
+			t.args = args
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+func bashCompletion(w io.Writer) {
+	targets := completionTargets()
+
+	names := []string{}
+	for _, t := range targets[1:] {
+		names = append(names, t.name)
+	}
+
+	fmt.Fprintln(w, "# bash completion for walkman")
+	fmt.Fprintln(w, "_walkman() {")
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" cmd=""`)
+	fmt.Fprintln(w, `	[[ $COMP_CWORD -gt 1 ]] && cmd="${COMP_WORDS[1]}"`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `	case "$cmd" in`)
+
+	// the listing command catches everything else and must come last
+	ordered := append(append([]completionTarget{}, targets[1:]...), targets[0])
+	for _, t := range ordered {
+		if t.name == "" {
+			fmt.Fprintln(w, "	*)")
+		} else {
+			fmt.Fprintf(w, "	%s)\n", t.name)
+		}
+
+		fmt.Fprintln(w, `		case "$prev" in`)
+		for _, f := range t.flags {
+			if f.isBool {
+				continue
+			}
+			fmt.Fprintf(w, "		--%s|-%s) %s; return ;;\n", f.name, f.name, bashReply(f.values))
+		}
+		fmt.Fprintln(w, "		esac")
+
+		opts := []string{}
+		for _, f := range t.flags {
+			opts = append(opts, "--"+f.name)
+		}
+
+		fmt.Fprintln(w, `		if [[ "$cur" == -* ]]; then`)
+		fmt.Fprintf(w, "			COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(opts, " "))
+
+		if t.name == "" {
+			fmt.Fprintln(w, `		elif [[ $COMP_CWORD -eq 1 ]]; then`)
+			fmt.Fprintf(w, "			COMPREPLY=($(compgen -W %q -- \"$cur\") $(compgen -d -- \"$cur\"))\n", strings.Join(names, " "))
+		}
+
+		fmt.Fprintln(w, "		else")
+		fmt.Fprintf(w, "			%s\n", bashReply(t.args))
+		fmt.Fprintln(w, "		fi")
+		fmt.Fprintln(w, "		;;")
+	}
+
+	fmt.Fprintln(w, "	esac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o filenames -F _walkman walkman")
+}
+
+func bashReply(c completer) string {
+	switch {
+	case c.words != nil:
+		return fmt.Sprintf(`COMPREPLY=($(compgen -W %q -- "$cur"))`, strings.Join(c.words, " "))
+	case c.dirs:
+		return `COMPREPLY=($(compgen -d -- "$cur"))`
+	case c.files:
+		return `COMPREPLY=($(compgen -f -- "$cur"))`
+	}
+	return "COMPREPLY=()"
+}
+
+func zshCompletion(w io.Writer) {
+	targets := completionTargets()
+
+	fmt.Fprintln(w, "#compdef walkman")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_walkman() {")
+	fmt.Fprintln(w, "	local -a commands")
+	fmt.Fprintln(w, "	commands=(")
+	for _, t := range targets[1:] {
+		fmt.Fprintf(w, "		%s\n", shellQuote(t.name+":"+t.summary))
+	}
+	fmt.Fprintln(w, "	)")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "	if (( CURRENT > 2 )); then")
+	fmt.Fprintln(w, "		case $words[2] in")
+
+	for _, t := range targets[1:] {
+		fmt.Fprintf(w, "		%s)\n", t.name)
+		fmt.Fprintln(w, "			shift words")
+		fmt.Fprintln(w, "			(( CURRENT-- ))")
+		fmt.Fprintf(w, "			_arguments -S %s\n", zshSpecs(t, "*:argument:"+zshAction(t.args)))
+		fmt.Fprintln(w, "			return")
+		fmt.Fprintln(w, "			;;")
+	}
+
+	fmt.Fprintln(w, "		esac")
+	fmt.Fprintln(w, "	fi")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "	_arguments -S %s\n", zshSpecs(targets[0],
+		"1:command or directory:{_describe command commands; _files -/}"))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `_walkman "$@"`)
+}
+
+// Argument specs for zsh's _arguments, one per line.
+func zshSpecs(t completionTarget, args string) string {
+	specs := []string{}
+	for _, f := range t.flags {
+		desc := zshEscape(f.usage)
+		if f.isBool {
+			specs = append(specs, shellQuote(fmt.Sprintf("--%s[%s]", f.name, desc)))
+			continue
+		}
+
+		spec := fmt.Sprintf("--%s=[%s]:%s:%s", f.name, desc, f.name, zshAction(f.values))
+		specs = append(specs, shellQuote(spec))
+	}
+
+	specs = append(specs, shellQuote(args))
+	return strings.Join(specs, " \\\n\t\t\t\t")
+}
+
+func zshAction(c completer) string {
+	switch {
+	case c.words != nil:
+		return "(" + strings.Join(c.words, " ") + ")"
+	case c.dirs:
+		return "_files -/"
+	case c.files:
+		return "_files"
+	}
+	return " "
+}
+
+// Escapes characters with a meaning inside an _arguments description.
+func zshEscape(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func fishCompletion(w io.Writer) {
+	targets := completionTargets()
+
+	fmt.Fprintln(w, "# fish completion for walkman")
+	fmt.Fprintln(w, "complete -c walkman -f")
+
+	for _, t := range targets {
+		cond := "__fish_use_subcommand"
+		if t.name != "" {
+			cond = "__fish_seen_subcommand_from " + t.name
+			fmt.Fprintf(w, "complete -c walkman -n __fish_use_subcommand -a %s -d %s\n",
+				t.name, shellQuote(t.summary))
+		}
+
+		prefix := "complete -c walkman -n " + shellQuote(cond)
+
+		for _, f := range t.flags {
+			line := fmt.Sprintf("%s -l %s", prefix, f.name)
+
+			if !f.isBool {
+				line += " " + fishArgs(f.values)
+			}
+			fmt.Fprintf(w, "%s -d %s\n", line, shellQuote(f.usage))
+		}
+
+		fmt.Fprintf(w, "%s %s\n", prefix, fishArgs(t.args))
+	}
+}
+
+func fishArgs(c completer) string {
+	switch {
+	case c.words != nil:
+		return "-x -a " + shellQuote(strings.Join(c.words, " "))
+	case c.dirs:
+		return "-x -a '(__fish_complete_directories)'"
+	case c.files:
+		return "-r -F"
+	}
+	return "-x"
+}
+
+// Wraps s in single quotes, escaping embedded ones.
+// The result is valid for both zsh and fish.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
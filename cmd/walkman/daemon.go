@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// flags of the daemon subcommand.
+type daemonFlags struct {
+	socket   string
+	interval time.Duration
+	names    bool
+	index    string
+	metrics  string
+	schedule string
+	keep     int
+	hooks    webhookFlags
+	priority priorityFlags
+}
+
+func (opts *daemonFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	fs.StringVar(&opts.socket, "socket", defaultSocket(), "listen for commands on the UNIX socket `FILE`")
+	fs.DurationVar(&opts.interval, "interval", time.Hour, "time between scheduled scans, 0 to only scan on request")
+	fs.BoolVar(&opts.names, "names", false, "match files by name and size instead of content (faster)")
+	fs.StringVar(&opts.index, "index", "", "keep hashes across scans in the index `FILE`")
+	fs.StringVar(&opts.metrics, "metrics", "", "serve Prometheus metrics on `ADDR`, e.g. localhost:9100")
+	fs.StringVar(&opts.schedule, "schedule", "", "also scan directories at the times set in `FILE`, one per line, e.g 'scan /srv/photos daily at 02:00'")
+	fs.IntVar(&opts.keep, "keep-reports", 10, "keep the reports of the last `N` scans for the reports command")
+	opts.hooks.register(fs)
+	opts.priority.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s daemon [flags] <dirname>...\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s daemon --schedule FILE [flags] [dirname]...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Socket in the user's runtime directory, falling back to the temp dir.
+func defaultSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "walkman.sock")
+}
+
+// walkman daemon [flags] DIR...
+//
+// Scans the directories on a schedule and answers commands on a UNIX
+// socket, so that frontends can be built on top of walkman. Requests
+// and replies are JSON objects, one per line:
+//
+//	{"cmd": "scan"}        start a scan of every directory, after the running one if any
+//	{"cmd": "duplicates"}  duplicate groups found by the last scans
+//	{"cmd": "stats"}       statistics of the last scan
+//	{"cmd": "reports"}     reports of the last scans, see --keep-reports
+//
+// Every reply has "ok" set, and "error" when it is false. Try it with
+//
+//	echo '{"cmd": "stats"}' | nc -U "$XDG_RUNTIME_DIR/walkman.sock"
+//
+// Directories of the --schedule file are scanned on their own at the
+// times set there instead, e.g nightly, replacing their files in the
+// results while keeping those of the other directories.
+func runDaemon(args []string) {
+	var opts daemonFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() == 0 && opts.schedule == "" {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	opts.priority.apply()
+
+	roots, err := absPaths(fs.Args())
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	options, ix := serviceOptions(opts.names, opts.index)
+	if ix != nil {
+		defer closeIndex(ix)
+	}
+	d := newService(roots, options)
+	d.keep = opts.keep
+	d.hooks = opts.hooks.webhooks()
+
+	if opts.schedule != "" {
+		if d.rules, err = loadSchedule(opts.schedule); err != nil {
+			fatal(err)
+		}
+
+		// the scan command walks them all at once
+		if err := checkOverlap(d.allRoots()); err != nil {
+			fatal(err)
+		}
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	// A socket left behind by a daemon that crashed would make Listen fail.
+	if conn, err := net.Dial("unix", opts.socket); err == nil {
+		conn.Close()
+		fatalf("a daemon is already listening on %s\n", opts.socket)
+	}
+	os.Remove(opts.socket)
+
+	l, err := net.Listen("unix", opts.socket)
+	if err != nil {
+		fatal(err)
+	}
+	defer l.Close()
+
+	if err := os.Chmod(opts.socket, 0600); err != nil {
+		fatal(err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	if opts.metrics != "" {
+		go serveMetrics(d, opts.metrics)
+	}
+
+	scheduled := d.start(ctx, opts.interval)
+
+	log.Printf("listening on %s\n", opts.socket)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Println(err)
+				stop()
+			}
+
+			// let a running scan finish with the index
+			<-scheduled
+			return
+		}
+		go d.serve(conn)
+	}
+}
+
+type daemonRequest struct {
+	Cmd string `json:"cmd"`
+}
+
+type daemonReply struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	serviceStatus
+	Groups  []daemonGroup `json:"groups,omitempty"`
+	Reports []scanReport  `json:"reports,omitempty"`
+}
+
+type daemonGroup struct {
+	Hash  string   `json:"hash"`
+	Files []string `json:"files"`
+}
+
+// Answers requests on conn until the client hangs up.
+func (d *service) serve(conn net.Conn) {
+	defer conn.Close()
+
+	enc := json.NewEncoder(conn)
+	scanner := bufio.NewScanner(conn)
+
+	for scanner.Scan() {
+		var req daemonRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			enc.Encode(daemonReply{Error: err.Error()})
+			continue
+		}
+
+		if err := enc.Encode(d.handle(req)); err != nil {
+			return
+		}
+	}
+}
+
+func (d *service) handle(req daemonRequest) daemonReply {
+	switch req.Cmd {
+	case "scan":
+		d.requestScan()
+
+		reply := daemonReply{OK: true, serviceStatus: d.status()}
+		reply.Scanning = true
+		return reply
+	case "stats":
+		return daemonReply{OK: true, serviceStatus: d.status()}
+	case "reports":
+		return daemonReply{OK: true, Reports: d.lastReports()}
+	case "duplicates":
+		hashes := d.results()
+
+		reply := daemonReply{OK: true, Groups: []daemonGroup{}}
+		for _, hash := range duplicateKeys(hashes) {
+			reply.Groups = append(reply.Groups, daemonGroup{Hash: hash, Files: paths(hashes[hash])})
+		}
+		return reply
+	}
+	return daemonReply{Error: fmt.Sprintf("unknown command %q", req.Cmd)}
+}
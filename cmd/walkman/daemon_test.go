@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/fs"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// A client of the daemon protocol over one end of a pipe.
+type daemonClient struct {
+	t       *testing.T
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+// Sends the request line and returns the reply.
+func (c *daemonClient) send(line string) daemonReply {
+	c.t.Helper()
+
+	if _, err := c.conn.Write([]byte(line + "\n")); err != nil {
+		c.t.Fatal(err)
+	}
+
+	if !c.scanner.Scan() {
+		c.t.Fatalf("no reply to %s: %v", line, c.scanner.Err())
+	}
+
+	var reply daemonReply
+	if err := json.Unmarshal(c.scanner.Bytes(), &reply); err != nil {
+		c.t.Fatal(err)
+	}
+	return reply
+}
+
+func TestDaemonProtocol(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a": "same", "b": "same", "c": "other"})
+
+	// the first scan waits for release once it reached a file,
+	// and files take a while so that the stats are polled mid-scan
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	hook := walkman.WithPreFileHook(func(string, fs.FileInfo) error {
+		once.Do(func() { close(started) })
+		<-release
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+
+	d := newService([]string{dir}, []walkman.Option{walkman.WithContentHash(), hook})
+	d.keep = 5
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scheduled := d.start(ctx, 0)
+
+	server, conn := net.Pipe()
+	go d.serve(server)
+	defer conn.Close()
+
+	c := &daemonClient{t: t, conn: conn, scanner: bufio.NewScanner(conn)}
+
+	// another client asks for stats all along, as a frontend would
+	polled := make(chan struct{})
+	pollServer, pollConn := net.Pipe()
+	go d.serve(pollServer)
+	go func() {
+		defer close(polled)
+		scanner := bufio.NewScanner(pollConn)
+		for {
+			if _, err := pollConn.Write([]byte(`{"cmd": "stats"}` + "\n")); err != nil || !scanner.Scan() {
+				return
+			}
+		}
+	}()
+	defer func() {
+		pollConn.Close()
+		<-polled
+	}()
+
+	<-started
+
+	if reply := c.send(`{"cmd": "stats"}`); !reply.OK || !reply.Scanning || reply.Stats == nil || reply.LastScan != nil {
+		t.Errorf("expected the running scan and its stats, got %+v", reply)
+	}
+
+	if reply := c.send(`{"cmd": "duplicates"}`); !reply.OK || len(reply.Groups) != 0 {
+		t.Errorf("expected no duplicates before the first scan completes, got %+v", reply)
+	}
+
+	if reply := c.send(`{"cmd": "resize"}`); reply.OK || reply.Error == "" {
+		t.Errorf("expected an unknown command to be refused, got %+v", reply)
+	}
+
+	if reply := c.send(`{"cmd": `); reply.OK || reply.Error == "" {
+		t.Errorf("expected malformed JSON to be refused, got %+v", reply)
+	}
+
+	close(release)
+	waitIdle(c, 1)
+
+	reply := c.send(`{"cmd": "duplicates"}`)
+	if !reply.OK || len(reply.Groups) != 1 || len(reply.Groups[0].Files) != 2 {
+		t.Errorf("expected a and b as duplicates, got %+v", reply)
+	}
+
+	// stats are read while the scan asked for runs
+	if reply := c.send(`{"cmd": "scan"}`); !reply.OK || !reply.Scanning {
+		t.Errorf("expected a scan to be started, got %+v", reply)
+	}
+	waitIdle(c, 2)
+
+	if reply := c.send(`{"cmd": "reports"}`); !reply.OK || len(reply.Reports) != 2 || reply.Reports[1].Groups != 1 {
+		t.Errorf("expected the reports of both scans, got %+v", reply)
+	}
+
+	cancel()
+	<-scheduled
+}
+
+// Polls stats until scans reports were kept and none is running.
+func waitIdle(c *daemonClient, scans int) {
+	c.t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		reply := c.send(`{"cmd": "stats"}`)
+		if !reply.Scanning && len(c.send(`{"cmd": "reports"}`).Reports) == scans {
+			return
+		}
+	}
+	c.t.Fatalf("no scan completed after %d", scans-1)
+}
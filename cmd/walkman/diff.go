@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the diff subcommand.
+type diffFlags struct {
+	where string
+}
+
+func (opts *diffFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.StringVar(&opts.where, "where", "", "only report files matching `EXPR`")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s diff [flags] <old.snap> <new.snap>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman diff [flags] OLD NEW
+//
+// Lists files added (+), removed (-) and modified (~) between two snapshots
+// written with --save. The exit status is 0 when nothing changed and 1
+// otherwise, so it can run from cron as a change auditor.
+func runDiff(args []string) {
+	var opts diffFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	filter := parseWhere(opts.where)
+
+	snaps := make([]walkman.Results, 2)
+	for i, path := range fs.Args() {
+		hashes, err := walkman.LoadResults(path)
+		if err != nil {
+			fatal(err)
+		}
+		snaps[i] = hashes.Filter(filter)
+	}
+
+	diff, err := walkman.DiffSnapshots(snaps[0], snaps[1])
+	if err != nil {
+		fatal(err)
+	}
+
+	for _, f := range diff.Added {
+		fmt.Println("+", f.Path)
+	}
+
+	for _, f := range diff.Removed {
+		fmt.Println("-", f.Path)
+	}
+
+	for _, m := range diff.Modified {
+		fmt.Println("~", m.New.Path)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d added, %d removed, %d modified\n",
+		len(diff.Added), len(diff.Removed), len(diff.Modified))
+
+	if !diff.Empty() {
+		os.Exit(exitDifferent)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// prints groups of duplicate directories, see dupes --dirs.
+func printDirs(groups []walkman.DirGroup) {
+	for _, g := range groups {
+		fmt.Printf("%s--->%d directories of %d files, %d bytes each\n", g.Hash, len(g.Dirs), g.Files, g.Size)
+
+		for _, dir := range g.Dirs {
+			fmt.Println("    " + dir)
+		}
+
+		fmt.Println()
+	}
+}
+
+// Like printDirs but writes bare, NUL terminated paths
+// with an empty record after each group.
+func printDirs0(groups []walkman.DirGroup) {
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	for _, g := range groups {
+		for _, dir := range g.Dirs {
+			writeRecord(w, dir, 0)
+		}
+		writeRecord(w, "", 0)
+	}
+}
+
+// Drops groups whose directories all live below the same root.
+func dirsAcrossRoots(groups []walkman.DirGroup, roots []string) []walkman.DirGroup {
+	var across []walkman.DirGroup
+
+	for _, g := range groups {
+		seen := map[string]bool{}
+		for _, dir := range g.Dirs {
+			seen[rootOf(dir, roots)] = true
+		}
+
+		if len(seen) > 1 {
+			across = append(across, g)
+		}
+	}
+	return across
+}
+
+// prints pairs of overlapping directories, see dupes --overlap.
+func printOverlaps(overlaps []walkman.DirOverlap) {
+	for _, o := range overlaps {
+		fmt.Printf("%.0f%% shared--->%d bytes\n", 100*o.Ratio(), o.Shared)
+		fmt.Printf("    %s (%d bytes)\n", o.A, o.SizeA)
+		fmt.Printf("    %s (%d bytes)\n", o.B, o.SizeB)
+		fmt.Println()
+	}
+}
+
+// Like printOverlaps but writes bare, NUL terminated paths
+// with an empty record after each pair.
+func printOverlaps0(overlaps []walkman.DirOverlap) {
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	for _, o := range overlaps {
+		writeRecord(w, o.A, 0)
+		writeRecord(w, o.B, 0)
+		writeRecord(w, "", 0)
+	}
+}
+
+// Drops pairs of directories below the same root.
+func overlapsAcrossRoots(overlaps []walkman.DirOverlap, roots []string) []walkman.DirOverlap {
+	var across []walkman.DirOverlap
+
+	for _, o := range overlaps {
+		if rootOf(o.A, roots) != rootOf(o.B, roots) {
+			across = append(across, o)
+		}
+	}
+	return across
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the du subcommand.
+type duFlags struct {
+	depth  int
+	human  bool
+	hidden bool
+	load   string
+}
+
+func (opts *duFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("du", flag.ExitOnError)
+	fs.IntVar(&opts.depth, "depth", -1, "only print directories at most `N` levels below the given ones")
+	fs.BoolVar(&opts.human, "human", false, "print sizes with binary units, e.g 1.5 GiB")
+	fs.BoolVar(&opts.hidden, "hidden", false, "also walk hidden directories")
+	fs.StringVar(&opts.load, "load", "", "read a snapshot from `FILE` instead of walking, e.g one saved by dupes --save")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s du [flags] <dirname>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman du [flags] DIR...
+//
+// Prints the bytes of the files below every directory, sorted by path,
+// like du(1) with --apparent-size. Nothing is read, or not even walked
+// with --load, so that the snapshot of a dupes scan also tells where
+// space goes.
+func runDu(args []string) {
+	var opts duFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() == 0 && opts.load == "" {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	roots, err := absPaths(fs.Args())
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	options, unmount, err := mountRemotes(roots)
+	if err != nil {
+		fatal(err)
+	}
+	defer unmount()
+
+	options = append(options, walkman.WithEmpty())
+	if opts.hidden {
+		options = append(options, walkman.WithIncludeHidden())
+	}
+
+	wm := walkman.New(options...)
+	hashes, interrupted := scan(ctx, wm, roots, opts.load, "")
+
+	sizes := hashes.DirSizes(roots...)
+	dirs := make([]string, 0, len(sizes))
+	for dir := range sizes {
+		if opts.depth < 0 || depthBelow(dir, roots) <= opts.depth {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+
+	w := bufio.NewWriter(os.Stdout)
+	for _, dir := range dirs {
+		size := strconv.FormatInt(sizes[dir], 10)
+		if opts.human {
+			size = formatBytes(sizes[dir])
+		}
+		fmt.Fprintf(w, "%s\t%s\n", size, dir)
+	}
+	w.Flush()
+
+	if reportErrors(wm) || interrupted {
+		os.Exit(exitError)
+	}
+}
+
+// Returns how many levels dir lies below the root it was found in,
+// 0 for roots and when there is none.
+func depthBelow(dir string, roots []string) int {
+	root := rootOf(dir, roots)
+	if root == "" {
+		return 0
+	}
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
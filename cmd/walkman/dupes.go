@@ -0,0 +1,615 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/abiiranathan/walkman"
+	"github.com/abiiranathan/walkman/ffmpeg"
+)
+
+// Frames sampled per video by --videos, and bits by which
+// their perceptual hashes may differ in copies of a video.
+const (
+	videoFrames   = 10
+	videoDistance = 10
+)
+
+// flags of the dupes subcommand.
+type dupesFlags struct {
+	delete   bool
+	hardlink bool
+	symlink  bool
+	moveTo   string
+	keep     string
+	dryRun   bool
+	yes      bool
+	journal  string
+	keepRoot string
+	prefer   stringList
+	ref      string
+	across   bool
+	print0   bool
+	where    string
+	stats    bool
+	save     string
+	load     string
+	since    string
+	index    string
+	archives bool
+	streams  bool
+	hidden   bool
+	marker   string
+	backup   bool
+	xattrs   bool
+	mime     bool
+	exif     bool
+	tags     bool
+	tracks   bool
+	videos   bool
+	names    bool
+	match    string
+	fold     bool
+	dirs     bool
+	overlap  int
+	similar  int
+	ignore   string
+	resume   string
+	lowMem   bool
+	maxFiles int64
+	maxBytes string
+	notify   notifyFlags
+	priority priorityFlags
+}
+
+func (opts *dupesFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("dupes", flag.ExitOnError)
+	fs.BoolVar(&opts.names, "names", false, "match files by name and size instead of content (faster), same as --match name+size")
+	fs.StringVar(&opts.match, "match", "content", "match files by `WHAT`: content, name+size, or name or size alone to triage without reading anything (report only); or by any hash algorithm, e.g md5")
+	fs.BoolVar(&opts.fold, "ignore-case", false, "match names regardless of case with --names (default on case-insensitive file systems)")
+	fs.BoolVar(&opts.delete, "delete", false, "delete duplicates")
+	fs.BoolVar(&opts.hardlink, "hardlink", false, "replace duplicates with hard links to the kept copy")
+	fs.BoolVar(&opts.symlink, "symlink", false, "replace duplicates with symbolic links to the kept copy")
+	fs.StringVar(&opts.moveTo, "move-to", "", "move duplicates into `DIR`")
+	fs.StringVar(&opts.keep, "keep", "oldest", "copy to keep: oldest, newest, shortest or resolution (with --exif)")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "only print what would be done (default unless --yes)")
+	fs.BoolVar(&opts.yes, "yes", false, "confirm destructive actions")
+	fs.StringVar(&opts.journal, "journal", "", "append executed actions to `FILE`")
+	fs.StringVar(&opts.keepRoot, "keep-root", "", "only keep copies found below `DIR`, one of the given directories")
+	fs.Var(&opts.prefer, "prefer", "keep copies found below `DIR` over others, the oldest among them; repeat to rank directories, earlier first")
+	fs.StringVar(&opts.ref, "reference", "", "only report copies of files found below the reference `DIR`, e.g a photo library, which is scanned too and never acted on")
+	fs.BoolVar(&opts.dirs, "dirs", false, "report directories holding the same files instead of files; report only")
+	fs.IntVar(&opts.overlap, "overlap", 0, "report pairs of directories sharing at least `PERCENT` of their bytes instead of files; report only")
+	fs.BoolVar(&opts.across, "across", false, "only report duplicates spanning more than one directory")
+	fs.StringVar(&opts.where, "where", "", "only consider files matching `EXPR`, e.g. 'size > 10MB && ext in (pdf, docx)'")
+	fs.BoolVar(&opts.stats, "stats", false, "print a summary of the scan to stderr")
+	fs.StringVar(&opts.save, "save", "", "save the scan as a snapshot to `FILE`")
+	fs.StringVar(&opts.since, "incremental", "", "only hash files changed since the snapshot `FILE`")
+	fs.BoolVar(&opts.archives, "archives", false, "look for duplicates inside zip and tar archives too; members are never removed")
+	fs.BoolVar(&opts.streams, "streams", false, "look for duplicates among alternate data streams of files too; streams are never removed; NTFS only")
+	fs.BoolVar(&opts.hidden, "hidden", false, "also walk hidden directories")
+	fs.StringVar(&opts.marker, "skip-marker", "", "skip directories holding a file named `NAME`, e.g .nodedup")
+	fs.BoolVar(&opts.backup, "backup-semantics", false, "read files other processes locked with backup semantics when they allow it; Windows only")
+	fs.Int64Var(&opts.maxFiles, "max-files", 0, "stop after `N` files, reporting the duplicates found so far")
+	fs.StringVar(&opts.maxBytes, "max-bytes", "", "stop after hashing `SIZE` bytes, e.g 50GB, reporting the duplicates found so far")
+	opts.priority.register(fs)
+	opts.notify.register(fs)
+	fs.StringVar(&opts.resume, "resume", "", "save the scan to `FILE` every minute and pick it up from there if interrupted, e.g by a reboot")
+	fs.StringVar(&opts.ignore, "ignore-hashes", "", "leave out files whose md5 is listed in `FILE`, e.g the NSRL")
+	fs.BoolVar(&opts.xattrs, "xattrs", false, "only match files whose extended attributes (ACLs, SELinux labels) match too")
+	fs.BoolVar(&opts.mime, "mime", false, "detect the content type of files, for mime in --where")
+	fs.BoolVar(&opts.exif, "exif", false, "read the EXIF tags of photos, for taken, camera, width and height in --where")
+	fs.BoolVar(&opts.tags, "tags", false, "read the tags of MP3, FLAC and Ogg files, for artist, title, album and duration in --where")
+	fs.BoolVar(&opts.tracks, "tracks", false, "group audio files by artist, title and duration instead of content, implies --tags; report only")
+	fs.BoolVar(&opts.videos, "videos", false, "group re-encoded or trimmed copies of videos by frames sampled with ffmpeg; report only")
+	fs.IntVar(&opts.similar, "similar-names", -1, "group files whose names look like copies, e.g 'a (2).docx', allowing `N` more edits, whatever their content; report only")
+	fs.BoolVar(&opts.lowMem, "low-memory", false, "only keep duplicates in memory while scanning, for trees of millions of mostly distinct files")
+	fs.StringVar(&opts.index, "index", "", "keep hashes across runs in the index `FILE`, only hashing changed files")
+	fs.StringVar(&opts.load, "load", "", "read a snapshot from `FILE` instead of walking; directories are then only used by --across")
+	fs.BoolVar(&opts.print0, "print0", false, "print bare paths terminated by NUL; groups are separated by an empty record")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s dupes [flags] <dirname>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// walkman dupes [flags] <dir>...
+//
+// Reports groups of duplicate files across all directories. When one of
+// the destructive flags is given, the resulting plan is only printed
+// unless --yes is passed as well.
+func runDupes(args []string) {
+	var opts dupesFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() == 0 && opts.load == "" {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	opts.priority.apply()
+
+	action, destructive, err := opts.action()
+	if err != nil {
+		fatal(err)
+	}
+
+	if opts.names {
+		opts.match = "name+size"
+	}
+
+	switch opts.match {
+	case "content":
+	case "name+size":
+		opts.names = true
+	case "name", "size":
+		// files of the same name or size are only candidates
+		if destructive {
+			fatalf("--match %s can not be combined with --delete, --hardlink, --symlink or --move-to\n", opts.match)
+		}
+	case walkman.AlgorithmMD5:
+		opts.match = "content"
+	default:
+		// any other hasher by its algorithm, e.g sizeonly
+		if _, err := walkman.HasherByName(opts.match); err != nil {
+			fatalf("--match must be content, name+size, name, size or one of %s\n", strings.Join(walkman.HasherNames(), ", "))
+		}
+
+		if destructive {
+			fatalf("--match %s can not be combined with --delete, --hardlink, --symlink or --move-to\n", opts.match)
+		}
+	}
+
+	// copies of a track, video or name differ in content, there is nothing safe to do about them
+	if (opts.tracks || opts.videos || opts.similar >= 0) && destructive {
+		fatalf("--tracks, --videos and --similar-names can not be combined with --delete, --hardlink, --symlink or --move-to\n")
+	}
+
+	// directories are compared by every file below them
+	if (opts.dirs || opts.overlap > 0) && (destructive || opts.tracks || opts.videos || opts.where != "") {
+		fatalf("--dirs and --overlap can not be combined with --where, --tracks, --videos or the destructive flags\n")
+	}
+
+	if opts.ref != "" && (opts.keepRoot != "" || opts.dirs || opts.overlap > 0) {
+		fatalf("--reference can not be combined with --keep-root, --dirs or --overlap\n")
+	}
+
+	// the other modes need every file, or do not group by content
+	if opts.lowMem && (opts.dirs || opts.overlap > 0 || opts.match != "content" || opts.tracks || opts.videos || opts.similar >= 0 || opts.save != "") {
+		fatalf("--low-memory can not be combined with --dirs, --overlap, --names, --match, --tracks, --videos, --similar-names or --save\n")
+	}
+
+	if opts.overlap < 0 || opts.overlap > 100 {
+		fatalf("--overlap must be a percentage between 0 and 100\n")
+	}
+
+	// ties between preferred copies go to the oldest
+	if len(opts.prefer) > 0 && opts.keep != "oldest" {
+		fatalf("--prefer can not be combined with --keep\n")
+	}
+
+	keep, err := keepPolicy(opts.keep)
+	if err != nil {
+		fatal(err)
+	}
+
+	if len(opts.prefer) > 0 {
+		dirs, err := absPaths(opts.prefer)
+		if err != nil {
+			fatal(err)
+		}
+		keep = walkman.KeepByDirPriority(dirs)
+	}
+
+	filter := parseWhere(opts.where)
+
+	roots, err := absPaths(fs.Args())
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	if opts.keepRoot != "" {
+		root, err := filepath.Abs(opts.keepRoot)
+		if err != nil {
+			fatal(err)
+		}
+		keep = walkman.KeepUnder(root, keep)
+	}
+
+	var ref string
+	if opts.ref != "" {
+		if ref, err = filepath.Abs(opts.ref); err != nil {
+			fatal(err)
+		}
+
+		// only copies outside the reference are ever acted on
+		keep = walkman.KeepUnder(ref, keep)
+		if rootOf(ref, roots) == "" {
+			roots = append(roots, ref)
+		}
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	options, unmount, err := mountRemotes(roots)
+	if err != nil {
+		fatal(err)
+	}
+
+	options = append(options, walkman.WithDeterministicOrder())
+
+	if opts.since != "" {
+		prev, err := walkman.LoadResults(opts.since)
+		if err != nil {
+			fatal(err)
+		}
+		options = append(options, walkman.WithPrevious(prev))
+	}
+
+	if opts.archives {
+		options = append(options, walkman.WithArchives())
+	}
+
+	if opts.streams {
+		options = append(options, walkman.WithAlternateStreams())
+	}
+
+	if opts.hidden {
+		options = append(options, walkman.WithIncludeHidden())
+	}
+
+	if opts.marker != "" {
+		options = append(options, walkman.WithSkipMarker(opts.marker))
+	}
+
+	if opts.backup {
+		options = append(options, walkman.WithBackupSemantics())
+	}
+
+	if opts.resume != "" {
+		options = append(options, walkman.WithCheckpoint(opts.resume, 0))
+	}
+
+	options = append(options, budgetOptions(opts.maxFiles, opts.maxBytes)...)
+
+	if opts.ignore != "" {
+		options = append(options, knownHashes(opts.ignore, walkman.KnownExclude))
+	}
+
+	if opts.fold {
+		options = append(options, walkman.WithCaseInsensitiveNames())
+	}
+
+	if opts.xattrs {
+		options = append(options, walkman.WithXattrHash(), walkman.WithMetadata(walkman.ExtractXattrs))
+	}
+
+	if opts.mime {
+		options = append(options, walkman.WithMIME())
+	}
+
+	if opts.exif {
+		options = append(options, walkman.WithMetadata(walkman.ExtractEXIF))
+	}
+
+	if opts.tags || opts.tracks {
+		options = append(options, walkman.WithMetadata(walkman.ExtractTags))
+	}
+
+	// only files of the same size are hashed by content,
+	// videos are compared whatever their size
+	switch {
+	case opts.videos:
+		options = append(options, walkman.WithHasher(walkman.VideoHasher(ffmpeg.Decoder{}, videoFrames)))
+	case opts.similar >= 0:
+		// only names matter, nothing is read
+	case opts.match == "name":
+		options = append(options, walkman.WithNameOnly())
+	case opts.match == "size":
+		options = append(options, walkman.WithSizeOnly())
+	case opts.match != "content" && !opts.names:
+		hasher, _ := walkman.HasherByName(opts.match)
+		options = append(options, walkman.WithNamedHasher(opts.match, hasher))
+	case !opts.names:
+		options = append(options, walkman.WithContentHash(), walkman.WithSizeGrouping())
+	}
+
+	if opts.lowMem {
+		options = append(options, walkman.WithDuplicatesOnly())
+	}
+
+	var ix *walkman.Index
+	if opts.index != "" {
+		if ix, err = walkman.OpenIndex(opts.index); err != nil {
+			fatal(err)
+		}
+		options = append(options, walkman.WithIndex(ix))
+	}
+
+	wm := walkman.New(options...)
+
+	hashes, interrupted := scan(ctx, wm, roots, opts.load, opts.save)
+	stop()
+	unmount()
+
+	if ix != nil {
+		closeIndex(ix)
+	}
+
+	var dirs []walkman.DirGroup
+	if opts.dirs {
+		dirs = hashes.DuplicateDirs(roots...)
+	}
+
+	var overlaps []walkman.DirOverlap
+	if opts.overlap > 0 {
+		overlaps = hashes.OverlappingDirs(float64(opts.overlap)/100, roots...)
+	}
+
+	hashes = hashes.Filter(filter)
+
+	if opts.tracks {
+		hashes = hashes.GroupBy(walkman.ByTrack)
+	}
+
+	if opts.videos {
+		hashes = hashes.SimilarVideos(videoDistance)
+	}
+
+	if opts.similar >= 0 {
+		hashes = hashes.SimilarNames(opts.similar)
+	}
+
+	if ref != "" {
+		hashes = hashes.ReferenceCopies(ref)
+	}
+
+	if opts.across {
+		hashes = acrossRoots(hashes, roots)
+	}
+
+	// exit status reflects what the scan found, whatever is done about it
+	defer exitStatus(wm, hashes, interrupted)
+
+	if opts.stats {
+		defer printStats(os.Stderr, wm, hashes)
+	}
+
+	defer opts.notify.send(wm, roots, hashes, interrupted)
+
+	if opts.dirs {
+		if opts.across {
+			dirs = dirsAcrossRoots(dirs, roots)
+		}
+
+		if opts.print0 {
+			printDirs0(dirs)
+		} else {
+			printDirs(dirs)
+		}
+	}
+
+	if opts.overlap > 0 {
+		if opts.across {
+			overlaps = overlapsAcrossRoots(overlaps, roots)
+		}
+
+		if opts.print0 {
+			printOverlaps0(overlaps)
+		} else {
+			printOverlaps(overlaps)
+		}
+	}
+
+	if opts.dirs || opts.overlap > 0 {
+		return
+	}
+
+	if !destructive {
+		if opts.print0 {
+			printDuplicates0(hashes)
+		} else {
+			printDuplicates(hashes)
+		}
+		return
+	}
+
+	moveTo := opts.moveTo
+	if moveTo != "" {
+		if moveTo, err = filepath.Abs(moveTo); err != nil {
+			fatal(err)
+		}
+	}
+
+	plan := walkman.NewPlan(hashes, keep, action, moveTo)
+
+	// Never act on an interrupted scan, the user asked us to stop.
+	if opts.dryRun || !opts.yes || interrupted {
+		// Only the paths that would be acted upon, ready for xargs -0
+		if opts.print0 {
+			w := bufio.NewWriter(os.Stdout)
+			for _, step := range plan {
+				writeRecord(w, step.Target.Path, 0)
+			}
+			w.Flush()
+			return
+		}
+
+		for _, step := range plan {
+			fmt.Println("would", step)
+		}
+
+		fmt.Printf("\n%d files, %d bytes reclaimable. Pass --yes to apply.\n",
+			len(plan), plan.Reclaimable())
+		return
+	}
+
+	journal := os.Stdout
+	if opts.journal != "" {
+		journal, err = os.OpenFile(opts.journal, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fatal(err)
+		}
+		defer journal.Close()
+	}
+
+	fmt.Fprintf(os.Stderr, "%d files, %s expected to be freed\n", len(plan), formatBytes(plan.Reclaimable()))
+
+	done, savings, err := plan.ExecuteMeasured(journal)
+	fmt.Fprintf(os.Stderr, "%d of %d steps applied\n", done, len(plan))
+	if savings.Measured {
+		fmt.Fprintf(os.Stderr, "%s freed of %s expected\n", formatBytes(savings.Actual), formatBytes(savings.Expected))
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// Exits with exitError if the walk met errors or was interrupted,
+// otherwise exitDuplicates or exitOK depending on hashes.
+func exitStatus(wm *walkman.Walkman, hashes walkman.Results, interrupted bool) {
+	if reportErrors(wm) || interrupted {
+		os.Exit(exitError)
+	}
+
+	if len(duplicateKeys(hashes)) > 0 {
+		os.Exit(exitDuplicates)
+	}
+	os.Exit(exitOK)
+}
+
+// Returns the action selected by the flags and whether any was selected.
+func (o dupesFlags) action() (walkman.Action, bool, error) {
+	var action walkman.Action
+	n := 0
+
+	if o.delete {
+		action, n = walkman.ActionDelete, n+1
+	}
+
+	if o.hardlink {
+		action, n = walkman.ActionHardlink, n+1
+	}
+
+	if o.symlink {
+		action, n = walkman.ActionSymlink, n+1
+	}
+
+	if o.moveTo != "" {
+		action, n = walkman.ActionMove, n+1
+	}
+
+	if n > 1 {
+		return action, false, fmt.Errorf("--delete, --hardlink, --symlink and --move-to are mutually exclusive")
+	}
+
+	return action, n == 1, nil
+}
+
+func keepPolicy(name string) (walkman.KeepPolicy, error) {
+	switch name {
+	case "oldest":
+		return walkman.KeepOldest(), nil
+	case "newest":
+		return walkman.KeepNewest(), nil
+	case "shortest":
+		return walkman.KeepShortestPath(), nil
+	case "resolution":
+		return walkman.KeepHighestResolution(), nil
+	}
+	return nil, fmt.Errorf("unknown keep policy %q", name)
+}
+
+func absPaths(paths []string) ([]string, error) {
+	abs := make([]string, len(paths))
+	for i, p := range paths {
+		// remote roots are left as is, see mountRemotes
+		if strings.Contains(p, "://") {
+			abs[i] = p
+			continue
+		}
+
+		var err error
+		if abs[i], err = filepath.Abs(p); err != nil {
+			return nil, err
+		}
+	}
+	return abs, nil
+}
+
+// Drops groups whose files all live below the same root.
+func acrossRoots(hashes walkman.Results, roots []string) walkman.Results {
+	across := make(walkman.Results)
+
+	for hash, files := range hashes {
+		seen := map[string]bool{}
+		for _, f := range files {
+			seen[rootOf(f.Path, roots)] = true
+		}
+
+		if len(seen) > 1 {
+			across[hash] = files
+		}
+	}
+	return across
+}
+
+// Returns the root that path was found in.
+func rootOf(path string, roots []string) string {
+	for _, root := range roots {
+		if walkman.IsUnder(path, root) {
+			return root
+		}
+	}
+	return ""
+}
+
+// Hashes of groups with more than one file, sorted.
+func duplicateKeys(hashes walkman.Results) []string {
+	keys := make([]string, 0, len(hashes))
+	for hash, files := range hashes {
+		if len(files) > 1 {
+			keys = append(keys, hash)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// prints groups with more than one file, sorted by hash.
+func printDuplicates(hashes walkman.Results) {
+	for _, hash := range duplicateKeys(hashes) {
+		fmt.Printf("%s--->%d files\n", hash, len(hashes[hash]))
+
+		for _, f := range hashes[hash] {
+			fmt.Println("    " + f.Path)
+		}
+
+		fmt.Println()
+	}
+}
+
+// Like printDuplicates but writes bare, NUL terminated paths
+// with an empty record after each group.
+func printDuplicates0(hashes walkman.Results) {
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	for _, hash := range duplicateKeys(hashes) {
+		for _, f := range hashes[hash] {
+			writeRecord(w, f.Path, 0)
+		}
+		writeRecord(w, "", 0)
+	}
+}
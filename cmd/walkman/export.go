@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the export subcommand.
+type exportFlags struct {
+	where  string
+	hidden bool
+	load   string
+}
+
+func (opts *exportFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.StringVar(&opts.where, "where", "", "only export files matching `EXPR`, e.g. 'size > 10MB && ext in (pdf, docx)'")
+	fs.BoolVar(&opts.hidden, "hidden", false, "also walk hidden directories")
+	fs.StringVar(&opts.load, "load", "", "read a snapshot from `FILE` instead of walking")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s export [flags] <store> <dirname>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman export [flags] STORE DIR...
+//
+// Copies one instance of every distinct file below DIR into STORE, named
+// by its md5 like .git/objects, and appends the path of every file to
+// STORE/index. Files are only grouped by name since every one is hashed
+// while it is copied.
+func runExport(args []string) {
+	var opts exportFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() < 2 && !(fs.NArg() == 1 && opts.load != "") {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	filter := parseWhere(opts.where)
+	store := fs.Arg(0)
+
+	roots, err := absPaths(fs.Args()[1:])
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	options, unmount, err := mountRemotes(roots)
+	if err != nil {
+		fatal(err)
+	}
+	defer unmount()
+
+	options = append(options, walkman.WithEmpty())
+	if opts.hidden {
+		options = append(options, walkman.WithIncludeHidden())
+	}
+
+	wm := walkman.New(options...)
+	hashes, interrupted := scan(ctx, wm, roots, opts.load, "")
+	hashes = hashes.Filter(filter)
+
+	n, err := hashes.ExportCAS(store)
+	if err != nil {
+		fatal(err)
+	}
+	log.Printf("wrote %d objects to %s\n", n, store)
+
+	if reportErrors(wm) || interrupted {
+		os.Exit(exitError)
+	}
+}
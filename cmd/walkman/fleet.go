@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// What an agent reports for every file it hashed.
+type fleetRecord struct {
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+	Host string `json:"host"`
+	Path string `json:"path"`
+}
+
+// flags of the agent subcommand.
+type agentFlags struct {
+	coordinator string
+	host        string
+	names       bool
+	index       string
+}
+
+func (opts *agentFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	fs.StringVar(&opts.coordinator, "coordinator", "", "`URL` of the coordinator, e.g. http://backup0:9090")
+	fs.StringVar(&opts.host, "host", "", "name reported for this machine (default the hostname)")
+	fs.BoolVar(&opts.names, "names", false, "match files by name and size instead of content (faster)")
+	fs.StringVar(&opts.index, "index", "", "keep hashes across runs in the index `FILE`")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s agent --coordinator URL [flags] <dirname>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman agent --coordinator URL [flags] DIR...
+//
+// Walks the local directories and streams a (hash, size, host, path)
+// record for every file to the coordinator, replacing what this host
+// reported before. Run it from cron on every machine of the fleet.
+func runAgent(args []string) {
+	var opts agentFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || opts.coordinator == "" {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	host := opts.host
+	if host == "" {
+		var err error
+		if host, err = os.Hostname(); err != nil {
+			fatal(err)
+		}
+	}
+
+	roots, err := absPaths(fs.Args())
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	options, ix := serviceOptions(opts.names, opts.index)
+	if ix != nil {
+		defer closeIndex(ix)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	wm := walkman.New(options...)
+	hashes, err := wm.WalkContext(ctx, roots...)
+	if checkInterrupted(err) {
+		// a partial report would make the coordinator forget files
+		os.Exit(exitError)
+	}
+	failed := reportErrors(wm)
+
+	if err := report(ctx, opts.coordinator, host, hashes); err != nil {
+		fatal(err)
+	}
+
+	log.Printf("reported %d files to %s\n", countFiles(hashes), opts.coordinator)
+	if failed {
+		os.Exit(exitError)
+	}
+}
+
+// Streams hashes to the coordinator as newline delimited JSON.
+func report(ctx context.Context, coordinator, host string, hashes walkman.Results) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		enc := json.NewEncoder(pw)
+		for hash, files := range hashes {
+			for _, f := range files {
+				rec := fleetRecord{Hash: hash, Size: f.Stats.Size(), Host: host, Path: f.Path}
+				if err := enc.Encode(rec); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+		}
+		pw.Close()
+	}()
+
+	u := strings.TrimSuffix(coordinator, "/") + "/ingest?host=" + url.QueryEscape(host)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, pr)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("coordinator: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// flags of the coordinator subcommand.
+type coordinatorFlags struct {
+	addr      string
+	maxReport string
+}
+
+func (opts *coordinatorFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("coordinator", flag.ExitOnError)
+	fs.StringVar(&opts.addr, "addr", "localhost:9090", "listen for agents on `ADDR`")
+	fs.StringVar(&opts.maxReport, "max-report", "1GB", "refuse reports larger than `SIZE`, as they are read into memory")
+	return fs
+}
+
+// walkman coordinator [--addr ADDR]
+//
+// Collects the reports of agents and finds duplicates across hosts:
+//
+//	POST /ingest?host=NAME       newline delimited records from an agent
+//	GET  /hosts                  hosts with the number of files and time of their last report
+//	GET  /duplicates?across=1    duplicate groups, largest first; across only
+//	                             keeps groups spanning more than one host
+//
+// Reports are kept in memory, those over --max-report are refused. There
+// is no authentication, keep --addr on a trusted network.
+func runCoordinator(args []string) {
+	var opts coordinatorFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	maxReport, err := walkman.ParseSize(opts.maxReport)
+	if err != nil {
+		fatalf("invalid --max-report: %v\n", err)
+	}
+	c := newCoordinator(maxReport)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	server := &http.Server{Addr: opts.addr, Handler: c.routes()}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	log.Printf("listening on %s\n", opts.addr)
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		fatal(err)
+	}
+}
+
+type coordinator struct {
+	maxReport int64 // bytes of a report, see coordinator --max-report
+
+	mu        sync.Mutex
+	algorithm string // of the first report, every host must use the same
+	hosts     map[string]*fleetHost
+}
+
+func newCoordinator(maxReport int64) *coordinator {
+	return &coordinator{maxReport: maxReport, hosts: make(map[string]*fleetHost)}
+}
+
+func (c *coordinator) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ingest", c.handleIngest)
+	mux.HandleFunc("/hosts", c.handleHosts)
+	mux.HandleFunc("/duplicates", c.handleDuplicates)
+	return mux
+}
+
+type fleetHost struct {
+	records  []fleetRecord
+	reported time.Time
+}
+
+func (c *coordinator) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, http.StatusMethodNotAllowed, "use POST to report files")
+		return
+	}
+
+	host := r.URL.Query().Get("host")
+	if host == "" {
+		httpError(w, http.StatusBadRequest, "missing host")
+		return
+	}
+
+	records := []fleetRecord{}
+	algorithm := ""
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, c.maxReport))
+	for {
+		var rec fleetRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("report larger than %d bytes, see --max-report", c.maxReport))
+			return
+		}
+
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if rec.Host != host {
+			httpError(w, http.StatusBadRequest, fmt.Sprintf("record of host %q in a report of %q", rec.Host, host))
+			return
+		}
+
+		a := rec.Hash
+		if i := strings.IndexByte(a, ':'); i >= 0 {
+			a = a[:i]
+		}
+
+		if algorithm != "" && a != algorithm {
+			httpError(w, http.StatusBadRequest, "report mixes hash algorithms")
+			return
+		}
+		algorithm = a
+
+		records = append(records, rec)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a host may switch algorithms when it is the only one
+	if _, ok := c.hosts[host]; ok && len(c.hosts) == 1 {
+		c.algorithm = ""
+	}
+
+	if c.algorithm != "" && algorithm != "" && algorithm != c.algorithm {
+		httpError(w, http.StatusConflict,
+			fmt.Sprintf("report uses %q hashes, the fleet uses %q", algorithm, c.algorithm))
+		return
+	}
+
+	if c.algorithm == "" {
+		c.algorithm = algorithm
+	}
+
+	c.hosts[host] = &fleetHost{records: records, reported: time.Now()}
+	log.Printf("%s reported %d files\n", host, len(records))
+
+	writeJSON(w, http.StatusOK, map[string]int{"files": len(records)})
+}
+
+type hostReply struct {
+	Host     string    `json:"host"`
+	Files    int       `json:"files"`
+	Reported time.Time `json:"reported"`
+}
+
+func (c *coordinator) handleHosts(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hosts := []hostReply{}
+	for name, h := range c.hosts {
+		hosts = append(hosts, hostReply{Host: name, Files: len(h.records), Reported: h.reported})
+	}
+
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	writeJSON(w, http.StatusOK, hosts)
+}
+
+type fleetGroup struct {
+	Hash        string        `json:"hash"`
+	Size        int64         `json:"size"`
+	Reclaimable int64         `json:"reclaimable"`
+	Files       []fleetRecord `json:"files"`
+}
+
+func (c *coordinator) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+	across := r.URL.Query().Get("across") != ""
+
+	c.mu.Lock()
+	byHash := make(map[string][]fleetRecord)
+	for _, h := range c.hosts {
+		for _, rec := range h.records {
+			byHash[rec.Hash] = append(byHash[rec.Hash], rec)
+		}
+	}
+	c.mu.Unlock()
+
+	groups := []fleetGroup{}
+	for hash, records := range byHash {
+		if len(records) < 2 || (across && !spansHosts(records)) {
+			continue
+		}
+
+		sort.Slice(records, func(i, j int) bool {
+			if records[i].Host != records[j].Host {
+				return records[i].Host < records[j].Host
+			}
+			return records[i].Path < records[j].Path
+		})
+
+		size := records[0].Size
+		groups = append(groups, fleetGroup{
+			Hash:        hash,
+			Size:        size,
+			Reclaimable: size * int64(len(records)-1),
+			Files:       records,
+		})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Reclaimable != groups[j].Reclaimable {
+			return groups[i].Reclaimable > groups[j].Reclaimable
+		}
+		return groups[i].Hash < groups[j].Hash
+	})
+
+	writeJSON(w, http.StatusOK, groups)
+}
+
+func spansHosts(records []fleetRecord) bool {
+	for _, rec := range records[1:] {
+		if rec.Host != records[0].Host {
+			return true
+		}
+	}
+	return false
+}
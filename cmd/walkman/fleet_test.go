@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// Walks files written to a new directory and reports them as host.
+func reportTree(t *testing.T, url, host string, files map[string]string) string {
+	t.Helper()
+
+	dir := writeTree(t, files)
+	hashes, err := walkman.New(walkman.WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := report(context.Background(), url, host, hashes); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// POSTs body to /ingest as host and returns the status.
+func ingest(t *testing.T, url, host, body string) int {
+	t.Helper()
+
+	resp, err := http.Post(url+"/ingest?host="+host, "application/x-ndjson", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestCoordinator(t *testing.T) {
+	server := httptest.NewServer(newCoordinator(1 << 20).routes())
+	defer server.Close()
+
+	a := reportTree(t, server.URL, "a", map[string]string{"shared": "on both hosts", "mine": "only on a"})
+	b := reportTree(t, server.URL, "b", map[string]string{"copy": "on both hosts", "x1": "twice on b", "x2": "twice on b"})
+
+	var hosts []hostReply
+	if getJSON(t, server.URL+"/hosts", &hosts); len(hosts) != 2 || hosts[0].Host != "a" || hosts[0].Files != 2 || hosts[1].Files != 3 {
+		t.Errorf("expected both hosts and their files, got %+v", hosts)
+	}
+
+	var groups []fleetGroup
+	if getJSON(t, server.URL+"/duplicates", &groups); len(groups) != 2 {
+		t.Fatalf("expected 2 groups of duplicates, got %+v", groups)
+	}
+
+	groups = nil
+	getJSON(t, server.URL+"/duplicates?across=1", &groups)
+	if len(groups) != 1 || len(groups[0].Files) != 2 {
+		t.Fatalf("expected a single group across hosts, got %+v", groups)
+	}
+
+	if files := groups[0].Files; files[0].Path != filepath.Join(a, "shared") || files[1].Host != "b" || files[1].Path != filepath.Join(b, "copy") {
+		t.Errorf("expected shared on a and copy on b, got %+v", files)
+	}
+
+	// reporting again replaces what the host reported before
+	reportTree(t, server.URL, "b", map[string]string{"other": "something else"})
+
+	groups = nil
+	if getJSON(t, server.URL+"/duplicates", &groups); len(groups) != 0 {
+		t.Errorf("expected the duplicates of b gone, got %+v", groups)
+	}
+}
+
+func TestCoordinatorRejects(t *testing.T) {
+	server := httptest.NewServer(newCoordinator(1024).routes())
+	defer server.Close()
+
+	if status := ingest(t, server.URL, "a", `{"hash":"md5:01","size":1,"host":"a","path":"/x"}`+"\n"); status != http.StatusOK {
+		t.Fatalf("expected the report of a accepted, got %d", status)
+	}
+
+	tests := []struct {
+		name   string
+		host   string
+		body   string
+		status int
+	}{
+		{"mixed algorithms", "b", `{"hash":"md5:01","host":"b"}` + "\n" + `{"hash":"sha256:01","host":"b"}`, http.StatusBadRequest},
+		{"other algorithm than the fleet", "b", `{"hash":"sha256:01","host":"b"}`, http.StatusConflict},
+		{"record of another host", "b", `{"hash":"md5:01","host":"a"}`, http.StatusBadRequest},
+		{"malformed", "b", `{"hash":`, http.StatusBadRequest},
+		{"no host", "", ``, http.StatusBadRequest},
+		{"too large", "b", strings.Repeat(`{"hash":"md5:01","host":"b"}`+"\n", 100), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		if status := ingest(t, server.URL, tt.host, tt.body); status != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.name, status, tt.status)
+		}
+	}
+
+	var hosts []hostReply
+	if getJSON(t, server.URL+"/hosts", &hosts); len(hosts) != 1 {
+		t.Errorf("expected rejected reports to be left out, got %+v", hosts)
+	}
+
+	resp, err := http.Get(server.URL + "/ingest?host=a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET /ingest to be refused, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the history subcommand.
+type historyFlags struct {
+	root  string
+	human bool
+}
+
+func (opts *historyFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.StringVar(&opts.root, "root", "", "only show scans of `DIR`")
+	fs.BoolVar(&opts.human, "human", false, "print sizes with binary units, e.g 1.5 GiB")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s history [flags] <index>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman history [flags] INDEX
+//
+// Prints a line per complete scan recorded in the index of dupes --index,
+// oldest first, with how the bytes taken by duplicates changed since the
+// scan before it.
+func runHistory(args []string) {
+	var opts historyFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	if _, err := os.Stat(fs.Arg(0)); err != nil {
+		fatal(err) // rather than creating an empty index
+	}
+
+	ix, err := walkman.OpenIndex(fs.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	runs := ix.History()
+	closeIndex(ix)
+
+	if opts.root != "" {
+		root, err := filepath.Abs(opts.root)
+		if err != nil {
+			fatal(err)
+		}
+		runs = scansOf(runs, root)
+	}
+
+	size := func(n int64) string {
+		if opts.human {
+			return formatBytes(n)
+		}
+		return strconv.FormatInt(n, 10)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tFILES\tBYTES\tDUPLICATES\tDUPLICATE BYTES\tCHANGE\tROOTS")
+
+	for i, run := range runs {
+		change := ""
+		if i > 0 {
+			delta := run.DuplicateBytes - runs[i-1].DuplicateBytes
+			change = size(delta)
+			if delta >= 0 {
+				change = "+" + change
+			}
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%s\t%s\n", run.Time.Format("2006-01-02 15:04"), run.Files,
+			size(run.Bytes), run.DuplicateFiles, size(run.DuplicateBytes), change, strings.Join(run.Roots, " "))
+	}
+	tw.Flush()
+}
+
+// Returns the runs that walked root.
+func scansOf(runs []walkman.RunSummary, root string) []walkman.RunSummary {
+	var kept []walkman.RunSummary
+	for _, run := range runs {
+		for _, r := range run.Roots {
+			if r == root {
+				kept = append(kept, run)
+				break
+			}
+		}
+	}
+	return kept
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the layers subcommand.
+type layersFlags struct {
+	names bool
+	list  bool
+}
+
+func (opts *layersFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("layers", flag.ExitOnError)
+	fs.BoolVar(&opts.names, "names", false, "match files by name and size instead of content (faster)")
+	fs.BoolVar(&opts.list, "list", false, "also list every file duplicated across layers")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s layers [flags] <dirname>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman layers [flags] DIR...
+//
+// Finds files duplicated across the layers of container images exported
+// to DIR (an OCI image layout or the output of docker save, unpacked),
+// and prints, for each layer, how many of its bytes also live in
+// another layer:
+//
+//	docker save myapp:latest | tar -x -C /tmp/myapp
+//	walkman layers /tmp/myapp
+func runLayers(args []string) {
+	var opts layersFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	roots, err := absPaths(fs.Args())
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	options := []walkman.Option{walkman.WithImageLayers(), walkman.NoDefaultSkip()}
+	if !opts.names {
+		options = append(options, walkman.WithContentHash())
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	wm := walkman.New(options...)
+	hashes, err := wm.WalkContext(ctx, roots...)
+	interrupted := checkInterrupted(err)
+	failed := reportErrors(wm)
+
+	type layer struct {
+		files, duplicated int
+		size, wasted      int64
+	}
+
+	layers := map[string]*layer{}
+	groups := map[string]walkman.FileList{}
+	var saving int64
+
+	for hash, files := range hashes {
+		members := walkman.FileList{}
+		seen := map[string]bool{}
+
+		for _, f := range files {
+			if f.Archive == "" {
+				continue
+			}
+
+			l := layers[f.Archive]
+			if l == nil {
+				l = &layer{}
+				layers[f.Archive] = l
+			}
+			l.files++
+			l.size += f.Stats.Size()
+
+			members = append(members, f)
+			seen[f.Archive] = true
+		}
+
+		if len(seen) < 2 {
+			continue
+		}
+
+		groups[hash] = members
+		saving += members.WastedBytes()
+
+		for _, f := range members {
+			layers[f.Archive].duplicated++
+			layers[f.Archive].wasted += f.Stats.Size()
+		}
+	}
+
+	if opts.list {
+		printLayerGroups(groups, roots)
+	}
+
+	names := make([]string, 0, len(layers))
+	for name := range layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LAYER\tFILES\tSIZE\tDUPLICATED\tDUPLICATED SIZE")
+	for _, name := range names {
+		l := layers[name]
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\n", layerName(name, roots), l.files,
+			formatBytes(l.size), l.duplicated, formatBytes(l.wasted))
+	}
+	tw.Flush()
+
+	fmt.Printf("\n%d files duplicated across %d layers, %s could be saved\n",
+		len(groups), len(layers), formatBytes(saving))
+
+	if failed || interrupted {
+		os.Exit(exitError)
+	}
+}
+
+// Prints the files of each group with the layer they are in.
+func printLayerGroups(groups map[string]walkman.FileList, roots []string) {
+	keys := make([]string, 0, len(groups))
+	for hash := range groups {
+		keys = append(keys, hash)
+	}
+	sort.Strings(keys)
+
+	for _, hash := range keys {
+		members := groups[hash]
+		sort.Slice(members, func(i, j int) bool {
+			if members[i].Archive != members[j].Archive {
+				return members[i].Archive < members[j].Archive
+			}
+			return members[i].Path < members[j].Path
+		})
+
+		fmt.Printf("%s--->%d files\n", hash, len(members))
+		for _, f := range members {
+			fmt.Printf("    %s: %s\n", layerName(f.Archive, roots), memberPath(f))
+		}
+		fmt.Println()
+	}
+}
+
+// Names a layer by its path relative to the root it was found in.
+func layerName(archive string, roots []string) string {
+	for _, root := range roots {
+		if !walkman.IsUnder(archive, root) {
+			continue
+		}
+
+		if rel, err := filepath.Rel(root, archive); err == nil {
+			return rel
+		}
+	}
+	return archive
+}
+
+// Returns the path of an archive member inside its archive.
+func memberPath(f walkman.File) string {
+	rel, err := filepath.Rel(f.Archive, f.Path)
+	if err != nil {
+		return f.Path
+	}
+	return rel
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+)
+
+// Serves the state of the service in the Prometheus text format.
+//
+// Counters only cover completed scans, so that they never go back when
+// a scan is interrupted. Gauges describe the last completed scan, except
+// for the worker gauges which follow the running one.
+func (d *service) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+
+	scanning, busy, workers := 0, 0, 0
+	if d.current != nil {
+		scanning = 1
+		busy, workers = d.current.Utilization()
+	}
+
+	var lastScan float64
+	if !d.lastScan.IsZero() {
+		lastScan = float64(d.lastScan.UnixNano()) / 1e9
+	}
+
+	metrics := []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"walkman_scans_total", "counter", "Completed scans.", float64(d.scans)},
+		{"walkman_files_scanned_total", "counter", "Files hashed.", float64(d.totals.FilesScanned)},
+		{"walkman_files_reused_total", "counter", "Unchanged files whose hash was reused.", float64(d.totals.FilesReused)},
+		{"walkman_bytes_hashed_total", "counter", "Bytes read by the hashers.", float64(d.totals.BytesHashed)},
+		{"walkman_dirs_scanned_total", "counter", "Directories read.", float64(d.totals.DirsScanned)},
+		{"walkman_scan_seconds_total", "counter", "Time spent scanning.", d.totals.Elapsed.Seconds()},
+		{"walkman_dir_seconds_total", "counter", "Time workers spent reading directories.", d.totals.DirTime.Seconds()},
+		{"walkman_hash_seconds_total", "counter", "Time workers spent hashing files.", d.totals.HashTime.Seconds()},
+		{"walkman_idle_seconds_total", "counter", "Time workers had nothing to do.", d.totals.IdleTime.Seconds()},
+		{"walkman_errors_total", "counter", "Files and directories left out.", float64(d.totals.Errors)},
+		{"walkman_retries_total", "counter", "Failed reads tried again.", float64(d.totals.Retries)},
+		{"walkman_last_scan_duration_seconds", "gauge", "Duration of the last scan.", d.stats.Elapsed.Seconds()},
+		{"walkman_last_scan_timestamp_seconds", "gauge", "Unix time the last scan completed.", lastScan},
+		{"walkman_last_scan_errors", "gauge", "Files and directories left out of the last scan.", float64(len(d.errors))},
+		{"walkman_duplicate_groups", "gauge", "Groups of duplicate files.", float64(d.groups)},
+		{"walkman_reclaimable_bytes", "gauge", "Bytes freed by keeping one file of each group.", float64(d.reclaimable)},
+		{"walkman_scanning", "gauge", "1 while a scan is running.", float64(scanning)},
+		{"walkman_workers", "gauge", "Workers of the running scan.", float64(workers)},
+		{"walkman_workers_busy", "gauge", "Workers of the running scan busy right now.", float64(busy)},
+	}
+
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.kind)
+		fmt.Fprintf(bw, "%s %g\n", m.name, m.value)
+	}
+	bw.Flush()
+}
+
+// Serves /metrics of d on addr, for commands without an HTTP server.
+func serveMetrics(d *service, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", d.handleMetrics)
+
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		fatal(err)
+	}
+}
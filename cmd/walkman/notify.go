@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// Groups listed by --email-report, those wasting the most first.
+const reportGroups = 20
+
+// flags telling the user what an unattended scan found,
+// e.g one started by cron or the Task Scheduler.
+type notifyFlags struct {
+	desktop bool
+	email   string
+}
+
+func (n *notifyFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&n.desktop, "notify", false, "show a desktop notification summing up the scan once done")
+	fs.StringVar(&n.email, "email-report", "", "mail a summary of the scan to `ADDR` once done, through the SMTP server $WALKMAN_SMTP or else sendmail")
+}
+
+// Sends the notifications asked for, failures are only logged.
+func (n notifyFlags) send(wm *walkman.Walkman, roots []string, hashes walkman.Results, interrupted bool) {
+	if !n.desktop && n.email == "" {
+		return
+	}
+
+	groups, reclaimable := summarize(hashes)
+	title := fmt.Sprintf("walkman: %d duplicate groups, %s reclaimable", groups, formatBytes(reclaimable))
+
+	status := "Scan of " + strings.Join(roots, ", ") + " done"
+	if interrupted {
+		status = "Scan of " + strings.Join(roots, ", ") + " interrupted"
+	}
+	if errs := len(wm.Errors()); errs > 0 {
+		status += fmt.Sprintf(", %d errors", errs)
+	}
+
+	if n.desktop {
+		if err := notify(title, status); err != nil {
+			log.Printf("--notify: %v\n", err)
+		}
+	}
+
+	if n.email != "" {
+		var body bytes.Buffer
+		fmt.Fprintf(&body, "%s.\n\n", status)
+		printStats(&body, wm, hashes)
+
+		keys := largestFirst(hashes)
+		if len(keys) > 0 {
+			fmt.Fprintf(&body, "\nLargest groups:\n")
+		}
+		for i, hash := range keys {
+			if i == reportGroups {
+				fmt.Fprintf(&body, "\nand %d more.\n", len(keys)-reportGroups)
+				break
+			}
+
+			fmt.Fprintf(&body, "\n%s wasted:\n", formatBytes(hashes[hash].WastedBytes()))
+			for _, path := range paths(hashes[hash]) {
+				fmt.Fprintf(&body, "  %s\n", path)
+			}
+		}
+
+		if err := sendMail(n.email, title, body.String()); err != nil {
+			log.Printf("--email-report: %v\n", err)
+		}
+	}
+}
+
+// Mails body to the address to, through the SMTP server at $WALKMAN_SMTP
+// (host:port) if set, logging in as $WALKMAN_SMTP_USER with the password
+// $WALKMAN_SMTP_PASSWORD if set, else through the local sendmail. The
+// sender is $WALKMAN_SMTP_FROM, by default walkman at this host.
+func sendMail(to, subject, body string) error {
+	from := os.Getenv("WALKMAN_SMTP_FROM")
+	if from == "" {
+		host, _ := os.Hostname()
+		from = "walkman@" + host
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	server := os.Getenv("WALKMAN_SMTP")
+	if server == "" {
+		cmd := exec.Command("sendmail", "-t", "-i")
+		cmd.Stdin = &msg
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("sendmail: %v: %s", err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+
+	var auth smtp.Auth
+	if user := os.Getenv("WALKMAN_SMTP_USER"); user != "" {
+		host, _, err := net.SplitHostPort(server)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", user, os.Getenv("WALKMAN_SMTP_PASSWORD"), host)
+	}
+	return smtp.SendMail(server, auth, from, []string{to}, msg.Bytes())
+}
@@ -0,0 +1,13 @@
+package main
+
+import "os/exec"
+
+// Shows a notification in the Notification Center, passing
+// the texts as arguments so that they need no quoting.
+func notify(title, body string) error {
+	return exec.Command("osascript",
+		"-e", "on run argv",
+		"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+		"-e", "end run",
+		title, body).Run()
+}
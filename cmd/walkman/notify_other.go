@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package main
+
+func notify(title, body string) error {
+	return errUnsupported()
+}
@@ -0,0 +1,10 @@
+//go:build unix && !darwin
+
+package main
+
+import "os/exec"
+
+// Shows a notification through the desktop's notification server.
+func notify(title, body string) error {
+	return exec.Command("notify-send", "--app-name=walkman", title, body).Run()
+}
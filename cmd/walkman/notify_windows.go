@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Shows a balloon notification from the tray. PowerShell keeps the
+// icon around while it is shown, so it is left running on its own.
+const notifyScript = `
+Add-Type -AssemblyName System.Windows.Forms
+$icon = New-Object System.Windows.Forms.NotifyIcon
+$icon.Icon = [System.Drawing.SystemIcons]::Information
+$icon.Visible = $true
+$icon.ShowBalloonTip(10000, $env:WALKMAN_TITLE, $env:WALKMAN_BODY, 'Info')
+Start-Sleep -Seconds 10
+$icon.Dispose()
+`
+
+func notify(title, body string) error {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", notifyScript)
+	cmd.Env = append(os.Environ(), "WALKMAN_TITLE="+title, "WALKMAN_BODY="+body)
+	return cmd.Start()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+)
+
+// flags lowering the priority of a walk, e.g for overnight scans
+// that should not slow down whoever uses the machine meanwhile.
+type priorityFlags struct {
+	nice       int
+	ionice     string
+	background bool
+}
+
+func (p *priorityFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&p.nice, "nice", 0, "run at the nice value `N`, from 1 to 19 the lowest CPU priority, like nice(1)")
+	fs.StringVar(&p.ionice, "ionice", "", "use the I/O scheduling `CLASS` idle or best-effort, like ionice(1); Linux only")
+	fs.BoolVar(&p.background, "background", false, "run at the lowest CPU and I/O priority: nice 19 and idle I/O on Linux, background QoS on macOS and Windows")
+}
+
+// Lowers the priority of the whole process as asked,
+// so that every worker of the walk runs at it.
+func (p priorityFlags) apply() {
+	if p.nice < 0 || p.nice > 19 {
+		fatalf("--nice must be between 1 and 19\n")
+	}
+
+	if p.background {
+		if err := setBackground(); err != nil {
+			fatalf("--background: %v\n", err)
+		}
+	}
+
+	if p.nice > 0 {
+		if err := setNice(p.nice); err != nil {
+			fatalf("--nice: %v\n", err)
+		}
+	}
+
+	if p.ionice != "" {
+		if err := setIOClass(p.ionice); err != nil {
+			fatalf("--ionice: %v\n", err)
+		}
+	}
+}
+
+// The error of features missing on this platform.
+func errUnsupported() error {
+	return fmt.Errorf("not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,24 @@
+package main
+
+import "syscall"
+
+// Background QoS of the process, see setpriority(2) on macOS.
+const (
+	prioDarwinProcess = 4
+	prioDarwinBG      = 0x1000
+)
+
+func setNice(n int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, n)
+}
+
+// I/O classes are Linux only, background QoS throttles I/O too.
+func setIOClass(class string) error {
+	return errUnsupported()
+}
+
+// Runs the process in the background band, throttling
+// both its CPU and its disk and network I/O.
+func setBackground() error {
+	return syscall.Setpriority(prioDarwinProcess, 0, prioDarwinBG)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// I/O scheduling classes and the lowest level of best-effort,
+// see ioprio_set(2).
+const (
+	ioprioWhoProcess    = 1
+	ioprioClassShift    = 13
+	ioprioClassBE       = 2
+	ioprioClassIdle     = 3
+	ioprioLowestBELevel = 7
+)
+
+// Sets the nice value of every thread, as setpriority(2) only changes
+// the calling one on Linux. Threads started later inherit it.
+func setNice(n int) error {
+	return eachThread(func(tid int) error {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, tid, n)
+	})
+}
+
+// Sets the I/O scheduling class of every thread, idle or best-effort
+// at its lowest level.
+func setIOClass(class string) error {
+	var prio int
+	switch class {
+	case "idle":
+		prio = ioprioClassIdle << ioprioClassShift
+	case "best-effort":
+		prio = ioprioClassBE<<ioprioClassShift | ioprioLowestBELevel
+	default:
+		return fmt.Errorf("unknown class %q, want idle or best-effort", class)
+	}
+
+	return eachThread(func(tid int) error {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// Lowest CPU and I/O priority.
+func setBackground() error {
+	if err := setNice(19); err != nil {
+		return err
+	}
+	return setIOClass("idle")
+}
+
+// Calls fn with the id of every thread of the process.
+func eachThread(fn func(tid int) error) error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+
+		// the thread may have exited meanwhile
+		if err := fn(tid); err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
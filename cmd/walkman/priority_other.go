@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+func setNice(n int) error {
+	return errUnsupported()
+}
+
+func setIOClass(class string) error {
+	return errUnsupported()
+}
+
+func setBackground() error {
+	return errUnsupported()
+}
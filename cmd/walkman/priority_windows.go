@@ -0,0 +1,45 @@
+package main
+
+import "syscall"
+
+// Priority classes, see SetPriorityClass.
+const (
+	belowNormalPriorityClass   = 0x00004000
+	idlePriorityClass          = 0x00000040
+	processModeBackgroundBegin = 0x00100000
+)
+
+var setPriorityClass = syscall.NewLazyDLL("kernel32.dll").NewProc("SetPriorityClass")
+
+// Windows has no nice values, the lower half of them maps to
+// below normal priority and the upper half to idle.
+func setNice(n int) error {
+	class := uintptr(belowNormalPriorityClass)
+	if n >= 10 {
+		class = idlePriorityClass
+	}
+	return setClass(class)
+}
+
+// I/O classes are Linux only, background mode lowers I/O priority too.
+func setIOClass(class string) error {
+	return errUnsupported()
+}
+
+// Enters background processing mode, lowering the CPU,
+// I/O and memory priority of the process.
+func setBackground() error {
+	return setClass(processModeBackgroundBegin)
+}
+
+func setClass(class uintptr) error {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return err
+	}
+
+	if r, _, err := setPriorityClass.Call(uintptr(process), class); r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abiiranathan/walkman"
+	"github.com/abiiranathan/walkman/s3"
+	"github.com/abiiranathan/walkman/sftp"
+	"github.com/abiiranathan/walkman/webdav"
+)
+
+// Failed reads of remote files are tried again, as connections drop.
+const (
+	remoteRetries = 3
+	remoteBackoff = time.Second
+)
+
+// Connects to the servers named by remote roots, e.g
+// sftp://user@host:port/path, s3://bucket/prefix or
+// davs://user@host/path, and returns the options mounting
+// them. unmount ends every session.
+func mountRemotes(roots []string) ([]walkman.Option, func(), error) {
+	options := []walkman.Option{}
+	servers := map[string]fs.FS{}
+
+	unmount := func() {
+		for _, fsys := range servers {
+			if c, ok := fsys.(io.Closer); ok {
+				c.Close()
+			}
+		}
+	}
+
+	for _, root := range roots {
+		prefix, target, ok := splitRemote(root)
+		if !ok {
+			continue
+		}
+
+		if _, ok := servers[prefix]; ok {
+			continue
+		}
+
+		fsys, err := dialRemote(prefix, target)
+		if err != nil {
+			unmount()
+			return nil, nil, err
+		}
+
+		servers[prefix] = fsys
+		options = append(options, walkman.WithFS(prefix, fsys))
+	}
+
+	if len(servers) > 0 {
+		options = append(options, walkman.WithRetry(remoteRetries, remoteBackoff))
+	}
+	return options, unmount, nil
+}
+
+// Connects to the server or bucket at authority.
+func dialRemote(prefix, authority string) (fs.FS, error) {
+	switch {
+	case strings.HasPrefix(prefix, "sftp://"):
+		return sftp.Dial(authority)
+	case strings.HasPrefix(prefix, "s3://"):
+		return s3.FromEnv(authority)
+	case strings.HasPrefix(prefix, "dav://"), strings.HasPrefix(prefix, "davs://"):
+		return dialDAV(prefix, authority)
+	}
+	return nil, fmt.Errorf("%s: unsupported scheme, use sftp://, s3://, dav:// or davs://", prefix)
+}
+
+// Connects to a WebDAV server, over https for davs://, with the
+// password in $WEBDAV_PASSWORD so that it never shows in paths.
+func dialDAV(prefix, authority string) (fs.FS, error) {
+	scheme := "http"
+	if strings.HasPrefix(prefix, "davs://") {
+		scheme = "https"
+	}
+
+	host, user := authority, ""
+	if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+		user, host = authority[:i], authority[i+1:]
+	}
+
+	return webdav.New(webdav.Config{
+		URL:      scheme + "://" + host,
+		Username: user,
+		Password: os.Getenv("WEBDAV_PASSWORD"),
+	})
+}
+
+// Splits a remote root into the scheme://authority prefix
+// it is mounted at and the authority.
+func splitRemote(root string) (prefix, authority string, ok bool) {
+	i := strings.Index(root, "://")
+	if i < 0 {
+		return "", "", false
+	}
+
+	authority = root[i+3:]
+	if j := strings.IndexByte(authority, '/'); j >= 0 {
+		authority = authority[:j]
+	}
+	return root[:i+3] + authority, authority, true
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// A rule of daemon --schedule, scanning root at set times, e.g
+//
+//	scan /srv/photos daily at 02:00
+//	scan /srv/backups weekly on sunday at 03:30
+//	scan /home/shared hourly
+//	scan /mnt/nas every 6h
+//
+// Times are local.
+type scheduleRule struct {
+	root string
+
+	every   time.Duration // for "every", the calendar fields are unused then
+	hourly  bool
+	weekly  bool
+	weekday time.Weekday
+	hour    int
+	minute  int
+}
+
+// Returns the first time after t the rule scans.
+func (r scheduleRule) next(t time.Time) time.Time {
+	if r.every > 0 {
+		return t.Add(r.every)
+	}
+
+	if r.hourly {
+		return t.Truncate(time.Hour).Add(time.Hour)
+	}
+
+	next := time.Date(t.Year(), t.Month(), t.Day(), r.hour, r.minute, 0, 0, t.Location())
+	for !next.After(t) || (r.weekly && next.Weekday() != r.weekday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func (r scheduleRule) String() string {
+	switch {
+	case r.every > 0:
+		return fmt.Sprintf("scan %s every %s", r.root, r.every)
+	case r.hourly:
+		return fmt.Sprintf("scan %s hourly", r.root)
+	case r.weekly:
+		return fmt.Sprintf("scan %s weekly on %s at %02d:%02d", r.root, strings.ToLower(r.weekday.String()), r.hour, r.minute)
+	}
+	return fmt.Sprintf("scan %s daily at %02d:%02d", r.root, r.hour, r.minute)
+}
+
+// Reads the rules of the schedule file at path, one per line.
+// Empty lines and those starting with # are skipped.
+func loadSchedule(path string) ([]scheduleRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules, err := parseSchedule(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+func parseSchedule(r io.Reader) ([]scheduleRule, error) {
+	var rules []scheduleRule
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// Returns an error if a directory of roots lies below another.
+func checkOverlap(roots []string) error {
+	for i, a := range roots {
+		for _, b := range roots[i+1:] {
+			if walkman.IsUnder(a, b) || walkman.IsUnder(b, a) {
+				return fmt.Errorf("overlapping directories %q and %q, schedule the parent only", a, b)
+			}
+		}
+	}
+	return nil
+}
+
+// Parses a rule like "scan /srv/photos daily at 02:00". Directories may
+// hold spaces, the first word naming a frequency ends them.
+func parseRule(line string) (scheduleRule, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "scan" {
+		return scheduleRule{}, fmt.Errorf("expected scan DIR daily|weekly|hourly|every ..., got %q", line)
+	}
+
+	for i := 2; i < len(fields); i++ {
+		rule, ok, err := parseWhen(fields[i:])
+		if !ok {
+			continue
+		}
+
+		if err != nil {
+			return scheduleRule{}, err
+		}
+
+		root, err := filepath.Abs(strings.Join(fields[1:i], " "))
+		if err != nil {
+			return scheduleRule{}, err
+		}
+		rule.root = root
+		return rule, nil
+	}
+	return scheduleRule{}, fmt.Errorf("no frequency in %q, expected daily, weekly, hourly or every", line)
+}
+
+// Parses the frequency of a rule, e.g "daily at 02:00", reporting
+// whether fields start with one.
+func parseWhen(fields []string) (rule scheduleRule, ok bool, err error) {
+	switch fields[0] {
+	case "every":
+		if len(fields) != 2 {
+			return rule, true, fmt.Errorf("expected every DURATION, e.g every 6h")
+		}
+
+		rule.every, err = time.ParseDuration(fields[1])
+		if err == nil && rule.every < time.Minute {
+			err = fmt.Errorf("scans must be at least a minute apart, got %s", rule.every)
+		}
+		return rule, true, err
+	case "hourly":
+		rule.hourly = true
+		if len(fields) != 1 {
+			err = fmt.Errorf("unexpected %q after hourly", strings.Join(fields[1:], " "))
+		}
+		return rule, true, err
+	case "daily":
+		if len(fields) != 3 || fields[1] != "at" {
+			return rule, true, fmt.Errorf("expected daily at HH:MM")
+		}
+		rule.hour, rule.minute, err = parseClock(fields[2])
+		return rule, true, err
+	case "weekly":
+		if len(fields) != 5 || fields[1] != "on" || fields[3] != "at" {
+			return rule, true, fmt.Errorf("expected weekly on DAY at HH:MM")
+		}
+
+		rule.weekly = true
+		if rule.weekday, err = parseWeekday(fields[2]); err != nil {
+			return rule, true, err
+		}
+		rule.hour, rule.minute, err = parseClock(fields[4])
+		return rule, true, err
+	}
+	return rule, false, nil
+}
+
+// Parses a time of day like 02:00.
+func parseClock(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// Parses a day like sunday or sun.
+func parseWeekday(s string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if strings.EqualFold(s, name) || strings.EqualFold(s, name[:3]) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day %q", s)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line string
+		root string
+		want scheduleRule
+	}{
+		{"scan /srv/photos daily at 02:00", "/srv/photos", scheduleRule{hour: 2}},
+		{"scan /srv/backups weekly on sunday at 03:30", "/srv/backups", scheduleRule{weekly: true, weekday: time.Sunday, hour: 3, minute: 30}},
+		{"scan /srv/backups weekly on Fri at 23:05", "/srv/backups", scheduleRule{weekly: true, weekday: time.Friday, hour: 23, minute: 5}},
+		{"scan /home/shared hourly", "/home/shared", scheduleRule{hourly: true}},
+		{"scan /mnt/nas every 6h", "/mnt/nas", scheduleRule{every: 6 * time.Hour}},
+		{"scan /srv/my photos  daily at 12:45", "/srv/my photos", scheduleRule{hour: 12, minute: 45}},
+	}
+
+	for _, tt := range tests {
+		root, err := filepath.Abs(tt.root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.want.root = root
+
+		got, err := parseRule(tt.line)
+		if err != nil {
+			t.Errorf("parseRule(%q): %v", tt.line, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("parseRule(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	bad := []string{
+		"scan /srv",
+		"walk /srv daily at 02:00",
+		"scan /srv monthly",
+		"scan /srv daily 02:00",
+		"scan /srv daily at 25:00",
+		"scan /srv weekly at 03:30",
+		"scan /srv weekly on someday at 03:30",
+		"scan /srv hourly at 02:00",
+		"scan /srv every",
+		"scan /srv every often",
+		"scan /srv every 30s",
+	}
+
+	for _, line := range bad {
+		if _, err := parseRule(line); err == nil {
+			t.Errorf("parseRule(%q): expected an error", line)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	rules, err := parseSchedule(strings.NewReader(`
+# nightly
+scan /srv/photos daily at 02:00
+
+  scan /mnt/nas every 6h
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 2 || rules[0].hour != 2 || rules[1].every != 6*time.Hour {
+		t.Errorf("expected the two rules, skipping comments and empty lines, got %+v", rules)
+	}
+
+	_, err = parseSchedule(strings.NewReader("scan /srv hourly\n\nscan /srv sometimes\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Errorf("expected an error on line 3, got %v", err)
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2026, 10, 14, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		rule scheduleRule
+		want time.Time
+	}{
+		{scheduleRule{hour: 2}, time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)},
+		{scheduleRule{hour: 12}, time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)},
+		{scheduleRule{hour: 10, minute: 30}, time.Date(2026, 10, 15, 10, 30, 0, 0, time.UTC)},
+		{scheduleRule{weekly: true, weekday: time.Sunday, hour: 3, minute: 30}, time.Date(2026, 10, 18, 3, 30, 0, 0, time.UTC)},
+		{scheduleRule{weekly: true, weekday: time.Wednesday, hour: 11}, time.Date(2026, 10, 14, 11, 0, 0, 0, time.UTC)},
+		{scheduleRule{weekly: true, weekday: time.Wednesday, hour: 9}, time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC)},
+		{scheduleRule{hourly: true}, time.Date(2026, 10, 14, 11, 0, 0, 0, time.UTC)},
+		{scheduleRule{every: 6 * time.Hour}, time.Date(2026, 10, 14, 16, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rule.next(now); !got.Equal(tt.want) {
+			t.Errorf("%s: next(%v) = %v, want %v", tt.rule, now, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOverlap(t *testing.T) {
+	if err := checkOverlap([]string{"/srv/a", "/srv/ab", "/srv/b"}); err != nil {
+		t.Errorf("expected siblings not to overlap, got %v", err)
+	}
+
+	if err := checkOverlap([]string{"/srv/b", "/srv", "/home"}); err == nil {
+		t.Error("expected /srv/b below /srv to overlap")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the scrub subcommand.
+type scrubFlags struct {
+	update bool
+}
+
+func (opts *scrubFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("scrub", flag.ExitOnError)
+	fs.BoolVar(&opts.update, "update", false, "replace the snapshot with the new scan afterwards")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s scrub [flags] <snapshot> <dir>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman scrub [flags] SNAPSHOT DIR...
+//
+// Hashes the content of every file and lists those whose content no longer
+// matches the snapshot although their size and mtime did not change, i.e.
+// files corrupted on disk. If the snapshot does not exist yet it is created
+// and nothing is checked. The exit status is 1 when corruption was found.
+func runScrub(args []string) {
+	var opts scrubFlags
+
+	flags := opts.flagSet()
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	path := flags.Arg(0)
+	roots, err := absPaths(flags.Args()[1:])
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	baseline, err := walkman.LoadResults(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fatal(err)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	wm := walkman.New(walkman.WithContentHash())
+	current, err := wm.WalkContext(ctx, roots...)
+	if checkInterrupted(err) {
+		os.Exit(exitError)
+	}
+	failed := reportErrors(wm)
+
+	if baseline == nil {
+		if err := saveResults(current, path); err != nil {
+			fatal(err)
+		}
+		log.Printf("no snapshot at %s yet, recorded %d files\n", path, countFiles(current))
+		if failed {
+			os.Exit(exitError)
+		}
+		return
+	}
+
+	corrupted, err := walkman.Scrub(baseline, current)
+	if err != nil {
+		fatal(err)
+	}
+
+	for _, m := range corrupted {
+		fmt.Println("!", m.New.Path)
+	}
+	fmt.Fprintf(os.Stderr, "%d files checked, %d corrupted\n", countFiles(current), len(corrupted))
+
+	if opts.update {
+		if err := saveResults(current, path); err != nil {
+			fatal(err)
+		}
+	}
+
+	switch {
+	case failed:
+		os.Exit(exitError)
+	case len(corrupted) > 0:
+		os.Exit(exitCorrupted)
+	}
+}
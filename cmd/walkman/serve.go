@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// flags of the serve subcommand.
+type serveFlags struct {
+	addr     string
+	interval time.Duration
+	names    bool
+	index    string
+	hooks    webhookFlags
+	priority priorityFlags
+}
+
+func (opts *serveFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.StringVar(&opts.addr, "addr", "localhost:8080", "listen for HTTP requests on `ADDR`")
+	fs.DurationVar(&opts.interval, "interval", 0, "time between scheduled scans, 0 to only scan on request")
+	fs.BoolVar(&opts.names, "names", false, "match files by name and size instead of content (faster)")
+	fs.StringVar(&opts.index, "index", "", "keep hashes across scans in the index `FILE`")
+	opts.hooks.register(fs)
+	opts.priority.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s serve [flags] <dirname>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Largest page of duplicate groups served at once.
+const maxPerPage = 1000
+
+// walkman serve [flags] DIR...
+//
+// Serves the scans of DIR over HTTP for dashboards and remote
+// administration:
+//
+//	POST /api/scan                            start a scan
+//	GET  /api/status                          progress of the running scan
+//	GET  /api/duplicates?page=1&per_page=100  duplicate groups, largest first
+//	GET  /api/groups?limit=100&cursor=C       every group by hash, resuming after cursor C
+//	GET  /api/report.csv                      duplicate files as CSV
+//	GET  /api/snapshot.json                   the last scan, see --load
+//	GET  /metrics                             Prometheus metrics
+//
+// The directories are fixed on the command line, clients can not walk
+// anything else. There is no authentication, keep --addr on a trusted
+// network.
+func runServe(args []string) {
+	var opts serveFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	opts.priority.apply()
+
+	roots, err := absPaths(fs.Args())
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	options, ix := serviceOptions(opts.names, opts.index)
+	if ix != nil {
+		defer closeIndex(ix)
+	}
+	d := newService(roots, options)
+	d.hooks = opts.hooks.webhooks()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	server := &http.Server{Addr: opts.addr, Handler: d.routes()}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	scheduled := d.start(ctx, opts.interval)
+
+	log.Printf("listening on %s\n", opts.addr)
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Println(err)
+		stop()
+	}
+
+	// let a running scan finish with the index
+	<-scheduled
+}
+
+func (d *service) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/scan", d.handleScan)
+	mux.HandleFunc("/api/status", d.handleStatus)
+	mux.HandleFunc("/api/duplicates", d.handleDuplicates)
+	mux.HandleFunc("/api/groups", d.handleGroups)
+	mux.HandleFunc("/api/report.csv", d.handleReport)
+	mux.HandleFunc("/api/snapshot.json", d.handleSnapshot)
+	mux.HandleFunc("/metrics", d.handleMetrics)
+	return mux
+}
+
+func (d *service) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, http.StatusMethodNotAllowed, "use POST to start a scan")
+		return
+	}
+
+	d.requestScan()
+
+	st := d.status()
+	st.Scanning = true
+	writeJSON(w, http.StatusAccepted, st)
+}
+
+func (d *service) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, d.status())
+}
+
+type duplicatesPage struct {
+	Page    int          `json:"page"`
+	PerPage int          `json:"per_page"`
+	Total   int          `json:"total"` // number of groups
+	Groups  []groupReply `json:"groups"`
+}
+
+type groupReply struct {
+	Hash        string   `json:"hash"`
+	Size        int64    `json:"size"`        // of each file
+	Reclaimable int64    `json:"reclaimable"` // by keeping a single copy
+	Files       []string `json:"files"`
+}
+
+func (d *service) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		httpError(w, http.StatusBadRequest, "page must be a positive number")
+		return
+	}
+
+	perPage, err := queryInt(r, "per_page", 100)
+	if err != nil || perPage < 1 || perPage > maxPerPage {
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("per_page must be between 1 and %d", maxPerPage))
+		return
+	}
+
+	hashes := d.results()
+	keys := largestFirst(hashes)
+
+	reply := duplicatesPage{Page: page, PerPage: perPage, Total: len(keys), Groups: []groupReply{}}
+
+	// pages past the last are empty, checked first as page*perPage may overflow
+	if page > (len(keys)+perPage-1)/perPage {
+		writeJSON(w, http.StatusOK, reply)
+		return
+	}
+
+	for i := (page - 1) * perPage; i < len(keys) && i < page*perPage; i++ {
+		files := hashes[keys[i]]
+		size := files[0].Stats.Size()
+
+		reply.Groups = append(reply.Groups, groupReply{
+			Hash:        keys[i],
+			Size:        size,
+			Reclaimable: files.WastedBytes(),
+			Files:       paths(files),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, reply)
+}
+
+type groupsPage struct {
+	Groups []groupReply `json:"groups"`
+	Next   string       `json:"next,omitempty"` // cursor of the next page
+}
+
+// Pages through every group, duplicate or not, ordered by hash.
+func (d *service) handleGroups(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", 100)
+	if err != nil || limit < 1 || limit > maxPerPage {
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPerPage))
+		return
+	}
+
+	groups, next := d.results().Page(r.URL.Query().Get("cursor"), limit)
+
+	reply := groupsPage{Groups: []groupReply{}, Next: next}
+	for _, g := range groups {
+		size := g.Files[0].Stats.Size()
+
+		reply.Groups = append(reply.Groups, groupReply{
+			Hash:        g.Hash,
+			Size:        size,
+			Reclaimable: g.Files.WastedBytes(),
+			Files:       paths(g.Files),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, reply)
+}
+
+func (d *service) handleReport(w http.ResponseWriter, r *http.Request) {
+	hashes := d.results()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="duplicates.csv"`)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"hash", "size", "path"})
+
+	for _, hash := range largestFirst(hashes) {
+		files := hashes[hash]
+		for _, path := range paths(files) {
+			cw.Write([]string{hash, strconv.FormatInt(files[0].Stats.Size(), 10), path})
+		}
+	}
+	cw.Flush()
+}
+
+func (d *service) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="snapshot.json"`)
+
+	if err := d.results().WriteSnapshot(w); err != nil {
+		log.Println(err)
+	}
+}
+
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
+func httpError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
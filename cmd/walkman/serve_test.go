@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// Writes files, by path relative to a new temporary directory,
+// and returns the directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// Returns a service of a tree holding 3 groups of duplicates, wasting
+// 3, 2 and 1 bytes, already scanned once.
+func scannedService(t *testing.T) *service {
+	t.Helper()
+
+	dir := writeTree(t, map[string]string{
+		"a1": "aaa", "a2": "aaa",
+		"b1": "bb", "b2": "bb",
+		"c1": "c", "sub/c2": "c",
+		"unique": "unique",
+	})
+
+	d := newService([]string{dir}, []walkman.Option{walkman.WithContentHash()})
+	d.scan(context.Background(), d.roots)
+	return d
+}
+
+// GETs url and decodes the JSON reply into v, returning the status.
+func getJSON(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestServeDuplicatesPaging(t *testing.T) {
+	server := httptest.NewServer(scannedService(t).routes())
+	defer server.Close()
+
+	tests := []struct {
+		query string
+		sizes []int64 // of the groups of the page
+	}{
+		{"", []int64{3, 2, 1}},
+		{"?per_page=2", []int64{3, 2}},
+		{"?page=2&per_page=2", []int64{1}},
+		{"?page=3&per_page=2", []int64{}},
+		{"?page=9223372036854775807&per_page=1000", []int64{}},
+		{"?page=4611686018427387904&per_page=2", []int64{}},
+	}
+
+	for _, tt := range tests {
+		var reply duplicatesPage
+		if status := getJSON(t, server.URL+"/api/duplicates"+tt.query, &reply); status != http.StatusOK {
+			t.Errorf("%s: status %d", tt.query, status)
+			continue
+		}
+
+		sizes := []int64{}
+		for _, g := range reply.Groups {
+			sizes = append(sizes, g.Size)
+
+			if len(g.Files) != 2 || g.Reclaimable != g.Size {
+				t.Errorf("%s: unexpected group %+v", tt.query, g)
+			}
+		}
+
+		if reply.Total != 3 || fmt.Sprint(sizes) != fmt.Sprint(tt.sizes) {
+			t.Errorf("%s: got groups of sizes %v out of %d, want %v out of 3", tt.query, sizes, reply.Total, tt.sizes)
+		}
+	}
+}
+
+func TestServeDuplicatesBadInput(t *testing.T) {
+	server := httptest.NewServer(scannedService(t).routes())
+	defer server.Close()
+
+	for _, query := range []string{
+		"?page=0",
+		"?page=-1",
+		"?page=two",
+		"?page=99999999999999999999",
+		"?per_page=0",
+		"?per_page=1001",
+		"?per_page=ten",
+	} {
+		var reply map[string]string
+		if status := getJSON(t, server.URL+"/api/duplicates"+query, &reply); status != http.StatusBadRequest || reply["error"] == "" {
+			t.Errorf("%s: expected a bad request with an error, got %d %v", query, status, reply)
+		}
+	}
+}
+
+func TestServeScanNeedsPost(t *testing.T) {
+	server := httptest.NewServer(scannedService(t).routes())
+	defer server.Close()
+
+	var reply map[string]string
+	if status := getJSON(t, server.URL+"/api/scan", &reply); status != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET /api/scan to be refused, got %d", status)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// Scans roots on a schedule or on request and keeps the results
+// of the last complete scan, for the daemon and serve commands.
+type service struct {
+	roots   []string
+	rules   []scheduleRule // roots scanned at their own times, see daemon --schedule
+	options []walkman.Option
+	trigger chan struct{} // requests a scan of every root, buffered by one
+	keep    int           // reports kept, see daemon --keep-reports
+	hooks   *webhooks     // notified of scans, nil if none
+
+	mu       sync.Mutex
+	current  *walkman.Walkman // the running scan, nil if none
+	hashes   walkman.Results
+	stats    walkman.RunStats
+	lastScan time.Time
+	errors   []string
+	reports  []scanReport // of the last complete scans, oldest first
+
+	// for /metrics
+	scans       int64            // completed scans
+	totals      walkman.RunStats // summed over completed scans
+	groups      int              // duplicate groups of the last scan
+	reclaimable int64            // bytes wasted by them
+}
+
+// Scans the roots once at start up, then every interval, and the roots
+// of rules when they are due. Every root is scanned whenever triggered.
+// Scans run one at a time, a rule due meanwhile waits for its turn.
+func (d *service) schedule(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	d.scan(ctx, d.roots)
+
+	due := make([]time.Time, len(d.rules))
+	for i, rule := range d.rules {
+		due[i] = rule.next(time.Now())
+	}
+
+	for {
+		// the rule due first, if any
+		next := -1
+		for i := range due {
+			if next < 0 || due[i].Before(due[next]) {
+				next = i
+			}
+		}
+
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if next >= 0 {
+			timer = time.NewTimer(time.Until(due[next]))
+			fire = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			d.scan(ctx, d.roots)
+		case <-d.trigger:
+			d.scan(ctx, d.allRoots())
+		case <-fire:
+			log.Printf("starting scheduled %s\n", d.rules[next])
+			d.scan(ctx, []string{d.rules[next].root})
+			due[next] = d.rules[next].next(time.Now())
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Returns the roots and those of rules, each once.
+func (d *service) allRoots() []string {
+	roots := append([]string(nil), d.roots...)
+	for _, rule := range d.rules {
+		if !slices.Contains(roots, rule.root) {
+			roots = append(roots, rule.root)
+		}
+	}
+	return roots
+}
+
+// Scans roots, replacing their files in the results kept.
+func (d *service) scan(ctx context.Context, roots []string) {
+	if len(roots) == 0 {
+		return
+	}
+
+	wm := walkman.New(d.options...)
+
+	d.mu.Lock()
+	d.current = wm
+	d.mu.Unlock()
+
+	hashes, err := wm.WalkContext(ctx, roots...)
+
+	errs := []string{}
+	for _, err := range wm.Errors() {
+		errs = append(errs, err.Error())
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.current = nil
+
+	// keep the results of the last complete scan
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			log.Println(err)
+		}
+		return
+	}
+
+	d.hashes = replaceRoots(d.hashes, hashes, roots)
+	d.stats = wm.LastRunStats()
+	d.lastScan = time.Now()
+	d.errors = errs
+
+	d.scans++
+	d.totals.FilesScanned += d.stats.FilesScanned
+	d.totals.FilesReused += d.stats.FilesReused
+	d.totals.BytesHashed += d.stats.BytesHashed
+	d.totals.DirsScanned += d.stats.DirsScanned
+	d.totals.DirsSkipped += d.stats.DirsSkipped
+	d.totals.Errors += d.stats.Errors
+	d.totals.Retries += d.stats.Retries
+	d.totals.Elapsed += d.stats.Elapsed
+	d.totals.DirTime += d.stats.DirTime
+	d.totals.HashTime += d.stats.HashTime
+	d.totals.IdleTime += d.stats.IdleTime
+	d.totals.IndexTime += d.stats.IndexTime
+
+	before := d.reclaimable
+	d.groups, d.reclaimable = summarize(d.hashes)
+
+	report := scanReport{
+		Roots:       roots,
+		Finished:    d.lastScan,
+		Stats:       d.stats,
+		Errors:      errs,
+		Groups:      d.groups,
+		Reclaimable: d.reclaimable,
+	}
+
+	if d.keep > 0 {
+		d.reports = append(d.reports, report)
+
+		if len(d.reports) > d.keep {
+			d.reports = slices.Delete(d.reports, 0, len(d.reports)-d.keep)
+		}
+	}
+
+	if d.hooks != nil {
+		d.hooks.scanned(report, before)
+	}
+}
+
+// Returns hashes with the files found below roots replaced by those of
+// fresh, a scan of roots.
+func replaceRoots(hashes, fresh walkman.Results, roots []string) walkman.Results {
+	merged := make(walkman.Results, len(hashes))
+	for hash, files := range hashes {
+		var kept walkman.FileList
+		for _, f := range files {
+			if !slices.Contains(roots, f.Root) {
+				kept = append(kept, f)
+			}
+		}
+
+		if len(kept) > 0 {
+			merged[hash] = kept
+		}
+	}
+
+	// scans of a service always use the same hasher
+	if err := merged.Merge(fresh); err != nil {
+		log.Println(err)
+	}
+	return merged
+}
+
+// The outcome of a complete scan, see daemon --keep-reports. Duplicates
+// are counted over the results of every root after the scan.
+type scanReport struct {
+	Roots       []string         `json:"roots"`
+	Finished    time.Time        `json:"finished"`
+	Stats       walkman.RunStats `json:"stats"`
+	Errors      []string         `json:"errors,omitempty"`
+	Groups      int              `json:"duplicate_groups"`
+	Reclaimable int64            `json:"reclaimable"`
+}
+
+// Returns the reports of the last complete scans, oldest first.
+func (d *service) lastReports() []scanReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]scanReport{}, d.reports...)
+}
+
+func newService(roots []string, options []walkman.Option) *service {
+	return &service{roots: roots, options: options, trigger: make(chan struct{}, 1)}
+}
+
+// Runs the schedule in the background. The returned channel is
+// closed once ctx is done and the running scan, if any, returned.
+func (d *service) start(ctx context.Context, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		d.schedule(ctx, interval)
+		close(done)
+	}()
+	return done
+}
+
+// Options of the long running commands: content hashing unless names
+// is set, and the index at path if it is not empty. The caller closes
+// the index.
+func serviceOptions(names bool, path string) ([]walkman.Option, *walkman.Index) {
+	options := []walkman.Option{}
+	if !names {
+		options = append(options, walkman.WithContentHash())
+	}
+
+	if path == "" {
+		return options, nil
+	}
+
+	ix, err := walkman.OpenIndex(path)
+	if err != nil {
+		fatal(err)
+	}
+	return append(options, walkman.WithIndex(ix)), ix
+}
+
+// Asks for a scan; it starts after the running one, if any.
+func (d *service) requestScan() {
+	select {
+	case d.trigger <- struct{}{}:
+	default: // a scan is already pending
+	}
+}
+
+func paths(files walkman.FileList) []string {
+	p := make([]string, 0, len(files))
+	for _, f := range files {
+		p = append(p, f.Path)
+	}
+	sort.Strings(p)
+	return p
+}
+
+// What a service is up to.
+type serviceStatus struct {
+	Scanning bool              `json:"scanning"`
+	LastScan *time.Time        `json:"last_scan,omitempty"`
+	Stats    *walkman.RunStats `json:"stats,omitempty"` // of the running scan, else the last one
+	Errors   []string          `json:"errors,omitempty"`
+}
+
+func (d *service) status() serviceStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	st := serviceStatus{Scanning: d.current != nil, Errors: d.errors}
+	if !d.lastScan.IsZero() {
+		lastScan := d.lastScan
+		st.LastScan = &lastScan
+	}
+
+	stats := d.stats
+	if d.current != nil {
+		stats = d.current.LastRunStats()
+	}
+
+	if d.current != nil || !d.lastScan.IsZero() {
+		st.Stats = &stats
+	}
+	return st
+}
+
+// Returns the results of the last complete scan.
+func (d *service) results() walkman.Results {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.hashes
+}
+
+// Returns the hashes of duplicate groups, those wasting the
+// most space first.
+func largestFirst(hashes walkman.Results) []string {
+	keys := duplicateKeys(hashes)
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return hashes[keys[i]].WastedBytes() > hashes[keys[j]].WastedBytes()
+	})
+	return keys
+}
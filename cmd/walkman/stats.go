@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// Returns the number of duplicate groups and the bytes
+// freed by keeping a single file of each.
+func summarize(hashes walkman.Results) (groups int, reclaimable int64) {
+	for _, files := range hashes {
+		if len(files) > 1 {
+			groups++
+			reclaimable += files.WastedBytes()
+		}
+	}
+	return groups, reclaimable
+}
+
+// Prints the --stats summary of a walk to w.
+func printStats(w io.Writer, wm *walkman.Walkman, hashes walkman.Results) {
+	stats := wm.LastRunStats()
+	groups, reclaimable := summarize(hashes)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "files scanned\t%d\n", stats.FilesScanned)
+	if stats.FilesReused > 0 {
+		fmt.Fprintf(tw, "files unchanged\t%d\n", stats.FilesReused)
+	}
+	if stats.FilesSkipped > 0 {
+		fmt.Fprintf(tw, "files skipped\t%d\n", stats.FilesSkipped)
+	}
+	if stats.FilesKnown > 0 {
+		fmt.Fprintf(tw, "files known\t%d\n", stats.FilesKnown)
+	}
+	if stats.FilesUnique > 0 {
+		fmt.Fprintf(tw, "files of unique size\t%d\n", stats.FilesUnique)
+	}
+	fmt.Fprintf(tw, "directories scanned\t%d\n", stats.DirsScanned)
+	fmt.Fprintf(tw, "directories skipped\t%d\n", stats.DirsSkipped)
+	if stats.LinksSkipped > 0 {
+		fmt.Fprintf(tw, "links skipped\t%d\n", stats.LinksSkipped)
+	}
+	fmt.Fprintf(tw, "bytes hashed\t%s\n", formatBytes(stats.BytesHashed))
+	fmt.Fprintf(tw, "by size\t%s\n", histogramLine(hashes))
+	fmt.Fprintf(tw, "by extension\t%s\n", extensionsLine(hashes, 5))
+	fmt.Fprintf(tw, "by category\t%s\n", categoriesLine(hashes.ByCategory()))
+	fmt.Fprintf(tw, "duplicate groups\t%d\n", groups)
+	fmt.Fprintf(tw, "reclaimable\t%s\n", formatBytes(reclaimable))
+	if groups > 0 {
+		fmt.Fprintf(tw, "reclaimable by category\t%s\n", categoriesLine(copiesOf(hashes).ByCategory()))
+	}
+	fmt.Fprintf(tw, "elapsed\t%s\n", roundDuration(stats.Elapsed))
+	fmt.Fprintf(tw, "throughput\t%.0f files/s, %.1f MB/s\n", stats.FilesPerSecond(), stats.MBPerSecond())
+	fmt.Fprintf(tw, "workers\t%d reading for %s, %d hashing for %s, idle %s\n",
+		stats.WalkWorkers, roundDuration(stats.DirTime), stats.HashWorkers, roundDuration(stats.HashTime),
+		roundDuration(stats.IdleTime))
+	if stats.IndexTime > 0 {
+		fmt.Fprintf(tw, "index update\t%s\n", roundDuration(stats.IndexTime))
+	}
+	if stats.Retries > 0 {
+		fmt.Fprintf(tw, "retries\t%d\n", stats.Retries)
+	}
+	if stats.Errors > 0 {
+		fmt.Fprintf(tw, "errors\t%d\n", stats.Errors)
+	}
+	if stats.FilesLocked > 0 {
+		fmt.Fprintf(tw, "locked by another process\t%d\n", stats.FilesLocked)
+	}
+	if denied := wm.Report().Denied(); len(denied) > 0 {
+		fmt.Fprintf(tw, "permission denied\t%d\n", len(denied))
+	}
+	tw.Flush()
+}
+
+// Summarizes the files of hashes by size, e.g "< 1.0 KiB: 120 (40.0 KiB)".
+func histogramLine(hashes walkman.Results) string {
+	var parts []string
+	for _, b := range hashes.Histogram(nil) {
+		if b.Files == 0 {
+			continue
+		}
+
+		bound := "< " + formatBytes(b.Max)
+		if b.Max == math.MaxInt64 {
+			bound = ">= " + formatBytes(b.Min)
+		}
+		parts = append(parts, fmt.Sprintf("%s: %d (%s)", bound, b.Files, formatBytes(b.Bytes)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Summarizes the n extensions of hashes taking the most bytes,
+// e.g "pdf: 12 (1.5 GiB)".
+func extensionsLine(hashes walkman.Results, n int) string {
+	var parts []string
+	for _, e := range hashes.ByExtension() {
+		if len(parts) == n {
+			break
+		}
+
+		ext := e.Ext
+		if ext == "" {
+			ext = "(none)"
+		}
+		parts = append(parts, fmt.Sprintf("%s: %d (%s)", ext, e.Files, formatBytes(e.Bytes)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Summarizes categories, e.g "video: 12 (3.2 GiB)".
+func categoriesLine(stats []walkman.CategoryStats) string {
+	parts := make([]string, 0, len(stats))
+	for _, c := range stats {
+		parts = append(parts, fmt.Sprintf("%s: %d (%s)", c.Category, c.Files, formatBytes(c.Bytes)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Returns the copies beyond the first of every group of duplicates,
+// those keeping a single file of each would remove.
+func copiesOf(hashes walkman.Results) walkman.Results {
+	copies := make(walkman.Results)
+	for hash, files := range hashes {
+		if len(files) > 1 {
+			copies[hash] = files[1:]
+		}
+	}
+	return copies
+}
+
+// Rounds d to a precision that suits its magnitude.
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// Formats n bytes with a binary unit, e.g 1.5 GiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
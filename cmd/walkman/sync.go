@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the sync subcommand.
+type syncFlags struct {
+	names  bool
+	delete bool
+	hidden bool
+	dryRun bool
+	yes    bool
+}
+
+func (opts *syncFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	fs.BoolVar(&opts.names, "names", false, "match files by name and size instead of content (faster)")
+	fs.BoolVar(&opts.delete, "delete", false, "also delete files of the destination missing from the source")
+	fs.BoolVar(&opts.hidden, "hidden", false, "also walk hidden directories")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "only print what would be done (default unless --yes)")
+	fs.BoolVar(&opts.yes, "yes", false, "confirm copying and deleting files")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s sync [flags] <src> <dst>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman sync [flags] SRC DST
+//
+// Makes DST hold the files of SRC like rsync, except that files are
+// matched by content: a file renamed or moved in SRC is copied from
+// where DST already has it rather than transferred again.
+func runSync(args []string) {
+	var opts syncFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	roots, err := absPaths(fs.Args())
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	trees := make([]walkman.Results, 2)
+	for i, root := range roots {
+		options := []walkman.Option{walkman.WithContentHash()}
+		if opts.names {
+			options = nil
+		}
+
+		if opts.hidden {
+			options = append(options, walkman.WithIncludeHidden())
+		}
+
+		wm := walkman.New(options...)
+
+		hashes, err := wm.WalkContext(ctx, root)
+		if checkInterrupted(err) {
+			os.Exit(exitError)
+		}
+
+		// Never act on a partial view of either tree.
+		if reportErrors(wm) {
+			os.Exit(exitError)
+		}
+		trees[i] = hashes
+	}
+
+	plan := walkman.SyncPlan{}
+	for _, step := range walkman.PlanSync(trees[0], trees[1], roots[0], roots[1]) {
+		if step.Action == walkman.SyncCopy || (step.Action == walkman.SyncDelete && opts.delete) {
+			plan = append(plan, step)
+		}
+	}
+
+	if opts.dryRun || !opts.yes {
+		for _, step := range plan {
+			fmt.Println("would", step)
+		}
+
+		fmt.Printf("\n%d steps, %d bytes to transfer. Pass --yes to apply.\n", len(plan), plan.Transfer())
+		return
+	}
+
+	done, err := plan.Execute()
+	fmt.Fprintf(os.Stderr, "%d of %d steps applied\n", done, len(plan))
+	if err != nil {
+		fatal(err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the undo subcommand.
+type undoFlags struct {
+	dryRun bool
+	yes    bool
+}
+
+func (opts *undoFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "only print what would be restored (default unless --yes)")
+	fs.BoolVar(&opts.yes, "yes", false, "confirm restoring files")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s undo [flags] <journal>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman undo [flags] JOURNAL
+//
+// Reverses the steps recorded by dupes --journal, last first: moved files
+// come back from the quarantine, and deleted files and links are replaced
+// by a copy of the file kept. Steps that can not be undone, e.g as a file
+// took the place of the one removed, are reported and left alone.
+func runUndo(args []string) {
+	var opts undoFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+
+	entries, err := walkman.ReadJournal(f)
+	f.Close()
+	if err != nil {
+		fatal(err)
+	}
+
+	if opts.dryRun || !opts.yes {
+		for i := len(entries) - 1; i >= 0; i-- {
+			fmt.Println("would", undoString(entries[i]))
+		}
+
+		fmt.Printf("\n%d steps to undo. Pass --yes to apply.\n", len(entries))
+		return
+	}
+
+	done := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := entries[i].Undo(); err != nil {
+			fmt.Fprintf(os.Stderr, "can not %s: %v\n", undoString(entries[i]), err)
+			continue
+		}
+		done++
+	}
+
+	fmt.Fprintf(os.Stderr, "%d of %d steps undone\n", done, len(entries))
+	if done < len(entries) {
+		os.Exit(exitError)
+	}
+}
+
+func undoString(e walkman.JournalEntry) string {
+	if e.Action == walkman.ActionMove.String() {
+		return fmt.Sprintf("restore %q from %q", e.Path, e.Dest)
+	}
+	return fmt.Sprintf("restore %q (%s) as a copy of %q", e.Path, e.Action, e.Keep)
+}
@@ -1,38 +1,576 @@
 // walks directory and prints every file to stdout
+//
+//	walkman [flags] <dirname>        print every file
+//	walkman dupes [flags] <dir>...   report (and optionally remove) duplicates
+//	walkman compare [flags] <a> <b>  compare two directory trees
+//	walkman diff [flags] <old> <new> list changes between two snapshots
+//	walkman sync [flags] <src> <dst> copy files missing from dst, by content
+//	walkman history [flags] <index>  show how duplicates grew over time
+//	walkman scrub <snap> <dir>...    find files corrupted since a snapshot
+//	walkman layers [flags] <dir>...  find files duplicated across image layers
+//	walkman du [flags] <dir>...      print the size of every directory
+//	walkman cold [flags] <dir>...    find files left unchanged for long
+//	walkman blocks [flags] <dir>...  estimate savings of block-level dedup
+//	walkman export <store> <dir>...  copy every distinct file into a store
+//	walkman watch [flags] <dir>      report new duplicates as they appear
+//	walkman daemon [flags] <dir>...  scan on a schedule, serve a control socket
+//	walkman serve [flags] <dir>...   serve scans and results over HTTP
+//	walkman agent [flags] <dir>...   report files to a coordinator
+//	walkman coordinator [flags]      find duplicates across hosts
+//	walkman completion bash|zsh|fish print a shell completion script
+//
+// Exit status is 0 when no duplicates were found, 1 when duplicates
+// were found and 2 if errors occurred during the walk.
 package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/abiiranathan/walkman"
 )
 
+// Exit codes, so that scripts can branch on the outcome of a scan.
+const (
+	exitOK         = 0 // no duplicates
+	exitDuplicates = 1 // duplicates found
+	exitDifferent  = 1 // compare, diff: the trees differ
+	exitCorrupted  = 1 // scrub: corrupted files found
+	exitError      = 2 // errors during the walk or bad usage
+)
+
+// A subcommand of walkman.
+type command struct {
+	name    string
+	summary string
+	flags   func() *flag.FlagSet // flags for shell completion, may be nil
+	run     func(args []string)
+}
+
+func subcommands() []command {
+	return []command{
+		{
+			name:    "dupes",
+			summary: "report and remove duplicate files",
+			flags:   func() *flag.FlagSet { return new(dupesFlags).flagSet() },
+			run:     runDupes,
+		},
+		{
+			name:    "undo",
+			summary: "restore the files removed by dupes, from its journal",
+			flags:   func() *flag.FlagSet { return new(undoFlags).flagSet() },
+			run:     runUndo,
+		},
+		{
+			name:    "compare",
+			summary: "compare two directory trees",
+			flags:   func() *flag.FlagSet { return new(compareFlags).flagSet() },
+			run:     runCompare,
+		},
+		{
+			name:    "diff",
+			summary: "list changes between two snapshots",
+			flags:   func() *flag.FlagSet { return new(diffFlags).flagSet() },
+			run:     runDiff,
+		},
+		{
+			name:    "sync",
+			summary: "make a tree hold the files of another, matched by content",
+			flags:   func() *flag.FlagSet { return new(syncFlags).flagSet() },
+			run:     runSync,
+		},
+		{
+			name:    "history",
+			summary: "show how duplicates grew across scans kept in an index",
+			flags:   func() *flag.FlagSet { return new(historyFlags).flagSet() },
+			run:     runHistory,
+		},
+		{
+			name:    "scrub",
+			summary: "find files corrupted since a snapshot",
+			flags:   func() *flag.FlagSet { return new(scrubFlags).flagSet() },
+			run:     runScrub,
+		},
+		{
+			name:    "layers",
+			summary: "find files duplicated across container image layers",
+			flags:   func() *flag.FlagSet { return new(layersFlags).flagSet() },
+			run:     runLayers,
+		},
+		{
+			name:    "du",
+			summary: "print the size of every directory",
+			flags:   func() *flag.FlagSet { return new(duFlags).flagSet() },
+			run:     runDu,
+		},
+		{
+			name:    "cold",
+			summary: "find files left unchanged for long",
+			flags:   func() *flag.FlagSet { return new(coldFlags).flagSet() },
+			run:     runCold,
+		},
+		{
+			name:    "blocks",
+			summary: "estimate what block-level deduplication would save",
+			flags:   func() *flag.FlagSet { return new(blocksFlags).flagSet() },
+			run:     runBlocks,
+		},
+		{
+			name:    "export",
+			summary: "copy every distinct file into a content-addressed store",
+			flags:   func() *flag.FlagSet { return new(exportFlags).flagSet() },
+			run:     runExport,
+		},
+		{
+			name:    "watch",
+			summary: "report new duplicates as they appear",
+			flags:   func() *flag.FlagSet { return new(watchFlags).flagSet() },
+			run:     runWatch,
+		},
+		{
+			name:    "daemon",
+			summary: "scan on a schedule and serve a control socket",
+			flags:   func() *flag.FlagSet { return new(daemonFlags).flagSet() },
+			run:     runDaemon,
+		},
+		{
+			name:    "serve",
+			summary: "serve scans and results over HTTP",
+			flags:   func() *flag.FlagSet { return new(serveFlags).flagSet() },
+			run:     runServe,
+		},
+		{
+			name:    "agent",
+			summary: "report files to a coordinator",
+			flags:   func() *flag.FlagSet { return new(agentFlags).flagSet() },
+			run:     runAgent,
+		},
+		{
+			name:    "coordinator",
+			summary: "find duplicates across the hosts of agents",
+			flags:   func() *flag.FlagSet { return new(coordinatorFlags).flagSet() },
+			run:     runCoordinator,
+		},
+		{
+			name:    "completion",
+			summary: "print a shell completion script",
+			run:     runCompletion,
+		},
+	}
+}
+
 func main() {
 	if len(os.Args) < 2 {
-		log.Fatalf("Usage: %s <dirname>\n", os.Args[0])
+		fatalf("Usage: %s [dupes|undo|compare|diff|sync|history|scrub|layers|du|cold|blocks|export|watch|daemon|serve|agent|coordinator|completion] <dirname>\n", os.Args[0])
+	}
+
+	for _, cmd := range subcommands() {
+		if cmd.name == os.Args[1] {
+			cmd.run(os.Args[2:])
+			return
+		}
 	}
 
-	dir, err := filepath.Abs(os.Args[1])
+	runList(os.Args[1:])
+}
+
+// Files written at once by --ndjson and --csv.
+const sinkBatch = 1000
+
+// flags of the default listing command.
+type listFlags struct {
+	print0   bool
+	relative bool
+	where    string
+	maxFiles int64
+	maxBytes string
+	stats    bool
+	save     string
+	archives bool
+	streams  bool
+	hidden   bool
+	marker   string
+	backup   bool
+	mime     bool
+	exif     bool
+	tags     bool
+	empty    bool
+	broken   bool
+	match    string
+	ignore   string
+	resume   string
+	ndjson   string
+	csv      string
+	priority priorityFlags
+}
+
+func (o *listFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("walkman", flag.ExitOnError)
+	fs.BoolVar(&o.print0, "print0", false, "terminate paths with NUL instead of newline, for xargs -0")
+	fs.BoolVar(&o.relative, "relative", false, "print paths relative to the directory walked")
+	fs.StringVar(&o.where, "where", "", "only print files matching `EXPR`, e.g. 'size > 10MB && ext in (pdf, docx)'")
+	fs.BoolVar(&o.stats, "stats", false, "print a summary of the scan to stderr")
+	fs.StringVar(&o.save, "save", "", "save the scan as a snapshot to `FILE`")
+	fs.BoolVar(&o.archives, "archives", false, "also list the members of zip and tar archives")
+	fs.BoolVar(&o.streams, "streams", false, "also list the alternate data streams of files, as path\\to\\file:name; NTFS only")
+	fs.BoolVar(&o.hidden, "hidden", false, "also walk hidden directories")
+	fs.StringVar(&o.marker, "skip-marker", "", "skip directories holding a file named `NAME`, e.g .nodedup")
+	fs.BoolVar(&o.backup, "backup-semantics", false, "read files other processes locked with backup semantics when they allow it; Windows only")
+	fs.BoolVar(&o.mime, "mime", false, "detect the content type of files, for mime in --where")
+	fs.BoolVar(&o.exif, "exif", false, "read the EXIF tags of photos, for taken, camera, width and height in --where")
+	fs.BoolVar(&o.tags, "tags", false, "read the tags of MP3, FLAC and Ogg files, for artist, title, album and duration in --where")
+	fs.BoolVar(&o.empty, "empty", false, "only print empty files, then empty directories")
+	fs.BoolVar(&o.broken, "broken-links", false, "only print symbolic links whose target is missing")
+	fs.StringVar(&o.match, "match-hashes", "", "only print files whose md5 is listed in `FILE`, e.g indicators of compromise")
+	fs.StringVar(&o.ignore, "ignore-hashes", "", "leave out files whose md5 is listed in `FILE`, e.g the NSRL")
+	fs.StringVar(&o.ndjson, "ndjson", "", "write every file to `FILE` as a line of JSON while scanning, so that a crash only loses the last few; gzipped if FILE ends in .gz")
+	fs.StringVar(&o.csv, "csv", "", "write every file to `FILE` as a CSV record while scanning; gzipped if FILE ends in .gz")
+	fs.Int64Var(&o.maxFiles, "max-files", 0, "stop after `N` files, printing those found so far")
+	fs.StringVar(&o.maxBytes, "max-bytes", "", "stop after hashing `SIZE` bytes, e.g 50GB, printing the files found so far")
+	o.priority.register(fs)
+	fs.StringVar(&o.resume, "resume", "", "save the scan to `FILE` every minute and pick it up from there if interrupted, e.g by a reboot")
+	return fs
+}
+
+// prints every file below dirname.
+func runList(args []string) {
+	var opts listFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fatalf("Usage: %s [--print0] [--where EXPR] <dirname>\n", os.Args[0])
+	}
+
+	opts.priority.apply()
+	filter := parseWhere(opts.where)
+
+	roots, err := absPaths(fs.Args())
 	if err != nil {
-		log.Fatalf("can not create absolute path: %v\n", err)
+		fatalf("can not create absolute path: %v\n", err)
 	}
 
-	wm := walkman.New()
-	hashes, err := wm.Walk(dir)
+	ctx, stop := signalContext()
+	defer stop()
+
+	options, unmount, err := mountRemotes(roots)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+	defer unmount()
+
+	options = append(options, walkman.WithDeterministicOrder())
+
+	if opts.archives {
+		options = append(options, walkman.WithArchives())
+	}
+
+	if opts.streams {
+		options = append(options, walkman.WithAlternateStreams())
+	}
+
+	if opts.hidden {
+		options = append(options, walkman.WithIncludeHidden())
+	}
+
+	if opts.marker != "" {
+		options = append(options, walkman.WithSkipMarker(opts.marker))
+	}
+
+	if opts.backup {
+		options = append(options, walkman.WithBackupSemantics())
+	}
+
+	if opts.mime {
+		options = append(options, walkman.WithMIME())
+	}
+
+	if opts.exif {
+		options = append(options, walkman.WithMetadata(walkman.ExtractEXIF))
+	}
+
+	if opts.tags {
+		options = append(options, walkman.WithMetadata(walkman.ExtractTags))
+	}
+
+	if opts.empty {
+		options = append(options, walkman.WithEmpty())
+	}
+
+	if opts.broken {
+		options = append(options, walkman.WithSymlinks(walkman.SymlinkRecord))
+	}
+
+	if opts.resume != "" {
+		options = append(options, walkman.WithCheckpoint(opts.resume, 0))
+	}
+
+	options = append(options, budgetOptions(opts.maxFiles, opts.maxBytes)...)
+
+	if opts.ndjson != "" && opts.csv != "" {
+		fatalf("--ndjson and --csv are mutually exclusive\n")
+	}
+
+	// files written out while scanning are also kept in memory to be listed
+	var memory *walkman.MemoryBackend
+	if opts.ndjson != "" || opts.csv != "" {
+		if opts.resume != "" {
+			fatalf("--resume can not be combined with --ndjson or --csv\n")
+		}
+
+		var out walkman.ResultBackend
+		if opts.ndjson != "" {
+			out = walkman.NDJSONBackend(createFile(opts.ndjson))
+		} else {
+			out = walkman.CSVBackend(createFile(opts.csv))
+		}
+		defer closeBackend(out)
+
+		memory = walkman.NewMemoryBackend()
+		options = append(options, walkman.WithResultBackend(memory, out), walkman.WithFlushEvery(sinkBatch))
+	}
+
+	if opts.match != "" && opts.ignore != "" {
+		fatalf("--match-hashes and --ignore-hashes are mutually exclusive\n")
+	}
+
+	if opts.match != "" {
+		options = append(options, walkman.WithContentHash(), knownHashes(opts.match, walkman.KnownFlag))
+	}
+
+	if opts.ignore != "" {
+		options = append(options, walkman.WithContentHash(), knownHashes(opts.ignore, walkman.KnownExclude))
+	}
+
+	wm := walkman.New(options...)
+
+	var hashes walkman.Results
+	var interrupted bool
+	if memory == nil {
+		hashes, interrupted = scan(ctx, wm, roots, "", opts.save)
+	} else {
+		// the walk only returns duplicates, every file is in memory
+		_, interrupted = scan(ctx, wm, roots, "", "")
+		hashes = memory.Results()
+
+		if opts.save != "" {
+			if err := saveResults(hashes, opts.save); err != nil {
+				fatal(err)
+			}
+		}
+	}
+	hashes = hashes.Filter(filter)
+
+	var sep byte = '\n'
+	if opts.print0 {
+		sep = 0
+	}
+
+	files := hashes.ToSlice()
+	if opts.empty || opts.broken {
+		files = nil
 	}
 
-	buf := bytes.Buffer{}
-	for _, f := range hashes.ToSlice() {
-		buf.WriteString(f.Path)
-		buf.WriteString("\n")
+	if opts.empty {
+		files = append(files, hashes.EmptyFiles()...)
+		files = append(files, hashes.EmptyDirs()...)
 	}
 
-	io.Copy(bufio.NewWriter(os.Stdout), &buf)
+	if opts.broken {
+		files = append(files, hashes.BrokenSymlinks()...)
+	}
+
+	if opts.match != "" {
+		files = onlyKnown(files)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	for _, f := range files {
+		path := f.Path
+		if opts.relative && f.RelPath != "" {
+			path = f.RelPath
+		}
+		writeRecord(w, path, sep)
+	}
+	w.Flush()
+
+	if opts.stats {
+		printStats(os.Stderr, wm, hashes)
+	}
+
+	if reportErrors(wm) || interrupted {
+		os.Exit(exitError)
+	}
+}
+
+// Compiles the --where expression, an empty one matches every file.
+func parseWhere(expr string) walkman.PathFilter {
+	if expr == "" {
+		return func(walkman.File) bool { return true }
+	}
+
+	filter, err := walkman.ParseFilter(expr)
+	if err != nil {
+		fatalf("invalid --where expression: %v\n", err)
+	}
+	return filter
+}
+
+// Returns the options stopping walks after maxFiles files or
+// maxBytes bytes hashed, a size like 50GB, when set.
+func budgetOptions(maxFiles int64, maxBytes string) []walkman.Option {
+	var options []walkman.Option
+	if maxFiles > 0 {
+		options = append(options, walkman.WithMaxFiles(maxFiles))
+	}
+
+	if maxBytes != "" {
+		n, err := walkman.ParseSize(maxBytes)
+		if err != nil {
+			fatalf("invalid --max-bytes: %v\n", err)
+		}
+		options = append(options, walkman.WithMaxBytesHashed(n))
+	}
+	return options
+}
+
+// Creates the file at path, compressed if its name ends in .gz,
+// exiting on failure.
+func createFile(path string) io.WriteCloser {
+	f, err := walkman.CreateOutput(path)
+	if err != nil {
+		fatal(err)
+	}
+	return f
+}
+
+// Closes backend, logging rather than exiting on failure
+// since whatever else was asked for is done by then.
+func closeBackend(backend walkman.ResultBackend) {
+	if err := backend.Close(); err != nil {
+		log.Println(err)
+	}
+}
+
+// Returns the option matching the hashes listed in the file at path.
+func knownHashes(path string, policy walkman.KnownPolicy) walkman.Option {
+	known, err := walkman.LoadKnownHashes(path)
+	if err != nil {
+		fatal(err)
+	}
+	return walkman.WithKnownHashes(known, policy)
+}
+
+// Returns the files of files whose hash is known.
+func onlyKnown(files walkman.FileList) walkman.FileList {
+	var known walkman.FileList
+	for _, f := range files {
+		if f.Known {
+			known = append(known, f)
+		}
+	}
+	return known
+}
+
+// Walks roots with wm, or reads the snapshot load instead if it is set.
+// Results are saved to the snapshot save if set, even when the walk was
+// interrupted. Reports whether it was.
+func scan(ctx context.Context, wm *walkman.Walkman, roots []string, load, save string) (walkman.Results, bool) {
+	if load != "" {
+		hashes, err := walkman.LoadResults(load)
+		if err != nil {
+			fatal(err)
+		}
+		return hashes, false
+	}
+
+	hashes, err := wm.WalkContext(ctx, roots...)
+	interrupted := checkInterrupted(err)
+
+	if save != "" {
+		if err := saveResults(hashes, save); err != nil {
+			fatal(err)
+		}
+	}
+	return hashes, interrupted
+}
+
+// Saves hashes to a snapshot at path, in the binary format if its
+// name ends in .gob, otherwise as JSON, compressed if it ends in .gz.
+func saveResults(hashes walkman.Results, path string) error {
+	if filepath.Ext(strings.TrimSuffix(path, ".gz")) == ".gob" {
+		return hashes.SaveBinary(path)
+	}
+	return hashes.Save(path)
+}
+
+// Returns a context cancelled on SIGINT or SIGTERM.
+// Once stop is called, signals behave as usual again.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// Reports whether err means the walk was interrupted by a signal,
+// exiting on any other error.
+func checkInterrupted(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) {
+		log.Println("scan interrupted, results are partial")
+		return true
+	}
+
+	if errors.Is(err, walkman.ErrBudgetExceeded) {
+		log.Println("scan budget exceeded, results are partial")
+		return true
+	}
+
+	fatal(err)
+	return false
+}
+
+// Prints errors met during the walk to stderr.
+// Returns true if there were any.
+func reportErrors(wm *walkman.Walkman) bool {
+	errs := wm.Errors()
+	for _, err := range errs {
+		log.Println(err)
+	}
+	return len(errs) > 0
+}
+
+// Closes ix, an error only means the index could not be compacted
+// or flushed, so it is logged rather than fatal.
+func closeIndex(ix *walkman.Index) {
+	if err := ix.Close(); err != nil {
+		log.Println(err)
+	}
+}
+
+func fatal(v ...interface{}) {
+	log.Print(v...)
+	os.Exit(exitError)
+}
+
+func fatalf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+	os.Exit(exitError)
+}
+
+// Writes s followed by the separator sep.
+func writeRecord(w *bufio.Writer, s string, sep byte) {
+	w.WriteString(s)
+	w.WriteByte(sep)
 }
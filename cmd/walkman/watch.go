@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// flags of the watch subcommand.
+type watchFlags struct {
+	interval time.Duration
+	names    bool
+	index    string
+}
+
+func (opts *watchFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	fs.DurationVar(&opts.interval, "interval", 5*time.Second, "how often to look for changes")
+	fs.BoolVar(&opts.names, "names", false, "match files by name and size instead of content (faster)")
+	fs.StringVar(&opts.index, "index", "", "keep the index `FILE` up to date while watching")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s watch [flags] <dirname>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// walkman watch [flags] DIR
+//
+// Prints a line for every file added to DIR that duplicates one already
+// there, until interrupted:
+//
+//	/downloads/report (1).pdf = /downloads/report.pdf
+func runWatch(args []string) {
+	var opts watchFlags
+
+	fs := opts.flagSet()
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	dir, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		fatalf("can not create absolute path: %v\n", err)
+	}
+
+	options, ix := serviceOptions(opts.names, opts.index)
+	if ix != nil {
+		defer closeIndex(ix)
+	}
+	options = append(options, walkman.WithWatchInterval(opts.interval))
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	events, err := walkman.Watch(ctx, dir, options...)
+	if err != nil {
+		fatal(err)
+	}
+
+	for e := range events {
+		if e.Err != nil {
+			log.Println(e.Err)
+			continue
+		}
+
+		fmt.Printf("%s = %s\n", e.File.Path, e.Duplicates[0].Path)
+	}
+}
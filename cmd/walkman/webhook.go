@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// Time given to a webhook to answer.
+const webhookTimeout = 10 * time.Second
+
+// Webhook events.
+const (
+	eventScanFinished     = "scan_finished"     // a scan completed
+	eventReclaimableAbove = "reclaimable_above" // reclaimable bytes rose to the threshold or above
+	eventReclaimableBelow = "reclaimable_below" // and fell back below it
+)
+
+// Values of a flag that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// flags of the webhooks of daemon and serve.
+type webhookFlags struct {
+	urls      stringList
+	threshold string
+}
+
+func (w *webhookFlags) register(fs *flag.FlagSet) {
+	fs.Var(&w.urls, "webhook", "POST a JSON summary of every completed scan to `URL`, may be repeated")
+	fs.StringVar(&w.threshold, "webhook-threshold", "", "only notify webhooks when reclaimable bytes cross `SIZE`, e.g 10GB, either way")
+}
+
+// Returns the webhooks set by the flags, nil if none.
+func (w webhookFlags) webhooks() *webhooks {
+	if len(w.urls) == 0 {
+		if w.threshold != "" {
+			fatalf("--webhook-threshold needs --webhook\n")
+		}
+		return nil
+	}
+
+	hooks := &webhooks{urls: w.urls, client: &http.Client{Timeout: webhookTimeout}}
+	hooks.host, _ = os.Hostname()
+
+	if w.threshold != "" {
+		n, err := walkman.ParseSize(w.threshold)
+		if err != nil {
+			fatalf("invalid --webhook-threshold: %v\n", err)
+		}
+		hooks.threshold = n
+	}
+	return hooks
+}
+
+// Posts a summary of scans to URLs, e.g of chat or monitoring systems.
+type webhooks struct {
+	urls      []string
+	threshold int64 // notify only when reclaimable bytes cross it, if positive
+	host      string
+	client    *http.Client
+}
+
+// The body posted to webhooks. Text is a summary for chat systems,
+// which show the text field of messages posted to them.
+type webhookPayload struct {
+	Event     string `json:"event"`
+	Host      string `json:"host"`
+	Text      string `json:"text"`
+	Threshold int64  `json:"threshold,omitempty"`
+	scanReport
+}
+
+// Notifies the webhooks of a completed scan, reclaimable bytes being
+// before it. Delivery happens in the background, failures are logged.
+func (h *webhooks) scanned(report scanReport, before int64) {
+	event := eventScanFinished
+	if h.threshold > 0 {
+		above, wasAbove := report.Reclaimable >= h.threshold, before >= h.threshold
+		switch {
+		case above && !wasAbove:
+			event = eventReclaimableAbove
+		case !above && wasAbove:
+			event = eventReclaimableBelow
+		default:
+			return
+		}
+	}
+
+	payload := webhookPayload{Event: event, Host: h.host, Threshold: h.threshold, scanReport: report}
+	payload.Text = fmt.Sprintf("walkman on %s: scan of %s finished, %d duplicate groups, %s reclaimable",
+		h.host, strings.Join(report.Roots, ", "), report.Groups, formatBytes(report.Reclaimable))
+	if event != eventScanFinished {
+		payload.Text += fmt.Sprintf(" (threshold %s)", formatBytes(h.threshold))
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, u := range h.urls {
+		go func(u string) {
+			if err := h.post(u, body); err != nil {
+				log.Printf("webhook %s: %v\n", u, err)
+			}
+		}(u)
+	}
+}
+
+func (h *webhooks) post(u string, body []byte) error {
+	resp, err := h.client.Post(u, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhooksScanned(t *testing.T) {
+	posted := make(chan webhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		posted <- payload
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		threshold     int64
+		before, after int64
+		event         string // posted, none if empty
+	}{
+		{0, 0, 0, eventScanFinished},
+		{0, 500, 100, eventScanFinished},
+		{100, 0, 100, eventReclaimableAbove},
+		{100, 99, 500, eventReclaimableAbove},
+		{100, 100, 99, eventReclaimableBelow},
+		{100, 500, 0, eventReclaimableBelow},
+		{100, 100, 500, ""},
+		{100, 500, 100, ""},
+		{100, 0, 99, ""},
+		{100, 99, 0, ""},
+	}
+
+	for _, tt := range tests {
+		hooks := &webhooks{urls: []string{server.URL}, threshold: tt.threshold, host: "nas", client: server.Client()}
+		hooks.scanned(scanReport{Roots: []string{"/srv"}, Groups: 3, Reclaimable: tt.after}, tt.before)
+
+		// deliveries run in the background, give them a moment when none is expected
+		wait := 5 * time.Second
+		if tt.event == "" {
+			wait = 100 * time.Millisecond
+		}
+
+		select {
+		case payload := <-posted:
+			if payload.Event != tt.event || payload.Host != "nas" || payload.Reclaimable != tt.after || payload.Threshold != tt.threshold || payload.Text == "" {
+				t.Errorf("threshold %d, %d then %d: got %+v, want %q", tt.threshold, tt.before, tt.after, payload, tt.event)
+			}
+		case <-time.After(wait):
+			if tt.event != "" {
+				t.Errorf("threshold %d, %d then %d: expected %s posted, got nothing", tt.threshold, tt.before, tt.after, tt.event)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package walkman
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ColdDir holds the files of a directory left alone for long,
+// see Results.ColdDirs.
+type ColdDir struct {
+	Dir   string
+	Files FileList // sorted by path
+	Size  int64    // bytes of Files
+}
+
+// Reports the files not modified since cutoff, grouped by the directory
+// they are in, largest first, e.g to find what could be archived.
+//
+// With accessed, files read since cutoff are left out too. Access times
+// are only known for local files, and many file systems are mounted to
+// update them rarely (relatime) or never (noatime); files without one
+// are judged by their modification time alone.
+//
+// Members of archives, links and files of mounted file systems are
+// left out.
+func (hashes Results) ColdDirs(cutoff time.Time, accessed bool) []ColdDir {
+	dirs := make(map[string]*ColdDir)
+
+	for hash, files := range hashes {
+		alg := algorithmOf(hash)
+		if alg == AlgorithmLink || alg == AlgorithmDeadLink {
+			continue
+		}
+
+		for _, f := range files {
+			if f.Archive != "" || isRemote(f.Path) || f.Stats.IsDir() {
+				continue
+			}
+
+			if !f.Stats.ModTime().Before(cutoff) || (accessed && !f.Accessed.IsZero() && !f.Accessed.Before(cutoff)) {
+				continue
+			}
+
+			dir := filepath.Dir(f.Path)
+			if dirs[dir] == nil {
+				dirs[dir] = &ColdDir{Dir: dir}
+			}
+			dirs[dir].Files = append(dirs[dir].Files, f)
+			dirs[dir].Size += f.Stats.Size()
+		}
+	}
+
+	cold := make([]ColdDir, 0, len(dirs))
+	for _, d := range dirs {
+		sort.Slice(d.Files, func(i, j int) bool { return d.Files[i].Path < d.Files[j].Path })
+		cold = append(cold, *d)
+	}
+
+	sort.Slice(cold, func(i, j int) bool {
+		if cold[i].Size != cold[j].Size {
+			return cold[i].Size > cold[j].Size
+		}
+		return cold[i].Dir < cold[j].Dir
+	})
+	return cold
+}
+
+// Returns the last access of the file described by info, zero if unknown.
+func accessTime(info fs.FileInfo) time.Time {
+	if info == nil {
+		return time.Time{}
+	}
+	return sysAccessTime(info)
+}
@@ -0,0 +1,47 @@
+package walkman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestColdDirs(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().AddDate(-2, 0, 0)
+
+	writeFile(t, dir, "archive/2019.tar", "old taxes", old)
+	writeFile(t, dir, "archive/2020.tar", "taxes", old)
+	read := writeFile(t, dir, "docs/manual.pdf", "read often", old)
+	writeFile(t, dir, "docs/draft.txt", "new", time.Now())
+
+	// read yesterday, written long ago
+	if err := os.Chtimes(read.Path, time.Now().AddDate(0, 0, -1), old); err != nil {
+		t.Fatal(err)
+	}
+
+	hashes, err := New().Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cutoff := time.Now().AddDate(-1, 0, 0)
+	cold := hashes.ColdDirs(cutoff, false)
+	if len(cold) != 2 || cold[0].Dir != filepath.Join(dir, "archive") || cold[0].Size != 14 || len(cold[0].Files) != 2 {
+		t.Fatalf("expected archive first with 14 bytes in 2 files, got %+v", cold)
+	}
+
+	if len(cold[1].Files) != 1 || cold[1].Files[0].Path != read.Path {
+		t.Errorf("expected only %s cold in docs, got %v", read.Path, cold[1].Files)
+	}
+
+	byAccess := hashes.ColdDirs(cutoff, true)
+	if accessTime(read.Stats).IsZero() {
+		t.Skip("access times are not known here")
+	}
+
+	if len(byAccess) != 1 || byAccess[0].Dir != filepath.Join(dir, "archive") {
+		t.Errorf("expected %s left out as it was read, got %+v", read.Path, byAccess)
+	}
+}
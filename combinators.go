@@ -0,0 +1,92 @@
+package walkman
+
+import "sort"
+
+// Condition is a filter And, Or and Not combine, knowing whether it is
+// expensive to run, e.g as it reads files or runs another program, so
+// that they run it after the cheap ones, which often settle the outcome
+// before. A PathFilter is a cheap Condition, one marked with Expensive an
+// expensive one, as http.HandlerFunc is an http.Handler. Pass the Match
+// method of a Condition to Filter.
+type Condition interface {
+	Match(file File) bool
+	Expensive() bool
+}
+
+func (f PathFilter) Match(file File) bool { return f(file) }
+func (f PathFilter) Expensive() bool      { return false }
+
+// ExpensiveFilter is a PathFilter marked as expensive, see Expensive.
+type ExpensiveFilter PathFilter
+
+func (f ExpensiveFilter) Match(file File) bool { return f(file) }
+func (f ExpensiveFilter) Expensive() bool      { return true }
+
+// Marks f as expensive, so that And and Or run it after the cheap
+// conditions they are given. Combinations of expensive conditions are
+// expensive too.
+func Expensive(f PathFilter) ExpensiveFilter {
+	return ExpensiveFilter(f)
+}
+
+// Passes files passing every condition, like Filter does with several.
+// Conditions run in order, stopping at the first failing, except that
+// expensive ones run last. No condition passes every file.
+func And(conditions ...Condition) Condition {
+	conditions = cheapFirst(conditions)
+
+	and := func(file File) bool {
+		for _, c := range conditions {
+			if !c.Match(file) {
+				return false
+			}
+		}
+		return true
+	}
+	return costOf(and, conditions...)
+}
+
+// Passes files passing any condition. Conditions run in order, stopping
+// at the first passing, except that expensive ones run last. No condition
+// passes no file.
+func Or(conditions ...Condition) Condition {
+	conditions = cheapFirst(conditions)
+
+	or := func(file File) bool {
+		for _, c := range conditions {
+			if c.Match(file) {
+				return true
+			}
+		}
+		return false
+	}
+	return costOf(or, conditions...)
+}
+
+// Passes files c fails.
+func Not(c Condition) Condition {
+	not := func(file File) bool {
+		return !c.Match(file)
+	}
+	return costOf(not, c)
+}
+
+// Returns f as an expensive Condition if any of conditions is,
+// as a cheap one otherwise.
+func costOf(f PathFilter, conditions ...Condition) Condition {
+	for _, c := range conditions {
+		if c.Expensive() {
+			return Expensive(f)
+		}
+	}
+	return f
+}
+
+// Returns conditions with the expensive ones last, in order otherwise.
+func cheapFirst(conditions []Condition) []Condition {
+	sorted := append([]Condition(nil), conditions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return !sorted[i].Expensive() && sorted[j].Expensive()
+	})
+	return sorted
+}
@@ -0,0 +1,65 @@
+package walkman
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCombinators(t *testing.T) {
+	pdf := PathFilter(func(file File) bool { return strings.HasSuffix(file.Path, ".pdf") })
+	docs := PathFilter(func(file File) bool { return strings.HasPrefix(file.Path, "/docs/") })
+
+	for _, tt := range []struct {
+		filter Condition
+		path   string
+		want   bool
+	}{
+		{And(pdf, docs), "/docs/a.pdf", true},
+		{And(pdf, docs), "/tmp/a.pdf", false},
+		{And(), "/tmp/a.pdf", true},
+		{Or(pdf, docs), "/tmp/a.pdf", true},
+		{Or(pdf, docs), "/tmp/a.txt", false},
+		{Or(), "/tmp/a.pdf", false},
+		{Not(pdf), "/docs/a.pdf", false},
+		{And(docs, Not(pdf)), "/docs/a.txt", true},
+	} {
+		if got := tt.filter.Match(File{Path: tt.path}); got != tt.want {
+			t.Errorf("filter(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExpensiveRunsLast(t *testing.T) {
+	var calls []string
+	track := func(name string, pass bool) PathFilter {
+		return func(File) bool {
+			calls = append(calls, name)
+			return pass
+		}
+	}
+
+	and := And(Expensive(track("slow", true)), track("cheap", false))
+	if and.Match(File{}) {
+		t.Error("expected And to fail")
+	}
+
+	if len(calls) != 1 || calls[0] != "cheap" {
+		t.Errorf("expected only the cheap filter to run, got %v", calls)
+	}
+
+	calls = nil
+	or := Or(Expensive(track("slow", false)), track("cheap", true))
+	if !or.Match(File{}) || len(calls) != 1 {
+		t.Errorf("expected Or to pass on the cheap filter alone, got %v", calls)
+	}
+
+	if !Not(Expensive(track("slow", true))).Expensive() || And(track("cheap", true)).Expensive() {
+		t.Error("expected combinations to be expensive only when made of expensive filters")
+	}
+
+	calls = nil
+	hashes := Results{"md5:a": {{Path: "/a"}, {Path: "/b"}}}
+	if n := len(hashes.Filter(And(Expensive(track("slow", true)), track("cheap", false)).Match)); n != 0 || len(calls) != 2 {
+		t.Errorf("expected Filter to only run the cheap filter, got %d groups and %v", n, calls)
+	}
+}
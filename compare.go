@@ -0,0 +1,77 @@
+package walkman
+
+import "fmt"
+
+// Match holds the copies of one file found on both sides of a Comparison.
+type Match struct {
+	A FileList // copies in the first tree
+	B FileList // copies in the second tree
+}
+
+// Comparison is the outcome of Compare.
+type Comparison struct {
+	OnlyA Results          // files with no counterpart in b
+	OnlyB Results          // files with no counterpart in a
+	Both  map[string]Match // files present in both, keyed by hash
+}
+
+// Compares the results of walking two trees, e.g a directory and its backup.
+//
+// Files are matched by hash only, so a file that was renamed or moved
+// is still found in both trees. For a content based comparison walk
+// both trees with WithContentHash.
+//
+// Files listed without being hashed are left out, e.g those of a size
+// no other file had with WithSizeGrouping, as their keys would match
+// unrelated files of the same size. Walk both trees without it.
+func Compare(a, b Results) Comparison {
+	c := Comparison{
+		OnlyA: make(Results),
+		OnlyB: make(Results),
+		Both:  make(map[string]Match),
+	}
+
+	for hash, files := range a {
+		if listedOnly(algorithmOf(hash)) {
+			continue
+		}
+
+		if other, ok := b[hash]; ok {
+			c.Both[hash] = Match{A: files, B: other}
+		} else {
+			c.OnlyA[hash] = files
+		}
+	}
+
+	for hash, files := range b {
+		if listedOnly(algorithmOf(hash)) {
+			continue
+		}
+
+		if _, ok := a[hash]; !ok {
+			c.OnlyB[hash] = files
+		}
+	}
+
+	return c
+}
+
+// Reports whether both trees hold the same set of files.
+func (c Comparison) Equal() bool {
+	return len(c.OnlyA) == 0 && len(c.OnlyB) == 0
+}
+
+// Adds the files of other to hashes, e.g to look for duplicates across
+// the snapshots of several drives. Fails, leaving hashes as it was, if both
+// hold files hashed by different algorithms, as their hashes would never
+// match.
+func (hashes Results) Merge(other Results) error {
+	if a, b := hashes.Algorithm(), other.Algorithm(); !hashes.unhashed() && !other.unhashed() && a != b {
+		return fmt.Errorf("walkman: can not merge %q results into %q results", b, a)
+	}
+
+	for hash, files := range other {
+		hashes[hash] = append(hashes[hash], files...)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package walkman
+
+import (
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	a := Results{
+		"1": FileList{{Path: "/a/same.txt"}},
+		"2": FileList{{Path: "/a/old.txt"}},
+		"3": FileList{{Path: "/a/moved.txt"}},
+	}
+
+	b := Results{
+		"1": FileList{{Path: "/b/same.txt"}},
+		"3": FileList{{Path: "/b/dir/moved.txt"}},
+		"4": FileList{{Path: "/b/new.txt"}},
+	}
+
+	c := Compare(a, b)
+
+	if len(c.OnlyA) != 1 || c.OnlyA["2"] == nil {
+		t.Errorf("expected only old.txt in a, got %v", c.OnlyA)
+	}
+
+	if len(c.OnlyB) != 1 || c.OnlyB["4"] == nil {
+		t.Errorf("expected only new.txt in b, got %v", c.OnlyB)
+	}
+
+	if len(c.Both) != 2 || c.Both["3"].B[0].Path != "/b/dir/moved.txt" {
+		t.Errorf("expected same.txt and moved.txt in both, got %v", c.Both)
+	}
+
+	if c.Equal() {
+		t.Errorf("trees should not be equal")
+	}
+
+	if !Compare(a, a).Equal() {
+		t.Errorf("a tree should equal itself")
+	}
+}
+
+func TestCompareUnhashed(t *testing.T) {
+	a := Results{"size:3": FileList{{Path: "/a/one.txt"}}}
+	b := Results{"size:3": FileList{{Path: "/b/two.txt"}}}
+
+	if c := Compare(a, b); len(c.Both) != 0 || len(c.OnlyA) != 0 || len(c.OnlyB) != 0 {
+		t.Errorf("expected files listed by size alone to be left out, got %+v", c)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := Results{"md5:1": FileList{{Path: "/a/x.txt"}}}
+	b := Results{
+		"md5:1":  FileList{{Path: "/b/x.txt"}},
+		"size:9": FileList{{Path: "/b/unique.txt"}},
+	}
+
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a["md5:1"]) != 2 || len(a["size:9"]) != 1 || a.Algorithm() != AlgorithmMD5 {
+		t.Errorf("expected x.txt twice and unique.txt, got %v", a)
+	}
+
+	names := Results{"name:x.txt-3": FileList{{Path: "/c/x.txt"}}}
+	if err := a.Merge(names); err == nil {
+		t.Error("expected an error merging name results into md5 results")
+	}
+
+	if len(a) != 2 {
+		t.Errorf("expected a failed merge to leave the results alone, got %v", a)
+	}
+}
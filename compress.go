@@ -0,0 +1,55 @@
+package walkman
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Reports whether files written at path are compressed with gzip, that
+// is whether its name ends in .gz, e.g scan.json.gz.
+func compressed(path string) bool {
+	return filepath.Ext(path) == ".gz"
+}
+
+// Creates the file at path for writing, compressing what is written with
+// gzip if its name ends in .gz, e.g for NDJSONBackend. Lists of files compress
+// very well, usually to a tenth of their size. Closing the writer closes
+// the file.
+func CreateOutput(path string) (io.WriteCloser, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !compressed(path) {
+		return f, nil
+	}
+	return &gzipFile{Writer: gzip.NewWriter(f), file: f}, nil
+}
+
+// A file compressed with gzip while written.
+type gzipFile struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	err := g.Writer.Close()
+	if cerr := g.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Returns a writer compressing to w if path names a compressed file, see
+// compressed, and a function ending the compressed stream.
+func compressTo(w io.Writer, path string) (io.Writer, func() error) {
+	if !compressed(path) {
+		return w, func() error { return nil }
+	}
+
+	gz := gzip.NewWriter(w)
+	return gz, gz.Close
+}
@@ -0,0 +1,89 @@
+package walkman
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompressedSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "same", time.Now())
+	writeFile(t, dir, "b.txt", "same", time.Now())
+
+	hashes, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	for name, save := range map[string]func(string) error{
+		"scan.json.gz": hashes.Save,
+		"scan.gob.gz":  hashes.SaveBinary,
+	} {
+		path := filepath.Join(out, name)
+		if err := save(path); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.HasPrefix(data, gzipMagic) {
+			t.Errorf("%s: expected a gzip stream", name)
+		}
+
+		loaded, err := LoadResults(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if len(loaded) != 1 || len(loaded.ToSlice()) != 2 {
+			t.Errorf("%s: expected a group of 2 files, got %v", name, loaded)
+		}
+	}
+}
+
+func TestCreateOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "files.ndjson.gz")
+
+	w, err := CreateOutput(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := NDJSONBackend(w)
+	if err := backend.Add("md5:00", File{Path: "/a", Stats: snapshotInfo{name: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(data, []byte(`"path":"/a"`)) {
+		t.Errorf("expected the file in the output, got %s", data)
+	}
+}
@@ -0,0 +1,15 @@
+package walkman
+
+import "runtime"
+
+// CPUs the process may use: runtime.GOMAXPROCS(0), lowered to the CPU quota
+// of its cgroup if any. Inside a container limited to 2 CPUs on a host of
+// 64, GOMAXPROCS still reports 64 unless Go picked the quota up itself, and
+// spawning workers for all of them only adds contention and throttling.
+func availableCPUs() int {
+	n := runtime.GOMAXPROCS(0)
+	if quota, ok := cpuQuota(); ok && quota < n {
+		n = quota
+	}
+	return n
+}
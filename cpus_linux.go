@@ -0,0 +1,128 @@
+package walkman
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Where cgroup file systems are mounted.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// Returns the CPU quota of the cgroups of the process rounded up, e.g 2
+// for 1.5 CPUs, and false if none is set.
+func cpuQuota() (int, bool) {
+	cgroups, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return 0, false
+	}
+
+	quota, ok := cgroupQuota(cgroupRoot, cgroups)
+	if !ok {
+		return 0, false
+	}
+	return int(math.Max(1, math.Ceil(quota))), true
+}
+
+// Returns the lowest CPU quota set on the cgroups listed by cgroups, the
+// content of /proc/self/cgroup, or any of their parents, with the cgroup
+// file systems mounted under root. Both cgroup v2 (cpu.max) and v1
+// (cpu.cfs_quota_us) are read, as hybrid systems mount both.
+func cgroupQuota(root string, cgroups []byte) (float64, bool) {
+	quota, found := math.Inf(1), false
+
+	scanner := bufio.NewScanner(bytes.NewReader(cgroups))
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		var mounts []string
+		var read func(dir string) (float64, bool)
+
+		switch controllers := fields[1]; {
+		case fields[0] == "0" && controllers == "":
+			mounts = []string{root, filepath.Join(root, "unified")}
+			read = readCPUMax
+		case hasController(controllers, "cpu"):
+			mounts = []string{filepath.Join(root, controllers), filepath.Join(root, "cpu")}
+			read = readCFSQuota
+		default:
+			continue
+		}
+
+		for _, mount := range mounts {
+			// containers see their own cgroup as the root of the mount
+			for dir := path.Clean(fields[2]); ; dir = path.Dir(dir) {
+				if q, ok := read(filepath.Join(mount, filepath.FromSlash(dir))); ok && q < quota {
+					quota, found = q, true
+				}
+
+				if dir == "/" || dir == "." {
+					break
+				}
+			}
+		}
+	}
+
+	return quota, found
+}
+
+func hasController(list, name string) bool {
+	for _, c := range strings.Split(list, ",") {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads cpu.max of cgroup v2, "max 100000" without a quota.
+func readCPUMax(dir string) (float64, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "cpu.max"))
+	if err != nil {
+		return 0, false
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, false
+	}
+	return ratio(fields[0], fields[1])
+}
+
+// Reads cpu.cfs_quota_us and cpu.cfs_period_us of cgroup v1,
+// the quota is -1 without one.
+func readCFSQuota(dir string) (float64, bool) {
+	quota, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, false
+	}
+
+	period, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, false
+	}
+	return ratio(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// Returns quota/period if both are positive numbers.
+func ratio(quota, period string) (float64, bool) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return float64(q) / float64(p), true
+}
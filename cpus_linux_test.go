@@ -0,0 +1,54 @@
+package walkman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCgroup(t *testing.T, root, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCgroupQuotaV2(t *testing.T) {
+	root := t.TempDir()
+	writeCgroup(t, root, "cpu.max", "max 100000\n")
+	writeCgroup(t, root, "app/cpu.max", "400000 100000\n")
+	writeCgroup(t, root, "app/worker/cpu.max", "150000 100000\n")
+
+	quota, ok := cgroupQuota(root, []byte("0::/app/worker\n"))
+	if !ok || quota != 1.5 {
+		t.Errorf("expected a quota of 1.5, got %v (%v)", quota, ok)
+	}
+
+	// the parent limits the child
+	writeCgroup(t, root, "app/cpu.max", "100000 100000\n")
+	if quota, _ := cgroupQuota(root, []byte("0::/app/worker\n")); quota != 1 {
+		t.Errorf("expected the quota of the parent, got %v", quota)
+	}
+}
+
+func TestCgroupQuotaV1(t *testing.T) {
+	root := t.TempDir()
+	writeCgroup(t, root, "cpu,cpuacct/cpu.cfs_quota_us", "-1\n")
+	writeCgroup(t, root, "cpu,cpuacct/cpu.cfs_period_us", "100000\n")
+
+	cgroups := []byte("4:memory:/\n3:cpu,cpuacct:/\n0::/\n")
+	if _, ok := cgroupQuota(root, cgroups); ok {
+		t.Error("expected no quota")
+	}
+
+	writeCgroup(t, root, "cpu,cpuacct/cpu.cfs_quota_us", "200000\n")
+	if quota, ok := cgroupQuota(root, cgroups); !ok || quota != 2 {
+		t.Errorf("expected a quota of 2, got %v (%v)", quota, ok)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package walkman
+
+// Only Linux has cgroups.
+func cpuQuota() (int, bool) {
+	return 0, false
+}
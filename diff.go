@@ -0,0 +1,102 @@
+package walkman
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Modification is a file whose hash changed between two snapshots.
+type Modification struct {
+	Old     File
+	New     File
+	OldHash string
+	NewHash string
+}
+
+// SnapshotDiff lists what changed between two walks of the same tree.
+// Every list is sorted by path.
+type SnapshotDiff struct {
+	Added    FileList // files only in the new results
+	Removed  FileList // files only in the old results
+	Modified []Modification
+}
+
+// Reports whether nothing changed.
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Compares two walks of the same tree by path, e.g the snapshots
+// of last night and tonight, effectively auditing what changed.
+//
+// Both results must come from the same hash algorithm, otherwise
+// every file would look modified.
+func DiffSnapshots(old, new Results) (SnapshotDiff, error) {
+	var diff SnapshotDiff
+
+	if a, b := old.Algorithm(), new.Algorithm(); len(old) > 0 && len(new) > 0 && a != b {
+		return diff, fmt.Errorf("walkman: can not diff %q results against %q results", a, b)
+	}
+
+	before := byPath(old)
+	after := byPath(new)
+
+	for path, n := range after {
+		o, ok := before[path]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, n.file)
+		case modified(o, n):
+			diff.Modified = append(diff.Modified, Modification{
+				Old:     o.file,
+				New:     n.file,
+				OldHash: o.hash,
+				NewHash: n.hash,
+			})
+		}
+	}
+
+	for path, o := range before {
+		if _, ok := after[path]; !ok {
+			diff.Removed = append(diff.Removed, o.file)
+		}
+	}
+
+	sortFiles(diff.Added)
+	sortFiles(diff.Removed)
+	sort.Slice(diff.Modified, func(i, j int) bool {
+		return diff.Modified[i].New.Path < diff.Modified[j].New.Path
+	})
+
+	return diff, nil
+}
+
+type hashedFile struct {
+	hash string
+	file File
+}
+
+func byPath(hashes Results) map[string]hashedFile {
+	files := make(map[string]hashedFile)
+	for hash, list := range hashes {
+		for _, f := range list {
+			files[f.Path] = hashedFile{hash: hash, file: f}
+		}
+	}
+	return files
+}
+
+// Reports whether a file changed between two walks. Files left unhashed
+// by either, see WithSizeGrouping, are compared by size and modification time.
+func modified(o, n hashedFile) bool {
+	if algorithmOf(o.hash) == AlgorithmSize || algorithmOf(n.hash) == AlgorithmSize {
+		return o.file.Stats.Size() != n.file.Stats.Size() || !o.file.Stats.ModTime().Equal(n.file.Stats.ModTime())
+	}
+	return o.hash != n.hash
+}
+
+func sortFiles(files FileList) {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+}
@@ -0,0 +1,42 @@
+package walkman
+
+import (
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	old := Results{
+		"md5:1": FileList{{Path: "/kept"}, {Path: "/removed"}},
+		"md5:2": FileList{{Path: "/edited"}},
+	}
+
+	new := Results{
+		"md5:1": FileList{{Path: "/kept"}},
+		"md5:3": FileList{{Path: "/edited"}, {Path: "/added"}},
+	}
+
+	diff, err := DiffSnapshots(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff.Added) != 1 || diff.Added[0].Path != "/added" {
+		t.Errorf("expected /added to be added, got %v", diff.Added)
+	}
+
+	if len(diff.Removed) != 1 || diff.Removed[0].Path != "/removed" {
+		t.Errorf("expected /removed to be removed, got %v", diff.Removed)
+	}
+
+	if len(diff.Modified) != 1 || diff.Modified[0].NewHash != "md5:3" {
+		t.Errorf("expected /edited to be modified, got %v", diff.Modified)
+	}
+
+	if diff, _ := DiffSnapshots(old, old); !diff.Empty() {
+		t.Errorf("expected no changes between identical results")
+	}
+
+	if _, err := DiffSnapshots(old, Results{"name:x-1": FileList{{Path: "/x"}}}); err == nil {
+		t.Errorf("expected results of different algorithms to be refused")
+	}
+}
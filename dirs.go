@@ -0,0 +1,392 @@
+package walkman
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirGroup holds directories with the same files, see Results.DuplicateDirs.
+type DirGroup struct {
+	Hash  string   // of the names and hashes of everything below, e.g dir:d41d8c...
+	Dirs  []string // sorted
+	Size  int64    // bytes of the files below one of them
+	Files int      // files below one of them
+}
+
+// Reports directories whose trees hold the same files under the same
+// names, hashed like a Merkle tree from the hashes of their files and
+// subdirectories. Their own names do not matter, so a backup copy is
+// found whatever it is called. Groups are sorted by the bytes a single
+// copy of each would free, largest first.
+//
+// Only directories below roots, or roots themselves, are considered,
+// as nothing is known of the rest of their parents: pass the roots
+// that were walked. Subdirectories of duplicate directories are only
+// reported when they have copies elsewhere too.
+//
+// What walks leave out, like hidden directories, is not compared, and
+// empty directories only count with WithEmpty. Members of archives,
+// links and files of mounted file systems are left out.
+func (hashes Results) DuplicateDirs(roots ...string) []DirGroup {
+	tree := hashes.dirTree(roots)
+
+	byHash := make(map[string][]string)
+	for _, dir := range tree.bottomUp() {
+		n := tree[dir]
+		if n.files > 0 {
+			byHash[n.hash] = append(byHash[n.hash], dir)
+		}
+	}
+
+	duplicate := make(map[string]bool)
+	for _, dirs := range byHash {
+		if len(dirs) > 1 {
+			for _, dir := range dirs {
+				duplicate[dir] = true
+			}
+		}
+	}
+
+	var groups []DirGroup
+	for hash, dirs := range byHash {
+		if len(dirs) < 2 {
+			continue
+		}
+
+		// copies found with their parents already
+		nested := true
+		for _, dir := range dirs {
+			if _, ok := tree[filepath.Dir(dir)]; !ok || !duplicate[filepath.Dir(dir)] {
+				nested = false
+			}
+		}
+
+		if nested {
+			continue
+		}
+
+		sort.Strings(dirs)
+		n := tree[dirs[0]]
+		groups = append(groups, DirGroup{Hash: hash, Dirs: dirs, Size: n.size, Files: n.files})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		a := groups[i].Size * int64(len(groups[i].Dirs)-1)
+		b := groups[j].Size * int64(len(groups[j].Dirs)-1)
+		if a != b {
+			return a > b
+		}
+		return groups[i].Hash < groups[j].Hash
+	})
+	return groups
+}
+
+// Directories rebuilt from the paths of results, by path.
+type dirTree map[string]*dirNode
+
+type dirNode struct {
+	entries []dirEntry // files and subdirectories right inside
+	size    int64      // bytes of the files below
+	files   int        // files below
+	hash    string     // of entries, see dirTree.bottomUp
+}
+
+// A file or subdirectory by name, with its hash.
+type dirEntry struct {
+	name string
+	hash string
+	dir  string // path of subdirectories, whose hash is not known yet
+}
+
+// Rebuilds the directories below roots from the files of hashes.
+func (hashes Results) dirTree(roots []string) dirTree {
+	tree := make(dirTree)
+
+	for hash, files := range hashes {
+		alg := algorithmOf(hash)
+		if alg == AlgorithmLink || alg == AlgorithmDeadLink {
+			continue
+		}
+
+		for _, f := range files {
+			if f.Archive != "" || isRemote(f.Path) {
+				continue
+			}
+
+			root := rootBelow(f.Path, roots)
+			if root == "" {
+				continue
+			}
+
+			path := filepath.Clean(f.Path)
+			if f.Stats.IsDir() {
+				tree.node(path, root) // empty, see WithEmpty
+				continue
+			}
+
+			if path == root {
+				continue // a file given as root
+			}
+
+			// every empty file has a hash of its own
+			h := hash
+			if alg == AlgorithmEmpty {
+				h = AlgorithmEmpty
+			}
+
+			dir := filepath.Dir(path)
+			n := tree.node(dir, root)
+			n.entries = append(n.entries, dirEntry{name: filepath.Base(path), hash: h})
+
+			for {
+				n.size += f.Stats.Size()
+				n.files++
+
+				if dir == root {
+					break
+				}
+				dir = filepath.Dir(dir)
+				n = tree[dir]
+			}
+		}
+	}
+	return tree
+}
+
+// Returns the node of dir, adding it and its parents up to root.
+func (tree dirTree) node(dir, root string) *dirNode {
+	n, ok := tree[dir]
+	if ok {
+		return n
+	}
+
+	n = new(dirNode)
+	tree[dir] = n
+
+	if dir != root {
+		parent := tree.node(filepath.Dir(dir), root)
+		parent.entries = append(parent.entries, dirEntry{name: filepath.Base(dir), dir: dir})
+	}
+	return n
+}
+
+// Hashes every directory from its entries, subdirectories first, and
+// returns their paths in that order.
+func (tree dirTree) bottomUp() []string {
+	dirs := make([]string, 0, len(tree))
+	for dir := range tree {
+		dirs = append(dirs, dir)
+	}
+
+	sep := string(filepath.Separator)
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], sep) > strings.Count(dirs[j], sep)
+	})
+
+	for _, dir := range dirs {
+		n := tree[dir]
+		for i, e := range n.entries {
+			if e.dir != "" {
+				n.entries[i].hash = tree[e.dir].hash
+			}
+		}
+
+		sort.Slice(n.entries, func(i, j int) bool { return n.entries[i].name < n.entries[j].name })
+
+		h := md5.New()
+		for _, e := range n.entries {
+			fmt.Fprintf(h, "%s\x00%s\n", e.name, e.hash)
+		}
+		n.hash = "dir:" + hex.EncodeToString(h.Sum(nil))
+	}
+	return dirs
+}
+
+// Returns the root of roots path lies below, cleaned, or an empty
+// string if there is none.
+func rootBelow(path string, roots []string) string {
+	for _, root := range roots {
+		if IsUnder(path, root) {
+			return filepath.Clean(root)
+		}
+	}
+	return ""
+}
+
+// DirOverlap is a pair of directories sharing files, see
+// Results.OverlappingDirs.
+type DirOverlap struct {
+	A, B   string // sorted
+	SizeA  int64  // bytes of the distinct files below A
+	SizeB  int64  // bytes of the distinct files below B
+	Shared int64  // bytes of the files below both
+}
+
+// Returns the share of the larger directory found in the other one.
+func (o DirOverlap) Ratio() float64 {
+	if size := max(o.SizeA, o.SizeB); size > 0 {
+		return float64(o.Shared) / float64(size)
+	}
+	return 0
+}
+
+// Returns the key of the pair of directories a and b.
+func dirPair(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// Reports pairs of directories sharing at least share (between 0 and 1)
+// of their bytes, by the hashes of the files below them, e.g a backup
+// that was never finished or copies of a project that went their own
+// ways. Pairs are sorted by the bytes they share, largest first.
+//
+// Directories are considered as by DuplicateDirs. A pair is left out
+// when their parents, or the parent of either and the other, make a
+// pair too. Copies of a file within the same
+// directory count once.
+//
+// Every directory holding a copy of a file is paired with every other,
+// so files with many copies, like licenses found in every project, make
+// it expensive; filter them out first if need be.
+func (hashes Results) OverlappingDirs(share float64, roots ...string) []DirOverlap {
+	contents := make(map[string]map[string]int64) // hashes below each directory, with their size
+
+	for hash, files := range hashes {
+		// files of a unique size count, but are never shared
+		if alg := algorithmOf(hash); alg != AlgorithmSize && listedOnly(alg) {
+			continue
+		}
+
+		for _, f := range files {
+			if f.Archive != "" || isRemote(f.Path) {
+				continue
+			}
+
+			root := rootBelow(f.Path, roots)
+			path := filepath.Clean(f.Path)
+			if root == "" || path == root {
+				continue
+			}
+
+			for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+				if contents[dir] == nil {
+					contents[dir] = make(map[string]int64)
+				}
+				contents[dir][hash] = f.Stats.Size()
+
+				if dir == root {
+					break
+				}
+			}
+		}
+	}
+
+	holders := make(map[string][]string) // directories by hash below them
+	sizes := make(map[string]int64)
+	for dir, below := range contents {
+		for hash, size := range below {
+			holders[hash] = append(holders[hash], dir)
+			sizes[dir] += size
+		}
+	}
+
+	shared := make(map[[2]string]int64)
+	for hash, dirs := range holders {
+		sort.Strings(dirs)
+
+		for i, a := range dirs {
+			for _, b := range dirs[i+1:] {
+				if !IsUnder(a, b) && !IsUnder(b, a) {
+					shared[[2]string{a, b}] += contents[a][hash]
+				}
+			}
+		}
+	}
+
+	found := make(map[[2]string]DirOverlap)
+	for pair, bytes := range shared {
+		o := DirOverlap{A: pair[0], B: pair[1], SizeA: sizes[pair[0]], SizeB: sizes[pair[1]], Shared: bytes}
+		if o.Ratio() >= share {
+			found[pair] = o
+		}
+	}
+
+	var overlaps []DirOverlap
+	for _, o := range found {
+		a, b := filepath.Dir(o.A), filepath.Dir(o.B)
+		outer := false
+		for _, pair := range [][2]string{dirPair(a, b), dirPair(a, o.B), dirPair(o.A, b)} {
+			_, ok := found[pair]
+			outer = outer || ok
+		}
+
+		if !outer {
+			overlaps = append(overlaps, o)
+		}
+	}
+
+	sort.Slice(overlaps, func(i, j int) bool {
+		if overlaps[i].Shared != overlaps[j].Shared {
+			return overlaps[i].Shared > overlaps[j].Shared
+		}
+		if overlaps[i].A != overlaps[j].A {
+			return overlaps[i].A < overlaps[j].A
+		}
+		return overlaps[i].B < overlaps[j].B
+	})
+	return overlaps
+}
+
+// Returns the bytes of the files below every directory, like du(1)
+// with --apparent-size: copies count as often as they are found.
+// Empty directories found with WithEmpty have a size of 0.
+//
+// With roots, only directories below them, or roots themselves, are
+// sized, otherwise every parent of the files is. Members of archives,
+// links and files of mounted file systems are left out.
+func (hashes Results) DirSizes(roots ...string) map[string]int64 {
+	sizes := make(map[string]int64)
+
+	for hash, files := range hashes {
+		alg := algorithmOf(hash)
+		if alg == AlgorithmLink || alg == AlgorithmDeadLink {
+			continue
+		}
+
+		for _, f := range files {
+			if f.Archive != "" || isRemote(f.Path) {
+				continue
+			}
+
+			path := filepath.Clean(f.Path)
+			root := rootBelow(path, roots)
+			if len(roots) > 0 && root == "" {
+				continue
+			}
+
+			dir, size := path, int64(0)
+			if !f.Stats.IsDir() {
+				if path == root {
+					continue // a file given as root
+				}
+				dir, size = filepath.Dir(path), f.Stats.Size()
+			}
+
+			for ; ; dir = filepath.Dir(dir) {
+				sizes[dir] += size
+
+				if dir == root || filepath.Dir(dir) == dir {
+					break
+				}
+			}
+		}
+	}
+	return sizes
+}
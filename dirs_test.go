@@ -0,0 +1,133 @@
+package walkman
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDuplicateDirs(t *testing.T) {
+	dir := t.TempDir()
+	for _, copy := range []string{"photos", "backup/photos-old", "usb/2021"} {
+		writeFile(t, dir, copy+"/a.jpg", "sunset", time.Now())
+		writeFile(t, dir, copy+"/trip/b.jpg", "beach", time.Now())
+	}
+
+	// the same files under other names, and a copy with one more file
+	writeFile(t, dir, "renamed/x.jpg", "sunset", time.Now())
+	writeFile(t, dir, "renamed/trip/b.jpg", "beach", time.Now())
+	writeFile(t, dir, "more/a.jpg", "sunset", time.Now())
+	writeFile(t, dir, "more/trip/b.jpg", "beach", time.Now())
+	writeFile(t, dir, "more/c.jpg", "cliffs", time.Now())
+
+	hashes, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := hashes.DuplicateDirs(dir)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups of directories, got %+v", groups)
+	}
+
+	join := func(names ...string) []string {
+		for i, name := range names {
+			names[i] = filepath.Join(dir, name)
+		}
+		return names
+	}
+
+	// the trips of renamed and more have copies of their own, the
+	// trips of photos and its copies are left to their parents
+	if want := join("backup/photos-old", "photos", "usb/2021"); !reflect.DeepEqual(groups[0].Dirs, want) {
+		t.Errorf("expected %v first, got %v", want, groups[0].Dirs)
+	}
+
+	if groups[0].Files != 2 || groups[0].Size != int64(len("sunset")+len("beach")) {
+		t.Errorf("expected 2 files of 11 bytes, got %d of %d", groups[0].Files, groups[0].Size)
+	}
+
+	if want := join("backup/photos-old/trip", "more/trip", "photos/trip", "renamed/trip", "usb/2021/trip"); !reflect.DeepEqual(groups[1].Dirs, want) {
+		t.Errorf("expected %v, got %v", want, groups[1].Dirs)
+	}
+
+	// directories outside of the roots are left out
+	if groups := hashes.DuplicateDirs(filepath.Join(dir, "backup"), filepath.Join(dir, "usb")); len(groups) != 1 ||
+		!reflect.DeepEqual(groups[0].Dirs, join("backup/photos-old", "usb/2021")) {
+		t.Errorf("expected backup/photos-old and usb/2021, got %+v", groups)
+	}
+}
+
+func TestOverlappingDirs(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{"a.raw", "b.raw", "c.raw", "d.raw"} {
+		content := string(rune('a'+i)) + "0123456789"
+		writeFile(t, dir, "project/"+name, content, time.Now())
+
+		// a backup that stopped halfway and an unrelated copy of one file
+		if i < 3 {
+			writeFile(t, dir, "backup/project/"+name, content, time.Now())
+		}
+		if i == 0 {
+			writeFile(t, dir, "misc/"+name, content, time.Now())
+		}
+	}
+	writeFile(t, dir, "misc/notes.txt", "a long text that nothing else has", time.Now())
+
+	hashes, err := New(WithContentHash(), WithSizeGrouping()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overlaps := hashes.OverlappingDirs(0.5, dir)
+	if len(overlaps) != 1 {
+		t.Fatalf("expected a single pair, got %+v", overlaps)
+	}
+
+	// backup holds nothing else, backup/project is left to it
+	o := overlaps[0]
+	if o.A != filepath.Join(dir, "backup") || o.B != filepath.Join(dir, "project") {
+		t.Errorf("expected backup and project, got %s and %s", o.A, o.B)
+	}
+
+	if o.Shared != 33 || o.SizeA != 33 || o.SizeB != 44 || o.Ratio() != 0.75 {
+		t.Errorf("expected 33 of 44 bytes shared, got %+v", o)
+	}
+
+	if overlaps := hashes.OverlappingDirs(0.1, dir); len(overlaps) != 3 {
+		t.Errorf("expected misc paired with both projects too, got %+v", overlaps)
+	}
+}
+
+func TestDirSizes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "12345", time.Now())
+	writeFile(t, dir, "sub/b.txt", "123", time.Now())
+	writeFile(t, dir, "sub/deeper/c.txt", "12", time.Now())
+	writeFile(t, dir, "sub/deeper/copy.txt", "12", time.Now())
+	if err := os.Mkdir(filepath.Join(dir, "vacant"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	hashes, err := New(WithEmpty()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int64{
+		dir:                              12,
+		filepath.Join(dir, "sub"):        7,
+		filepath.Join(dir, "sub/deeper"): 4,
+		filepath.Join(dir, "vacant"):     0,
+	}
+
+	if sizes := hashes.DirSizes(dir); !reflect.DeepEqual(sizes, want) {
+		t.Errorf("expected %v, got %v", want, sizes)
+	}
+
+	if sizes := hashes.DirSizes(); sizes[filepath.Dir(dir)] != 12 {
+		t.Errorf("expected the parents of the files sized without roots, got %v", sizes)
+	}
+}
@@ -0,0 +1,56 @@
+package walkman
+
+import (
+	"io/fs"
+	"sort"
+)
+
+// List empty files and directories too, see Results.EmptyFiles and
+// Results.EmptyDirs, e.g to clean them up. They are never duplicates
+// of one another: each is kept in a group of its own, keyed by its
+// path, e.g empty:/home/me/notes.txt.
+//
+// Directories are empty when they hold no entry at all, not even
+// hidden or skipped ones.
+func WithEmpty() Option {
+	return func(w *Walkman) {
+		w.config.empty = true
+	}
+}
+
+// Lists an empty file or directory, see WithEmpty.
+func (wm *Walkman) addEmpty(path string, fi fs.FileInfo) {
+	wm.pairs <- pair{hash: AlgorithmEmpty + ":" + path, path: path, info: fi}
+}
+
+// Returns the empty files found with WithEmpty, sorted by path.
+func (hashes Results) EmptyFiles() FileList {
+	return hashes.listed(func(alg string, f File) bool {
+		return alg == AlgorithmEmpty && !f.Stats.IsDir()
+	})
+}
+
+// Returns the empty directories found with WithEmpty, sorted by path.
+func (hashes Results) EmptyDirs() FileList {
+	return hashes.listed(func(alg string, f File) bool {
+		return alg == AlgorithmEmpty && f.Stats.IsDir()
+	})
+}
+
+// Returns the files for which keep returns true, given the algorithm
+// of their hash, sorted by path.
+func (hashes Results) listed(keep func(alg string, f File) bool) FileList {
+	var found FileList
+	for hash, files := range hashes {
+		alg := algorithmOf(hash)
+
+		for _, f := range files {
+			if keep(alg, f) {
+				found = append(found, f)
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Path < found[j].Path })
+	return found
+}
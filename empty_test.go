@@ -0,0 +1,58 @@
+package walkman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithEmpty(t *testing.T) {
+	dir := t.TempDir()
+	empty := writeFile(t, dir, "sub/empty.txt", "", time.Now())
+	other := writeFile(t, dir, "other.txt", "", time.Now())
+	writeFile(t, dir, "full/a.txt", "a", time.Now())
+	writeFile(t, dir, "full/b.txt", "a", time.Now())
+	writeFile(t, dir, "full/.DS_Store", "", time.Now())
+
+	for _, name := range []string{"vacant", "nested/vacant", "hidden/.git"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hashes, err := New(WithEmpty()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := hashes.EmptyFiles()
+	if len(files) != 2 || files[0].Path != other.Path || files[1].Path != empty.Path {
+		t.Errorf("expected %s and %s empty, got %v", other.Path, empty.Path, files)
+	}
+
+	// nested and hidden hold a directory, .git is skipped
+	dirs := hashes.EmptyDirs()
+	if len(dirs) != 2 || dirs[0].Path != filepath.Join(dir, "nested/vacant") || dirs[1].Path != filepath.Join(dir, "vacant") {
+		t.Errorf("expected nested/vacant and vacant empty, got %v", dirs)
+	}
+
+	for hash, files := range hashes {
+		if algorithmOf(hash) == AlgorithmEmpty && len(files) != 1 {
+			t.Errorf("expected a group of its own for every empty entry, got %v", files)
+		}
+	}
+
+	if alg := hashes.Algorithm(); alg != AlgorithmName {
+		t.Errorf("expected empty entries left out of the algorithm, got %q", alg)
+	}
+
+	hashes, err = New().Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashes) != 2 || len(hashes.EmptyFiles()) != 0 || len(hashes.EmptyDirs()) != 0 {
+		t.Errorf("expected only a.txt and b.txt without WithEmpty, got %v", hashes)
+	}
+}
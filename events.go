@@ -0,0 +1,72 @@
+package walkman
+
+import (
+	"strconv"
+	"time"
+)
+
+// EventKind tells what an Event is about.
+type EventKind int
+
+const (
+	EventEnterDir   EventKind = iota + 1 // a directory is being read
+	EventSkipDir                         // a hidden or skip listed directory is left out
+	EventFileQueued                      // a file waits for a worker to hash it
+	EventFileHashed                      // a file was hashed, or its hash reused
+	EventError                           // a file or directory was left out, see Errors
+	EventFileKnown                       // the hash of a file is known, see WithKnownHashes
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventEnterDir:
+		return "EnterDir"
+	case EventSkipDir:
+		return "SkipDir"
+	case EventFileQueued:
+		return "FileQueued"
+	case EventFileHashed:
+		return "FileHashed"
+	case EventError:
+		return "Error"
+	case EventFileKnown:
+		return "FileKnown"
+	}
+	return "EventKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Event is something a walk did, see WithEventSink.
+type Event struct {
+	Kind   EventKind
+	Time   time.Time
+	Path   string // of the directory or file, empty for errors that do not name one
+	Hash   string // set for EventFileHashed and EventFileKnown
+	Reused bool   // the hash of EventFileHashed comes from a previous walk or an index
+	Err    error  // set for EventError
+}
+
+// Calls sink with an Event for every directory entered or skipped and
+// every file queued and hashed during walks, and for errors, e.g to
+// show progress in a user interface or keep an audit log.
+//
+// Calls are never concurrent but come from the goroutines of the walk,
+// which waits for sink to return: it must be quick, or hand events
+// over to another goroutine.
+func WithEventSink(sink func(Event)) Option {
+	return func(w *Walkman) {
+		w.sink = sink
+	}
+}
+
+// Sends e to the event sink, if any.
+func (wm *Walkman) emit(e Event) {
+	if wm.sink == nil {
+		return
+	}
+
+	e.Time = time.Now()
+
+	wm.sinkMu.Lock()
+	defer wm.sinkMu.Unlock()
+	wm.sink(e)
+}
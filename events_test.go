@@ -0,0 +1,74 @@
+package walkman
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithEventSink(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "docs/a.txt", "a", time.Now())
+	bad := writeFile(t, dir, "bad.txt", "b", time.Now())
+	writeFile(t, dir, ".git/HEAD", "ref: refs/heads/master", time.Now())
+
+	errBroken := errors.New("broken")
+	hasher := func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		if path == bad.Path {
+			return "", &fs.PathError{Op: "read", Path: path, Err: errBroken}
+		}
+		return NameSizeHasher(path, info, open)
+	}
+
+	events := []Event{}
+	wm := New(WithHasher(hasher), WithEventSink(func(e Event) { events = append(events, e) }))
+	if _, err := wm.Walk(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]int{} // index of the first event of each kind and path
+	for i, e := range events {
+		if e.Time.IsZero() {
+			t.Errorf("%v event without a time", e.Kind)
+		}
+
+		key := e.Kind.String() + " " + e.Path
+		if _, ok := seen[key]; !ok {
+			seen[key] = i
+		}
+
+		// names are folded on case-insensitive file systems
+		if e.Kind == EventFileHashed && e.Hash != "name:a.txt-1" && e.Hash != "iname:a.txt-1" {
+			t.Errorf("FileHashed %s with hash %q", e.Path, e.Hash)
+		}
+
+		if e.Kind == EventError && !errors.Is(e.Err, errBroken) {
+			t.Errorf("Error event with %v, want %v", e.Err, errBroken)
+		}
+	}
+
+	for _, key := range []string{
+		"EnterDir " + dir,
+		"EnterDir " + filepath.Join(dir, "docs"),
+		"SkipDir " + filepath.Join(dir, ".git"),
+		"FileQueued " + a.Path,
+		"FileHashed " + a.Path,
+		"FileQueued " + bad.Path,
+		"Error " + bad.Path,
+	} {
+		if _, ok := seen[key]; !ok {
+			t.Errorf("expected a %s event, got %v", key, events)
+		}
+	}
+
+	if seen["FileHashed "+a.Path] < seen["FileQueued "+a.Path] {
+		t.Errorf("%s hashed before it was queued", a.Path)
+	}
+
+	if _, ok := seen["FileHashed "+bad.Path]; ok {
+		t.Errorf("%s reported as hashed", bad.Path)
+	}
+}
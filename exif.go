@@ -0,0 +1,241 @@
+package walkman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Largest part of a TIFF file read looking for its tags,
+// raw photos can be huge but keep their tags at the start.
+const maxTIFFHead = 1 << 20
+
+// Extractor of the capture date, camera and dimensions of JPEG and
+// TIFF images (including most raw formats) from their EXIF tags.
+//
+//	wm := walkman.New(walkman.WithMetadata(walkman.ExtractEXIF))
+func ExtractEXIF(path string, open func() (io.ReadCloser, error), meta *Meta) error {
+	file, err := open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	magic, err := r.Peek(4)
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case magic[0] == 0xff && magic[1] == 0xd8:
+		err = readJPEG(r, meta)
+	case string(magic) == "II*\x00" || string(magic) == "MM\x00*":
+		var head []byte
+		if head, err = io.ReadAll(io.LimitReader(r, maxTIFFHead)); err == nil {
+			err = readTIFF(head, meta)
+		}
+	}
+
+	// malformed tags only mean there is nothing to learn
+	if errors.Is(err, errBadEXIF) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+var errBadEXIF = errors.New("walkman: malformed EXIF")
+
+// Reads the segments of a JPEG up to its image data, looking
+// for the EXIF tags and the frame header with the dimensions.
+func readJPEG(r *bufio.Reader, meta *Meta) error {
+	r.Discard(2) // start of image
+
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return errBadEXIF
+		}
+
+		if marker[0] != 0xff {
+			return errBadEXIF
+		}
+
+		kind := marker[1]
+		size := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if size < 0 {
+			return errBadEXIF
+		}
+
+		switch {
+		case kind == 0xda: // start of scan, no more headers
+			return nil
+		case kind == 0xe1: // APP1, EXIF or XMP
+			segment := make([]byte, size)
+			if _, err := io.ReadFull(r, segment); err != nil {
+				return errBadEXIF
+			}
+
+			if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				// dimensions of the frame header win over those of the tags
+				width, height := meta.Width, meta.Height
+				if err := readTIFF(segment[6:], meta); err != nil {
+					return err
+				}
+
+				if width != 0 {
+					meta.Width, meta.Height = width, height
+				}
+			}
+		case isStartOfFrame(kind):
+			frame := make([]byte, size)
+			if _, err := io.ReadFull(r, frame); err != nil || size < 5 {
+				return errBadEXIF
+			}
+			meta.Height = int(binary.BigEndian.Uint16(frame[1:]))
+			meta.Width = int(binary.BigEndian.Uint16(frame[3:]))
+		default:
+			if _, err := r.Discard(size); err != nil {
+				return errBadEXIF
+			}
+		}
+	}
+}
+
+// Reports whether a JPEG marker starts a frame, SOF0 to SOF15
+// except for DHT, JPG and DAC which share the range.
+func isStartOfFrame(kind byte) bool {
+	return kind >= 0xc0 && kind <= 0xcf && kind != 0xc4 && kind != 0xc8 && kind != 0xcc
+}
+
+// TIFF tags of interest.
+const (
+	tagImageWidth       = 0x0100
+	tagImageLength      = 0x0101
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+	tagPixelXDimension  = 0xa002
+	tagPixelYDimension  = 0xa003
+)
+
+// Reads the tags of a TIFF structure, as found in
+// TIFF files and the APP1 segment of JPEGs.
+func readTIFF(data []byte, meta *Meta) error {
+	if len(data) < 8 {
+		return errBadEXIF
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return errBadEXIF
+	}
+
+	t := tiff{data: data, order: order, tags: map[uint16]tiffValue{}}
+	if err := t.readIFD(order.Uint32(data[4:])); err != nil {
+		return err
+	}
+
+	if v, ok := t.tags[tagExifIFD]; ok {
+		if err := t.readIFD(v.number); err != nil {
+			return err
+		}
+	}
+
+	camera := strings.TrimSpace(t.tags[tagMake].text)
+	if model := strings.TrimSpace(t.tags[tagModel].text); model != "" {
+		// models usually repeat the make, e.g Canon EOS 80D
+		if camera == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(camera)) {
+			camera = model
+		} else {
+			camera += " " + model
+		}
+	}
+	if camera != "" {
+		meta.Camera = camera
+	}
+
+	for _, tag := range []uint16{tagDateTimeOriginal, tagDateTime} {
+		if taken, err := time.ParseInLocation("2006:01:02 15:04:05", t.tags[tag].text, time.Local); err == nil {
+			meta.Taken = taken
+			break
+		}
+	}
+
+	width, height := t.tags[tagPixelXDimension].number, t.tags[tagPixelYDimension].number
+	if width == 0 || height == 0 {
+		width, height = t.tags[tagImageWidth].number, t.tags[tagImageLength].number
+	}
+
+	if width != 0 && height != 0 {
+		meta.Width, meta.Height = int(width), int(height)
+	}
+	return nil
+}
+
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+	tags  map[uint16]tiffValue
+}
+
+// Value of a tag, text for ASCII tags and number for numeric ones.
+type tiffValue struct {
+	text   string
+	number uint32
+}
+
+// Reads the entries of the IFD at offset into t.tags.
+func (t *tiff) readIFD(offset uint32) error {
+	if uint64(offset)+2 > uint64(len(t.data)) {
+		return errBadEXIF
+	}
+
+	count := int(t.order.Uint16(t.data[offset:]))
+	entries := t.data[offset+2:]
+	if len(entries) < 12*count {
+		return errBadEXIF
+	}
+
+	for i := 0; i < count; i++ {
+		e := entries[12*i : 12*i+12]
+		tag, kind, n := t.order.Uint16(e), t.order.Uint16(e[2:]), t.order.Uint32(e[4:])
+
+		switch kind {
+		case 2: // ASCII, NUL terminated
+			value := e[8:12]
+			if n > 4 {
+				start := t.order.Uint32(e[8:])
+				if uint64(start)+uint64(n) > uint64(len(t.data)) {
+					continue
+				}
+				value = t.data[start : start+n]
+			} else {
+				value = value[:n]
+			}
+			t.tags[tag] = tiffValue{text: string(bytes.TrimRight(value, "\x00"))}
+		case 3: // SHORT
+			t.tags[tag] = tiffValue{number: uint32(t.order.Uint16(e[8:]))}
+		case 4: // LONG
+			t.tags[tag] = tiffValue{number: t.order.Uint32(e[8:])}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package walkman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Builds a JPEG holding EXIF tags with the given camera model and
+// capture date, followed by a frame header of width x height.
+func testJPEG(model, taken string, width, height uint16) string {
+	var tiff bytes.Buffer
+	le := binary.LittleEndian
+
+	// header, IFD0 right after it with Model and a pointer to the Exif IFD
+	tiff.WriteString("II*\x00")
+	binary.Write(&tiff, le, uint32(8))
+
+	entry := func(tag, kind uint16, n, value uint32) {
+		binary.Write(&tiff, le, struct {
+			Tag, Kind uint16
+			N, Value  uint32
+		}{tag, kind, n, value})
+	}
+
+	ifd0 := uint32(8)
+	exifIFD := ifd0 + 2 + 2*12 + 4
+	values := exifIFD + 2 + 12 + 4
+
+	binary.Write(&tiff, le, uint16(2))
+	entry(tagModel, 2, uint32(len(model)+1), values)
+	entry(tagExifIFD, 4, 1, exifIFD)
+	binary.Write(&tiff, le, uint32(0))
+
+	binary.Write(&tiff, le, uint16(1))
+	entry(tagDateTimeOriginal, 2, uint32(len(taken)+1), values+uint32(len(model)+1))
+	binary.Write(&tiff, le, uint32(0))
+
+	tiff.WriteString(model + "\x00" + taken + "\x00")
+
+	var jpeg bytes.Buffer
+	jpeg.Write([]byte{0xff, 0xd8})
+
+	app1 := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	jpeg.Write([]byte{0xff, 0xe1})
+	binary.Write(&jpeg, binary.BigEndian, uint16(len(app1)+2))
+	jpeg.Write(app1)
+
+	jpeg.Write([]byte{0xff, 0xc0, 0, 11, 8})
+	binary.Write(&jpeg, binary.BigEndian, []uint16{height, width})
+	jpeg.Write([]byte{1, 1, 0x11, 0})
+
+	jpeg.Write([]byte{0xff, 0xda, 0, 2})
+	jpeg.WriteString(model + taken) // image data, different for every photo
+	return jpeg.String()
+}
+
+func TestExtractEXIF(t *testing.T) {
+	dir := t.TempDir()
+	small := writeFile(t, dir, "small.jpg", testJPEG("Pixel 7", "2021:06:01 10:00:00", 640, 480), time.Now())
+	large := writeFile(t, dir, "large.jpg", testJPEG("Pixel 7 ", "2021:06:01 10:00:00", 4080, 3072), time.Now())
+	writeFile(t, dir, "notes.txt", "no metadata", time.Now())
+
+	hashes, err := New(WithContentHash(), WithMetadata(ExtractEXIF)).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]File{}
+	for _, f := range hashes.ToSlice() {
+		files[filepath.Base(f.Path)] = f
+	}
+
+	want := Meta{
+		Taken:  time.Date(2021, 6, 1, 10, 0, 0, 0, time.Local),
+		Camera: "Pixel 7",
+		Width:  4080,
+		Height: 3072,
+	}
+
+	if m := files["large.jpg"].Meta; m == nil || !m.Taken.Equal(want.Taken) || m.Camera != want.Camera ||
+		m.Width != want.Width || m.Height != want.Height {
+		t.Errorf("Meta = %+v, want %+v", m, want)
+	}
+
+	if m := files["notes.txt"].Meta; m != nil {
+		t.Errorf("expected no metadata for a text file, got %+v", m)
+	}
+
+	// same moment, different content
+	groups := hashes.GroupBy(func(f File) string {
+		if f.Meta == nil {
+			return ""
+		}
+		return "taken:" + f.Meta.Taken.Format(time.RFC3339)
+	})
+
+	if len(groups) != 1 {
+		t.Fatalf("expected a single group of photos, got %v", groups)
+	}
+
+	for _, group := range groups {
+		if k := KeepHighestResolution()(group); group[k].Path != large.Path {
+			t.Errorf("kept %s, want %s over %s", group[k].Path, large.Path, small.Path)
+		}
+	}
+
+	filter, err := ParseFilter(`camera == "Pixel 7" && width < 1000`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filtered := hashes.Filter(filter).ToSlice(); len(filtered) != 1 || filtered[0].Path != small.Path {
+		t.Errorf("expected only %s to match, got %v", small.Path, filtered)
+	}
+}
@@ -0,0 +1,231 @@
+package walkman
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryBackend is a GroupedBackend collecting files in memory like a
+// walk does without one, e.g to gather every file of a walk while also
+// streaming them elsewhere, or the files of several walks.
+type MemoryBackend struct {
+	mu      sync.Mutex
+	results Results
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{results: make(Results)}
+}
+
+func (b *MemoryBackend) Add(hash string, file File) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.results[hash] = append(b.results[hash], file)
+	return nil
+}
+
+func (b *MemoryBackend) Flush() error { return nil }
+func (b *MemoryBackend) Close() error { return nil }
+
+func (b *MemoryBackend) Groups(fn func(hash string, files FileList) error) error {
+	for hash, files := range b.Results() {
+		if err := fn(hash, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the files added so far. The results are shared with the backend.
+func (b *MemoryBackend) Results() Results {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.results
+}
+
+// A ResultBackend writing every file as a line of JSON.
+type ndjsonBackend struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+	out io.Writer
+}
+
+// Returns a ResultBackend writing each file to w as a line of JSON holding
+// its hash along with the fields of a snapshot file, e.g
+//
+//	{"hash":"md5:d41d8c...","path":"/srv/a.txt","size":12,...}
+//
+// Close closes w if it is an io.Closer.
+func NDJSONBackend(w io.Writer) ResultBackend {
+	bw := bufio.NewWriter(w)
+	return &ndjsonBackend{w: bw, enc: json.NewEncoder(bw), out: w}
+}
+
+func (b *ndjsonBackend) Add(hash string, file File) error {
+	type record struct {
+		Hash string `json:"hash"`
+		snapshotFile
+	}
+	return b.enc.Encode(record{Hash: hash, snapshotFile: newSnapshotFile(file)})
+}
+
+func (b *ndjsonBackend) Flush() error {
+	return b.w.Flush()
+}
+
+func (b *ndjsonBackend) Close() error {
+	return flushAndClose(b.Flush, b.out)
+}
+
+// Columns written by CSVBackend.
+var csvHeader = []string{"hash", "path", "size", "mode", "mtime", "archive", "mime"}
+
+// A ResultBackend writing every file as a CSV record.
+type csvBackend struct {
+	w      *csv.Writer
+	out    io.Writer
+	header bool // written yet
+}
+
+// Returns a ResultBackend writing each file to w as a CSV record of its
+// hash, path, size, mode, modification time, archive and content type,
+// after a header naming them. Close closes w if it is an io.Closer.
+func CSVBackend(w io.Writer) ResultBackend {
+	return &csvBackend{w: csv.NewWriter(w), out: w}
+}
+
+func (b *csvBackend) Add(hash string, file File) error {
+	if !b.header {
+		if err := b.w.Write(csvHeader); err != nil {
+			return err
+		}
+		b.header = true
+	}
+
+	return b.w.Write([]string{
+		hash,
+		file.Path,
+		strconv.FormatInt(file.Stats.Size(), 10),
+		file.Stats.Mode().String(),
+		file.Stats.ModTime().Format(time.RFC3339Nano),
+		file.Archive,
+		file.MIME,
+	})
+}
+
+func (b *csvBackend) Flush() error {
+	b.w.Flush()
+	return b.w.Error()
+}
+
+func (b *csvBackend) Close() error {
+	return flushAndClose(b.Flush, b.out)
+}
+
+// Calls flush, then closes w if it is an io.Closer.
+func flushAndClose(flush func() error, w io.Writer) error {
+	err := flush()
+	if c, ok := w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// A ResultBackend inserting files into a table of a database.
+type sqlBackend struct {
+	db      *sql.DB
+	table   string
+	pending [][]interface{} // rows not inserted yet
+}
+
+// Returns a ResultBackend inserting files into table of db, e.g a SQLite
+// database opened with the driver of your choice. The table is created
+// if needed with the columns hash, path, size, mode, mtime (RFC 3339),
+// archive and mime. Rows are inserted in a transaction on Flush.
+//
+// As table is written into the statements as is, it must be a plain
+// identifier: letters, digits and underscores, not starting with a digit.
+// Statements use ? placeholders, as SQLite and MySQL do. Close does not
+// close db.
+func SQLBackend(db *sql.DB, table string) (ResultBackend, error) {
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("walkman: invalid table name %q", table)
+	}
+
+	_, err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	hash TEXT NOT NULL,
+	path TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	mode INTEGER NOT NULL,
+	mtime TEXT NOT NULL,
+	archive TEXT,
+	mime TEXT
+)`, table))
+	if err != nil {
+		return nil, err
+	}
+	return &sqlBackend{db: db, table: table}, nil
+}
+
+// Names SQLBackend accepts for its table.
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (b *sqlBackend) Add(hash string, file File) error {
+	b.pending = append(b.pending, []interface{}{
+		hash,
+		file.Path,
+		file.Stats.Size(),
+		int64(file.Stats.Mode()),
+		file.Stats.ModTime().Format(time.RFC3339Nano),
+		file.Archive,
+		file.MIME,
+	})
+	return nil
+}
+
+func (b *sqlBackend) Flush() error {
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (hash, path, size, mode, mtime, archive, mime) VALUES (?, ?, ?, ?, ?, ?, ?)", b.table))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range b.pending {
+		if _, err := stmt.Exec(row...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	b.pending = nil
+	return nil
+}
+
+func (b *sqlBackend) Close() error {
+	return b.Flush()
+}
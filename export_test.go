@@ -0,0 +1,207 @@
+package walkman
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/csv"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "same", time.Now())
+	writeFile(t, dir, "sub/a.txt", "same", time.Now())
+	writeFile(t, dir, "b.txt", "other", time.Now())
+
+	var buf bytes.Buffer
+	memory := NewMemoryBackend()
+	hashes, err := New(WithContentHash(), WithResultBackend(NDJSONBackend(&buf), memory)).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := memory.Results()
+	a := hashOf(t, got, filepath.Join(dir, "a.txt"))
+	if len(got) != 2 || len(got[a]) != 2 {
+		t.Errorf("expected both copies of a.txt in the backend, got %v", got)
+	}
+
+	if len(hashes) != 1 || len(hashes[a]) != 2 {
+		t.Errorf("expected the duplicates of the backend returned, got %v", hashes)
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Errorf("expected every file written to the other backend too, got %d lines", lines)
+	}
+}
+
+func TestWithResultBackendOnly(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "same", time.Now())
+	writeFile(t, dir, "b.txt", "same", time.Now())
+
+	var buf bytes.Buffer
+	hashes, err := New(WithContentHash(), WithResultBackend(CSVBackend(&buf))).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashes) != 0 {
+		t.Errorf("expected files only written to the backend, got %v", hashes)
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Errorf("expected a header and both files, got %d lines", lines)
+	}
+}
+
+func TestWithResultBackendUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "a", time.Now())
+
+	for name, option := range map[string]Option{
+		"checkpoint":      WithCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"), 0),
+		"duplicates only": WithDuplicatesOnly(),
+	} {
+		_, err := New(WithResultBackend(NewMemoryBackend()), option).Walk(dir)
+		if err == nil {
+			t.Errorf("%s: expected the combination to be rejected", name)
+		}
+	}
+}
+
+func TestCSVBackend(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a,b.txt", "a", time.Now())
+
+	var buf bytes.Buffer
+	backend := CSVBackend(&buf)
+	if err := backend.Add("md5:1", a); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || strings.Join(records[0], ",") != strings.Join(csvHeader, ",") {
+		t.Fatalf("expected a header and a record, got %q", records)
+	}
+
+	if r := records[1]; r[0] != "md5:1" || r[1] != a.Path || r[2] != "1" {
+		t.Errorf("unexpected record %q", r)
+	}
+}
+
+func TestSQLBackend(t *testing.T) {
+	// opened through a connector, as registering a driver twice
+	// panics with -count
+	drv := &recordingDriver{}
+	db := sql.OpenDB(drv)
+	defer db.Close()
+
+	for _, table := range []string{"", "1files", "files; DROP TABLE files", "files\"", "main.files"} {
+		if _, err := SQLBackend(db, table); err == nil {
+			t.Errorf("expected table name %q to be rejected", table)
+		}
+	}
+
+	backend, err := SQLBackend(db, "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.txt", "a", time.Now())
+	b := writeFile(t, dir, "b.txt", "b", time.Now())
+	for _, f := range []File{a, b} {
+		if err := backend.Add("md5:"+f.Path, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+
+	if len(drv.execs) != 3 || !strings.HasPrefix(drv.execs[0].query, "CREATE TABLE IF NOT EXISTS files") {
+		t.Fatalf("expected the table created and 2 rows inserted, got %+v", drv.execs)
+	}
+
+	if row := drv.execs[2]; !strings.HasPrefix(row.query, "INSERT INTO files") || row.args[1] != b.Path || drv.commits != 1 {
+		t.Errorf("expected b.txt inserted in a transaction, got %+v and %d commits", row, drv.commits)
+	}
+}
+
+// A database/sql driver recording the statements executed.
+type recordingDriver struct {
+	mu      sync.Mutex
+	execs   []recordedExec
+	commits int
+}
+
+type recordedExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return recordingConn{d}, nil }
+
+// A driver.Connector, for sql.OpenDB.
+func (d *recordingDriver) Connect(context.Context) (driver.Conn, error) { return recordingConn{d}, nil }
+func (d *recordingDriver) Driver() driver.Driver                        { return d }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{c.d, query}, nil
+}
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { return recordingTx{c.d}, nil }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+
+	s.d.execs = append(s.d.execs, recordedExec{s.query, args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s recordingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+type recordingTx struct{ d *recordingDriver }
+
+func (tx recordingTx) Commit() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+
+	tx.d.commits++
+	return nil
+}
+
+func (tx recordingTx) Rollback() error { return nil }
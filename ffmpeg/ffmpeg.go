@@ -0,0 +1,177 @@
+// Package ffmpeg decodes frames of videos with the ffmpeg and ffprobe
+// commands for walkman.VideoHasher:
+//
+//	hasher := walkman.VideoHasher(ffmpeg.Decoder{}, 10)
+//	wm := walkman.New(walkman.WithHasher(hasher))
+//	hashes, err := wm.Walk("/home/me/Videos")
+//	similar := hashes.SimilarVideos(10)
+//
+// Like walkman, it uses no external Go dependencies, only the commands.
+package ffmpeg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/abiiranathan/walkman"
+)
+
+// Extensions of the files decoded when Decoder.Extensions is nil.
+var Extensions = []string{
+	".3gp", ".avi", ".flv", ".m2ts", ".m4v", ".mkv", ".mov",
+	".mp4", ".mpeg", ".mpg", ".mts", ".ogv", ".ts", ".webm", ".wmv",
+}
+
+// Decoder is a walkman.FrameDecoder running ffmpeg and ffprobe.
+// The zero value finds them in $PATH.
+type Decoder struct {
+	FFmpeg     string   // path of ffmpeg, default "ffmpeg"
+	FFprobe    string   // path of ffprobe, default "ffprobe"
+	Extensions []string // of the files that are videos, default Extensions
+}
+
+// Frames returns n frames spread evenly over the video at path, shrunk
+// to 72x64 pixels which is all perceptual hashes need. Files whose
+// extension is not a video one are not looked at.
+//
+// Videos that are not on the local disk, e.g on a server mounted with
+// walkman.WithFS, are copied to a temporary file first.
+func (d Decoder) Frames(path string, open func() (io.ReadCloser, error), n int) ([]image.Image, error) {
+	if !d.isVideo(path) {
+		return nil, walkman.ErrNotVideo
+	}
+
+	if strings.Contains(path, "://") {
+		local, err := download(path, open)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(local)
+		path = local
+	}
+
+	duration, err := d.duration(path)
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]image.Image, 0, n)
+	for i := 0; i < n; i++ {
+		at := duration * (float64(i) + 0.5) / float64(n)
+
+		frame, err := d.frame(path, at)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+func (d Decoder) isVideo(path string) bool {
+	extensions := d.Extensions
+	if extensions == nil {
+		extensions = Extensions
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the duration of the video at path in seconds.
+func (d Decoder) duration(path string) (float64, error) {
+	out, err := run(command(d.FFprobe, "ffprobe"), "-v", "error",
+		"-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", "--", path)
+	if err != nil {
+		return 0, err
+	}
+
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: unknown duration of %s", path)
+	}
+	return duration, nil
+}
+
+// Returns the frame of the video at path shown at seconds.
+func (d Decoder) frame(path string, seconds float64) (image.Image, error) {
+	out, err := run(command(d.FFmpeg, "ffmpeg"), "-v", "error",
+		"-ss", strconv.FormatFloat(seconds, 'f', 3, 64), "-i", path,
+		"-frames:v", "1", "-vf", "scale=72:64", "-f", "image2pipe", "-vcodec", "png", "-")
+	if err != nil {
+		return nil, err
+	}
+
+	frame, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: no frame at %.3fs of %s", seconds, path)
+	}
+	return frame, nil
+}
+
+func command(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path
+}
+
+// Runs name with args, returning its output or its error
+// messages as an error.
+func run(name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("%s: %s", filepath.Base(name), strings.TrimSpace(stderr.String()))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// Copies the file read through open to a temporary file, keeping
+// the extension of path which ffmpeg may rely on.
+func download(path string, open func() (io.ReadCloser, error)) (string, error) {
+	src, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "walkman-*"+filepath.Ext(path))
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
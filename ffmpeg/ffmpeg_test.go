@@ -0,0 +1,43 @@
+package ffmpeg
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/abiiranathan/walkman"
+)
+
+func TestFrames(t *testing.T) {
+	open := func() (io.ReadCloser, error) { return nil, errors.New("opened") }
+
+	if _, err := (Decoder{}).Frames("/music/song.mp3", open, 4); !errors.Is(err, walkman.ErrNotVideo) {
+		t.Errorf("expected ErrNotVideo for an mp3, got %v", err)
+	}
+
+	if _, err := (Decoder{Extensions: []string{".y4m"}}).Frames("/videos/movie.mp4", open, 4); !errors.Is(err, walkman.ErrNotVideo) {
+		t.Errorf("expected ErrNotVideo for an extension left out, got %v", err)
+	}
+
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+
+	path := filepath.Join(t.TempDir(), "testsrc.mp4")
+	out, err := exec.Command("ffmpeg", "-v", "error", "-f", "lavfi", "-i", "testsrc=duration=4:size=320x240:rate=10", path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("ffmpeg: %v: %s", err, out)
+	}
+
+	frames, err := (Decoder{}).Frames(path, func() (io.ReadCloser, error) { return os.Open(path) }, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 4 || frames[0].Bounds().Dx() != 72 || frames[0].Bounds().Dy() != 64 {
+		t.Errorf("expected 4 frames of 72x64, got %d", len(frames))
+	}
+}
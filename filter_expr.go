@@ -0,0 +1,553 @@
+package walkman
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Compiles a filter expression into a PathFilter.
+//
+// Expressions compare file attributes with values and are combined
+// with &&, || and ! and grouped with parentheses:
+//
+//	size > 10MB && ext in (pdf, docx) && mtime < 2023-01-01
+//	!(path ~ "*/node_modules/*") || name == README.md
+//
+// Attributes are size (bytes, with optional B, KB, MB, GB, TB, KiB, MiB,
+// GiB or TiB suffix), mtime (2006-01-02 or RFC 3339), ext (without the
+// dot, case insensitive), name, path and mime (the content type without
+// parameters, e.g image/jpeg, only known with WithMIME) and category
+// (document, image, video, audio, archive, code or other, see
+// File.Category). The metadata of WithMetadata is matched with taken
+// (like mtime), camera, width and height of photos and artist, title,
+// album and duration (like 3m30s) of audio tracks; files without it never
+// match. uid and gid match the owner of files where it is known, see
+// File.Owner.
+//
+// Operators are ==, !=, <, <=, >, >=, in (...), not in (...) and
+// ~ which matches a glob pattern as understood by filepath.Match.
+// For path, the pattern may match any trailing part of the path, so
+// docs/*.pdf matches /home/me/docs/cv.pdf.
+func ParseFilter(expr string) (PathFilter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("walkman: unexpected %q at offset %d", tok.text, tok.pos)
+	}
+	return filter, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokWord             // attribute names, bare values and keywords
+	tokString           // quoted values
+	tokOp               // comparison and boolean operators
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// Operators, longest first so that <= is not read as <.
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "~", "!"}
+
+func tokenize(expr string) ([]token, error) {
+	tokens := []token{}
+	i := 0
+
+scan:
+	for i < len(expr) {
+		c := expr[i]
+
+		switch {
+		case isSpace(c):
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+			continue
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+			continue
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("walkman: unterminated string at offset %d", i)
+			}
+
+			tokens = append(tokens, token{tokString, expr[i+1 : i+1+end], i})
+			i += end + 2
+			continue
+		}
+
+		for _, op := range exprOperators {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, token{tokOp, op, i})
+				i += len(op)
+				continue scan
+			}
+		}
+
+		start := i
+		for i < len(expr) && isWordByte(expr[i]) {
+			i++
+		}
+
+		if start == i {
+			return nil, fmt.Errorf("walkman: unexpected %q at offset %d", expr[i], i)
+		}
+		tokens = append(tokens, token{tokWord, expr[start:i], start})
+	}
+
+	return append(tokens, token{tokEOF, "end of expression", len(expr)}), nil
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func isWordByte(b byte) bool {
+	return !isSpace(b) && strings.IndexByte("()<>=!~&|,\"'", b) < 0
+}
+
+// Recursive descent parser producing PathFilters directly.
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *exprParser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, fmt.Errorf("walkman: expected %s, got %q at offset %d", what, tok.text, tok.pos)
+	}
+	return tok, nil
+}
+
+func (p *exprParser) parseOr() (PathFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokOp && p.peek().text == "||" {
+		p.next()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(file File) bool { return l(file) || right(file) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (PathFilter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokOp && p.peek().text == "&&" {
+		p.next()
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(file File) bool { return l(file) && right(file) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (PathFilter, error) {
+	tok := p.peek()
+
+	if tok.kind == tokOp && tok.text == "!" {
+		p.next()
+
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(file File) bool { return !f(file) }, nil
+	}
+
+	if tok.kind == tokLParen {
+		p.next()
+
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (PathFilter, error) {
+	attr, err := p.expect(tokWord, "attribute")
+	if err != nil {
+		return nil, err
+	}
+
+	field, ok := exprFields[strings.ToLower(attr.text)]
+	if !ok {
+		return nil, fmt.Errorf("walkman: unknown attribute %q at offset %d", attr.text, attr.pos)
+	}
+
+	op := p.next()
+
+	// in (...) and not in (...)
+	if op.kind == tokWord && (op.text == "in" || op.text == "not") {
+		negate := op.text == "not"
+		if negate {
+			if _, err := p.expectWord("in"); err != nil {
+				return nil, err
+			}
+		}
+
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := field.in(values)
+		if err != nil {
+			return nil, err
+		}
+
+		if negate {
+			return func(file File) bool { return !f(file) }, nil
+		}
+		return f, nil
+	}
+
+	if op.kind != tokOp || op.text == "&&" || op.text == "||" || op.text == "!" {
+		return nil, fmt.Errorf("walkman: expected operator after %s, got %q at offset %d", attr.text, op.text, op.pos)
+	}
+
+	value := p.next()
+	if value.kind != tokWord && value.kind != tokString {
+		return nil, fmt.Errorf("walkman: expected value, got %q at offset %d", value.text, value.pos)
+	}
+
+	return field.compare(op.text, value.text)
+}
+
+func (p *exprParser) expectWord(word string) (token, error) {
+	tok := p.next()
+	if tok.kind != tokWord || tok.text != word {
+		return tok, fmt.Errorf("walkman: expected %q, got %q at offset %d", word, tok.text, tok.pos)
+	}
+	return tok, nil
+}
+
+// Parses (a, b, c)
+func (p *exprParser) parseList() ([]string, error) {
+	if _, err := p.expect(tokLParen, "("); err != nil {
+		return nil, err
+	}
+
+	values := []string{}
+	for {
+		tok := p.next()
+		if tok.kind != tokWord && tok.kind != tokString {
+			return nil, fmt.Errorf("walkman: expected value, got %q at offset %d", tok.text, tok.pos)
+		}
+		values = append(values, tok.text)
+
+		sep := p.next()
+		if sep.kind == tokRParen {
+			return values, nil
+		}
+
+		if sep.kind != tokComma {
+			return nil, fmt.Errorf("walkman: expected , or ), got %q at offset %d", sep.text, sep.pos)
+		}
+	}
+}
+
+// An attribute that can appear on the left side of a comparison.
+type exprField struct {
+	compare func(op, value string) (PathFilter, error)
+	in      func(values []string) (PathFilter, error)
+}
+
+var exprFields = map[string]exprField{
+	"size":  numberField(func(f File) int64 { return f.Stats.Size() }, ParseSize),
+	"mtime": numberField(func(f File) int64 { return f.Stats.ModTime().UnixNano() }, parseTime),
+	"ext": stringField(func(f File) string {
+		return strings.ToLower(strings.TrimPrefix(filepath.Ext(f.Path), "."))
+	}, func(v string) string {
+		return strings.ToLower(strings.TrimPrefix(v, "."))
+	}, matchGlob),
+	"name":     stringField(func(f File) string { return filepath.Base(f.Path) }, nil, matchGlob),
+	"path":     stringField(func(f File) string { return f.Path }, nil, matchPathSuffix),
+	"mime":     stringField(func(f File) string { return mediaType(f.MIME) }, mediaType, matchGlob),
+	"category": stringField(func(f File) string { return string(f.Category()) }, strings.ToLower, matchGlob),
+
+	"taken": metaField(numberField(func(f File) int64 { return f.Meta.Taken.UnixNano() }, parseTime),
+		func(m *Meta) bool { return !m.Taken.IsZero() }),
+	"camera": metaField(stringField(func(f File) string { return f.Meta.Camera }, nil, matchGlob),
+		func(m *Meta) bool { return m.Camera != "" }),
+	"width": metaField(numberField(func(f File) int64 { return int64(f.Meta.Width) }, parseCount),
+		func(m *Meta) bool { return m.Width != 0 }),
+	"height": metaField(numberField(func(f File) int64 { return int64(f.Meta.Height) }, parseCount),
+		func(m *Meta) bool { return m.Height != 0 }),
+
+	"artist": metaField(stringField(func(f File) string { return f.Meta.Artist }, nil, matchGlob),
+		func(m *Meta) bool { return m.Artist != "" }),
+	"title": metaField(stringField(func(f File) string { return f.Meta.Title }, nil, matchGlob),
+		func(m *Meta) bool { return m.Title != "" }),
+	"album": metaField(stringField(func(f File) string { return f.Meta.Album }, nil, matchGlob),
+		func(m *Meta) bool { return m.Album != "" }),
+	"duration": metaField(numberField(func(f File) int64 { return int64(f.Meta.Duration) }, parseDuration),
+		func(m *Meta) bool { return m.Duration != 0 }),
+
+	"uid": ownerField(numberField(func(f File) int64 { return int64(f.Owner.UID) }, parseCount)),
+	"gid": ownerField(numberField(func(f File) int64 { return int64(f.Owner.GID) }, parseCount)),
+}
+
+// Restricts field to files whose Meta has it, the others never match.
+func metaField(field exprField, has func(*Meta) bool) exprField {
+	known := func(filter PathFilter, err error) (PathFilter, error) {
+		if err != nil {
+			return nil, err
+		}
+		return func(f File) bool { return f.Meta != nil && has(f.Meta) && filter(f) }, nil
+	}
+
+	return exprField{
+		compare: func(op, value string) (PathFilter, error) { return known(field.compare(op, value)) },
+		in:      func(values []string) (PathFilter, error) { return known(field.in(values)) },
+	}
+}
+
+// Restricts field to files of known owner, the others never match.
+func ownerField(field exprField) exprField {
+	known := func(filter PathFilter, err error) (PathFilter, error) {
+		if err != nil {
+			return nil, err
+		}
+		return func(f File) bool { return f.Owner != nil && filter(f) }, nil
+	}
+
+	return exprField{
+		compare: func(op, value string) (PathFilter, error) { return known(field.compare(op, value)) },
+		in:      func(values []string) (PathFilter, error) { return known(field.in(values)) },
+	}
+}
+
+// Returns the lower case content type without its parameters,
+// e.g text/plain for "text/plain; charset=utf-8".
+func mediaType(mime string) string {
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mime))
+}
+
+func matchGlob(pattern, s string) bool {
+	ok, _ := filepath.Match(pattern, s)
+	return ok
+}
+
+// Reports whether pattern matches path or any of its trailing components.
+func matchPathSuffix(pattern, path string) bool {
+	for {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+
+		i := strings.IndexRune(path, filepath.Separator)
+		if i < 0 {
+			return false
+		}
+		path = path[i+1:]
+	}
+}
+
+func numberField(get func(File) int64, parse func(string) (int64, error)) exprField {
+	compare := func(op, value string) (PathFilter, error) {
+		v, err := parse(value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "==":
+			return func(f File) bool { return get(f) == v }, nil
+		case "!=":
+			return func(f File) bool { return get(f) != v }, nil
+		case "<":
+			return func(f File) bool { return get(f) < v }, nil
+		case "<=":
+			return func(f File) bool { return get(f) <= v }, nil
+		case ">":
+			return func(f File) bool { return get(f) > v }, nil
+		case ">=":
+			return func(f File) bool { return get(f) >= v }, nil
+		}
+		return nil, fmt.Errorf("walkman: operator %s can not be used with numbers", op)
+	}
+
+	in := func(values []string) (PathFilter, error) {
+		set := map[int64]bool{}
+		for _, value := range values {
+			v, err := parse(value)
+			if err != nil {
+				return nil, err
+			}
+			set[v] = true
+		}
+		return func(f File) bool { return set[get(f)] }, nil
+	}
+
+	return exprField{compare: compare, in: in}
+}
+
+// match implements the ~ operator.
+func stringField(get func(File) string, normalize func(string) string, match func(pattern, s string) bool) exprField {
+	if normalize == nil {
+		normalize = func(s string) string { return s }
+	}
+
+	compare := func(op, value string) (PathFilter, error) {
+		v := normalize(value)
+
+		switch op {
+		case "==":
+			return func(f File) bool { return get(f) == v }, nil
+		case "!=":
+			return func(f File) bool { return get(f) != v }, nil
+		case "~":
+			if _, err := filepath.Match(v, ""); err != nil {
+				return nil, fmt.Errorf("walkman: bad pattern %q: %w", value, err)
+			}
+
+			return func(f File) bool { return match(v, get(f)) }, nil
+		}
+		return nil, fmt.Errorf("walkman: operator %s can not be used with text", op)
+	}
+
+	in := func(values []string) (PathFilter, error) {
+		set := map[string]bool{}
+		for _, value := range values {
+			set[normalize(value)] = true
+		}
+		return func(f File) bool { return set[get(f)] }, nil
+	}
+
+	return exprField{compare: compare, in: in}
+}
+
+// Size units accepted in expressions, longest suffix first.
+var sizeUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// Parses sizes like 512, 10MB or 1.5GiB into bytes, as written in filters.
+func ParseSize(s string) (int64, error) {
+	upper := strings.ToUpper(s)
+	factor := int64(1)
+
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSuffix(upper, unit.suffix)
+			factor = unit.factor
+			break
+		}
+	}
+
+	// NaN fails every comparison, so check it is in range rather than out
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || !(n >= 0 && n*float64(factor) < math.MaxInt64) {
+		return 0, fmt.Errorf("walkman: invalid size %q", s)
+	}
+	return int64(n * float64(factor)), nil
+}
+
+// Parses plain numbers like pixel counts.
+func parseCount(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("walkman: invalid number %q", s)
+	}
+	return n, nil
+}
+
+// Parses durations like 90s or 3m30s into nanoseconds.
+func parseDuration(s string) (int64, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("walkman: invalid duration %q", s)
+	}
+	return int64(d), nil
+}
+
+// Parses 2006-01-02 or RFC 3339 timestamps into unix nanoseconds.
+func parseTime(s string) (int64, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t.UnixNano(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, fmt.Errorf("walkman: invalid time %q, expected 2006-01-02 or RFC 3339", s)
+	}
+	return t.UnixNano(), nil
+}
@@ -0,0 +1,75 @@
+package walkman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local)
+
+	report := writeFile(t, dir, "docs/Report.PDF", string(make([]byte, 2048)), old)
+	notes := writeFile(t, dir, "notes.txt", "hello", time.Now())
+	report.MIME, notes.MIME = "application/pdf", "text/plain; charset=utf-8"
+
+	tests := []struct {
+		expr   string
+		report bool
+		notes  bool
+	}{
+		{"size > 1KB", true, false},
+		{"size <= 5", false, true},
+		{"ext in (pdf, docx)", true, false},
+		{"ext not in (.pdf)", false, true},
+		{"mtime < 2023-01-01", true, false},
+		{`name == "notes.txt"`, false, true},
+		{"path ~ */docs/*", true, false},
+		{"size > 1KiB && ext == pdf || name == notes.txt", true, true},
+		{"!(ext == txt)", true, false},
+		{"mime == text/plain", false, true},
+		{"mime ~ application/*", true, false},
+		{"category == Document", true, true},
+		{"category in (image, video)", false, false},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+
+		if got := f(report); got != tt.report {
+			t.Errorf("%q on %s = %v, want %v", tt.expr, report.Path, got, tt.report)
+		}
+
+		if got := f(notes); got != tt.notes {
+			t.Errorf("%q on %s = %v, want %v", tt.expr, notes.Path, got, tt.notes)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	bad := []string{
+		"",
+		"size >",
+		"colour == red",
+		"size > ten",
+		"name < b",
+		"ext in (pdf",
+		"(size > 1",
+		"size > 1 size",
+		`name == "open`,
+		"size > NaN",
+		"size > Inf",
+		"size > -1",
+		"size > 1e30TB",
+	}
+
+	for _, expr := range bad {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("ParseFilter(%q) should fail", expr)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package walkman
+
+// Flush the backends of WithResultBackend every n files besides once the
+// walk ends, e.g to write files to a database as they come so that a
+// crash only loses the last batch. It has no effect without a backend.
+func WithFlushEvery(n int) Option {
+	return func(w *Walkman) {
+		if n < 1 {
+			n = 1
+		}
+		w.flushEvery = n
+	}
+}
+
+// Adds file to the backends of WithResultBackend, flushing them once
+// WithFlushEvery files were added. A failing backend is recorded among
+// the errors of the walk.
+func (wm *Walkman) addToBatch(hash string, file File) {
+	for _, backend := range wm.backends {
+		if err := backend.Add(hash, file); err != nil {
+			wm.addError(err)
+		}
+	}
+
+	wm.batched++
+	if wm.flushEvery > 0 && wm.batched >= wm.flushEvery {
+		wm.flushBatch()
+	}
+}
+
+// Flushes the files added to the backends since the last flush.
+func (wm *Walkman) flushBatch() {
+	if wm.batched == 0 {
+		return
+	}
+
+	for _, backend := range wm.backends {
+		if err := backend.Flush(); err != nil {
+			wm.addError(err)
+		}
+	}
+	wm.batched = 0
+}
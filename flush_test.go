@@ -0,0 +1,84 @@
+package walkman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Records how many files were added before every flush.
+type batchBackend struct {
+	added int
+	sizes []int
+}
+
+func (s *batchBackend) Add(hash string, file File) error {
+	s.added++
+	return nil
+}
+
+func (s *batchBackend) Flush() error {
+	s.sizes = append(s.sizes, s.added)
+	s.added = 0
+	return nil
+}
+
+func (s *batchBackend) Close() error { return nil }
+
+func TestWithFlushEvery(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		writeFile(t, dir, fmt.Sprintf("%d.txt", i), "same", time.Now())
+	}
+
+	backend := new(batchBackend)
+	if _, err := New(WithResultBackend(backend), WithFlushEvery(2)).Walk(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(backend.sizes) != "[2 2 1]" {
+		t.Errorf("expected batches of 2, 2 and 1 files, got %v", backend.sizes)
+	}
+
+	backend = new(batchBackend)
+	if _, err := New(WithResultBackend(backend)).Walk(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(backend.sizes) != "[5]" {
+		t.Errorf("expected a single flush as the walk ends, got %v", backend.sizes)
+	}
+
+	var buf bytes.Buffer
+	memory := NewMemoryBackend()
+	if _, err := New(WithContentHash(), WithResultBackend(memory, NDJSONBackend(&buf)), WithFlushEvery(100)).Walk(dir); err != nil {
+		t.Fatal(err)
+	}
+	hashes := memory.Results()
+
+	lines := 0
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record struct {
+			Hash string `json:"hash"`
+			Path string `json:"path"`
+			Size int64  `json:"size"`
+		}
+
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(hashes[record.Hash]) != 5 || record.Path == "" || record.Size != 4 {
+			t.Errorf("expected a file of the walk, got %+v", record)
+		}
+		lines++
+	}
+
+	if lines != 5 {
+		t.Errorf("expected a line per file, got %d", lines)
+	}
+}
@@ -0,0 +1,64 @@
+package walkman
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Hashers by the algorithm prefixing their hashes, see HasherByName.
+var (
+	hashersMu sync.RWMutex
+	hashers   = map[string]Hasher{
+		AlgorithmName:       NameSizeHasher,
+		AlgorithmNameFolded: foldedNameHasher,
+		AlgorithmNameOnly:   NameOnlyHasher,
+		AlgorithmSizeOnly:   SizeOnlyHasher,
+		AlgorithmMD5:        MD5ContentHasher,
+	}
+)
+
+// Returns the hasher of the algorithm name, e.g md5, so that it can be
+// picked from a command line flag or a configuration file.
+func HasherByName(name string) (Hasher, error) {
+	hashersMu.RLock()
+	defer hashersMu.RUnlock()
+
+	h, ok := hashers[name]
+	if !ok {
+		return nil, fmt.Errorf("walkman: unknown hash algorithm %q", name)
+	}
+	return h, nil
+}
+
+// Makes hashFunc known to HasherByName as the algorithm name,
+// the prefix of its hashes, replacing any hasher of that name.
+func RegisterHasher(name string, hashFunc Hasher) {
+	hashersMu.Lock()
+	defer hashersMu.Unlock()
+
+	hashers[name] = hashFunc
+}
+
+// Returns the names of the hashers known to HasherByName, sorted.
+func HasherNames() []string {
+	hashersMu.RLock()
+	defer hashersMu.RUnlock()
+
+	names := make([]string, 0, len(hashers))
+	for name := range hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Like WithHasher for a hasher whose hashes are prefixed with algorithm,
+// e.g one returned by HasherByName, so that walks with it can use an index
+// or the results of previous walks.
+func WithNamedHasher(algorithm string, hashFunc Hasher) Option {
+	return func(w *Walkman) {
+		w.hashFunc = hashFunc
+		w.algorithm = algorithm
+	}
+}
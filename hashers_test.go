@@ -0,0 +1,52 @@
+package walkman
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func TestHasherByName(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "same", time.Now())
+	writeFile(t, dir, "b.txt", "same", time.Now())
+
+	h, err := HasherByName(AlgorithmMD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wm := New(WithNamedHasher(AlgorithmMD5, h))
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashes) != 1 || hashes.Algorithm() != AlgorithmMD5 {
+		t.Errorf("expected a single md5 group, got %v", hashes)
+	}
+
+	if _, err := HasherByName("crc7"); err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
+
+func TestRegisterHasher(t *testing.T) {
+	RegisterHasher("test", func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		return "test:" + info.Name(), nil
+	})
+
+	if _, err := HasherByName("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, name := range HasherNames() {
+		found = found || name == "test"
+	}
+
+	if !found {
+		t.Errorf("expected test among %v", HasherNames())
+	}
+}
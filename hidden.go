@@ -0,0 +1,21 @@
+package walkman
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// Walk hidden directories too. Directories are hidden when their name
+// starts with a dot and, on Windows, when they carry the hidden or
+// system attribute, e.g $Recycle.Bin or AppData. Hidden files are
+// always walked.
+func WithIncludeHidden() Option {
+	return func(w *Walkman) {
+		w.config.hidden = true
+	}
+}
+
+// Reports whether the directory fi is hidden, see WithIncludeHidden.
+func isHidden(fi fs.FileInfo) bool {
+	return strings.HasPrefix(fi.Name(), ".") || hiddenAttribute(fi)
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package walkman
+
+import "io/fs"
+
+// Only Windows marks files hidden by attribute.
+func hiddenAttribute(fi fs.FileInfo) bool {
+	return false
+}
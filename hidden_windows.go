@@ -0,0 +1,15 @@
+package walkman
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// Reports whether fi carries the hidden or system attribute.
+func hiddenAttribute(fi fs.FileInfo) bool {
+	attrs, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+	return attrs.FileAttributes&(syscall.FILE_ATTRIBUTE_HIDDEN|syscall.FILE_ATTRIBUTE_SYSTEM) != 0
+}
@@ -0,0 +1,60 @@
+package walkman
+
+import (
+	"time"
+)
+
+// RunSummary sums up a complete walk recorded in an Index, see Index.History.
+type RunSummary struct {
+	Time           time.Time `json:"time"`
+	Roots          []string  `json:"roots"`
+	Algorithm      string    `json:"algorithm,omitempty"`
+	Files          int64     `json:"files"`
+	Bytes          int64     `json:"bytes"`
+	DuplicateFiles int64     `json:"duplicate_files"` // copies beyond the first of each group
+	DuplicateBytes int64     `json:"duplicate_bytes"` // bytes freed by keeping a single copy of each
+}
+
+// Sums up the results of a walk of roots.
+func newRunSummary(roots []string, hashes Results) RunSummary {
+	run := RunSummary{Time: time.Now(), Roots: roots, Algorithm: hashes.Algorithm()}
+
+	for hash, files := range hashes {
+		alg := algorithmOf(hash)
+		if alg == AlgorithmLink || alg == AlgorithmDeadLink {
+			continue
+		}
+
+		for i, f := range files {
+			if f.Stats == nil || f.Stats.IsDir() {
+				continue
+			}
+
+			run.Files++
+			run.Bytes += f.Stats.Size()
+
+			if i > 0 && !listedOnly(alg) {
+				run.DuplicateFiles++
+				run.DuplicateBytes += f.Stats.Size()
+			}
+		}
+	}
+	return run
+}
+
+// Returns the summaries of the complete walks that used the index,
+// oldest first, so that the growth of duplicates can be followed.
+func (ix *Index) History() []RunSummary {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	return append([]RunSummary(nil), ix.runs...)
+}
+
+// Records the summary of a walk.
+func (ix *Index) addRun(run RunSummary) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	return ix.append(indexRecord{Run: &run})
+}
@@ -0,0 +1,70 @@
+package walkman
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync/atomic"
+)
+
+// PreFileHook is called before a file is hashed, see WithPreFileHook.
+type PreFileHook func(path string, info fs.FileInfo) error
+
+// PostFileHook is called once the hash of a file is known, see
+// WithPostFileHook. hash is empty and err set if it could not be hashed.
+type PostFileHook func(path string, info fs.FileInfo, hash string, err error)
+
+// Returned by a PreFileHook to quietly leave a file out of the walk.
+var ErrSkipFile = errors.New("walkman: skip file")
+
+// Call hook before each file is hashed, e.g to hand it to an antivirus
+// first. A file is left out of the walk if hook returns an error: it is
+// counted as skipped for ErrSkipFile, any other error is recorded among
+// the Errors of the walk.
+//
+// Hooks are called from the hash workers, concurrently, in the order
+// they were given. Members of archives do not go through hooks.
+func WithPreFileHook(hook PreFileHook) Option {
+	return func(w *Walkman) {
+		w.preHooks = append(w.preHooks, hook)
+	}
+}
+
+// Call hook once the hash of each file is known, whether it was read,
+// carried over from a previous walk or left out by WithSizeGrouping
+// (a size: hash), e.g to make thumbnails or index files elsewhere.
+// Files excluded by WithKnownHashes still go through hooks.
+//
+// Hooks are called from the hash workers, concurrently, in the order
+// they were given. Members of archives do not go through hooks.
+func WithPostFileHook(hook PostFileHook) Option {
+	return func(w *Walkman) {
+		w.postHooks = append(w.postHooks, hook)
+	}
+}
+
+// Runs the pre file hooks, reporting whether path is to be hashed.
+func (wm *Walkman) preFile(path string, fi fs.FileInfo) bool {
+	for _, hook := range wm.preHooks {
+		err := hook(path, fi)
+		if err == nil {
+			continue
+		}
+
+		if errors.Is(err, ErrSkipFile) {
+			atomic.AddInt64(&wm.stats.FilesSkipped, 1)
+			wm.logger.Debug("skipping file", "path", path, "hook", true)
+		} else {
+			wm.addError(fmt.Errorf("%s: %w", path, err))
+		}
+		return false
+	}
+	return true
+}
+
+// Runs the post file hooks.
+func (wm *Walkman) postFile(path string, fi fs.FileInfo, hash string, err error) {
+	for _, hook := range wm.postHooks {
+		hook(path, fi, hash, err)
+	}
+}
@@ -0,0 +1,50 @@
+package walkman
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileHooks(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "a", time.Now())
+	writeFile(t, dir, "infected.exe", "x", time.Now())
+	writeFile(t, dir, "locked.doc", "l", time.Now())
+
+	var mu sync.Mutex
+	hashed := map[string]string{}
+
+	wm := New(WithContentHash(),
+		WithPreFileHook(func(path string, info fs.FileInfo) error {
+			switch filepath.Base(path) {
+			case "infected.exe":
+				return ErrSkipFile
+			case "locked.doc":
+				return errors.New("quarantined")
+			}
+			return nil
+		}),
+		WithPostFileHook(func(path string, info fs.FileInfo, hash string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			hashed[filepath.Base(path)] = hash
+		}),
+	)
+
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashes) != 1 || len(hashed) != 1 || hashed["a.txt"] != hashOf(t, hashes, filepath.Join(dir, "a.txt")) {
+		t.Errorf("expected only a.txt hashed, got %v and %v", hashes, hashed)
+	}
+
+	if stats := wm.LastRunStats(); stats.FilesSkipped != 1 || len(wm.Errors()) != 1 {
+		t.Errorf("expected infected.exe skipped and locked.doc an error, got %+v and %v", stats, wm.Errors())
+	}
+}
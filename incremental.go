@@ -0,0 +1,85 @@
+package walkman
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// What an earlier walk knew about a file.
+type prevFile struct {
+	hash    string
+	size    int64
+	modTime time.Time
+	mime    string
+	meta    *Meta
+}
+
+// Carry hashes over from the results of an earlier walk (e.g loaded
+// with LoadResults). Files whose size and modification time are
+// unchanged are not hashed again.
+//
+// prev must have been produced with the same hash algorithm.
+func WithPrevious(prev Results) Option {
+	return func(w *Walkman) {
+		w.previous = prev
+	}
+}
+
+// Walks dir, only hashing files that were added or changed since prev
+// was collected and carrying the hashes of unchanged files forward.
+//
+// Files that no longer exist are dropped. See WithPrevious, which prev
+// replaces for this walk only.
+func (wm *Walkman) WalkIncremental(dir string, prev Results) (Results, error) {
+	previous := wm.previous
+	defer func() { wm.previous = previous }()
+
+	wm.previous = prev
+	return wm.Walk(dir)
+}
+
+// Indexes the previous results by path, refusing those
+// produced by another algorithm.
+func (wm *Walkman) indexPrevious() error {
+	if len(wm.previous) == 0 {
+		return nil
+	}
+
+	algorithm := wm.previous.Algorithm()
+	if algorithm == "" && wm.previous.unhashed() {
+		return nil // nothing to carry over
+	}
+
+	if algorithm == "" || algorithm != wm.algorithm {
+		return fmt.Errorf("walkman: previous results use %q hashes, this walk uses %q", algorithm, wm.algorithm)
+	}
+
+	wm.prev = make(map[string]prevFile)
+	for hash, files := range wm.previous {
+		for _, f := range files {
+			wm.prev[f.Path] = prevFile{hash: hash, size: f.Stats.Size(), modTime: f.Stats.ModTime(), mime: f.MIME, meta: f.Meta}
+		}
+	}
+	return nil
+}
+
+// Returns what is known of path if the file did not change since, looking
+// in the previous results first and then in the index.
+func (wm *Walkman) reuse(path string, fi fs.FileInfo) (prevFile, bool) {
+	p, ok := wm.prev[path]
+	if !ok && wm.useIndex() {
+		var e IndexEntry
+		if e, ok = wm.index.Get(path); ok && algorithmOf(e.Hash) == wm.algorithm {
+			p = prevFile{hash: e.Hash, size: e.Size, modTime: e.ModTime}
+		} else {
+			ok = false
+		}
+	}
+
+	// files left unhashed may need a hash now, see WithSizeGrouping
+	if !ok || algorithmOf(p.hash) == AlgorithmSize || p.size != fi.Size() || !p.modTime.Equal(fi.ModTime()) {
+		return prevFile{}, false
+	}
+	return p, true
+}
@@ -0,0 +1,129 @@
+package walkman
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWalkIncremental(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+
+	writeFile(t, dir, "a.txt", "unchanged", mtime)
+	changed := writeFile(t, dir, "b.txt", "before", mtime)
+
+	prev, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(changed.Path, []byte("after!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "c.txt", "new", mtime)
+
+	wm := New(WithContentHash())
+	hashes, err := wm.WalkIncremental(dir, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := wm.LastRunStats()
+	if stats.FilesReused != 1 || stats.FilesScanned != 2 {
+		t.Errorf("expected 1 file reused and 2 hashed, got %d and %d", stats.FilesReused, stats.FilesScanned)
+	}
+
+	fresh, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for hash, files := range fresh {
+		if len(hashes[hash]) != len(files) {
+			t.Errorf("incremental walk differs from a fresh one for %s", files[0].Path)
+		}
+	}
+}
+
+func TestWalkAgain(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+
+	writeFile(t, dir, "a.txt", "a", mtime)
+	writeFile(t, dir, "b.txt", "b", mtime)
+
+	wm := New(WithContentHash(), WithSizeGrouping())
+	prev, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, dir, "c.txt", "c", mtime)
+	if _, err := wm.WalkIncremental(dir, prev); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := wm.LastRunStats(); stats.FilesReused != 2 || stats.FilesScanned != 1 {
+		t.Errorf("expected 2 files reused and 1 hashed, got %+v", stats)
+	}
+
+	// the previous results only counted for the incremental walk
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := wm.LastRunStats(); stats.FilesReused != 0 || stats.FilesScanned != 3 || len(hashes.ToSlice()) != 3 {
+		t.Errorf("expected 3 files hashed afresh, got %+v", stats)
+	}
+}
+
+func TestLastRunStatsWhileWalkingAgain(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		writeFile(t, dir, fmt.Sprintf("%d.txt", i), "same", time.Now())
+	}
+
+	wm := New(WithContentHash())
+	done := make(chan struct{})
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				wm.LastRunStats()
+			}
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		if _, err := wm.Walk(dir); err != nil {
+			t.Fatal(err)
+		}
+
+		if stats := wm.LastRunStats(); stats.FilesScanned != 20 {
+			t.Errorf("walk %d: expected 20 files hashed, got %d", i, stats.FilesScanned)
+		}
+	}
+	close(done)
+	<-polled
+}
+
+func TestWalkIncrementalRejectsOtherAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "a", time.Now())
+
+	prev, err := New().Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(WithContentHash()).WalkIncremental(dir, prev); err == nil {
+		t.Errorf("expected name hashes to be refused by an md5 walk")
+	}
+}
@@ -0,0 +1,420 @@
+package walkman
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Version of the index file format.
+const indexVersion = 1
+
+// Index is a persistent path → (size, mtime, hash) store kept in a
+// single file, so that hashes survive from one run to the next.
+//
+// The file is an append-only log of JSON records, one per line, that
+// is replayed into memory on open. Superseded records are dropped by
+// Compact. A record torn by a crash is discarded on the next open.
+//
+// An Index is safe for concurrent use. Pass it to a Walkman with
+// WithIndex to skip hashing files that did not change.
+type Index struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	w       *bufio.Writer
+	entries map[string]snapshotFile // keyed by path, see snapshotFile
+	hashes  map[string]string       // hash of each path
+	runs    []RunSummary            // walks recorded, see History
+	garbage int                     // superseded records in the file
+}
+
+// What the index knows about a file.
+type IndexEntry struct {
+	Hash    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+}
+
+type indexHeader struct {
+	Version int `json:"version"`
+}
+
+type indexRecord struct {
+	snapshotFile
+	Hash    string      `json:"hash,omitempty"`
+	Deleted bool        `json:"deleted,omitempty"`
+	Run     *RunSummary `json:"run,omitempty"` // a walk, not a file
+}
+
+// Opens the index at path, creating it if it does not exist.
+func OpenIndex(path string) (*Index, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &Index{
+		path:    path,
+		file:    file,
+		entries: make(map[string]snapshotFile),
+		hashes:  make(map[string]string),
+	}
+
+	if err := ix.load(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("walkman: reading index %s: %w", path, err)
+	}
+
+	ix.w = bufio.NewWriter(file)
+	return ix, nil
+}
+
+// Replays the log, truncating a torn record at its end.
+func (ix *Index) load() error {
+	r := bufio.NewReader(ix.file)
+
+	var offset int64
+	for first := true; ; first = false {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// an incomplete last line is a write interrupted by a crash
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if first {
+			var header indexHeader
+			if err := json.Unmarshal(line, &header); err != nil {
+				return err
+			}
+
+			if header.Version != indexVersion {
+				return fmt.Errorf("unsupported index version %d", header.Version)
+			}
+		} else {
+			var rec indexRecord
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return err
+			}
+			ix.apply(rec)
+		}
+
+		offset += int64(len(line))
+	}
+
+	if err := ix.file.Truncate(offset); err != nil {
+		return err
+	}
+
+	if _, err := ix.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	if offset == 0 {
+		return ix.writeHeader(ix.file)
+	}
+	return nil
+}
+
+func (ix *Index) writeHeader(w io.Writer) error {
+	return json.NewEncoder(w).Encode(indexHeader{Version: indexVersion})
+}
+
+func (ix *Index) apply(rec indexRecord) {
+	if rec.Run != nil {
+		ix.runs = append(ix.runs, *rec.Run)
+		return
+	}
+
+	if _, ok := ix.entries[rec.Path]; ok {
+		ix.garbage++
+	}
+
+	if rec.Deleted {
+		delete(ix.entries, rec.Path)
+		delete(ix.hashes, rec.Path)
+		ix.garbage++
+		return
+	}
+
+	ix.entries[rec.Path] = rec.snapshotFile
+	ix.hashes[rec.Path] = rec.Hash
+}
+
+func (ix *Index) append(rec indexRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	ix.apply(rec)
+
+	b = append(b, '\n')
+	_, err = ix.w.Write(b)
+	return err
+}
+
+// Returns what the index knows about path.
+func (ix *Index) Get(path string) (IndexEntry, bool) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	sf, ok := ix.entries[path]
+	if !ok {
+		return IndexEntry{}, false
+	}
+
+	return IndexEntry{Hash: ix.hashes[path], Size: sf.Size, Mode: sf.Mode, ModTime: sf.ModTime}, true
+}
+
+// Records the hash of the file at path described by fi.
+// Storing what the index already holds is a no-op.
+func (ix *Index) Put(path, hash string, fi fs.FileInfo) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	sf := snapshotFile{Path: path, Size: fi.Size(), Mode: fi.Mode(), ModTime: fi.ModTime()}
+
+	if old, ok := ix.entries[path]; ok && ix.hashes[path] == hash &&
+		old.Size == sf.Size && old.Mode == sf.Mode && old.ModTime.Equal(sf.ModTime) {
+		return nil
+	}
+
+	return ix.append(indexRecord{snapshotFile: sf, Hash: hash})
+}
+
+// Forgets path.
+func (ix *Index) Delete(path string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if _, ok := ix.entries[path]; !ok {
+		return nil
+	}
+
+	return ix.append(indexRecord{snapshotFile: snapshotFile{Path: path}, Deleted: true})
+}
+
+// Returns the number of files in the index.
+func (ix *Index) Len() int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	return len(ix.entries)
+}
+
+// Returns the contents of the index grouped by hash, like the results
+// of a walk. File.Stats describe each file as it was last indexed.
+func (ix *Index) Results() Results {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	hashes := make(Results)
+	for path, sf := range ix.entries {
+		hash := ix.hashes[path]
+		hashes[hash] = append(hashes[hash], File{Path: path, Stats: sf.info()})
+	}
+	return hashes
+}
+
+// Forgets every file below one of roots that is not in keep,
+// i.e. files removed since they were indexed.
+func (ix *Index) prune(roots []string, keep map[string]bool) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	for path := range ix.entries {
+		if keep[path] {
+			continue
+		}
+
+		for _, root := range roots {
+			if IsUnder(path, root) {
+				rec := indexRecord{snapshotFile: snapshotFile{Path: path}, Deleted: true}
+				if err := ix.append(rec); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Flushes pending records and commits them to stable storage.
+func (ix *Index) Sync() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if err := ix.w.Flush(); err != nil {
+		return err
+	}
+	return ix.file.Sync()
+}
+
+// Rewrites the index without superseded records.
+func (ix *Index) Compact() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	return ix.compact()
+}
+
+func (ix *Index) compact() error {
+	if err := ix.w.Flush(); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(ix.path), filepath.Base(ix.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := ix.writeLive(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), ix.path); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	ix.file.Close()
+	ix.file = tmp
+	ix.w = bufio.NewWriter(tmp)
+	ix.garbage = 0
+	return nil
+}
+
+// Writes a header, the walks recorded and one record per indexed file to f.
+func (ix *Index) writeLive(f *os.File) error {
+	w := bufio.NewWriter(f)
+	if err := ix.writeHeader(w); err != nil {
+		return err
+	}
+
+	for i := range ix.runs {
+		b, err := json.Marshal(indexRecord{Run: &ix.runs[i]})
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+
+	for path, sf := range ix.entries {
+		b, err := json.Marshal(indexRecord{snapshotFile: sf, Hash: ix.hashes[path]})
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Flushes the index and closes its file. The index is compacted
+// first when most of its records have been superseded.
+func (ix *Index) Close() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	var err error
+	if ix.garbage > len(ix.entries) {
+		err = ix.compact()
+	} else if err = ix.w.Flush(); err == nil {
+		err = ix.file.Sync()
+	}
+
+	if cerr := ix.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Keep hashes in ix across runs. Files whose size and modification
+// time match the index are not hashed again; everything hashed is
+// written back, and files under the walked roots that no longer exist
+// are dropped once the walk completes, when a summary of the walk is
+// recorded too, see Index.History.
+//
+// The index is only used with a built-in hash algorithm, see WithHasher.
+// The caller remains responsible for closing it.
+func WithIndex(ix *Index) Option {
+	return func(w *Walkman) {
+		w.index = ix
+	}
+}
+
+// Reports whether hashes of this walk can be stored in the index.
+// Hashes of custom hashers carry no algorithm to tell them apart.
+func (wm *Walkman) useIndex() bool {
+	return wm.index != nil && wm.algorithm != ""
+}
+
+func (wm *Walkman) indexFile(path, hash string, fi fs.FileInfo) {
+	if !wm.useIndex() || algorithmOf(hash) == AlgorithmSize {
+		return
+	}
+
+	if err := wm.index.Put(path, hash, fi); err != nil {
+		wm.addError(err)
+	}
+}
+
+// Drops removed files from the index after a complete walk
+// and commits its changes.
+func (wm *Walkman) updateIndex(roots []string, hashes Results) {
+	if !wm.useIndex() {
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		atomic.StoreInt64((*int64)(&wm.stats.IndexTime), int64(time.Since(start)))
+	}()
+
+	// walks with backends only return duplicates, if anything
+	if wm.ctx.Err() == nil && len(wm.backends) == 0 && !wm.config.duplicatesOnly {
+		seen := make(map[string]bool)
+		for _, files := range hashes {
+			for _, f := range files {
+				seen[f.Path] = true
+			}
+		}
+
+		if err := wm.index.prune(roots, seen); err != nil {
+			wm.addError(err)
+		}
+
+		if err := wm.index.addRun(newRunSummary(roots, hashes)); err != nil {
+			wm.addError(err)
+		}
+	}
+
+	if err := wm.index.Sync(); err != nil {
+		wm.addError(err)
+	}
+}
@@ -0,0 +1,154 @@
+package walkman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndexPersists(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "walkman.idx")
+	a := writeFile(t, dir, "a.txt", "a", time.Now())
+
+	ix, err := OpenIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Put("/gone", "md5:1", a.Stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Put(a.Path, "md5:2", a.Stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Delete("/gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash in the middle of writing a record
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"path":"/torn","si`)
+	f.Close()
+
+	ix, err = OpenIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+
+	if ix.Len() != 1 {
+		t.Errorf("expected 1 file in the index, got %d", ix.Len())
+	}
+
+	e, ok := ix.Get(a.Path)
+	if !ok || e.Hash != "md5:2" || e.Size != 1 || !e.ModTime.Equal(a.Stats.ModTime()) {
+		t.Errorf("unexpected entry for %s: %+v", a.Path, e)
+	}
+}
+
+func TestWalkWithIndex(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	mtime := time.Now().Add(-time.Hour)
+
+	writeFile(t, root, "a.txt", "hello", mtime)
+	removed := writeFile(t, root, "b.txt", "world", mtime)
+
+	ix, err := OpenIndex(filepath.Join(dir, "walkman.idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+
+	if _, err := New(WithContentHash(), WithIndex(ix)).Walk(root); err != nil {
+		t.Fatal(err)
+	}
+
+	if ix.Len() != 2 {
+		t.Fatalf("expected 2 files in the index, got %d", ix.Len())
+	}
+
+	if err := os.Remove(removed.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	wm := New(WithContentHash(), WithIndex(ix))
+	if _, err := wm.Walk(root); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := wm.LastRunStats(); stats.FilesReused != 1 || stats.FilesScanned != 0 {
+		t.Errorf("expected 1 file reused and none hashed, got %+v", stats)
+	}
+
+	if _, ok := ix.Get(removed.Path); ok {
+		t.Errorf("expected %s to be dropped from the index", removed.Path)
+	}
+}
+
+func TestIndexHistory(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	path := filepath.Join(dir, "walkman.idx")
+
+	writeFile(t, root, "a.txt", "hello", time.Now())
+	writeFile(t, root, "b.txt", "hello", time.Now())
+
+	ix, err := OpenIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(WithContentHash(), WithIndex(ix)).Walk(root); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, root, "c.txt", "hello", time.Now())
+
+	if _, err := New(WithContentHash(), WithIndex(ix)).Walk(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// runs are kept by compaction
+	if err := ix.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ix, err = OpenIndex(path); err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+
+	runs := ix.History()
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %+v", runs)
+	}
+
+	first, second := runs[0], runs[1]
+	if first.Files != 2 || first.Bytes != 10 || first.DuplicateFiles != 1 || first.DuplicateBytes != 5 {
+		t.Errorf("unexpected first run %+v", first)
+	}
+
+	if second.Files != 3 || second.DuplicateBytes != 10 || second.Algorithm != AlgorithmMD5 || second.Roots[0] != root {
+		t.Errorf("unexpected second run %+v", second)
+	}
+
+	if ix.Len() != 3 {
+		t.Errorf("expected only files counted as entries, got %d", ix.Len())
+	}
+}
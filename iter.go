@@ -0,0 +1,38 @@
+//go:build go1.23
+
+package walkman
+
+import "iter"
+
+// Returns an iterator over the groups of the results, in no particular
+// order, so that callers keep working should results later be read
+// lazily, e.g from a ResultBackend:
+//
+//	for hash, files := range hashes.All() {
+//		...
+//	}
+//
+// Range over Hashes for the groups in order.
+func (hashes Results) All() iter.Seq2[string, FileList] {
+	return func(yield func(string, FileList) bool) {
+		for hash, files := range hashes {
+			if !yield(hash, files) {
+				return
+			}
+		}
+	}
+}
+
+// Returns an iterator over every file of the results, group by group,
+// without flattening them into a slice like ToSlice.
+func (hashes Results) Files() iter.Seq[File] {
+	return func(yield func(File) bool) {
+		for _, files := range hashes {
+			for _, f := range files {
+				if !yield(f) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build go1.23
+
+package walkman
+
+import "testing"
+
+func TestResultsIterators(t *testing.T) {
+	hashes := Results{
+		"md5:a": {{Path: "/a1"}, {Path: "/a2"}},
+		"md5:b": {{Path: "/b"}},
+	}
+
+	groups := 0
+	for hash, files := range hashes.All() {
+		if len(files) != len(hashes[hash]) {
+			t.Errorf("%s: expected %d files, got %d", hash, len(hashes[hash]), len(files))
+		}
+		groups++
+	}
+
+	if groups != 2 {
+		t.Errorf("expected 2 groups, got %d", groups)
+	}
+
+	paths := make(map[string]bool)
+	for f := range hashes.Files() {
+		paths[f.Path] = true
+	}
+
+	if len(paths) != 3 {
+		t.Errorf("expected 3 files, got %v", paths)
+	}
+
+	for range hashes.Files() {
+		break // stopping early must not panic
+	}
+}
@@ -0,0 +1,106 @@
+package walkman
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// KnownHashes is a set of hashes as walks make them, e.g
+// md5:d41d8cd98f00b204e9800998ecf8427e, see WithKnownHashes.
+type KnownHashes map[string]bool
+
+// What walks do with files whose hash is known, see WithKnownHashes.
+type KnownPolicy int
+
+const (
+	KnownFlag    KnownPolicy = iota // keep them with File.Known set, e.g indicators of compromise
+	KnownExclude                    // leave them out, e.g files of the operating system
+)
+
+// Match the hash of every file against known, e.g the NSRL reference
+// set to leave out files that came with the operating system, or
+// indicators of compromise to find them. Matches are counted in
+// RunStats.FilesKnown and sent to the event sink as EventFileKnown.
+//
+// Hashes only match those of the same algorithm, so known md5 digests
+// need WithContentHash. Files of a unique size are hashed too with
+// WithSizeGrouping, as they could not be matched otherwise.
+func WithKnownHashes(known KnownHashes, policy KnownPolicy) Option {
+	return func(w *Walkman) {
+		w.known = known
+		w.knownPolicy = policy
+	}
+}
+
+// Reads hashes from r, one line each, e.g the output of md5sum or the
+// CSV files of the NSRL: the first field of 32 hexadecimal digits on a
+// line is taken as an md5 digest, or a field prefixed with its
+// algorithm as walks write them. Empty lines, lines starting with #
+// and lines without a hash, like headers, are ignored.
+func ReadKnownHashes(r io.Reader) (KnownHashes, error) {
+	known := make(KnownHashes)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == '\t' || r == ' '
+		})
+
+		for _, field := range fields {
+			if hash, ok := knownHash(strings.Trim(field, `"`)); ok {
+				known[hash] = true
+				break
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("walkman: reading known hashes: %w", err)
+	}
+	return known, nil
+}
+
+// Reads the hashes of the file at path, see ReadKnownHashes.
+func LoadKnownHashes(path string) (KnownHashes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadKnownHashes(f)
+}
+
+// Returns field as a hash of walks, if it is one.
+func knownHash(field string) (string, bool) {
+	if alg := algorithmOf(field); alg != "" && !listedOnly(alg) {
+		return field, true
+	}
+
+	if _, err := hex.DecodeString(field); err == nil && len(field) == 32 {
+		return AlgorithmMD5 + ":" + strings.ToLower(field), true
+	}
+	return "", false
+}
+
+// Reports whether the file at path with hash is known, see WithKnownHashes.
+func (wm *Walkman) isKnown(path, hash string) bool {
+	if !wm.known[hash] {
+		return false
+	}
+
+	atomic.AddInt64(&wm.stats.FilesKnown, 1)
+	wm.logger.Info("known file", "path", path, "hash", hash)
+	wm.emit(Event{Kind: EventFileKnown, Path: path, Hash: hash})
+	return true
+}
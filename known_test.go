@@ -0,0 +1,60 @@
+package walkman
+
+import (
+	"crypto/md5"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithKnownHashes(t *testing.T) {
+	dir := t.TempDir()
+	system := writeFile(t, dir, "system32/kernel.dll", "kernel", time.Now())
+	payload := writeFile(t, dir, "Downloads/invoice.exe", "payload", time.Now())
+	writeFile(t, dir, "Documents/letter.txt", "letter", time.Now())
+
+	md5sum := func(s string) string { return fmt.Sprintf("%x", md5.Sum([]byte(s))) }
+
+	// NSRL like CSV and md5sum output
+	list := `"SHA-1","MD5","CRC32","FileName"
+"0000000000000000000000000000000000000000","` + strings.ToUpper(md5sum("kernel")) + `","00000000","kernel.dll"
+# indicators
+` + md5sum("payload") + `  invoice.exe
+`
+
+	known, err := ReadKnownHashes(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(known) != 2 || !known["md5:"+md5sum("kernel")] {
+		t.Fatalf("expected 2 md5 hashes, got %v", known)
+	}
+
+	// every file has a size of its own, they are hashed all the same
+	wm := New(WithContentHash(), WithSizeGrouping(), WithKnownHashes(known, KnownFlag))
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range hashes.ToSlice() {
+		if want := f.Path == system.Path || f.Path == payload.Path; f.Known != want {
+			t.Errorf("expected %s known %v, got %v", f.Path, want, f.Known)
+		}
+	}
+
+	if n := wm.LastRunStats().FilesKnown; n != 2 {
+		t.Errorf("expected 2 known files, got %d", n)
+	}
+
+	hashes, err = New(WithContentHash(), WithKnownHashes(known, KnownExclude)).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := hashes.ToSlice(); len(files) != 1 || files[0].Known {
+		t.Errorf("expected only the letter left, got %v", files)
+	}
+}
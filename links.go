@@ -0,0 +1,72 @@
+package walkman
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// What walks do with symbolic links and, on Windows, junctions and
+// other mount points, see WithSymlinks. They are never followed.
+type SymlinkPolicy int
+
+const (
+	SymlinkSkip   SymlinkPolicy = iota // leave them out, the default
+	SymlinkRecord                      // list them, see Results.Symlinks
+)
+
+// Choose what walks do with links, e.g SymlinkRecord to find those
+// whose target is gone with Results.BrokenSymlinks. Recorded links are
+// never duplicates of one another: each is kept in a group of its own,
+// keyed by its path, e.g link:/home/me/latest or deadlink:/home/me/old
+// when its target is missing, with File.Target set to the target.
+func WithSymlinks(policy SymlinkPolicy) Option {
+	return func(w *Walkman) {
+		w.symlinks = policy
+	}
+}
+
+// Reports whether fi is a link to another file or directory, which
+// walks skip so that no loop is followed and nothing is counted twice:
+// symbolic links and, on Windows, junctions and other mount points.
+func isLink(fi fs.FileInfo) bool {
+	return fi.Mode()&fs.ModeSymlink != 0 || reparsePoint(fi)
+}
+
+// Lists the link at path, see SymlinkRecord. Links of mounted file
+// systems can not be resolved and are never found broken.
+func (wm *Walkman) addLink(path string, fi fs.FileInfo) {
+	if isRemote(path) {
+		wm.pairs <- pair{hash: AlgorithmLink + ":" + path, path: path, info: fi}
+		return
+	}
+
+	target, err := os.Readlink(longPath(path))
+	if err != nil {
+		wm.addError(err)
+		return
+	}
+
+	// a missing target, or a loop of links
+	key := AlgorithmLink
+	if _, err := os.Stat(longPath(path)); err != nil && !errors.Is(err, fs.ErrPermission) {
+		key = AlgorithmDeadLink
+	}
+	wm.pairs <- pair{hash: key + ":" + path, path: path, info: fi, target: target}
+}
+
+// Returns the links found with SymlinkRecord, broken or not,
+// sorted by path.
+func (hashes Results) Symlinks() FileList {
+	return hashes.listed(func(alg string, f File) bool {
+		return alg == AlgorithmLink || alg == AlgorithmDeadLink
+	})
+}
+
+// Returns the links found with SymlinkRecord whose target is
+// missing, sorted by path.
+func (hashes Results) BrokenSymlinks() FileList {
+	return hashes.listed(func(alg string, f File) bool {
+		return alg == AlgorithmDeadLink
+	})
+}
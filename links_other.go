@@ -0,0 +1,10 @@
+//go:build !windows
+
+package walkman
+
+import "io/fs"
+
+// Only Windows has reparse points.
+func reparsePoint(fi fs.FileInfo) bool {
+	return false
+}
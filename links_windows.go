@@ -0,0 +1,17 @@
+package walkman
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// Reports whether fi is a junction or mount point, which Go reports as
+// irregular files. Reparse points of files kept elsewhere, e.g OneDrive
+// placeholders, are regular files and not reported.
+func reparsePoint(fi fs.FileInfo) bool {
+	attrs, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+	return fi.Mode()&fs.ModeIrregular != 0 && attrs.FileAttributes&syscall.FILE_ATTRIBUTE_REPARSE_POINT != 0
+}
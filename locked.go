@@ -0,0 +1,52 @@
+package walkman
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// Open files held open by another process without sharing them, e.g the
+// registry hives, Outlook data files or databases on Windows, with backup
+// semantics when opening them as usual fails, as backup programs do. The
+// file is then shared for reading, writing and deletion, so that it can be
+// read whenever its owner allows any sharing at all, and ACLs are bypassed
+// if the process holds an enabled SeBackupPrivilege. Files locked for good
+// are still reported, see WalkReport.Locked.
+//
+// Only Windows has such locks, elsewhere this does nothing.
+func WithBackupSemantics() Option {
+	return func(w *Walkman) {
+		w.config.backupSemantics = true
+	}
+}
+
+// Reports whether err tells that a file could not be read because another
+// process locked it, ERROR_SHARING_VIOLATION or ERROR_LOCK_VIOLATION on
+// Windows. Retrying once that process is done may succeed.
+func IsLocked(err error) bool {
+	return isLocked(err)
+}
+
+// Returns the paths that could not be read as other processes locked them,
+// see IsLocked.
+func (r WalkReport) Locked() []string {
+	var paths []string
+	for _, err := range r.Errors {
+		var pathErr *fs.PathError
+		if IsLocked(err) && errors.As(err, &pathErr) {
+			paths = append(paths, pathErr.Path)
+		}
+	}
+	return paths
+}
+
+// Opens the file at path for reading,
+// with backup semantics if it is locked and asked to.
+func (wm *Walkman) openFile(path string) (*os.File, error) {
+	file, err := os.Open(longPath(path))
+	if err != nil && wm.config.backupSemantics && IsLocked(err) {
+		return openBackup(path)
+	}
+	return file, err
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package walkman
+
+import (
+	"errors"
+	"os"
+)
+
+// Only Windows locks files for other processes.
+func isLocked(err error) bool {
+	return false
+}
+
+func openBackup(path string) (*os.File, error) {
+	return nil, &os.PathError{Op: "open", Path: path, Err: errors.ErrUnsupported}
+}
@@ -0,0 +1,50 @@
+package walkman
+
+import (
+	"context"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Opens the file at path without sharing it, as databases do.
+func lockFile(t *testing.T, path string) {
+	t.Helper()
+
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ, 0, nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.CloseHandle(h) })
+}
+
+func TestWalkLockedFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "a", time.Now())
+	locked := writeFile(t, dir, "locked.db", "b", time.Now())
+	lockFile(t, locked.Path)
+
+	for _, options := range [][]Option{
+		{WithContentHash()},
+		{WithContentHash(), WithBackupSemantics()},
+	} {
+		_, report, err := New(options...).WalkWithReport(context.Background(), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := report.Locked(); len(got) != 1 || filepath.Clean(got[0]) != locked.Path {
+			t.Errorf("expected %s reported as locked, got %v", locked.Path, got)
+		}
+
+		if report.Stats.FilesLocked != 1 || len(report.Denied()) != 0 {
+			t.Errorf("expected 1 locked file and none denied, got %+v", report.Stats)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package walkman
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Log what walks do to logger: skipped directories at info level,
+// directories entered and files hashed at debug level, and files or
+// directories left out because of an error at error level, see Errors.
+//
+// Nothing is logged by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(w *Walkman) {
+		w.logger = logger
+	}
+}
+
+// slog.Handler dropping every record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
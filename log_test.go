@@ -0,0 +1,44 @@
+package walkman
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithLogger(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "docs/a.txt", "a", time.Now())
+	writeFile(t, dir, ".git/HEAD", "ref: refs/heads/master", time.Now())
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	if _, err := New(WithLogger(logger)).Walk(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	logged := map[string]string{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record struct{ Level, Msg, Path string }
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		logged[record.Msg+" "+record.Path] = record.Level
+	}
+
+	want := map[string]string{
+		"skipping directory " + filepath.Join(dir, ".git"): "INFO",
+		"entering directory " + filepath.Join(dir, "docs"): "DEBUG",
+		"hashed file " + a.Path:                            "DEBUG",
+	}
+
+	for msg, level := range want {
+		if logged[msg] != level {
+			t.Errorf("expected %q at %s, got %v", msg, level, logged)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package walkman
+
+// Paths are never too long outside of Windows.
+func longPath(path string) string {
+	return path
+}
@@ -0,0 +1,29 @@
+package walkman
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Paths this long need the \\?\ prefix, as directories must leave
+// room for a 8.3 file name below MAX_PATH (260).
+const maxShortPath = 248
+
+// Returns path in the \\?\ form Windows takes for long paths, made
+// absolute as the form does not allow relative ones. os does so for
+// absolute paths only.
+func longPath(path string) string {
+	if len(path) < maxShortPath || strings.HasPrefix(path, `\\?\`) || isRemote(path) {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:] // \\server\share
+	}
+	return `\\?\` + abs
+}
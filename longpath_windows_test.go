@@ -0,0 +1,63 @@
+package walkman
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLongPath(t *testing.T) {
+	long := strings.Repeat(`a\`, 150) + "file.txt"
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ path, want string }{
+		{`C:\short.txt`, `C:\short.txt`},
+		{`C:\` + long, `\\?\C:\` + long},
+		{`\\server\share\` + long, `\\?\UNC\server\share\` + long},
+		{`\\?\C:\` + long, `\\?\C:\` + long},
+		{long, `\\?\` + filepath.Join(cwd, long)},
+		{"sftp://nas/" + long, "sftp://nas/" + long},
+	} {
+		if got := longPath(tt.path); got != tt.want {
+			t.Errorf("longPath(%.40q...) = %.40q..., want %.40q...", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestWalkLongPaths(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	// relative, as os only fixes absolute paths itself
+	deep := strings.Repeat(`d\`, 130)
+	if err := os.MkdirAll(longPath(deep), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(longPath(deep+"file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wm := New(WithContentHash())
+	hashes, err := wm.Walk("d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := hashes.ToSlice()
+	if len(files) != 1 || files[0].Path != deep+"file.txt" || len(wm.Errors()) != 0 {
+		t.Errorf("expected %s, got %v and errors %v", deep+"file.txt", files, wm.Errors())
+	}
+}
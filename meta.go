@@ -0,0 +1,66 @@
+package walkman
+
+import (
+	"io"
+	"reflect"
+	"time"
+)
+
+// Meta is what extractors learnt from the content of a file, see WithMetadata.
+// Fields an extractor knows nothing about are left zero.
+type Meta struct {
+	Taken  time.Time `json:"taken,omitempty"`  // capture date of photos
+	Camera string    `json:"camera,omitempty"` // make and model of the camera
+	Width  int       `json:"width,omitempty"`  // in pixels
+	Height int       `json:"height,omitempty"` // in pixels
+
+	Artist   string        `json:"artist,omitempty"`
+	Title    string        `json:"title,omitempty"`
+	Album    string        `json:"album,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"` // of audio tracks
+
+	Xattrs map[string][]byte `json:"xattrs,omitempty"` // extended attributes by name, see ExtractXattrs
+}
+
+// Extractor fills meta with what it finds in the file at path, read through
+// open. Files it does not understand are left alone without an error, so
+// that extractors for different formats can be combined.
+type Extractor func(path string, open func() (io.ReadCloser, error), meta *Meta) error
+
+// Run extractors on every file to fill File.Meta, e.g ExtractEXIF. Files
+// none of them knows anything about have no Meta.
+//
+// Extractors read the files on their own, usually only their first bytes.
+// Members of archives are left out.
+func WithMetadata(extractors ...Extractor) Option {
+	return func(w *Walkman) {
+		w.extractors = append(w.extractors, extractors...)
+	}
+}
+
+// Returns the metadata of the file at path, nil if there is none.
+func (wm *Walkman) extract(path string, open func() (io.ReadCloser, error)) *Meta {
+	if len(wm.extractors) == 0 {
+		return nil
+	}
+
+	meta := new(Meta)
+	for _, extract := range wm.extractors {
+		if err := extract(path, open, meta); err != nil {
+			wm.addError(err)
+		}
+	}
+
+	if reflect.ValueOf(*meta).IsZero() {
+		return nil
+	}
+	return meta
+}
+
+// Pixels of an image, zero if unknown.
+func (m *Meta) pixels() int64 {
+	if m == nil {
+		return 0
+	}
+	return int64(m.Width) * int64(m.Height)
+}
@@ -0,0 +1,101 @@
+package walkman
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+)
+
+// Bytes looked at by http.DetectContentType.
+const sniffLen = 512
+
+// Detect the content type of every file from its first bytes with
+// http.DetectContentType into File.MIME, e.g to group or filter
+// by real type rather than by extension.
+//
+// The bytes read by content hashers are sniffed as they go by, so
+// WithContentHash and WithMIME read files once. Other hashers cost
+// a read of the first 512 bytes of each file.
+func WithMIME() Option {
+	return func(w *Walkman) {
+		w.config.mime = true
+	}
+}
+
+// Keeps the first bytes read through the files it opens.
+type sniffer struct {
+	open func() (io.ReadCloser, error)
+	head []byte
+}
+
+// Returns a sniffer keeping the first bytes read through open,
+// which is passed on to the hasher in its place.
+func newSniffer(open func() (io.ReadCloser, error)) *sniffer {
+	return &sniffer{open: open}
+}
+
+func (s *sniffer) Open() (io.ReadCloser, error) {
+	rc, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	return &sniffReader{ReadCloser: rc, s: s}, nil
+}
+
+// Returns the content type of the file. If nothing was read through
+// the sniffer, e.g by a name hasher, the file is opened to read its head.
+func (s *sniffer) mime() string {
+	if len(s.head) == 0 {
+		rc, err := s.open()
+		if err != nil {
+			return ""
+		}
+		defer rc.Close()
+
+		head := make([]byte, sniffLen)
+		n, _ := io.ReadFull(rc, head)
+		s.head = head[:n]
+	}
+
+	if len(s.head) == 0 {
+		return ""
+	}
+	return http.DetectContentType(s.head)
+}
+
+type sniffReader struct {
+	io.ReadCloser
+	s   *sniffer
+	off int
+}
+
+func (r *sniffReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+
+	// files opened again only add what was not read yet
+	if r.off == len(r.s.head) && r.off < sniffLen {
+		keep := sniffLen - r.off
+		if keep > n {
+			keep = n
+		}
+		r.s.head = append(r.s.head, p[:keep]...)
+	}
+
+	r.off += n
+	return n, err
+}
+
+// Hashes the file at path with the hasher of wm, detecting
+// its content type on the way if WithMIME was given.
+func (wm *Walkman) hash(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (hash, mime string, err error) {
+	if !wm.config.mime {
+		hash, err = wm.hashFunc(path, info, open)
+		return hash, "", err
+	}
+
+	s := newSniffer(open)
+	if hash, err = wm.hashFunc(path, info, s.Open); err != nil {
+		return "", "", err
+	}
+	return hash, s.mime(), nil
+}
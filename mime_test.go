@@ -0,0 +1,30 @@
+package walkman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithMIME(t *testing.T) {
+	for _, hasher := range []Option{WithHasher(NameSizeHasher), WithContentHash()} {
+		dir := t.TempDir()
+		png := writeFile(t, dir, "image.dat", "\x89PNG\r\n\x1a\n rest of the image", time.Now())
+		txt := writeFile(t, dir, "notes.png", "not an image at all", time.Now())
+
+		hashes, err := New(hasher, WithMIME()).Walk(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]string{
+			png.Path: "image/png",
+			txt.Path: "text/plain; charset=utf-8",
+		}
+
+		for _, f := range hashes.ToSlice() {
+			if f.MIME != want[f.Path] {
+				t.Errorf("MIME of %s = %q, want %q", f.Path, f.MIME, want[f.Path])
+			}
+		}
+	}
+}
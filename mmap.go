@@ -0,0 +1,89 @@
+package walkman
+
+import (
+	"io"
+	"io/fs"
+)
+
+// Size from which WithMmap maps files unless told otherwise.
+const defaultMmapThreshold = 64 << 20
+
+// Hash files of at least threshold bytes (64MB if not positive) by
+// mapping them into memory rather than reading them into a buffer,
+// which saves a system call per 32KB read. Only files on the local
+// disk are mapped, on 64-bit Linux, macOS and BSDs. Others, and files
+// that cannot be mapped, are read as usual.
+//
+// A mapped file truncated by another process while it is hashed
+// crashes the program, so avoid it on trees being written to.
+func WithMmap(threshold int64) Option {
+	return func(w *Walkman) {
+		if threshold <= 0 {
+			threshold = defaultMmapThreshold
+		}
+		w.mmapThreshold = threshold
+	}
+}
+
+// Like opener, mapping fi into memory when it is large enough,
+// see WithMmap.
+func (wm *Walkman) hashOpener(path string, fi fs.FileInfo) func() (io.ReadCloser, error) {
+	open := wm.opener(path)
+	if wm.mmapThreshold == 0 || fi.Size() < wm.mmapThreshold {
+		return open
+	}
+
+	if _, _, ok := wm.resolve(path); ok {
+		return open
+	}
+
+	// pages would be read by the kernel regardless of the limit
+	if wm.mountOf(path) != nil {
+		return open
+	}
+
+	return func() (io.ReadCloser, error) {
+		// the descriptor is closed once the file is mapped
+		release := wm.openSlot()
+		r, err := mmapOpen(path)
+		release()
+
+		if err == nil {
+			return r, nil
+		}
+		return open()
+	}
+}
+
+// Reads a file mapped into memory.
+type mmapReader struct {
+	data   []byte
+	off    int
+	unmap  func([]byte) error
+	closed bool
+}
+
+func (r *mmapReader) Read(p []byte) (int, error) {
+	if r.off >= len(r.data) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, r.data[r.off:])
+	r.off += n
+	return n, nil
+}
+
+// Hands the rest of the file to w at once, used by io.Copy.
+func (r *mmapReader) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(r.data[r.off:])
+	r.off += n
+	return int64(n), err
+}
+
+func (r *mmapReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.unmap(r.data)
+}
@@ -0,0 +1,13 @@
+//go:build !((linux || darwin || freebsd || netbsd || openbsd || dragonfly) && (amd64 || arm64 || loong64 || mips64 || mips64le || ppc64 || ppc64le || riscv64 || s390x))
+
+package walkman
+
+import (
+	"errors"
+	"io"
+)
+
+// Files are never mapped on this platform, see WithMmap.
+func mmapOpen(path string) (io.ReadCloser, error) {
+	return nil, errors.ErrUnsupported
+}
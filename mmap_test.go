@@ -0,0 +1,53 @@
+package walkman
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithMmap(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("walkman", 100000)
+	large := writeFile(t, dir, "large.bin", content, time.Now())
+	writeFile(t, dir, "copy.bin", content, time.Now())
+	writeFile(t, dir, "small.txt", "small", time.Now())
+
+	mapped, err := New(WithContentHash(), WithMmap(1024)).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mapped) != 2 || len(mapped[hashOf(t, mapped, large.Path)]) != 2 {
+		t.Errorf("expected the large files in a group, got %v", mapped)
+	}
+
+	for hash, files := range read {
+		if len(mapped[hash]) != len(files) {
+			t.Errorf("expected the same hashes whether files are mapped or not, got %v and %v", mapped, read)
+		}
+	}
+
+	r, err := mmapOpen(large.Path)
+	if err != nil {
+		t.Skipf("files can not be mapped here: %v", err)
+	}
+	defer r.Close()
+
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(r, head); err != nil || string(head) != content[:10] {
+		t.Fatalf("read %q, %v from the mapped file", head, err)
+	}
+
+	var rest bytes.Buffer
+	if _, err := io.Copy(&rest, r); err != nil || rest.String() != content[10:] {
+		t.Errorf("copied %d bytes, %v from the mapped file, want %d", rest.Len(), err, len(content)-10)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build (linux || darwin || freebsd || netbsd || openbsd || dragonfly) && (amd64 || arm64 || loong64 || mips64 || mips64le || ppc64 || ppc64le || riscv64 || s390x)
+
+package walkman
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// Maps the file at path into memory, see WithMmap.
+func mmapOpen(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // the mapping outlives the descriptor
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.Mode().IsRegular() || fi.Size() == 0 {
+		return nil, errors.New("walkman: only regular files that are not empty can be mapped")
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	return &mmapReader{data: data, unmap: syscall.Munmap}, nil
+}
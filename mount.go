@@ -0,0 +1,113 @@
+package walkman
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A file system walked in place of the local disk for paths below prefix.
+type mount struct {
+	prefix string
+	fsys   fs.FS
+}
+
+// Walk roots starting with prefix inside fsys instead of the local disk,
+// e.g remote file systems:
+//
+//	wm := walkman.New(walkman.WithFS("sftp://backup@nas", nasFS))
+//	hashes, err := wm.WalkDirs("/home/me/photos", "sftp://backup@nas/srv/photos")
+//
+// The rest of a root is a slash separated path inside fsys, an empty one
+// stands for its root. Files are reported under their full URL, so local
+// and remote roots can be walked together. fsys must implement
+// fs.ReadDirFS or return directories implementing fs.ReadDirFile, see
+// fs.WalkDir.
+//
+// Plans never touch files of a mounted file system.
+func WithFS(prefix string, fsys fs.FS) Option {
+	return func(w *Walkman) {
+		w.mounts = append(w.mounts, mount{prefix: strings.TrimSuffix(prefix, "/"), fsys: fsys})
+	}
+}
+
+// Returns the mount path lies in and the name of path inside it.
+func (wm *Walkman) resolve(path string) (*mount, string, bool) {
+	for i := range wm.mounts {
+		m := &wm.mounts[i]
+		if path != m.prefix && !strings.HasPrefix(path, m.prefix+"/") {
+			continue
+		}
+
+		name := strings.Trim(path[len(m.prefix):], "/")
+		if name == "" {
+			name = "."
+		}
+		return m, name, true
+	}
+	return nil, "", false
+}
+
+// Returns the reported path of name inside m.
+func (m *mount) join(name string) string {
+	if name == "." {
+		return m.prefix
+	}
+	return m.prefix + "/" + name
+}
+
+// Canonical form of a root, so that it compares equal
+// to the paths reported below it.
+func (wm *Walkman) cleanRoot(path string) string {
+	if m, name, ok := wm.resolve(path); ok {
+		return m.join(name)
+	}
+	return path
+}
+
+func (wm *Walkman) stat(path string) (fs.FileInfo, error) {
+	if m, name, ok := wm.resolve(path); ok {
+		return fs.Stat(m.fsys, name)
+	}
+	return os.Stat(longPath(path))
+}
+
+// Opens the file at path for a Hasher.
+func (wm *Walkman) opener(path string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return wm.openLimited(path, func() (io.ReadCloser, error) {
+			if m, name, ok := wm.resolve(path); ok {
+				return m.fsys.Open(name)
+			}
+			return wm.openFile(path)
+		})
+	}
+}
+
+// Walks the tree at root like filepath.WalkDir, inside its mount if any.
+func (wm *Walkman) walkDir(root string, fn fs.WalkDirFunc) error {
+	m, name, ok := wm.resolve(root)
+	if !ok {
+		long := longPath(root)
+		if long == root {
+			return filepath.WalkDir(root, fn)
+		}
+
+		// report paths below root as given
+		return filepath.WalkDir(long, func(path string, d fs.DirEntry, err error) error {
+			return fn(root+path[len(long):], d, err)
+		})
+	}
+
+	return fs.WalkDir(m.fsys, name, func(path string, d fs.DirEntry, err error) error {
+		return fn(m.join(path), d, err)
+	})
+}
+
+// Reports whether path names a file on a mounted file system,
+// by the scheme of its URL.
+func isRemote(path string) bool {
+	return strings.Contains(path, "://")
+}
@@ -0,0 +1,122 @@
+package walkman
+
+import (
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWithFS(t *testing.T) {
+	dir := t.TempDir()
+	local := writeFile(t, dir, "photo.jpg", "pixels", time.Now())
+
+	remote := fstest.MapFS{
+		"srv/photos/2021/photo.jpg": {Data: []byte("pixels")},
+		"srv/photos/other.jpg":      {Data: []byte("other pixels")},
+		"srv/photos/.cache/x.jpg":   {Data: []byte("pixels")},
+		"srv/music/song.mp3":        {Data: []byte("pixels")},
+	}
+
+	wm := New(WithContentHash(), WithFS("sftp://me@nas", remote))
+	hashes, err := wm.WalkDirs(dir, "sftp://me@nas/srv/photos/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := wm.Errors(); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	var group FileList
+	for _, files := range hashes {
+		for _, f := range files {
+			if f.Path == local.Path {
+				group = files
+			}
+		}
+	}
+
+	if len(group) != 2 {
+		t.Fatalf("expected the local and remote photo in one group, got %v", group)
+	}
+
+	for _, f := range group {
+		if f.Path != local.Path && f.Path != "sftp://me@nas/srv/photos/2021/photo.jpg" {
+			t.Errorf("unexpected file %s", f.Path)
+		}
+	}
+
+	if n := len(hashes.ToSlice()); n != 3 {
+		t.Errorf("expected 3 files, got %d", n)
+	}
+
+	if plan := NewPlan(hashes, KeepOldest(), ActionDelete, ""); len(plan) != 0 {
+		t.Errorf("expected remote files to be left out of plans, got %v", plan)
+	}
+}
+
+func TestWithFSRoot(t *testing.T) {
+	remote := fstest.MapFS{
+		"a.jpg":     {Data: []byte("pixels")},
+		"sub/b.jpg": {Data: []byte("pixels")},
+	}
+
+	// the root of a file system is named ".", which is not a hidden directory
+	hashes, err := New(WithFS("sftp://me@nas", remote)).Walk("sftp://me@nas/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := hashes.ToSlice(); len(files) != 2 {
+		t.Errorf("expected 2 files, got %v", files)
+	}
+}
+
+// Counts the files stat'd on a file system.
+type statCounter struct {
+	fstest.MapFS
+	mu    sync.Mutex
+	stats map[string]int
+}
+
+func (c *statCounter) Stat(name string) (fs.FileInfo, error) {
+	c.mu.Lock()
+	c.stats[name]++
+	c.mu.Unlock()
+	return c.MapFS.Stat(name)
+}
+
+func TestStatOnce(t *testing.T) {
+	remote := &statCounter{
+		MapFS: fstest.MapFS{
+			"a.txt":     {Data: []byte("a")},
+			"sub/b.txt": {Data: []byte("a")},
+		},
+		stats: map[string]int{},
+	}
+
+	hashes, err := New(WithFS("sftp://nas", remote)).Walk("sftp://nas/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := hashes.ToSlice(); len(files) != 2 {
+		t.Fatalf("expected 2 files, got %v", files)
+	}
+
+	for _, f := range hashes.ToSlice() {
+		if f.Stats == nil || f.Stats.Size() != 1 {
+			t.Errorf("expected the info of %s from the walk, got %v", f.Path, f.Stats)
+		}
+	}
+
+	// fs.WalkDir stats the directories it starts from,
+	// files come with their info
+	for name := range remote.stats {
+		if name != "." && name != "sub" {
+			t.Errorf("expected only directories to be stat'd, got %v", remote.stats)
+		}
+	}
+}
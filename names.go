@@ -0,0 +1,172 @@
+package walkman
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Marks left by file managers and browsers on copies, removed by
+// normalizeName until none is left.
+var copyMarks = []*regexp.Regexp{
+	regexp.MustCompile(`^copy of `),                 // Windows XP
+	regexp.MustCompile(`\s*\(\d+\)$`),               // browsers, "report (2)"
+	regexp.MustCompile(`\s*\[\d+\]$`),               // "report[1]"
+	regexp.MustCompile(`\s*-\s*copy(\s*\(\d+\))?$`), // Windows, "report - Copy (2)"
+	regexp.MustCompile(`[\s_-]copy(\s*\d+)?$`),      // macOS, "report copy 2"
+}
+
+// Stems shorter than this many letters only match once normalized,
+// as a few edits turn any short name into another.
+const minFuzzyName = 6
+
+// Groups files whose names look like copies of one another, whatever
+// their content, e.g thesis_final.docx, thesis_final (2).docx and
+// Copy of thesis_final.docx, for users to review. Names are compared
+// in lower case, without the marks file managers and browsers add to
+// copies, and then allowing maxDistance edits (insertions, deletions
+// or substitutions) to the part before the extension; 0 only allows
+// the marks. Names differing only in digits, like IMG_0001.jpg and
+// IMG_0002.jpg, are never alike.
+//
+// Groups are keyed by the smallest of their normalized names, e.g
+// similar:thesis_final.docx. Every file is in exactly one group,
+// use the groups of more than one. Directories and links are left out.
+//
+// With maxDistance above 0 every pair of names of the same extension
+// is compared, which is slow for hundreds of thousands of files.
+func (hashes Results) SimilarNames(maxDistance int) Results {
+	byName := make(map[string]FileList)
+
+	for hash, files := range hashes {
+		alg := algorithmOf(hash)
+		if alg == AlgorithmLink || alg == AlgorithmDeadLink {
+			continue
+		}
+
+		for _, f := range files {
+			if !f.Stats.IsDir() {
+				name := normalizeName(filepath.Base(f.Path))
+				byName[name] = append(byName[name], f)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// union find over the names, the root being the smallest
+	root := make([]int, len(names))
+	for i := range root {
+		root[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if root[i] != i {
+			root[i] = find(root[i])
+		}
+		return root[i]
+	}
+
+	if maxDistance > 0 {
+		byExt := make(map[string][]int)
+		for i, name := range names {
+			ext := filepath.Ext(name)
+			byExt[ext] = append(byExt[ext], i)
+		}
+
+		for _, indexes := range byExt {
+			for n, i := range indexes {
+				for _, j := range indexes[n+1:] {
+					if namesAlike(names[i], names[j], maxDistance) {
+						a, b := find(i), find(j)
+						if b < a {
+							a, b = b, a
+						}
+						root[b] = a
+					}
+				}
+			}
+		}
+	}
+
+	similar := make(Results)
+	for i, name := range names {
+		key := "similar:" + names[find(i)]
+		similar[key] = append(similar[key], byName[name]...)
+	}
+	return similar
+}
+
+// Returns name in lower case without the marks of copies, see copyMarks.
+func normalizeName(name string) string {
+	name = strings.ToLower(name)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	for changed := true; changed; {
+		changed = false
+		for _, mark := range copyMarks {
+			if s := mark.ReplaceAllString(stem, ""); s != stem && s != "" {
+				stem, changed = s, true
+			}
+		}
+	}
+	return stem + ext
+}
+
+// Reports whether the stems of the normalized names a and b are at
+// most maxDistance edits apart, see SimilarNames.
+func namesAlike(a, b string, maxDistance int) bool {
+	sa := []rune(strings.TrimSuffix(a, filepath.Ext(a)))
+	sb := []rune(strings.TrimSuffix(b, filepath.Ext(b)))
+
+	if len(sa) < minFuzzyName || len(sb) < minFuzzyName {
+		return false
+	}
+
+	if d := len(sa) - len(sb); d > maxDistance || -d > maxDistance {
+		return false
+	}
+
+	// numbered series, e.g IMG_0001 and IMG_0002
+	letters := func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return -1
+		}
+		return r
+	}
+	if strings.Map(letters, string(sa)) == strings.Map(letters, string(sb)) {
+		return false
+	}
+
+	return editDistance(sa, sb) <= maxDistance
+}
+
+// Returns the Levenshtein distance of a and b.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,69 @@
+package walkman
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSimilarNames(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"thesis_final.docx", "thesis_final (2).docx", "sub/Copy of thesis_final.docx",
+		"thesis_finl.docx", "thesis_final - Copy (3).docx", "thesis_final copy 2.docx",
+		"thesis_final.pdf", "IMG_0001.jpg", "IMG_0002.jpg", "a.txt", "b.txt",
+	} {
+		writeFile(t, dir, name, name, time.Now())
+	}
+
+	hashes, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(files FileList) []string {
+		var names []string
+		for _, f := range files {
+			rel, _ := filepath.Rel(dir, f.Path)
+			names = append(names, rel)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	marked := hashes.SimilarNames(0)
+	if got := names(marked["similar:thesis_final.docx"]); len(got) != 5 {
+		t.Errorf("expected the 5 marked copies of the thesis grouped, got %v", got)
+	}
+
+	if n := len(marked); n != 7 {
+		t.Errorf("expected the others apart, got %d groups: %v", n, marked)
+	}
+
+	fuzzy := hashes.SimilarNames(2)
+	if got := names(fuzzy["similar:thesis_final.docx"]); len(got) != 6 {
+		t.Errorf("expected the misspelt copy grouped too, got %v", got)
+	}
+
+	// other extensions, numbered series and short names stay apart
+	if n := len(fuzzy); n != 6 {
+		t.Errorf("expected 6 groups, got %d: %v", n, fuzzy)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"thesis", "thesis", 0},
+	} {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package walkman
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Files read at once from a root on a network file system,
+// unless set by WithPerMountConcurrency.
+const defaultNetworkReaders = 2
+
+// Limit the files read at once below each of the given directories across
+// all workers, e.g {"/mnt/nas": 4, "/mnt/usb": 1}. A file counts against
+// the deepest directory listed that holds it. A zero or negative count
+// lifts the limit.
+//
+// Roots on NFS, SMB, FUSE and other network file systems, or walked
+// over a URL like sftp://, are limited to two readers unless listed:
+// many network file systems serve a few sequential reads far faster than
+// dozens of parallel ones, which the hash workers would issue otherwise.
+// Directories are still read by all the walk workers.
+func WithPerMountConcurrency(limits map[string]int) Option {
+	return func(w *Walkman) {
+		w.mountConcurrency = limits
+	}
+}
+
+// A directory whose files are read by at most so many workers at once,
+// see WithPerMountConcurrency.
+type mountLimit struct {
+	dir     string
+	readers *limiter
+}
+
+// Sets the limits of WithPerMountConcurrency up for a walk of roots,
+// detecting those on network file systems.
+func (wm *Walkman) limitMounts(roots []string) {
+	wm.mountLimits = nil
+
+	for dir, n := range wm.mountConcurrency {
+		if n > 0 {
+			wm.mountLimits = append(wm.mountLimits, mountLimit{dir: filepath.Clean(dir), readers: newLimiter(n)})
+		}
+	}
+
+	for _, root := range roots {
+		if _, listed := wm.mountConcurrency[root]; listed {
+			continue
+		}
+
+		kind, ok := "", false
+		if isRemote(root) {
+			kind, ok = root[:strings.Index(root, "://")], true
+		} else {
+			kind, ok = networkFS(root)
+		}
+
+		if ok {
+			wm.logger.Info("network file system, limiting readers", "root", root, "type", kind, "readers", defaultNetworkReaders)
+			wm.mountLimits = append(wm.mountLimits, mountLimit{dir: root, readers: newLimiter(defaultNetworkReaders)})
+		}
+	}
+}
+
+// Returns the limiter of the deepest directory of WithPerMountConcurrency
+// holding path, nil if none does.
+func (wm *Walkman) mountOf(path string) *limiter {
+	var deepest *mountLimit
+	for i, m := range wm.mountLimits {
+		if IsUnder(path, m.dir) && (deepest == nil || len(m.dir) > len(deepest.dir)) {
+			deepest = &wm.mountLimits[i]
+		}
+	}
+
+	if deepest == nil {
+		return nil
+	}
+	return deepest.readers
+}
+
+// Waits for a reader of the directory of WithPerMountConcurrency holding
+// path to be available and returns the function giving it back.
+func (wm *Walkman) mountSlot(path string) func() {
+	readers := wm.mountOf(path)
+	if readers == nil {
+		return func() {}
+	}
+
+	readers.acquire()
+
+	var once sync.Once
+	return func() {
+		once.Do(readers.release)
+	}
+}
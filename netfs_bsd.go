@@ -0,0 +1,31 @@
+//go:build darwin || freebsd
+
+package walkman
+
+import (
+	"strings"
+	"syscall"
+)
+
+// Returns the type of the network file system holding path,
+// false if it is local.
+func networkFS(path string) (string, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return "", false
+	}
+
+	var name strings.Builder
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name.WriteByte(byte(c))
+	}
+
+	switch kind := name.String(); {
+	case kind == "nfs", kind == "smbfs", kind == "afpfs", kind == "webdav", strings.Contains(kind, "fuse"):
+		return kind, true
+	}
+	return "", false
+}
@@ -0,0 +1,28 @@
+package walkman
+
+import "syscall"
+
+// Magic numbers of network file systems in statfs(2).
+var networkMagic = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse",
+	0x01021997: "9p",
+	0x00c36400: "ceph",
+	0x6b414653: "afs",
+	0x73757245: "coda",
+}
+
+// Returns the type of the network file system holding path,
+// false if it is local.
+func networkFS(path string) (string, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return "", false
+	}
+
+	kind, ok := networkMagic[uint32(st.Type)]
+	return kind, ok
+}
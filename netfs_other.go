@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package walkman
+
+// File systems are assumed local on this platform.
+func networkFS(path string) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,57 @@
+package walkman
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRemoteRootsLimited(t *testing.T) {
+	remote := &openCounter{fsys: fstest.MapFS{}, holdOpen: time.Millisecond}
+	for i := 0; i < 50; i++ {
+		remote.fsys[fmt.Sprintf("file%d.txt", i)] = &fstest.MapFile{Data: []byte(fmt.Sprint(i))}
+	}
+
+	wm := New(WithFS("sftp://nas", remote), WithContentHash(), WithWorkers(64))
+	hashes, err := wm.Walk("sftp://nas/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := len(hashes.ToSlice()); files != 50 {
+		t.Errorf("expected 50 files, got %d and errors %v", files, wm.Errors())
+	}
+
+	// the root directory is open while the first files are hashed
+	if remote.maxOpen > defaultNetworkReaders+1 {
+		t.Errorf("expected at most %d files read at once, got %d", defaultNetworkReaders, remote.maxOpen)
+	}
+}
+
+func TestWithPerMountConcurrency(t *testing.T) {
+	remote := &openCounter{fsys: fstest.MapFS{}, holdOpen: time.Millisecond}
+	for i := 0; i < 50; i++ {
+		remote.fsys[fmt.Sprintf("dir%d/file%d.txt", i%2, i)] = &fstest.MapFile{Data: []byte(fmt.Sprint(i))}
+	}
+
+	limits := map[string]int{"sftp://nas/dir0": 1, "sftp://nas/dir1": 0}
+	wm := New(WithFS("sftp://nas", remote), WithContentHash(), WithWorkers(64), WithPerMountConcurrency(limits))
+
+	if _, err := wm.Walk("sftp://nas/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if readers := wm.mountOf("sftp://nas/dir0/file0.txt"); readers == nil || readers.limit != 1 {
+		t.Errorf("expected 1 reader for dir0, got %+v", readers)
+	}
+
+	// listed without a limit, the root is limited by default
+	if readers := wm.mountOf("sftp://nas/dir1/file1.txt"); readers == nil || readers.limit != defaultNetworkReaders {
+		t.Errorf("expected the default readers of the root for dir1, got %+v", readers)
+	}
+
+	if remote.maxOpen > defaultNetworkReaders+2 {
+		t.Errorf("expected few files read at once, got %d", remote.maxOpen)
+	}
+}
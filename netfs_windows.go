@@ -0,0 +1,25 @@
+package walkman
+
+import (
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// DRIVE_REMOTE of GetDriveTypeW
+const driveRemote = 4
+
+var getDriveType = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDriveTypeW")
+
+// Reports whether path is on a network share, mapped to a drive or not.
+func networkFS(path string) (string, bool) {
+	root, err := syscall.UTF16PtrFromString(filepath.VolumeName(path) + `\`)
+	if err != nil {
+		return "", false
+	}
+
+	if r, _, _ := getDriveType.Call(uintptr(unsafe.Pointer(root))); r == driveRemote {
+		return "smb", true
+	}
+	return "", false
+}
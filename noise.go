@@ -0,0 +1,26 @@
+package walkman
+
+import "strings"
+
+// Files macOS writes next to the real ones: Finder settings, localized
+// folder names and custom folder icons. They are skipped together with
+// AppleDouble resource forks (._name) and iCloud placeholders
+// (.name.icloud) unless NoDefaultSkip is given, so that copies made
+// by Macs do not show up as duplicates.
+var files_to_skip = []string{
+	".DS_Store",
+	".localized",
+	"Icon\r",
+}
+
+// Reports whether name is left on disk by macOS, see files_to_skip.
+func isMacNoise(name string) bool {
+	if strings.HasPrefix(name, "._") {
+		return true
+	}
+
+	if strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".icloud") {
+		return true
+	}
+	return slice_contains(files_to_skip, name)
+}
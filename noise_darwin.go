@@ -0,0 +1,17 @@
+package walkman
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// SF_DATALESS of sys/stat.h, set on files whose content is in the cloud.
+const sfDataless = 0x40000000
+
+// Reports whether the content of fi is not on disk, e.g an iCloud Drive
+// file evicted by macOS, which reading would download. Such files are
+// always skipped.
+func isDataless(fi fs.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && st.Flags&sfDataless != 0
+}
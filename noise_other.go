@@ -0,0 +1,10 @@
+//go:build !darwin
+
+package walkman
+
+import "io/fs"
+
+// Only macOS evicts the content of files.
+func isDataless(fi fs.FileInfo) bool {
+	return false
+}
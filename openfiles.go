@@ -0,0 +1,79 @@
+package walkman
+
+import (
+	"io"
+	"sync"
+)
+
+// Limit the files and directories walks keep open at once across all
+// workers, e.g to stay below the descriptor limit of the process with
+// many workers. A zero or negative n lifts the limit.
+//
+// Hashers and extractors are expected to close a file before they
+// open it again. Files read by other processes, e.g ffmpeg for
+// VideoHasher, are not counted.
+func WithMaxOpenFiles(n int) Option {
+	return func(w *Walkman) {
+		w.maxOpenFiles = n
+	}
+}
+
+// Waits for a descriptor to be available under WithMaxOpenFiles and
+// returns the function giving it back.
+func (wm *Walkman) openSlot() func() {
+	if wm.openFiles == nil {
+		return func() {}
+	}
+
+	wm.openFiles.acquire()
+
+	var once sync.Once
+	return func() {
+		once.Do(wm.openFiles.release)
+	}
+}
+
+// Opens the file at path with open, holding a descriptor of
+// WithMaxOpenFiles and a reader of WithPerMountConcurrency until
+// it is closed.
+func (wm *Walkman) openLimited(path string, open func() (io.ReadCloser, error)) (io.ReadCloser, error) {
+	releaseMount := wm.mountSlot(path)
+	releaseOpen := wm.openSlot()
+	release := func() {
+		releaseOpen()
+		releaseMount()
+	}
+
+	rc, err := open()
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	if wm.openFiles == nil && wm.mountOf(path) == nil {
+		return rc, nil
+	}
+
+	f := &limitedFile{ReadCloser: rc, release: release}
+
+	// some extractors seek to the end of files
+	if seeker, ok := rc.(io.Seeker); ok {
+		return limitedSeeker{limitedFile: f, Seeker: seeker}, nil
+	}
+	return f, nil
+}
+
+type limitedFile struct {
+	io.ReadCloser
+	release func()
+}
+
+func (f *limitedFile) Close() error {
+	defer f.release()
+	return f.ReadCloser.Close()
+}
+
+type limitedSeeker struct {
+	*limitedFile
+	io.Seeker
+}
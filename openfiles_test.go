@@ -0,0 +1,82 @@
+package walkman
+
+import (
+	"fmt"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Counts the files and directories open at once. Only Open is
+// offered so that directories are opened to be read too.
+type openCounter struct {
+	fsys fstest.MapFS
+
+	mu       sync.Mutex
+	open     int
+	maxOpen  int
+	opened   int
+	holdOpen time.Duration
+}
+
+func (c *openCounter) Open(name string) (fs.File, error) {
+	f, err := c.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.open++
+	c.opened++
+	if c.open > c.maxOpen {
+		c.maxOpen = c.open
+	}
+	c.mu.Unlock()
+
+	time.Sleep(c.holdOpen)
+	return &countedFile{File: f, c: c}, nil
+}
+
+type countedFile struct {
+	fs.File
+	c *openCounter
+}
+
+func (f *countedFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return f.File.(fs.ReadDirFile).ReadDir(n)
+}
+
+func (f *countedFile) Close() error {
+	f.c.mu.Lock()
+	f.c.open--
+	f.c.mu.Unlock()
+	return f.File.Close()
+}
+
+func TestWithMaxOpenFiles(t *testing.T) {
+	remote := &openCounter{fsys: fstest.MapFS{}, holdOpen: time.Millisecond}
+	for i := 0; i < 50; i++ {
+		remote.fsys[fmt.Sprintf("dir%d/file%d.txt", i%10, i)] = &fstest.MapFile{Data: []byte(fmt.Sprint(i))}
+	}
+
+	wm := New(WithFS("sftp://nas", remote), WithContentHash(), WithWorkers(64), WithMaxOpenFiles(3))
+	hashes, err := wm.Walk("sftp://nas/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := len(hashes.ToSlice()); files != 50 {
+		t.Errorf("expected 50 files, got %d and errors %v", files, wm.Errors())
+	}
+
+	// the root and 10 directories are read, 50 files hashed
+	if remote.opened < 61 {
+		t.Errorf("expected files and directories to be opened through the file system, got %d", remote.opened)
+	}
+
+	if remote.maxOpen > 3 {
+		t.Errorf("expected at most 3 open files at once, got %d", remote.maxOpen)
+	}
+}
@@ -0,0 +1,88 @@
+package walkman
+
+import (
+	"container/heap"
+	"io/fs"
+	"sync"
+)
+
+// Order in which files waiting for a hash worker are hashed,
+// see WithHashOrder.
+type HashOrder int
+
+const (
+	FIFO          HashOrder = iota // as they are found, the default
+	LargestFirst                   // the largest files first, to find the biggest duplicates early
+	SmallestFirst                  // the smallest files first, for a quick pass over most files
+)
+
+// Choose the order in which files are hashed once more are found than
+// there are hash workers, e.g to follow the largest duplicates early
+// through WithEventSink. Files are ordered among those found so far,
+// so the order is only strict with WithSizeGrouping, where the whole
+// tree is walked before hashing starts.
+func WithHashOrder(order HashOrder) Option {
+	return func(w *Walkman) {
+		w.hashOrder = order
+	}
+}
+
+// A file waiting for a hash worker.
+type hashTask struct {
+	path   string
+	info   fs.FileInfo
+	unique bool
+}
+
+// Queues a file for processFile. Files are hashed as their goroutine
+// gets a hash worker with FIFO, otherwise each goroutine takes the
+// first file of the queue once it has one.
+func (wm *Walkman) queueFile(path string, fi fs.FileInfo, unique bool) {
+	wm.wg.Add(1)
+
+	if wm.hashOrder != FIFO {
+		wm.queue.push(hashTask{path: path, info: fi, unique: unique})
+	}
+	go wm.processFile(path, fi, unique)
+}
+
+// Files waiting for a hash worker, ordered by size.
+type hashQueue struct {
+	mu      sync.Mutex
+	tasks   []hashTask
+	largest bool // first, else the smallest
+}
+
+func (q *hashQueue) push(t hashTask) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	heap.Push(q, t)
+}
+
+// Removes and returns the first file.
+func (q *hashQueue) pop() hashTask {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return heap.Pop(q).(hashTask)
+}
+
+// heap.Interface, to be called with mu held.
+func (q *hashQueue) Len() int { return len(q.tasks) }
+
+func (q *hashQueue) Less(i, j int) bool {
+	if q.largest {
+		return q.tasks[i].info.Size() > q.tasks[j].info.Size()
+	}
+	return q.tasks[i].info.Size() < q.tasks[j].info.Size()
+}
+
+func (q *hashQueue) Swap(i, j int)      { q.tasks[i], q.tasks[j] = q.tasks[j], q.tasks[i] }
+func (q *hashQueue) Push(x interface{}) { q.tasks = append(q.tasks, x.(hashTask)) }
+
+func (q *hashQueue) Pop() interface{} {
+	t := q.tasks[len(q.tasks)-1]
+	q.tasks = q.tasks[:len(q.tasks)-1]
+	return t
+}
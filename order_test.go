@@ -0,0 +1,62 @@
+package walkman
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithHashOrder(t *testing.T) {
+	dir := t.TempDir()
+	sizeOf := map[string]int64{}
+	for size := 1; size <= 5; size++ {
+		for _, copy := range []string{"a", "b"} {
+			f := writeFile(t, dir, fmt.Sprintf("%s%d.txt", copy, size), strings.Repeat("x", size), time.Now())
+			sizeOf[f.Path] = int64(size)
+		}
+	}
+
+	// the first file takes a while so that every other one is queued
+	// by the time the only hash worker is done with it
+	slow := func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		time.Sleep(10 * time.Millisecond)
+		return MD5ContentHasher(path, info, open)
+	}
+
+	hashed := func(order HashOrder) []int64 {
+		var sizes []int64
+		sink := func(e Event) {
+			if e.Kind == EventFileHashed {
+				sizes = append(sizes, sizeOf[e.Path])
+			}
+		}
+
+		wm := New(WithHasher(slow), WithSizeGrouping(), WithHashWorkers(1), WithHashOrder(order), WithEventSink(sink))
+		if _, err := wm.Walk(dir); err != nil {
+			t.Fatal(err)
+		}
+		if len(sizes) != 10 {
+			t.Fatalf("expected 10 files hashed, got %v", sizes)
+		}
+		return sizes[1:]
+	}
+
+	for _, tt := range []struct {
+		order HashOrder
+		want  string
+	}{
+		{LargestFirst, "descending"},
+		{SmallestFirst, "ascending"},
+	} {
+		sizes := hashed(tt.order)
+		for i := 1; i < len(sizes); i++ {
+			if (tt.order == LargestFirst && sizes[i] > sizes[i-1]) || (tt.order == SmallestFirst && sizes[i] < sizes[i-1]) {
+				t.Errorf("expected files hashed in %s size, got %v", tt.want, sizes)
+				break
+			}
+		}
+	}
+}
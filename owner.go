@@ -0,0 +1,49 @@
+package walkman
+
+import (
+	"archive/tar"
+	"io/fs"
+)
+
+// Owner of a file, known for local files on unix and for members of
+// tar archives.
+type Owner struct {
+	UID uint32 `json:"uid"`
+	GID uint32 `json:"gid"`
+}
+
+// Returns the owner of the file described by info, nil if unknown.
+func ownerOf(info fs.FileInfo) *Owner {
+	if info == nil {
+		return nil
+	}
+
+	if h, ok := info.Sys().(*tar.Header); ok {
+		return &Owner{UID: uint32(h.Uid), GID: uint32(h.Gid)}
+	}
+	return sysOwner(info)
+}
+
+// Keeps the files owned by the user uid, e.g to audit what a user
+// left behind. Files of unknown owner are left out.
+func ByOwner(uid uint32) PathFilter {
+	return func(file File) bool {
+		return file.Owner != nil && file.Owner.UID == uid
+	}
+}
+
+// Keeps the files owned by the group gid. Files of unknown owner
+// are left out.
+func ByGroup(gid uint32) PathFilter {
+	return func(file File) bool {
+		return file.Owner != nil && file.Owner.GID == gid
+	}
+}
+
+// Keeps the files anybody may write to. Windows has no such
+// permission, files there are writable by all unless read-only.
+func ByModeWorldWritable() PathFilter {
+	return func(file File) bool {
+		return file.Stats.Mode().Perm()&0o002 != 0
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package walkman
+
+import "io/fs"
+
+// Files have no unix owner here.
+func sysOwner(info fs.FileInfo) *Owner {
+	return nil
+}
@@ -0,0 +1,73 @@
+package walkman
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestOwnerFilters(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("files have no unix owner on Windows")
+	}
+
+	dir := t.TempDir()
+	shared := writeFile(t, dir, "shared.txt", "shared", time.Now())
+	writeFile(t, dir, "private.txt", "private", time.Now())
+
+	// the umask may strip the bits from new files
+	if err := os.Chmod(shared.Path, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	hashes, err := New().Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+	for _, f := range hashes.ToSlice() {
+		if f.Owner == nil || f.Owner.UID != uid || f.Owner.GID != gid {
+			t.Errorf("expected %s owned by %d:%d, got %+v", f.Path, uid, gid, f.Owner)
+		}
+	}
+
+	if n := len(hashes.Filter(ByOwner(uid), ByGroup(gid)).ToSlice()); n != 2 {
+		t.Errorf("expected 2 files of %d:%d, got %d", uid, gid, n)
+	}
+
+	if n := len(hashes.Filter(ByOwner(uid + 1)).ToSlice()); n != 0 {
+		t.Errorf("expected no files of another user, got %d", n)
+	}
+
+	writable := hashes.Filter(ByModeWorldWritable()).ToSlice()
+	if len(writable) != 1 || writable[0].Path != shared.Path {
+		t.Errorf("expected only %s world writable, got %v", shared.Path, writable)
+	}
+
+	where, err := ParseFilter(fmt.Sprintf("uid == %d && gid in (%d)", uid, gid))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(hashes.Filter(where).ToSlice()); n != 2 {
+		t.Errorf("expected 2 files matching uid and gid, got %d", n)
+	}
+
+	snap := filepath.Join(t.TempDir(), "scan.json")
+	if err := hashes.Save(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadResults(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(loaded.Filter(ByOwner(uid)).ToSlice()); n != 2 {
+		t.Errorf("expected owners kept in snapshots, got %d files of %d", n, uid)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package walkman
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func sysOwner(info fs.FileInfo) *Owner {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil // e.g remote files
+	}
+	return &Owner{UID: uint32(st.Uid), GID: uint32(st.Gid)}
+}
@@ -0,0 +1,79 @@
+package walkman
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Group is a hash with its files, as returned by Results.Page.
+type Group struct {
+	Hash  string
+	Files FileList // sorted by path
+}
+
+// Returns up to limit groups of hashes following cursor, ordered by hash, and
+// the cursor of the next page, empty after the last one. Pass an empty cursor
+// for the first page.
+//
+// Ordering only depends on the hashes, so a frontend can page through
+// millions of groups, or resume where it stopped, without ever holding
+// them all: every call goes over the map once, keeping at most limit keys.
+// Groups added or removed between calls are seen or not depending on
+// whether their hash sorts after the cursor.
+func (hashes Results) Page(cursor string, limit int) ([]Group, string) {
+	if limit <= 0 {
+		return nil, ""
+	}
+
+	// The limit smallest keys after cursor, largest on top.
+	keys := &largestKeys{}
+	more := false
+
+	for hash := range hashes {
+		if hash <= cursor {
+			continue
+		}
+
+		if keys.Len() < limit {
+			heap.Push(keys, hash)
+			continue
+		}
+
+		more = true
+		if hash < (*keys)[0] {
+			(*keys)[0] = hash
+			heap.Fix(keys, 0)
+		}
+	}
+
+	sort.Strings(*keys)
+
+	groups := make([]Group, 0, keys.Len())
+	for _, hash := range *keys {
+		files := make(FileList, len(hashes[hash]))
+		copy(files, hashes[hash])
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+		groups = append(groups, Group{Hash: hash, Files: files})
+	}
+
+	if !more {
+		return groups, ""
+	}
+	return groups, groups[len(groups)-1].Hash
+}
+
+// A max-heap of hashes for container/heap.
+type largestKeys []string
+
+func (h largestKeys) Len() int            { return len(h) }
+func (h largestKeys) Less(i, j int) bool  { return h[i] > h[j] }
+func (h largestKeys) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *largestKeys) Push(x interface{}) { *h = append(*h, x.(string)) }
+
+func (h *largestKeys) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,60 @@
+package walkman
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	hashes := make(Results)
+	for i := 0; i < 25; i++ {
+		hash := fmt.Sprintf("md5:%02d", i)
+		hashes[hash] = FileList{{Path: fmt.Sprintf("/b/%d", i)}, {Path: fmt.Sprintf("/a/%d", i)}}
+	}
+
+	var seen []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("expected 3 pages")
+		}
+
+		groups, next := hashes.Page(cursor, 10)
+		for _, g := range groups {
+			seen = append(seen, g.Hash)
+
+			if len(g.Files) != 2 || g.Files[0].Path > g.Files[1].Path {
+				t.Errorf("expected files of %s sorted by path, got %v", g.Hash, g.Files)
+			}
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if len(seen) != 25 {
+		t.Fatalf("expected 25 groups, got %d", len(seen))
+	}
+
+	for i, hash := range seen {
+		if want := fmt.Sprintf("md5:%02d", i); hash != want {
+			t.Errorf("expected %s at %d, got %s", want, i, hash)
+		}
+	}
+}
+
+func TestPageExact(t *testing.T) {
+	hashes := Results{"md5:a": {{Path: "/a"}}, "md5:b": {{Path: "/b"}}}
+
+	groups, next := hashes.Page("", 2)
+	if len(groups) != 2 || next != "" {
+		t.Errorf("expected both groups on a single page, got %v and cursor %q", groups, next)
+	}
+
+	groups, next = hashes.Page("md5:b", 2)
+	if len(groups) != 0 || next != "" {
+		t.Errorf("expected nothing after the last hash, got %v and cursor %q", groups, next)
+	}
+}
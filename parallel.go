@@ -0,0 +1,54 @@
+package walkman
+
+import "sync"
+
+// Like Filter, but groups are filtered by workers goroutines at once, for
+// filters doing I/O, e.g reading file headers or asking a remote service.
+// The files of a group are filtered in order by a single goroutine, so a
+// few huge groups gain little. Filters must be safe for concurrent use.
+// Workers default to twice the CPUs available if zero or negative.
+func (hashes Results) FilterParallel(workers int, filterFuncs ...PathFilter) Results {
+	if workers <= 0 {
+		workers = 2 * availableCPUs()
+	}
+
+	keys := make(chan string)
+	groups := make(chan Group)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for hash := range keys {
+				var kept FileList
+				for _, file := range hashes[hash] {
+					if included(file, filterFuncs) {
+						kept = append(kept, file)
+					}
+				}
+
+				if len(kept) > 0 {
+					groups <- Group{Hash: hash, Files: kept}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for hash := range hashes {
+			keys <- hash
+		}
+		close(keys)
+
+		wg.Wait()
+		close(groups)
+	}()
+
+	results := make(Results)
+	for g := range groups {
+		results[g.Hash] = g.Files
+	}
+	return results
+}
@@ -0,0 +1,50 @@
+package walkman
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFilterParallel(t *testing.T) {
+	hashes := make(Results)
+	for i := 0; i < 100; i++ {
+		hash := fmt.Sprintf("md5:%d", i)
+		hashes[hash] = FileList{{Path: fmt.Sprintf("/a/%d.txt", i)}, {Path: fmt.Sprintf("/b/%d.pdf", i)}, {Path: fmt.Sprintf("/c/%d.txt", i)}}
+	}
+	hashes["md5:none"] = FileList{{Path: "/a/none.pdf"}}
+
+	var running, peak int64
+	slow := func(file File) bool {
+		n := atomic.AddInt64(&running, 1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+
+		time.Sleep(100 * time.Microsecond)
+		atomic.AddInt64(&running, -1)
+		return true
+	}
+	txt := func(file File) bool { return strings.HasSuffix(file.Path, ".txt") }
+
+	want := hashes.Filter(txt, slow)
+	got := hashes.FilterParallel(8, txt, slow)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the results of Filter, got %v", got)
+	}
+
+	if _, ok := got["md5:none"]; ok {
+		t.Error("expected groups without a file left to be dropped")
+	}
+
+	if peak < 2 || peak > 8 {
+		t.Errorf("expected up to 8 filters running at once, got %d", peak)
+	}
+}
@@ -0,0 +1,466 @@
+package walkman
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeepPolicy picks the copy to keep from a group of duplicates.
+// It returns the index of the keeper in files.
+// A negative index leaves the whole group untouched.
+type KeepPolicy func(files FileList) int
+
+// Keeps the file with the oldest modification time.
+// Ties are broken by path so that the choice is stable across runs.
+func KeepOldest() KeepPolicy {
+	return func(files FileList) int {
+		return pick(files, older)
+	}
+}
+
+func older(a, b File) bool {
+	if a.Stats.ModTime().Equal(b.Stats.ModTime()) {
+		return a.Path < b.Path
+	}
+	return a.Stats.ModTime().Before(b.Stats.ModTime())
+}
+
+// Keeps the file with the newest modification time.
+func KeepNewest() KeepPolicy {
+	return func(files FileList) int {
+		return pick(files, func(a, b File) bool {
+			if a.Stats.ModTime().Equal(b.Stats.ModTime()) {
+				return a.Path < b.Path
+			}
+			return a.Stats.ModTime().After(b.Stats.ModTime())
+		})
+	}
+}
+
+// Keeps the file with the shortest path, usually the least nested copy.
+func KeepShortestPath() KeepPolicy {
+	return func(files FileList) int {
+		return pick(files, func(a, b File) bool {
+			if len(a.Path) == len(b.Path) {
+				return a.Path < b.Path
+			}
+			return len(a.Path) < len(b.Path)
+		})
+	}
+}
+
+// Keeps the image with the most pixels according to File.Meta, e.g of
+// a group of similar photos. Ties are broken by size, then by path.
+func KeepHighestResolution() KeepPolicy {
+	return func(files FileList) int {
+		return pick(files, func(a, b File) bool {
+			if a.Meta.pixels() != b.Meta.pixels() {
+				return a.Meta.pixels() > b.Meta.pixels()
+			}
+
+			if a.Stats.Size() != b.Stats.Size() {
+				return a.Stats.Size() > b.Stats.Size()
+			}
+			return a.Path < b.Path
+		})
+	}
+}
+
+// Only keeps a file that lives below dir, choosing among those with fallback.
+// Groups without a copy below dir are left alone.
+func KeepUnder(dir string, fallback KeepPolicy) KeepPolicy {
+	return func(files FileList) int {
+		index := []int{}
+		inside := FileList{}
+
+		for i, f := range files {
+			if IsUnder(f.Path, dir) {
+				index = append(index, i)
+				inside = append(inside, f)
+			}
+		}
+
+		if len(inside) == 0 {
+			return -1
+		}
+
+		k := fallback(inside)
+		if k < 0 {
+			return k
+		}
+		return index[k]
+	}
+}
+
+// Keeps a file in the first of dirs holding a copy, so that earlier
+// directories outrank later ones, e.g ~/Photos/Originals before ~/Downloads.
+// Files outside all of them rank last. Ties are broken like KeepOldest.
+func KeepByDirPriority(dirs []string) KeepPolicy {
+	rank := func(f File) int {
+		for i, dir := range dirs {
+			if IsUnder(f.Path, dir) {
+				return i
+			}
+		}
+		return len(dirs)
+	}
+
+	return func(files FileList) int {
+		return pick(files, func(a, b File) bool {
+			if ra, rb := rank(a), rank(b); ra != rb {
+				return ra < rb
+			}
+			return older(a, b)
+		})
+	}
+}
+
+// Returns the index of the file that sorts first according to less.
+func pick(files FileList, less func(a, b File) bool) int {
+	best := 0
+	for i := 1; i < len(files); i++ {
+		if less(files[i], files[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// Action is the destructive operation applied to duplicates in a Plan.
+type Action int
+
+const (
+	ActionDelete   Action = iota // remove the duplicate
+	ActionHardlink               // replace the duplicate with a hard link to the keeper
+	ActionSymlink                // replace the duplicate with a symbolic link to the keeper
+	ActionMove                   // move the duplicate into a quarantine directory
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionDelete:
+		return "delete"
+	case ActionHardlink:
+		return "hardlink"
+	case ActionSymlink:
+		return "symlink"
+	case ActionMove:
+		return "move"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// Step is a single operation of a Plan.
+type Step struct {
+	Action Action
+	Keep   File   // copy that stays untouched
+	Target File   // duplicate the action is applied to
+	Dest   string // destination of Target for ActionMove
+}
+
+// Plan is an ordered list of destructive steps built from walk results.
+// Nothing touches the file system until Execute is called.
+type Plan []Step
+
+// Builds a plan that applies action to every duplicate in hashes,
+// keeping the file chosen by keep in each group. Duplicates that are
+// hard links to the keeper already are left out.
+//
+// moveTo is the quarantine directory for ActionMove and is ignored otherwise.
+// Moved files keep their full original path below moveTo so they can be
+// put back later.
+func NewPlan(hashes Results, keep KeepPolicy, action Action, moveTo string) Plan {
+	plan := Plan{}
+
+	// Deterministic order makes dry runs comparable.
+	keys := make([]string, 0, len(hashes))
+	for hash := range hashes {
+		keys = append(keys, hash)
+	}
+	sort.Strings(keys)
+
+	for _, hash := range keys {
+		files := onDisk(hashes[hash])
+		if len(files) < 2 {
+			continue
+		}
+
+		k := keep(files)
+		if k < 0 {
+			continue
+		}
+
+		keeper, _ := os.Lstat(files[k].Path)
+
+		for i, f := range files {
+			if i == k {
+				continue
+			}
+
+			// A hard link to the keeper frees nothing, and renaming
+			// over it does nothing, leaving the temporary link behind.
+			if fi, err := os.Lstat(f.Path); err == nil && keeper != nil && os.SameFile(fi, keeper) {
+				continue
+			}
+
+			step := Step{Action: action, Keep: files[k], Target: f}
+			if action == ActionMove {
+				step.Dest = quarantinePath(moveTo, f.Path)
+			}
+			plan = append(plan, step)
+		}
+	}
+
+	return plan
+}
+
+// Returns the files on the local disk, leaving out archive members
+// and files of mounted file systems which can not be removed or linked.
+func onDisk(files FileList) FileList {
+	kept := make(FileList, 0, len(files))
+	for _, f := range files {
+		if f.Archive == "" && !isRemote(f.Path) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// Total bytes the plan frees once executed.
+// Moved files are not counted since their data is still on disk.
+func (p Plan) Reclaimable() int64 {
+	var n int64
+	for _, s := range p {
+		if s.Action != ActionMove {
+			n += s.Target.Stats.Size()
+		}
+	}
+	return n
+}
+
+func (s Step) String() string {
+	switch s.Action {
+	case ActionMove:
+		return fmt.Sprintf("move %q -> %q (keeping %q)", s.Target.Path, s.Dest, s.Keep.Path)
+	default:
+		return fmt.Sprintf("%s %q (keeping %q)", s.Action, s.Target.Path, s.Keep.Path)
+	}
+}
+
+// JournalEntry records a step that was carried out by Plan.Execute.
+type JournalEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Path   string    `json:"path"`
+	Keep   string    `json:"keep"`
+	Dest   string    `json:"dest,omitempty"`
+}
+
+// Returned (wrapped) by Execute when a target no longer
+// matches its keeper byte for byte.
+var ErrContentMismatch = errors.New("walkman: file content differs from keeper")
+
+// Executes the plan in order, stopping at the first error.
+//
+// Before acting on a duplicate, its content is compared with the keeper
+// so that plans built with a name based hasher never destroy data.
+// Mismatching steps are skipped and do not stop execution.
+//
+// Every completed step is written to journal as a JSON line if journal is not nil.
+// Returns the number of steps carried out.
+func (p Plan) Execute(journal io.Writer) (int, error) {
+	done := 0
+	enc := json.NewEncoder(ioWriterOrDiscard(journal))
+
+	for _, s := range p {
+		err := s.execute()
+		if errors.Is(err, ErrContentMismatch) {
+			continue
+		}
+
+		if err != nil {
+			return done, fmt.Errorf("%s: %w", s, err)
+		}
+
+		done++
+
+		entry := JournalEntry{
+			Time:   time.Now(),
+			Action: s.Action.String(),
+			Path:   s.Target.Path,
+			Keep:   s.Keep.Path,
+			Dest:   s.Dest,
+		}
+
+		if err := enc.Encode(entry); err != nil {
+			return done, err
+		}
+	}
+
+	return done, nil
+}
+
+func (s Step) execute() error {
+	same, err := sameContent(s.Keep.Path, s.Target.Path)
+	if err != nil {
+		return err
+	}
+
+	if !same {
+		return ErrContentMismatch
+	}
+
+	switch s.Action {
+	case ActionDelete:
+		return os.Remove(s.Target.Path)
+	case ActionHardlink:
+		return replaceWith(s.Target.Path, func(tmp string) error {
+			return os.Link(s.Keep.Path, tmp)
+		})
+	case ActionSymlink:
+		keep, err := filepath.Abs(s.Keep.Path)
+		if err != nil {
+			return err
+		}
+
+		return replaceWith(s.Target.Path, func(tmp string) error {
+			return os.Symlink(keep, tmp)
+		})
+	case ActionMove:
+		if s.Dest == "" {
+			return errors.New("no destination for move")
+		}
+		return moveFile(s.Target.Path, s.Dest)
+	}
+
+	return fmt.Errorf("unknown action %v", s.Action)
+}
+
+// Atomically replaces path with whatever create makes at a temporary
+// name in the same directory.
+func replaceWith(path string, create func(tmp string) error) error {
+	tmp := path + ".walkman-tmp"
+	if err := create(tmp); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Renames src to dst, falling back to copy & remove across devices.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, stat.ModTime(), stat.ModTime())
+}
+
+// Path of file below the quarantine directory dir.
+func quarantinePath(dir, path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+
+	// Drop the volume name on windows, C:\foo -> \foo
+	abs = strings.TrimPrefix(abs, filepath.VolumeName(abs))
+	return filepath.Join(dir, abs)
+}
+
+// Compares two files byte for byte.
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, 64*1024)
+	bufB := make([]byte, 64*1024)
+
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+
+		eofA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		eofB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+
+		if eofA || eofB {
+			return eofA && eofB, nil
+		}
+
+		if errA != nil {
+			return false, errA
+		}
+
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+func ioWriterOrDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
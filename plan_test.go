@@ -0,0 +1,173 @@
+package walkman
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writes content to name inside dir and returns a File for it.
+func writeFile(t *testing.T, dir, name, content string, mtime time.Time) File {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return File{Path: path, Stats: stat}
+}
+
+func TestKeepPolicies(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	files := FileList{
+		writeFile(t, dir, "b/newest.txt", "x", now),
+		writeFile(t, dir, "a.txt", "x", now.Add(-time.Hour)),
+		writeFile(t, dir, "c/d/oldest.txt", "x", now.Add(-2*time.Hour)),
+	}
+
+	if got := KeepOldest()(files); got != 2 {
+		t.Errorf("KeepOldest() = %d, want 2", got)
+	}
+
+	if got := KeepNewest()(files); got != 0 {
+		t.Errorf("KeepNewest() = %d, want 0", got)
+	}
+
+	if got := KeepShortestPath()(files); got != 1 {
+		t.Errorf("KeepShortestPath() = %d, want 1", got)
+	}
+}
+
+func TestPlanExecute(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	keep := writeFile(t, dir, "keep.txt", "same", now.Add(-time.Hour))
+	dup := writeFile(t, dir, "dup.txt", "same", now)
+	other := writeFile(t, dir, "other/keep.txt", "diff", now)
+
+	hashes := Results{"x": FileList{dup, keep, other}}
+
+	plan := NewPlan(hashes, KeepOldest(), ActionDelete, "")
+	if len(plan) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(plan))
+	}
+
+	journal := bytes.Buffer{}
+	done, err := plan.Execute(&journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// other.txt differs in content and must survive.
+	if done != 1 {
+		t.Errorf("expected 1 step to be applied, got %d", done)
+	}
+
+	if _, err := os.Stat(dup.Path); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be deleted", dup.Path)
+	}
+
+	if _, err := os.Stat(other.Path); err != nil {
+		t.Errorf("expected %s to survive: %v", other.Path, err)
+	}
+
+	if !bytes.Contains(journal.Bytes(), []byte(`"action":"delete"`)) {
+		t.Errorf("journal does not record the deletion: %s", journal.String())
+	}
+}
+
+func TestKeepUnder(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	files := FileList{
+		writeFile(t, dir, "backup/x.txt", "x", now.Add(-time.Hour)),
+		writeFile(t, dir, "photos/x.txt", "x", now),
+	}
+
+	keep := KeepUnder(filepath.Join(dir, "photos"), KeepOldest())
+	if got := keep(files); got != 1 {
+		t.Errorf("KeepUnder() = %d, want 1", got)
+	}
+
+	keep = KeepUnder(filepath.Join(dir, "music"), KeepOldest())
+	if got := keep(files); got >= 0 {
+		t.Errorf("KeepUnder() = %d, want group to be skipped", got)
+	}
+}
+
+func TestKeepByDirPriority(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	files := FileList{
+		writeFile(t, dir, "tmp/x.txt", "x", now.Add(-2*time.Hour)),
+		writeFile(t, dir, "Downloads/x.txt", "x", now.Add(-time.Hour)),
+		writeFile(t, dir, "Originals/new/x.txt", "x", now),
+		writeFile(t, dir, "Originals/old/x.txt", "x", now.Add(-time.Minute)),
+	}
+
+	originals, downloads := filepath.Join(dir, "Originals"), filepath.Join(dir, "Downloads")
+
+	keep := KeepByDirPriority([]string{originals, downloads})
+	if got := keep(files); got != 3 {
+		t.Errorf("KeepByDirPriority() = %d, want the oldest copy in Originals", got)
+	}
+
+	keep = KeepByDirPriority([]string{downloads, originals})
+	if got := keep(files); got != 1 {
+		t.Errorf("KeepByDirPriority() = %d, want the copy in Downloads", got)
+	}
+
+	keep = KeepByDirPriority([]string{filepath.Join(dir, "music")})
+	if got := keep(files); got != 0 {
+		t.Errorf("KeepByDirPriority() = %d, want the oldest copy", got)
+	}
+}
+
+func TestNewPlanSkipsLinksToKeeper(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	keep := writeFile(t, dir, "a.txt", "same", now.Add(-time.Hour))
+	if err := os.Link(keep.Path, filepath.Join(dir, "b.txt")); err != nil {
+		t.Skip("hard links not supported:", err)
+	}
+	linked := File{Path: filepath.Join(dir, "b.txt"), Stats: keep.Stats}
+	dup := writeFile(t, dir, "c.txt", "same", now)
+
+	plan := NewPlan(Results{"md5:x": {keep, linked, dup}}, KeepOldest(), ActionHardlink, "")
+	if len(plan) != 1 || plan[0].Target.Path != dup.Path {
+		t.Fatalf("expected only c.txt to be linked, got %v", plan)
+	}
+
+	if n := plan.Reclaimable(); n != 4 {
+		t.Errorf("expected 4 bytes reclaimable, got %d", n)
+	}
+
+	if _, err := plan.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.walkman-tmp")); len(matches) != 0 {
+		t.Errorf("expected no temporary files left, got %v", matches)
+	}
+}
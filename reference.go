@@ -0,0 +1,45 @@
+package walkman
+
+import "sort"
+
+// Narrows hashes down to the files whose content is already below the
+// reference directory ref, e.g a primary photo library, when other roots
+// are scanned against it.
+//
+// Every group left holds a single reference file first, the one with the
+// smallest path, followed by the copies outside ref. Other copies in ref and
+// groups without any are dropped, so that a plan built with KeepUnder(ref, ...)
+// only ever acts on files outside the reference.
+func (hashes Results) ReferenceCopies(ref string) Results {
+	copies := make(Results)
+
+	for hash, files := range hashes {
+		if listedOnly(algorithmOf(hash)) {
+			continue
+		}
+
+		var reference File
+		var found bool
+		var others FileList
+
+		for _, f := range files {
+			if !IsUnder(f.Path, ref) {
+				others = append(others, f)
+				continue
+			}
+
+			if !found || f.Path < reference.Path {
+				reference, found = f, true
+			}
+		}
+
+		if !found || len(others) == 0 {
+			continue
+		}
+
+		sort.Slice(others, func(i, j int) bool { return others[i].Path < others[j].Path })
+		copies[hash] = append(FileList{reference}, others...)
+	}
+
+	return copies
+}
@@ -0,0 +1,40 @@
+package walkman
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReferenceCopies(t *testing.T) {
+	dir := t.TempDir()
+	ref := filepath.Join(dir, "library")
+
+	writeFile(t, ref, "2021/a.jpg", "a", time.Now())
+	writeFile(t, ref, "best/a.jpg", "a", time.Now())
+	writeFile(t, ref, "b.jpg", "bb", time.Now())
+	copyA := writeFile(t, dir, "phone/a.jpg", "a", time.Now())
+	writeFile(t, dir, "phone/c.jpg", "ccc", time.Now())
+	writeFile(t, dir, "usb/c.jpg", "ccc", time.Now())
+
+	hashes, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copies := hashes.ReferenceCopies(ref)
+	if len(copies) != 1 {
+		t.Fatalf("expected only the copies of a.jpg, got %v", copies)
+	}
+
+	for _, files := range copies {
+		if len(files) != 2 || files[0].Path != filepath.Join(ref, "2021/a.jpg") || files[1].Path != copyA.Path {
+			t.Errorf("expected 2021/a.jpg then phone/a.jpg, got %v", files)
+		}
+	}
+
+	plan := NewPlan(copies, KeepUnder(ref, KeepNewest()), ActionDelete, "")
+	if len(plan) != 1 || plan[0].Target.Path != copyA.Path {
+		t.Errorf("expected only phone/a.jpg deleted, got %v", plan)
+	}
+}
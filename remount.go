@@ -0,0 +1,28 @@
+package walkman
+
+import "path/filepath"
+
+// Returns hashes with the files found under the root from moved under to,
+// e.g once a drive walked at /media/usb is mounted at /mnt/usb, so that a
+// snapshot stays valid. Paths are rebuilt from File.RelPath, other files
+// are kept as they are.
+func (hashes Results) Remount(from, to string) Results {
+	from, to = filepath.Clean(from), filepath.Clean(to)
+	moved := make(Results, len(hashes))
+
+	for hash, files := range hashes {
+		group := make(FileList, len(files))
+		for i, f := range files {
+			if f.Root == from {
+				f.Path, f.Root = filepath.Join(to, f.RelPath), to
+
+				if rel, err := filepath.Rel(from, f.Archive); f.Archive != "" && err == nil {
+					f.Archive = filepath.Join(to, rel)
+				}
+			}
+			group[i] = f
+		}
+		moved[hash] = group
+	}
+	return moved
+}
@@ -0,0 +1,56 @@
+package walkman
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileRoot(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+
+	writeFile(t, a, "photos/x.jpg", "x", time.Now())
+	writeFile(t, b, "y.jpg", "y", time.Now())
+
+	hashes, err := New().WalkDirs(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range hashes.ToSlice() {
+		if filepath.Join(f.Root, f.RelPath) != f.Path {
+			t.Errorf("expected %s below %s as %s", f.Path, f.Root, f.RelPath)
+		}
+
+		if f.Root != a && f.Root != b {
+			t.Errorf("expected %s under a walked root, got %q", f.Path, f.Root)
+		}
+	}
+
+	snap := filepath.Join(dir, "snapshot.json")
+	if err := hashes.Save(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadResults(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved := loaded.Remount(a, "/mnt/usb")
+	for _, f := range moved.ToSlice() {
+		switch f.RelPath {
+		case filepath.Join("photos", "x.jpg"):
+			if f.Path != filepath.Join("/mnt/usb", "photos", "x.jpg") || f.Root != "/mnt/usb" {
+				t.Errorf("expected x.jpg moved to /mnt/usb, got %s under %s", f.Path, f.Root)
+			}
+		case "y.jpg":
+			if f.Path != filepath.Join(b, "y.jpg") {
+				t.Errorf("expected y.jpg left under %s, got %s", b, f.Path)
+			}
+		default:
+			t.Errorf("unexpected file %s", f.Path)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package walkman
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sync/atomic"
+)
+
+// SkipReason tells why a walk did not descend into a directory.
+type SkipReason string
+
+const (
+	SkipHidden  SkipReason = "hidden"    // see WithIncludeHidden
+	SkipListed  SkipReason = "skip list" // see SkipDirs, WithSkipList and NoDefaultSkip
+	SkipPattern SkipReason = "pattern"   // see WithSkipRegex
+	SkipMarked  SkipReason = "marker"    // see WithSkipMarker
+)
+
+// SkippedDir is a directory left out of a walk on purpose.
+type SkippedDir struct {
+	Path   string
+	Reason SkipReason
+}
+
+// WalkReport tells what a walk covered and what it left out, so that
+// automation can tell a complete scan from one that silently missed half
+// the tree, e.g for lack of permissions.
+type WalkReport struct {
+	Roots   []string     // directories walked
+	Skipped []SkippedDir // directories left out on purpose, in no particular order
+	Errors  []error      // files and directories that could not be read, see Walkman.Errors
+	Stats   RunStats     // counts and timings
+}
+
+// Like WalkContext but also returns the report of the walk.
+func (wm *Walkman) WalkWithReport(ctx context.Context, dirs ...string) (Results, WalkReport, error) {
+	hashes, err := wm.WalkContext(ctx, dirs...)
+	return hashes, wm.Report(), err
+}
+
+// Returns the report of the last walk.
+func (wm *Walkman) Report() WalkReport {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	return WalkReport{
+		Roots:   append([]string(nil), wm.roots...),
+		Skipped: append([]SkippedDir(nil), wm.skipped...),
+		Errors:  append([]error(nil), wm.errs...),
+		Stats:   wm.LastRunStats(),
+	}
+}
+
+// Returns the paths that could not be read for lack of permissions.
+func (r WalkReport) Denied() []string {
+	var paths []string
+	for _, err := range r.Errors {
+		var pathErr *fs.PathError
+		if errors.Is(err, fs.ErrPermission) && errors.As(err, &pathErr) {
+			paths = append(paths, pathErr.Path)
+		}
+	}
+	return paths
+}
+
+// Reports whether every file below the roots that was not skipped on
+// purpose was read.
+func (r WalkReport) Complete() bool {
+	return len(r.Errors) == 0
+}
+
+// Records that the directory at path was skipped.
+func (wm *Walkman) skipDir(path string, reason SkipReason) {
+	atomic.AddInt64(&wm.stats.DirsSkipped, 1)
+	wm.logger.Info("skipping directory", "path", path, "reason", string(reason))
+	wm.emit(Event{Kind: EventSkipDir, Path: path})
+
+	wm.mu.Lock()
+	wm.skipped = append(wm.skipped, SkippedDir{Path: path, Reason: reason})
+	wm.mu.Unlock()
+}
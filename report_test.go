@@ -0,0 +1,56 @@
+package walkman
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWalkReport(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "a", time.Now())
+	writeFile(t, dir, ".git/config", "b", time.Now())
+	writeFile(t, dir, "node_modules/x.js", "c", time.Now())
+
+	_, report, err := New().WalkWithReport(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Roots) != 1 || report.Roots[0] != dir {
+		t.Errorf("expected %s as the root, got %v", dir, report.Roots)
+	}
+
+	reasons := make(map[string]SkipReason)
+	for _, s := range report.Skipped {
+		reasons[s.Path] = s.Reason
+	}
+
+	if reasons[filepath.Join(dir, ".git")] != SkipHidden || reasons[filepath.Join(dir, "node_modules")] != SkipListed {
+		t.Errorf("expected .git skipped as hidden and node_modules as listed, got %v", report.Skipped)
+	}
+
+	if report.Stats.DirsSkipped != 2 || report.Stats.FilesScanned != 1 {
+		t.Errorf("expected 2 directories skipped and 1 file scanned, got %+v", report.Stats)
+	}
+
+	if !report.Complete() {
+		t.Errorf("expected a complete walk, got errors %v", report.Errors)
+	}
+}
+
+func TestWalkReportDenied(t *testing.T) {
+	denied := &fs.PathError{Op: "open", Path: "/srv/private", Err: fs.ErrPermission}
+	report := WalkReport{Errors: []error{denied, fmt.Errorf("other")}}
+
+	if paths := report.Denied(); len(paths) != 1 || paths[0] != "/srv/private" {
+		t.Errorf("expected /srv/private denied, got %v", paths)
+	}
+
+	if report.Complete() {
+		t.Error("expected a walk with errors to be incomplete")
+	}
+}
@@ -0,0 +1,56 @@
+package walkman
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sync/atomic"
+	"time"
+)
+
+// Try again up to n times to stat, read or hash a file or directory
+// whose first attempt failed, e.g on NFS or SMB mounts that drop out
+// for a moment. The first retry waits for backoff, each one after it
+// twice as long as the one before.
+//
+// Files that do not exist (anymore), may not be read or are invalid
+// are not retried. Errors left after the last retry are reported by
+// Errors as before.
+func WithRetry(n int, backoff time.Duration) Option {
+	return func(w *Walkman) {
+		w.retries = n
+		w.backoff = backoff
+	}
+}
+
+// Calls op until it succeeds, fails for good or the retries of
+// WithRetry run out, returning its last error.
+func (wm *Walkman) retry(op func() error) error {
+	err := op()
+
+	wait := wm.backoff
+	for i := 0; i < wm.retries && err != nil && transient(err); i++ {
+		atomic.AddInt64(&wm.stats.Retries, 1)
+		wm.logger.Info("retrying", "err", err, "wait", wait)
+
+		select {
+		case <-wm.ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		wait *= 2
+		err = op()
+	}
+	return err
+}
+
+// Reports whether trying again may help.
+func transient(err error) bool {
+	for _, permanent := range []error{fs.ErrNotExist, fs.ErrPermission, fs.ErrInvalid, context.Canceled, context.DeadlineExceeded} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
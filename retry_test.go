@@ -0,0 +1,85 @@
+package walkman
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Fails to open names a given number of times.
+type flakyFS struct {
+	fstest.MapFS
+
+	mu    sync.Mutex
+	fails map[string]int
+	err   error
+}
+
+func (f *flakyFS) fail(op, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fails[name] > 0 {
+		f.fails[name]--
+		return &fs.PathError{Op: op, Path: name, Err: f.err}
+	}
+	return nil
+}
+
+func (f *flakyFS) Open(name string) (fs.File, error) {
+	if err := f.fail("open", name); err != nil {
+		return nil, err
+	}
+	return f.MapFS.Open(name)
+}
+
+func (f *flakyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := f.fail("readdir", name); err != nil {
+		return nil, err
+	}
+	return f.MapFS.ReadDir(name)
+}
+
+func TestWithRetry(t *testing.T) {
+	errFlaky := errors.New("connection reset")
+
+	walk := func(err error, retries int) (Results, *Walkman) {
+		remote := &flakyFS{
+			MapFS: fstest.MapFS{
+				"a.txt":     {Data: []byte("a")},
+				"sub/b.txt": {Data: []byte("b")},
+			},
+			fails: map[string]int{"a.txt": 2, "sub": 2},
+			err:   err,
+		}
+
+		wm := New(WithFS("sftp://nas", remote), WithContentHash(), WithRetry(retries, time.Millisecond))
+		hashes, walkErr := wm.Walk("sftp://nas/")
+		if walkErr != nil {
+			t.Fatal(walkErr)
+		}
+		return hashes, wm
+	}
+
+	hashes, wm := walk(errFlaky, 2)
+	if files := len(hashes.ToSlice()); files != 2 || len(wm.Errors()) != 0 {
+		t.Errorf("expected both files after retries, got %d and errors %v", files, wm.Errors())
+	}
+
+	if retries := wm.LastRunStats().Retries; retries != 4 {
+		t.Errorf("expected 4 retries, got %d", retries)
+	}
+
+	hashes, wm = walk(errFlaky, 1)
+	if files := len(hashes.ToSlice()); files != 0 || len(wm.Errors()) != 2 {
+		t.Errorf("expected both files given up on, got %d and errors %v", files, wm.Errors())
+	}
+
+	hashes, wm = walk(fs.ErrPermission, 2)
+	if retries := wm.LastRunStats().Retries; retries != 0 || len(wm.Errors()) != 2 {
+		t.Errorf("expected denied files not to be retried, got %d retries and errors %v", retries, wm.Errors())
+	}
+}
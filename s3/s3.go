@@ -0,0 +1,489 @@
+// Package s3 exposes a bucket of an S3 compatible object store (AWS,
+// MinIO, Ceph, R2, ...) as a read only fs.FS, to be walked with
+// walkman.WithFS:
+//
+//	photos, err := s3.FromEnv("photos")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	wm := walkman.New(walkman.WithContentHash(), walkman.WithFS("s3://photos", photos))
+//	hashes, err := wm.WalkDirs("/home/me/Pictures", "s3://photos/2021")
+//
+// Directories are the common prefixes of keys split at "/". Listings
+// carry the ETag of every object, which is the md5 digest of objects
+// uploaded in a single part, so walkman.WithContentHash only downloads
+// objects uploaded in several parts.
+//
+// Requests are signed with AWS Signature Version 4. Like walkman,
+// it uses no external dependencies.
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config locates a bucket and the credentials to read it.
+type Config struct {
+	Bucket string
+	Region string // e.g us-east-1
+
+	// e.g http://localhost:9000 for MinIO. Buckets of custom endpoints are
+	// addressed by path, those of AWS by a virtual host.
+	Endpoint string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // of temporary credentials
+
+	// Download every object instead of trusting ETags, whose digest is
+	// not the md5 of the content for objects encrypted with SSE-KMS or
+	// SSE-C.
+	IgnoreETags bool
+
+	Client *http.Client // http.DefaultClient if nil
+}
+
+// FS is a bucket of an object store. It is safe for concurrent use.
+type FS struct {
+	config Config
+	now    func() time.Time // of signatures, for tests
+}
+
+// Returns an FS reading the bucket of config.
+func New(config Config) *FS {
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &FS{config: config, now: time.Now}
+}
+
+// Returns an FS reading bucket with the credentials, region and endpoint
+// of the usual AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN,
+// AWS_REGION (or AWS_DEFAULT_REGION) and AWS_ENDPOINT_URL variables.
+func FromEnv(bucket string) (*FS, error) {
+	config := Config{
+		Bucket:       bucket,
+		Region:       os.Getenv("AWS_REGION"),
+		Endpoint:     os.Getenv("AWS_ENDPOINT_URL"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("s3: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return New(config), nil
+}
+
+// Returns the URL of key, which is empty for the bucket itself.
+func (fsys *FS) url(key string, query url.Values) *url.URL {
+	var u *url.URL
+	if fsys.config.Endpoint != "" {
+		u, _ = url.Parse(strings.TrimSuffix(fsys.config.Endpoint, "/"))
+		u.Path += "/" + fsys.config.Bucket
+		if key != "" {
+			u.Path += "/" + key
+		}
+	} else {
+		u = &url.URL{
+			Scheme: "https",
+			Host:   fsys.config.Bucket + ".s3." + fsys.config.Region + ".amazonaws.com",
+			Path:   "/" + key,
+		}
+	}
+
+	// send exactly what was signed
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+	return u
+}
+
+// Sends a signed request, turning error responses into errors.
+func (fsys *FS) do(method string, u *url.URL, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	fsys.sign(req, fsys.now())
+
+	resp, err := fsys.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// An error response of the object store.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// Lets errors.Is match fs.ErrNotExist and fs.ErrPermission.
+func (e *Error) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return fs.ErrNotExist
+	case http.StatusForbidden:
+		return fs.ErrPermission
+	}
+	return nil
+}
+
+func responseError(resp *http.Response) error {
+	e := &Error{StatusCode: resp.StatusCode}
+
+	// HEAD responses have no body
+	var body struct {
+		Code    string
+		Message string
+	}
+	if xml.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body) == nil {
+		e.Code, e.Message = body.Code, body.Message
+	}
+	return e
+}
+
+// Returns the key of the object named name, refusing invalid names.
+func key(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		return "", nil
+	}
+	return name, nil
+}
+
+// Opens the named object, or directory.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.Unwrap(err)}
+	}
+
+	if info.IsDir() {
+		return &dir{fsys: fsys, name: name, info: info}, nil
+	}
+	return &file{fsys: fsys, name: name, info: info.(*fileInfo)}, nil
+}
+
+// Returns the FileInfo of the named object, or directory
+// if no object has that key but some have it as a prefix.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	k, err := key("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if k == "" {
+		return &fileInfo{name: ".", dir: true}, nil
+	}
+
+	resp, err := fsys.do(http.MethodHead, fsys.url(k, nil), nil)
+	if err == nil {
+		resp.Body.Close()
+
+		size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+		modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+
+		return &fileInfo{
+			name:        path.Base(name),
+			size:        size,
+			modTime:     modTime,
+			etag:        resp.Header.Get("ETag"),
+			ignoreETags: fsys.config.IgnoreETags,
+		}, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	list, lerr := fsys.list(k+"/", "", 1)
+	if lerr != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: lerr}
+	}
+
+	if len(list.Contents) == 0 && len(list.CommonPrefixes) == 0 {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return &fileInfo{name: path.Base(name), dir: true}, nil
+}
+
+// Lists the named directory, returning its entries sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	k, err := key("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if k != "" {
+		prefix = k + "/"
+	}
+
+	entries := []fs.DirEntry{}
+	token := ""
+
+	for {
+		list, err := fsys.list(prefix, token, 1000)
+		if err != nil {
+			return entries, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+
+		// keys come back sorted, but objects and prefixes separately
+		for _, p := range list.CommonPrefixes {
+			dirName := strings.TrimSuffix(strings.TrimPrefix(p.Prefix, prefix), "/")
+			if dirName != "" {
+				entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{name: dirName, dir: true}))
+			}
+		}
+
+		for _, o := range list.Contents {
+			base := strings.TrimPrefix(o.Key, prefix)
+
+			// keys ending in / are folder markers of consoles
+			if base == "" || strings.HasSuffix(base, "/") {
+				continue
+			}
+
+			entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{
+				name:        base,
+				size:        o.Size,
+				modTime:     o.LastModified,
+				etag:        o.ETag,
+				ignoreETags: fsys.config.IgnoreETags,
+			}))
+		}
+
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			break
+		}
+		token = list.NextContinuationToken
+	}
+
+	if len(entries) == 0 && k != "" {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// A page of ListObjectsV2.
+type listResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		LastModified time.Time
+		ETag         string
+		Size         int64
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+}
+
+func (fsys *FS) list(prefix, token string, max int) (*listResult, error) {
+	query := url.Values{
+		"list-type": {"2"},
+		"delimiter": {"/"},
+		"prefix":    {prefix},
+		"max-keys":  {strconv.Itoa(max)},
+	}
+
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+
+	resp, err := fsys.do(http.MethodGet, fsys.url("", query), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var list listResult
+	if err := xml.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("s3: listing %q: %w", prefix, err)
+	}
+	return &list, nil
+}
+
+// An object opened for reading. The content is only
+// requested on the first Read, so Stat is free.
+type file struct {
+	fsys *FS
+	name string
+	info *fileInfo
+	body io.ReadCloser
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.body == nil {
+		resp, err := f.fsys.do(http.MethodGet, f.fsys.url(f.name, nil), nil)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.body = resp.Body
+	}
+	return f.body.Read(p)
+}
+
+// Reads len(p) bytes at off with a ranged request,
+// e.g to hash parts of large objects.
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if off >= f.info.size {
+		return 0, io.EOF
+	}
+
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1)}}
+	resp, err := f.fsys.do(http.MethodGet, f.fsys.url(f.name, nil), header)
+	if err != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	defer resp.Body.Close()
+
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *file) Close() error {
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+// A prefix opened as a directory, listed on the first ReadDir.
+type dir struct {
+	fsys    *FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+type fileInfo struct {
+	name        string
+	size        int64
+	modTime     time.Time
+	etag        string
+	dir         bool
+	ignoreETags bool
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() interface{}   { return fi }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// Returns the md5 digest of the object in hex when its ETag is one,
+// which is not the case for objects uploaded in several parts.
+// Used by walkman.WithContentHash to skip downloading the object.
+func (fi *fileInfo) ContentMD5() (string, bool) {
+	etag := strings.ToLower(strings.Trim(fi.etag, `"`))
+	if fi.ignoreETags || len(etag) != 32 {
+		return "", false
+	}
+
+	for _, c := range etag {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return "", false
+		}
+	}
+	return etag, true
+}
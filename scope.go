@@ -0,0 +1,41 @@
+package walkman
+
+// Narrows hashes down to the files below dir, e.g for a UI drilling down
+// into ~/Downloads without walking it again. Groups keep the files below
+// dir only, so one may be left with a single file whose copies lie
+// elsewhere; groups without any are dropped.
+func (hashes Results) Under(dir string) Results {
+	return hashes.Filter(func(file File) bool {
+		return IsUnder(file.Path, dir)
+	})
+}
+
+// Narrows hashes down to the groups with files both below dirA and below
+// dirB, e.g what a backup drive duplicates from a laptop. Groups keep the
+// files below either, those of dirA first, others being dropped along with
+// the groups of files listed without being hashed, see WithSizeGrouping.
+func (hashes Results) DuplicatesAcross(dirA, dirB string) Results {
+	across := make(Results)
+
+	for hash, files := range hashes {
+		if listedOnly(algorithmOf(hash)) {
+			continue
+		}
+
+		var inA, inB FileList
+		for _, f := range files {
+			switch {
+			case IsUnder(f.Path, dirA):
+				inA = append(inA, f)
+			case IsUnder(f.Path, dirB):
+				inB = append(inB, f)
+			}
+		}
+
+		if len(inA) > 0 && len(inB) > 0 {
+			across[hash] = append(inA, inB...)
+		}
+	}
+
+	return across
+}
@@ -0,0 +1,62 @@
+package walkman
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUnder(t *testing.T) {
+	dir := t.TempDir()
+	downloads := filepath.Join(dir, "Downloads")
+
+	a := writeFile(t, downloads, "a.pdf", "a", time.Now())
+	writeFile(t, dir, "Documents/a.pdf", "a", time.Now())
+	b := writeFile(t, downloads, "old/b.txt", "bb", time.Now())
+	writeFile(t, dir, "Downloads2/c.txt", "ccc", time.Now())
+
+	hashes, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	under := hashes.Under(downloads)
+	files := under.ToSlice()
+	if len(under) != 2 || len(files) != 2 {
+		t.Fatalf("expected a.pdf and old/b.txt in 2 groups, got %v", under)
+	}
+
+	for _, f := range files {
+		if f.Path != a.Path && f.Path != b.Path {
+			t.Errorf("expected only files below %s, got %s", downloads, f.Path)
+		}
+	}
+}
+
+func TestDuplicatesAcross(t *testing.T) {
+	dir := t.TempDir()
+	laptop, backup := filepath.Join(dir, "laptop"), filepath.Join(dir, "backup")
+
+	a := writeFile(t, laptop, "a.jpg", "a", time.Now())
+	aCopy := writeFile(t, backup, "2021/a.jpg", "a", time.Now())
+	writeFile(t, dir, "tmp/a.jpg", "a", time.Now())
+	writeFile(t, laptop, "b.jpg", "bb", time.Now())
+	writeFile(t, laptop, "b copy.jpg", "bb", time.Now())
+	writeFile(t, backup, "c.jpg", "ccc", time.Now())
+
+	hashes, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	across := hashes.DuplicatesAcross(laptop, backup)
+	if len(across) != 1 {
+		t.Fatalf("expected only the copies of a.jpg, got %v", across)
+	}
+
+	for _, files := range across {
+		if len(files) != 2 || files[0].Path != a.Path || files[1].Path != aCopy.Path {
+			t.Errorf("expected the laptop's a.jpg then the backup's, got %v", files)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package walkman
+
+import (
+	"fmt"
+)
+
+// Compares a fresh content hashed walk with an earlier snapshot of the
+// same tree and returns files whose content changed while their size and
+// modification time stayed the same. Nothing but the disk writes content
+// without touching the mtime, so this is the signature of silent
+// corruption (bit rot).
+//
+// Both results must be hashed by content, see WithContentHash.
+func Scrub(snapshot, current Results) ([]Modification, error) {
+	for _, hashes := range []Results{snapshot, current} {
+		if alg := hashes.Algorithm(); len(hashes) > 0 && alg != AlgorithmMD5 {
+			return nil, fmt.Errorf("walkman: scrub needs content hashes, got %q", alg)
+		}
+	}
+
+	diff, err := DiffSnapshots(snapshot, current)
+	if err != nil {
+		return nil, err
+	}
+
+	corrupted := []Modification{}
+	for _, m := range diff.Modified {
+		if m.Old.Stats == nil || m.New.Stats == nil {
+			continue
+		}
+
+		if m.Old.Stats.Size() == m.New.Stats.Size() && m.Old.Stats.ModTime().Equal(m.New.Stats.ModTime()) {
+			corrupted = append(corrupted, m)
+		}
+	}
+	return corrupted, nil
+}
@@ -0,0 +1,41 @@
+package walkman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScrub(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+
+	rotten := writeFile(t, dir, "rotten.txt", "hello", mtime)
+	writeFile(t, dir, "edited.txt", "hello", mtime)
+
+	before, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// same size and mtime, different content
+	writeFile(t, dir, "rotten.txt", "hellO", mtime)
+	writeFile(t, dir, "edited.txt", "world", time.Now())
+
+	after, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupted, err := Scrub(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(corrupted) != 1 || corrupted[0].New.Path != rotten.Path {
+		t.Errorf("expected only %s to be flagged, got %v", rotten.Path, corrupted)
+	}
+
+	if _, err := Scrub(before, Results{"name:x-1": FileList{{Path: "/x"}}}); err == nil {
+		t.Errorf("expected name hashed results to be refused")
+	}
+}
@@ -0,0 +1,735 @@
+// Package sftp is a minimal, read only SFTP (version 3) client exposing
+// a remote server as an fs.FS, to be walked with walkman.WithFS:
+//
+//	fsys, err := sftp.Dial("backup@nas")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer fsys.Close()
+//
+//	wm := walkman.New(walkman.WithFS("sftp://backup@nas", fsys))
+//	hashes, err := wm.Walk("sftp://backup@nas/srv/photos")
+//
+// The connection is made by the ssh command, so keys, agents, known hosts
+// and ~/.ssh/config are handled as for any other ssh session.
+//
+// Like walkman, it uses no external dependencies.
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const version = 3
+
+// Packet types.
+const (
+	fxpInit    = 1
+	fxpVersion = 2
+	fxpOpen    = 3
+	fxpClose   = 4
+	fxpRead    = 5
+	fxpLstat   = 7
+	fxpFstat   = 8
+	fxpOpendir = 11
+	fxpReaddir = 12
+	fxpStat    = 17
+	fxpStatus  = 101
+	fxpHandle  = 102
+	fxpData    = 103
+	fxpName    = 104
+	fxpAttrs   = 105
+)
+
+// Flags of open and attributes.
+const (
+	fxfRead    = 0x1
+	attrSize   = 0x1
+	attrUIDGID = 0x2
+	attrPerms  = 0x4
+	attrTimes  = 0x8
+	attrExtend = 0x80000000
+)
+
+// Status codes.
+const (
+	fxOK     = 0
+	fxEOF    = 1
+	fxNoSuch = 2
+	fxDenied = 3
+)
+
+const (
+	maxPacket   = 256 * 1024 // largest packet accepted from the server
+	maxReadSize = 32 * 1024  // every server must support reads this large
+)
+
+// FS is a remote file system reached over SFTP.
+//
+// Names are slash separated paths from the root of the server,
+// e.g srv/photos is /srv/photos. It is safe for concurrent use,
+// requests of concurrent callers are pipelined on the connection.
+type FS struct {
+	conn io.ReadWriteCloser
+
+	wmu sync.Mutex // serializes writes to conn
+
+	mu      sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan packet
+	err     error // set once the connection is lost
+}
+
+// A response from the server, without its length and id.
+type packet struct {
+	kind byte
+	data []byte
+}
+
+// Connects to target, [user@]host[:port], by running
+// ssh -s [-p port] [user@]host sftp.
+func Dial(target string) (*FS, error) {
+	host, port := target, ""
+	if i := lastColon(target); i >= 0 {
+		host, port = target[:i], target[i+1:]
+	}
+	host = strings.NewReplacer("[", "", "]", "").Replace(host)
+
+	args := []string{"-s"}
+	if port != "" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, "--", host, "sftp")
+
+	cmd := exec.Command("ssh", args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	fsys, err := NewFS(&sshConn{cmd: cmd, stdin: stdin, stdout: stdout})
+	if err != nil {
+		return nil, fmt.Errorf("sftp %s: %w", target, err)
+	}
+	return fsys, nil
+}
+
+// Index of the colon before a port, ignoring those of IPv6 addresses.
+func lastColon(target string) int {
+	for i := len(target) - 1; i >= 0; i-- {
+		switch target[i] {
+		case ':':
+			return i
+		case ']', '@':
+			return -1
+		}
+	}
+	return -1
+}
+
+// The pipes of an ssh process running the sftp subsystem.
+type sshConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (c *sshConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *sshConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+// Closing stdin ends the session, ssh then exits on its own.
+func (c *sshConn) Close() error {
+	c.stdin.Close()
+	return c.cmd.Wait()
+}
+
+// Starts an SFTP session over conn, e.g the pipes of a subsystem
+// started by an SSH library. Closing the FS closes conn.
+func NewFS(conn io.ReadWriteCloser) (*FS, error) {
+	init := appendUint32([]byte{fxpInit}, version)
+	if err := writePacket(conn, init); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	p, err := readPacket(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if p[0] != fxpVersion || len(p) < 5 {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected packet %d instead of version", p[0])
+	}
+
+	if v := binary.BigEndian.Uint32(p[1:]); v < version {
+		conn.Close()
+		return nil, fmt.Errorf("server speaks version %d, version %d is required", v, version)
+	}
+
+	fsys := &FS{conn: conn, pending: make(map[uint32]chan packet)}
+	go fsys.readLoop()
+	return fsys, nil
+}
+
+// Ends the session. Files still open can no longer be read.
+func (fsys *FS) Close() error {
+	return fsys.conn.Close()
+}
+
+// Hands every response to the request waiting for it.
+func (fsys *FS) readLoop() {
+	for {
+		p, err := readPacket(fsys.conn)
+		if err == nil && len(p) < 5 {
+			err = errors.New("short packet")
+		}
+
+		if err != nil {
+			fsys.mu.Lock()
+			fsys.err = fmt.Errorf("sftp: connection lost: %w", err)
+			for id, ch := range fsys.pending {
+				close(ch)
+				delete(fsys.pending, id)
+			}
+			fsys.mu.Unlock()
+			return
+		}
+
+		id := binary.BigEndian.Uint32(p[1:])
+
+		fsys.mu.Lock()
+		ch, ok := fsys.pending[id]
+		delete(fsys.pending, id)
+		fsys.mu.Unlock()
+
+		if ok {
+			ch <- packet{kind: p[0], data: p[5:]}
+		}
+	}
+}
+
+// Sends a request of the given kind with payload and waits for its response.
+func (fsys *FS) call(kind byte, payload []byte) (packet, error) {
+	ch := make(chan packet, 1)
+
+	fsys.mu.Lock()
+	if fsys.err != nil {
+		fsys.mu.Unlock()
+		return packet{}, fsys.err
+	}
+	id := fsys.nextID
+	fsys.nextID++
+	fsys.pending[id] = ch
+	fsys.mu.Unlock()
+
+	req := make([]byte, 0, 5+len(payload))
+	req = append(req, kind)
+	req = appendUint32(req, id)
+	req = append(req, payload...)
+
+	fsys.wmu.Lock()
+	err := writePacket(fsys.conn, req)
+	fsys.wmu.Unlock()
+
+	if err != nil {
+		fsys.mu.Lock()
+		delete(fsys.pending, id)
+		fsys.mu.Unlock()
+		return packet{}, err
+	}
+
+	p, ok := <-ch
+	if !ok {
+		fsys.mu.Lock()
+		defer fsys.mu.Unlock()
+		return packet{}, fsys.err
+	}
+	return p, nil
+}
+
+// Like call, expecting a response of kind want.
+// Status responses are turned into errors.
+func (fsys *FS) expect(want, kind byte, payload []byte) ([]byte, error) {
+	p, err := fsys.call(kind, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.kind == fxpStatus {
+		return nil, parseStatus(p.data)
+	}
+
+	if p.kind != want {
+		return nil, fmt.Errorf("sftp: unexpected packet %d instead of %d", p.kind, want)
+	}
+	return p.data, nil
+}
+
+// Returns the path on the server of name, refusing invalid names.
+func remotePath(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join("/", name), nil
+}
+
+// Opens the named file for reading.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	p, err := remotePath("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := appendString(nil, p)
+	payload = appendUint32(payload, fxfRead)
+	payload = appendUint32(payload, 0) // no attributes
+
+	data, err := fsys.expect(fxpHandle, fxpOpen, payload)
+	if err != nil {
+		// directories can not be opened as files
+		if info, serr := fsys.Stat(name); serr == nil && info.IsDir() {
+			return &dir{fsys: fsys, name: name, info: info}, nil
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	handle, _, err := readString(data)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &file{fsys: fsys, name: name, handle: handle}, nil
+}
+
+// Returns the FileInfo of the named file, following symbolic links.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	return fsys.stat("stat", fxpStat, name)
+}
+
+// Returns the FileInfo of the named file without following symbolic links.
+func (fsys *FS) Lstat(name string) (fs.FileInfo, error) {
+	return fsys.stat("lstat", fxpLstat, name)
+}
+
+func (fsys *FS) stat(op string, kind byte, name string) (fs.FileInfo, error) {
+	p, err := remotePath(op, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fsys.expect(fxpAttrs, kind, appendString(nil, p))
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	a, _, err := readAttrs(data)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return &fileInfo{name: path.Base(p), attrs: a}, nil
+}
+
+// Reads the named directory, returning its entries sorted by name.
+// Symbolic links are reported as such and not followed.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := remotePath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fsys.expect(fxpHandle, fxpOpendir, appendString(nil, p))
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	handle, _, err := readString(data)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	defer fsys.closeHandle(handle)
+
+	entries := []fs.DirEntry{}
+	for {
+		data, err := fsys.expect(fxpName, fxpReaddir, appendString(nil, handle))
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return entries, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+
+		infos, err := readNames(data)
+		if err != nil {
+			return entries, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+
+		for _, info := range infos {
+			if info.name != "." && info.name != ".." {
+				entries = append(entries, fs.FileInfoToDirEntry(info))
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (fsys *FS) closeHandle(handle string) error {
+	_, err := fsys.call(fxpClose, appendString(nil, handle))
+	return err
+}
+
+// A file opened for reading.
+type file struct {
+	fsys   *FS
+	name   string
+	handle string
+	offset int64
+	closed bool
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	if len(p) > maxReadSize {
+		p = p[:maxReadSize]
+	}
+
+	payload := appendString(nil, f.handle)
+	payload = appendUint64(payload, uint64(f.offset))
+	payload = appendUint32(payload, uint32(len(p)))
+
+	data, err := f.fsys.expect(fxpData, fxpRead, payload)
+	if err == io.EOF {
+		return 0, io.EOF
+	}
+
+	if err != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+
+	chunk, _, err := readString(data)
+	if err != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+
+	n := copy(p, chunk)
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	data, err := f.fsys.expect(fxpAttrs, fxpFstat, appendString(nil, f.handle))
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: err}
+	}
+
+	a, _, err := readAttrs(data)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: err}
+	}
+	return &fileInfo{name: path.Base(f.name), attrs: a}, nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+
+	if err := f.fsys.closeHandle(f.handle); err != nil {
+		return &fs.PathError{Op: "close", Path: f.name, Err: err}
+	}
+	return nil
+}
+
+// A directory returned by Open, read in one go on the first ReadDir.
+type dir struct {
+	fsys    *FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// Attributes of a file as sent by the server.
+type attrs struct {
+	flags   uint32
+	size    uint64
+	perms   uint32
+	modTime uint32
+}
+
+type fileInfo struct {
+	name  string
+	attrs attrs
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return int64(fi.attrs.size) }
+func (fi *fileInfo) ModTime() time.Time { return time.Unix(int64(fi.attrs.modTime), 0) }
+func (fi *fileInfo) IsDir() bool        { return fi.Mode().IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+// Converts the POSIX mode sent by the server.
+func (fi *fileInfo) Mode() fs.FileMode {
+	m := fs.FileMode(fi.attrs.perms & 0777)
+
+	switch fi.attrs.perms & 0170000 {
+	case 0040000:
+		m |= fs.ModeDir
+	case 0120000:
+		m |= fs.ModeSymlink
+	case 0010000:
+		m |= fs.ModeNamedPipe
+	case 0140000:
+		m |= fs.ModeSocket
+	case 0020000:
+		m |= fs.ModeDevice | fs.ModeCharDevice
+	case 0060000:
+		m |= fs.ModeDevice
+	}
+	return m
+}
+
+// A status response other than OK.
+type statusError struct {
+	code uint32
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	if e.msg != "" {
+		return e.msg
+	}
+	return fmt.Sprintf("sftp status %d", e.code)
+}
+
+// Lets errors.Is match the fs errors of the common status codes.
+func (e *statusError) Unwrap() error {
+	switch e.code {
+	case fxNoSuch:
+		return fs.ErrNotExist
+	case fxDenied:
+		return fs.ErrPermission
+	}
+	return nil
+}
+
+// Returns the error of a status response, io.EOF for end of file.
+func parseStatus(data []byte) error {
+	if len(data) < 4 {
+		return errors.New("sftp: short status")
+	}
+
+	code := binary.BigEndian.Uint32(data)
+	if code == fxOK {
+		return errors.New("sftp: unexpected OK status")
+	}
+
+	if code == fxEOF {
+		return io.EOF
+	}
+
+	msg, _, _ := readString(data[4:])
+	return &statusError{code: code, msg: msg}
+}
+
+func readNames(data []byte) ([]*fileInfo, error) {
+	if len(data) < 4 {
+		return nil, errors.New("short name packet")
+	}
+
+	count := binary.BigEndian.Uint32(data)
+	data = data[4:]
+
+	infos := []*fileInfo{}
+	for i := uint32(0); i < count; i++ {
+		name, rest, err := readString(data)
+		if err != nil {
+			return nil, err
+		}
+
+		// the long name is meant for humans only
+		if _, rest, err = readString(rest); err != nil {
+			return nil, err
+		}
+
+		a, rest, err := readAttrs(rest)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, &fileInfo{name: name, attrs: a})
+		data = rest
+	}
+	return infos, nil
+}
+
+var errShort = errors.New("sftp: truncated packet")
+
+func readAttrs(data []byte) (attrs, []byte, error) {
+	var a attrs
+
+	if len(data) < 4 {
+		return a, nil, errShort
+	}
+	a.flags = binary.BigEndian.Uint32(data)
+	data = data[4:]
+
+	if a.flags&attrSize != 0 {
+		if len(data) < 8 {
+			return a, nil, errShort
+		}
+		a.size = binary.BigEndian.Uint64(data)
+		data = data[8:]
+	}
+
+	if a.flags&attrUIDGID != 0 {
+		if len(data) < 8 {
+			return a, nil, errShort
+		}
+		data = data[8:]
+	}
+
+	if a.flags&attrPerms != 0 {
+		if len(data) < 4 {
+			return a, nil, errShort
+		}
+		a.perms = binary.BigEndian.Uint32(data)
+		data = data[4:]
+	}
+
+	if a.flags&attrTimes != 0 {
+		if len(data) < 8 {
+			return a, nil, errShort
+		}
+		a.modTime = binary.BigEndian.Uint32(data[4:]) // after the access time
+		data = data[8:]
+	}
+
+	if a.flags&attrExtend != 0 {
+		if len(data) < 4 {
+			return a, nil, errShort
+		}
+		count := binary.BigEndian.Uint32(data)
+		data = data[4:]
+
+		for i := uint32(0); i < 2*count; i++ {
+			var err error
+			if _, data, err = readString(data); err != nil {
+				return a, nil, err
+			}
+		}
+	}
+
+	return a, data, nil
+}
+
+func readString(data []byte) (string, []byte, error) {
+	if len(data) < 4 {
+		return "", nil, errShort
+	}
+
+	n := binary.BigEndian.Uint32(data)
+	if uint64(len(data)-4) < uint64(n) {
+		return "", nil, errShort
+	}
+	return string(data[4 : 4+n]), data[4+n:], nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+// Writes p prefixed with its length.
+func writePacket(w io.Writer, p []byte) error {
+	_, err := w.Write(append(appendUint32(make([]byte, 0, 4+len(p)), uint32(len(p))), p...))
+	return err
+}
+
+// Reads a packet without its length prefix.
+func readPacket(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if n == 0 || n > maxPacket {
+		return nil, fmt.Errorf("sftp: bad packet length %d", n)
+	}
+
+	p := make([]byte, n)
+	if _, err := io.ReadFull(r, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
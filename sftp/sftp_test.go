@@ -0,0 +1,267 @@
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// Serves the files below root over conn, answering the
+// requests FS makes like an SFTP server would.
+type fakeServer struct {
+	root    string
+	mu      sync.Mutex
+	handles map[string]interface{} // *os.File or the entries of a directory
+	next    int
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		p, err := readPacket(conn)
+		if err != nil {
+			return
+		}
+
+		if p[0] == fxpInit {
+			writePacket(conn, appendUint32([]byte{fxpVersion}, version))
+			continue
+		}
+
+		id := binary.BigEndian.Uint32(p[1:])
+		kind, data := s.handle(p[0], p[5:])
+
+		reply := appendUint32([]byte{kind}, id)
+		if err := writePacket(conn, append(reply, data...)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) handle(kind byte, data []byte) (byte, []byte) {
+	arg, rest, _ := readString(data)
+
+	switch kind {
+	case fxpOpen:
+		f, err := os.Open(filepath.Join(s.root, arg))
+		if err != nil {
+			return status(err)
+		}
+
+		if fi, _ := f.Stat(); fi.IsDir() {
+			f.Close()
+			return status(fs.ErrInvalid)
+		}
+		return fxpHandle, appendString(nil, s.newHandle(f))
+	case fxpOpendir:
+		entries, err := os.ReadDir(filepath.Join(s.root, arg))
+		if err != nil {
+			return status(err)
+		}
+		return fxpHandle, appendString(nil, s.newHandle(entries))
+	case fxpRead:
+		offset := binary.BigEndian.Uint64(rest)
+		size := binary.BigEndian.Uint32(rest[8:])
+
+		f := s.lookup(arg).(*os.File)
+		buf := make([]byte, size)
+		n, err := f.ReadAt(buf, int64(offset))
+		if n == 0 && err != nil {
+			return status(err)
+		}
+		return fxpData, appendString(nil, string(buf[:n]))
+	case fxpReaddir:
+		entries, _ := s.lookup(arg).([]fs.DirEntry)
+		if len(entries) == 0 {
+			return status(io.EOF)
+		}
+
+		// two at a time to exercise repeated reads
+		if len(entries) > 2 {
+			s.store(arg, entries[2:])
+			entries = entries[:2]
+		} else {
+			s.store(arg, []fs.DirEntry{})
+		}
+
+		names := appendUint32(nil, uint32(len(entries)))
+		for _, e := range entries {
+			fi, err := e.Info()
+			if err != nil {
+				return status(err)
+			}
+			names = appendString(names, e.Name())
+			names = appendString(names, "long "+e.Name())
+			names = appendFileAttrs(names, fi)
+		}
+		return fxpName, names
+	case fxpFstat:
+		fi, err := s.lookup(arg).(*os.File).Stat()
+		if err != nil {
+			return status(err)
+		}
+		return fxpAttrs, appendFileAttrs(nil, fi)
+	case fxpStat, fxpLstat:
+		fi, err := os.Lstat(filepath.Join(s.root, arg))
+		if kind == fxpStat {
+			fi, err = os.Stat(filepath.Join(s.root, arg))
+		}
+
+		if err != nil {
+			return status(err)
+		}
+		return fxpAttrs, appendFileAttrs(nil, fi)
+	case fxpClose:
+		if f, ok := s.lookup(arg).(*os.File); ok {
+			f.Close()
+		}
+		s.store(arg, nil)
+		return status(nil)
+	}
+
+	return fxpStatus, appendString(appendUint32(nil, 8), "unsupported")
+}
+
+func (s *fakeServer) newHandle(v interface{}) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next++
+	h := strconv.Itoa(s.next)
+	s.handles[h] = v
+	return h
+}
+
+func (s *fakeServer) lookup(h string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handles[h]
+}
+
+func (s *fakeServer) store(h string, v interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handles[h] = v
+}
+
+func status(err error) (byte, []byte) {
+	code := uint32(fxOK)
+	switch {
+	case err == io.EOF:
+		code = fxEOF
+	case os.IsNotExist(err):
+		code = fxNoSuch
+	case err != nil:
+		code = 4 // failure
+	}
+
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	return fxpStatus, appendString(appendString(appendUint32(nil, code), msg), "en")
+}
+
+func appendFileAttrs(b []byte, fi fs.FileInfo) []byte {
+	perms := uint32(fi.Mode().Perm())
+	switch {
+	case fi.IsDir():
+		perms |= 0040000
+	case fi.Mode()&fs.ModeSymlink != 0:
+		perms |= 0120000
+	default:
+		perms |= 0100000
+	}
+
+	b = appendUint32(b, attrSize|attrPerms|attrTimes)
+	b = appendUint64(b, uint64(fi.Size()))
+	b = appendUint32(b, perms)
+	b = appendUint32(b, uint32(fi.ModTime().Unix()))
+	return appendUint32(b, uint32(fi.ModTime().Unix()))
+}
+
+func newTestFS(t *testing.T) *FS {
+	t.Helper()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"a.txt":         "hello",
+		"sub/b.txt":     "hello again",
+		"sub/c.txt":     "",
+		"sub/deep/d.go": "package d",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, server := net.Pipe()
+	go (&fakeServer{root: root, handles: map[string]interface{}{}}).serve(server)
+
+	fsys, err := NewFS(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fsys.Close() })
+
+	return fsys
+}
+
+func TestFS(t *testing.T) {
+	fsys := newTestFS(t)
+
+	if err := fstest.TestFS(fsys, "a.txt", "sub/b.txt", "sub/c.txt", "sub/deep/d.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := fs.ReadFile(fsys, "sub/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "hello again" {
+		t.Errorf("read %q, want %q", content, "hello again")
+	}
+
+	if _, err := fs.Stat(fsys, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("stat of a missing file: %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestLastColon(t *testing.T) {
+	tests := map[string]string{
+		"nas":            "",
+		"me@nas":         "",
+		"me@nas:2222":    "2222",
+		"[::1]:22":       "22",
+		"me@[fe80::1]":   "",
+		"me@[fe80::1]:2": "2",
+	}
+
+	for target, want := range tests {
+		port := ""
+		if i := lastColon(target); i >= 0 {
+			port = target[i+1:]
+		}
+
+		if port != want {
+			t.Errorf("port of %q = %q, want %q", target, port, want)
+		}
+	}
+}
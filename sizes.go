@@ -0,0 +1,82 @@
+package walkman
+
+import (
+	"io/fs"
+	"strconv"
+	"sync/atomic"
+)
+
+// Only hash files whose size is shared by another file, as the others can
+// not have a duplicate. The tree is walked first, collecting sizes, then
+// files of shared sizes are hashed. This saves most of the reading done
+// by content hashers on real trees.
+//
+// Files of a unique size are still listed, keyed by their size, e.g
+// size:1024, with their MIME type and metadata. They are neither hashed
+// nor indexed. Archives are always hashed to look inside them, but a
+// file whose only copy lies in an archive is not found.
+//
+// Hashers that match files of different sizes, like VideoHasher,
+// must not be combined with it.
+func WithSizeGrouping() Option {
+	return func(w *Walkman) {
+		w.config.sizeFirst = true
+	}
+}
+
+// A file waiting for the tree to be walked, see WithSizeGrouping.
+type sized struct {
+	path string
+	info fs.FileInfo
+}
+
+// Keeps a file until all sizes are known.
+func (wm *Walkman) addSized(path string, fi fs.FileInfo) {
+	wm.sizesMu.Lock()
+	defer wm.sizesMu.Unlock()
+
+	if wm.sizes == nil {
+		wm.sizes = make(map[int64][]sized)
+	}
+	wm.sizes[fi.Size()] = append(wm.sizes[fi.Size()], sized{path: path, info: fi})
+}
+
+// Processes the files kept by addSized once the tree was walked,
+// hashing those whose size is shared.
+func (wm *Walkman) processSized() {
+	for size, files := range wm.sizes {
+		if wm.config.duplicatesOnly && len(files) > 1 {
+			wm.expectSize(size, len(files))
+		}
+
+		for _, f := range files {
+			unique := len(files) == 1 && !wm.descends(f.path)
+
+			wm.queueFile(f.path, f.info, unique)
+		}
+	}
+	wm.sizes = nil
+}
+
+// Lists a file of unique size without hashing it.
+func (wm *Walkman) processUnique(path string, fi fs.FileInfo) {
+	atomic.AddInt64(&wm.stats.FilesUnique, 1)
+	wm.checkBudget()
+	wm.postFile(path, fi, sizeKey(fi.Size()), nil)
+
+	// it has no copy
+	if wm.config.duplicatesOnly {
+		return
+	}
+
+	mime := ""
+	if wm.config.mime {
+		mime = newSniffer(wm.opener(path)).mime()
+	}
+
+	wm.pairs <- pair{hash: sizeKey(fi.Size()), path: path, info: fi, mime: mime, meta: wm.extract(path, wm.opener(path))}
+}
+
+func sizeKey(size int64) string {
+	return AlgorithmSize + ":" + strconv.FormatInt(size, 10)
+}
@@ -0,0 +1,95 @@
+package walkman
+
+import (
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithSizeGrouping(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.txt", "hello", time.Now())
+	b := writeFile(t, dir, "sub/b.txt", "hello", time.Now())
+	c := writeFile(t, dir, "c.txt", "world", time.Now())
+	unique := writeFile(t, dir, "unique.txt", "nobody has my size", time.Now())
+
+	var mu sync.Mutex
+	hashed := map[string]bool{}
+	hasher := func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		mu.Lock()
+		hashed[filepath.Base(path)] = true
+		mu.Unlock()
+		return MD5ContentHasher(path, info, open)
+	}
+
+	wm := New(WithHasher(hasher), WithSizeGrouping(), WithMIME())
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashed) != 3 || hashed["unique.txt"] {
+		t.Errorf("expected only the files of 5 bytes to be hashed, got %v", hashed)
+	}
+
+	files := hashes["size:18"]
+	if len(files) != 1 || files[0].Path != unique.Path || files[0].MIME != "text/plain; charset=utf-8" {
+		t.Errorf("expected %s listed under its size with its MIME type, got %v", unique.Path, files)
+	}
+
+	if group := hashes[hashOf(t, hashes, a.Path)]; len(group) != 2 || hashOf(t, hashes, b.Path) != hashOf(t, hashes, a.Path) {
+		t.Errorf("expected %s and %s in a group, got %v", a.Path, b.Path, group)
+	}
+
+	if hashOf(t, hashes, c.Path) == hashOf(t, hashes, a.Path) {
+		t.Errorf("%s grouped with %s by size alone", c.Path, a.Path)
+	}
+
+	if stats := wm.LastRunStats(); stats.FilesScanned != 3 || stats.FilesUnique != 1 {
+		t.Errorf("expected 3 files hashed and 1 unique, got %d and %d", stats.FilesScanned, stats.FilesUnique)
+	}
+
+	if alg := hashes.Algorithm(); alg != AlgorithmMD5 {
+		t.Errorf("expected the results to be md5 ones, got %q", alg)
+	}
+
+	// a copy of the unique file shows up, both have to be hashed now
+	writeFile(t, dir, "copy.txt", "nobody has my size", time.Now())
+
+	next, err := New(WithContentHash(), WithSizeGrouping(), WithPrevious(hashes)).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if group := next[hashOf(t, next, unique.Path)]; len(group) != 2 {
+		t.Errorf("expected %s and its copy in a group, got %v", unique.Path, next)
+	}
+
+	diff, err := DiffSnapshots(hashes, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff.Added) != 1 || len(diff.Modified) != 0 {
+		t.Errorf("expected only copy.txt to be added, got %+v", diff)
+	}
+}
+
+// Returns the hash path is grouped under.
+func hashOf(t *testing.T, hashes Results, path string) string {
+	t.Helper()
+
+	for hash, files := range hashes {
+		for _, f := range files {
+			if f.Path == path {
+				return hash
+			}
+		}
+	}
+
+	t.Fatalf("%s is missing from %v", path, hashes)
+	return ""
+}
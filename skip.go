@@ -0,0 +1,71 @@
+package walkman
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Skips directories and files whose path matches any of the patterns,
+// e.g `/src/(.*/)?build$` for directories named build under a src tree,
+// which names in SkipDirs can not tell apart from the others. Paths are
+// matched whole with forward slashes, whatever the OS, roots excepted.
+//
+// Panics if a pattern does not compile, like regexp.MustCompile.
+func WithSkipRegex(patterns ...string) Option {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		res = append(res, regexp.MustCompile(pattern))
+	}
+
+	return func(wm *Walkman) {
+		wm.config.skipRegex = append(wm.config.skipRegex, res...)
+	}
+}
+
+// Reports whether path matches any pattern of WithSkipRegex.
+func (c *config) skipMatch(path string) bool {
+	if len(c.skipRegex) == 0 {
+		return false
+	}
+
+	path = filepath.ToSlash(path)
+	for _, re := range c.skipRegex {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Passes files whose path matches re, with forward slashes whatever
+// the OS like WithSkipRegex. Combine with Not to leave them out.
+func ByPathRegex(re *regexp.Regexp) PathFilter {
+	return func(file File) bool {
+		return re.MatchString(filepath.ToSlash(file.Path))
+	}
+}
+
+// Skips directories holding a file named marker, e.g ".nodedup", so that
+// users may leave folders out by dropping a file in them rather than
+// listing them all. Like with SkipDirs, the roots walked are never skipped.
+func WithSkipMarker(marker string) Option {
+	return func(wm *Walkman) {
+		wm.config.skipMarker = marker
+	}
+}
+
+// Reports whether dir holds the marker of WithSkipMarker.
+func (wm *Walkman) marked(dir string) bool {
+	if wm.config.skipMarker == "" {
+		return false
+	}
+
+	sep := string(filepath.Separator)
+	if isRemote(dir) {
+		sep = "/"
+	}
+
+	_, err := wm.stat(strings.TrimSuffix(dir, sep) + sep + wm.config.skipMarker)
+	return err == nil
+}
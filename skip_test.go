@@ -0,0 +1,103 @@
+package walkman
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestWithSkipRegex(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "src/app/build/out.o", "a", time.Now())
+	writeFile(t, dir, "src/app/main.c", "b", time.Now())
+	writeFile(t, dir, "build/keep.txt", "c", time.Now())
+	writeFile(t, dir, "notes.tmp", "d", time.Now())
+
+	wm := New(WithSkipRegex(`/src/(.*/)?build$`, `\.tmp$`))
+	hashes, report, err := wm.WalkWithReport(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]bool)
+	for _, file := range hashes.ToSlice() {
+		got[file.RelPath] = true
+	}
+
+	if len(got) != 2 || !got["src/app/main.c"] || !got["build/keep.txt"] {
+		t.Errorf("expected main.c and the build directory outside src, got %v", got)
+	}
+
+	want := filepath.Join(dir, "src", "app", "build")
+	if len(report.Skipped) != 1 || report.Skipped[0].Path != want || report.Skipped[0].Reason != SkipPattern {
+		t.Errorf("expected %s skipped by pattern, got %v", want, report.Skipped)
+	}
+
+	if report.Stats.FilesSkipped != 1 {
+		t.Errorf("expected notes.tmp skipped, got %+v", report.Stats)
+	}
+}
+
+func TestByPathRegex(t *testing.T) {
+	hashes := Results{"md5:a": {{Path: "/src/a/build/x.o"}, {Path: "/home/x.o"}}}
+
+	built := hashes.Filter(ByPathRegex(regexp.MustCompile(`/build/`)))
+	if files := built["md5:a"]; len(files) != 1 || files[0].Path != "/src/a/build/x.o" {
+		t.Errorf("expected only the built file, got %v", built)
+	}
+}
+
+func TestWithSkipList(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "node_modules/a.js", "a", time.Now())
+	writeFile(t, dir, "venv/b.py", "b", time.Now())
+	writeFile(t, dir, "cmake-build-debug/c.o", "c", time.Now())
+
+	var keep []string
+	for _, name := range DefaultSkipDirs() {
+		if name != "node_modules" {
+			keep = append(keep, name)
+		}
+	}
+
+	hashes, err := New(WithSkipList(append(keep, "cmake-build-*"))).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := hashes.ToSlice()
+	if len(files) != 1 || files[0].RelPath != "node_modules/a.js" {
+		t.Errorf("expected only node_modules walked, got %v", files)
+	}
+
+	defaults := DefaultSkipDirs()
+	defaults[0] = "changed"
+	if dirs_to_skip[0] == "changed" {
+		t.Error("expected DefaultSkipDirs to return a copy")
+	}
+}
+
+func TestWithSkipMarker(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ".nodedup", "", time.Now())
+	writeFile(t, dir, "a.txt", "a", time.Now())
+	writeFile(t, dir, "private/.nodedup", "", time.Now())
+	writeFile(t, dir, "private/b.txt", "b", time.Now())
+
+	hashes, report, err := New(WithSkipMarker(".nodedup")).WalkWithReport(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := hashes.ToSlice()
+	if len(files) != 1 || files[0].RelPath != "a.txt" {
+		t.Errorf("expected only a.txt from the marked root, got %v", files)
+	}
+
+	want := filepath.Join(dir, "private")
+	if len(report.Skipped) != 1 || report.Skipped[0].Path != want || report.Skipped[0].Reason != SkipMarked {
+		t.Errorf("expected %s skipped by marker, got %v", want, report.Skipped)
+	}
+}
@@ -0,0 +1,307 @@
+package walkman
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version of the snapshot format written by Results.Save.
+const snapshotVersion = 1
+
+// On disk layout of a snapshot.
+type snapshot struct {
+	Version   int             `json:"version"`
+	Algorithm string          `json:"algorithm"` // empty if the results mix algorithms
+	Created   time.Time       `json:"created"`
+	Groups    []snapshotGroup `json:"groups"`
+}
+
+type snapshotGroup struct {
+	Hash  string         `json:"hash"`
+	Files []snapshotFile `json:"files"`
+}
+
+type snapshotFile struct {
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mtime"`
+	Archive string      `json:"archive,omitempty"`
+	MIME    string      `json:"mime,omitempty"`
+	Meta    *Meta       `json:"meta,omitempty"`
+	Owner   *Owner      `json:"owner,omitempty"`
+	Target  string      `json:"target,omitempty"`
+	Known   bool        `json:"known,omitempty"`
+	Atime   time.Time   `json:"atime,omitempty"`
+	Root    string      `json:"root,omitempty"`
+	RelPath string      `json:"rel,omitempty"`
+}
+
+// Saves the results to a JSON snapshot at path, so that a slow
+// scan can be queried many times later on with LoadResults.
+// The snapshot is compressed with gzip if path ends in .gz.
+func (hashes Results) Save(path string) error {
+	return saveFile(path, hashes.WriteSnapshot)
+}
+
+// Writes a file at path with write, compressed if path ends in .gz.
+func saveFile(path string, write func(w io.Writer) error) error {
+	// Write to a temporary file first so that an existing
+	// snapshot is never left half written.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w, end := compressTo(tmp, path)
+	if err := write(w); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := end(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// on disk before it replaces the old one, e.g for checkpoints
+	// that have to survive a reboot
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// Flushes the entries of dir so that a file renamed into it survives a
+// crash. Best effort, as not every system syncs directories, e.g Windows.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// Writes the results to w in the snapshot format of Save.
+func (hashes Results) WriteSnapshot(w io.Writer) error {
+	snap := snapshot{
+		Version:   snapshotVersion,
+		Algorithm: hashes.Algorithm(),
+		Created:   time.Now(),
+		Groups:    make([]snapshotGroup, 0, len(hashes)),
+	}
+
+	for _, hash := range hashes.sortedKeys() {
+		snap.Groups = append(snap.Groups, hashes.snapshotGroup(hash))
+	}
+
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// Returns the hashes of the results in order.
+func (hashes Results) sortedKeys() []string {
+	keys := make([]string, 0, len(hashes))
+	for hash := range hashes {
+		keys = append(keys, hash)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the group of hash as recorded in a snapshot.
+func (hashes Results) snapshotGroup(hash string) snapshotGroup {
+	group := snapshotGroup{Hash: hash}
+	for _, f := range hashes[hash] {
+		group.Files = append(group.Files, newSnapshotFile(f))
+	}
+	return group
+}
+
+// Returns f as recorded in a snapshot.
+func newSnapshotFile(f File) snapshotFile {
+	return snapshotFile{
+		Path:    f.Path,
+		Size:    f.Stats.Size(),
+		Mode:    f.Stats.Mode(),
+		ModTime: f.Stats.ModTime(),
+		Archive: f.Archive,
+		MIME:    f.MIME,
+		Meta:    f.Meta,
+		Owner:   f.Owner,
+		Target:  f.Target,
+		Known:   f.Known,
+		Atime:   f.Accessed,
+		Root:    f.Root,
+		RelPath: f.RelPath,
+	}
+}
+
+// Returns the file recorded as sf.
+func (sf snapshotFile) file() File {
+	return File{
+		Path:     sf.Path,
+		Stats:    sf.info(),
+		Archive:  sf.Archive,
+		MIME:     sf.MIME,
+		Meta:     sf.Meta,
+		Owner:    sf.Owner,
+		Target:   sf.Target,
+		Known:    sf.Known,
+		Accessed: sf.Atime,
+		Root:     sf.Root,
+		RelPath:  sf.RelPath,
+	}
+}
+
+// Loads results saved with Results.Save or Results.SaveBinary,
+// compressed or not.
+//
+// File.Stats of loaded files describe each file at the time
+// of the scan, not its current state.
+func LoadResults(path string) (Results, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	in, err := decompress(f)
+	if err != nil {
+		return nil, fmt.Errorf("walkman: reading snapshot %s: %w", path, err)
+	}
+
+	r := bufio.NewReader(in)
+	if magic, _ := r.Peek(len(binarySnapshotMagic)); string(magic) == binarySnapshotMagic {
+		hashes, err := readBinarySnapshot(r)
+		if err != nil {
+			return nil, fmt.Errorf("walkman: reading snapshot %s: %w", path, err)
+		}
+		return hashes, nil
+	}
+
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("walkman: reading snapshot %s: %w", path, err)
+	}
+
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("walkman: unsupported snapshot version %d in %s", snap.Version, path)
+	}
+
+	hashes := make(Results, len(snap.Groups))
+	for _, group := range snap.Groups {
+		hashes.addSnapshotGroup(group)
+	}
+
+	if err := hashes.checkAlgorithm(snap.Algorithm); err != nil {
+		return nil, fmt.Errorf("walkman: snapshot %s %w", path, err)
+	}
+
+	return hashes, nil
+}
+
+// Adds the files of a group read from a snapshot.
+func (hashes Results) addSnapshotGroup(group snapshotGroup) {
+	files := make(FileList, 0, len(group.Files))
+	for _, sf := range group.Files {
+		files = append(files, sf.file())
+	}
+	hashes[group.Hash] = files
+}
+
+// Fails unless algorithm, as claimed by a snapshot, is that of the hashes.
+func (hashes Results) checkAlgorithm(algorithm string) error {
+	if hashes.Algorithm() != algorithm {
+		return fmt.Errorf("claims algorithm %q but holds %q hashes", algorithm, hashes.Algorithm())
+	}
+	return nil
+}
+
+// Returns the algorithm of the hasher that produced the results, shared
+// by every hash, e.g md5, or an empty string if there is none, as when
+// hashes of different algorithms were mixed. Files listed without being
+// hashed, e.g by WithSizeGrouping or WithEmpty, do not count.
+func (hashes Results) Algorithm() string {
+	algorithm := ""
+	for hash := range hashes {
+		a := algorithmOf(hash)
+		if listedOnly(a) {
+			continue
+		}
+
+		if a == "" || (algorithm != "" && a != algorithm) {
+			return ""
+		}
+		algorithm = a
+	}
+	return algorithm
+}
+
+// Reports whether no file of hashes was hashed, see WithSizeGrouping.
+func (hashes Results) unhashed() bool {
+	for hash := range hashes {
+		if !listedOnly(algorithmOf(hash)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether files keyed by the algorithm alg were listed without
+// being hashed, see WithSizeGrouping, WithEmpty and SymlinkRecord.
+func listedOnly(alg string) bool {
+	return alg == AlgorithmSize || alg == AlgorithmEmpty || alg == AlgorithmLink || alg == AlgorithmDeadLink
+}
+
+// Returns the algorithm prefix of hash, e.g md5 for md5:d41d8c...
+func algorithmOf(hash string) string {
+	i := strings.IndexByte(hash, ':')
+	if i < 0 {
+		return ""
+	}
+	return hash[:i]
+}
+
+// fs.FileInfo of a file as recorded in a snapshot.
+func (sf snapshotFile) info() fs.FileInfo {
+	return snapshotInfo{
+		name:    filepath.Base(sf.Path),
+		size:    sf.Size,
+		mode:    sf.Mode,
+		modTime: sf.ModTime,
+	}
+}
+
+type snapshotInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (si snapshotInfo) Name() string       { return si.name }
+func (si snapshotInfo) Size() int64        { return si.size }
+func (si snapshotInfo) Mode() fs.FileMode  { return si.mode }
+func (si snapshotInfo) ModTime() time.Time { return si.modTime }
+func (si snapshotInfo) IsDir() bool        { return si.mode.IsDir() }
+func (si snapshotInfo) Sys() interface{}   { return nil }
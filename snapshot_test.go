@@ -0,0 +1,63 @@
+package walkman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Date(2023, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	writeFile(t, dir, "a/x.txt", "same", mtime)
+	writeFile(t, dir, "b/x.txt", "same", mtime)
+	writeFile(t, dir, "c.txt", "other", mtime)
+
+	hashes, err := New(WithContentHash()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap := filepath.Join(t.TempDir(), "scan.json")
+	if err := hashes.Save(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadResults(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Algorithm() != AlgorithmMD5 {
+		t.Errorf("expected md5 results, got %q", loaded.Algorithm())
+	}
+
+	if len(loaded) != len(hashes) {
+		t.Fatalf("expected %d groups, got %d", len(hashes), len(loaded))
+	}
+
+	for hash, files := range hashes {
+		if len(loaded[hash]) != len(files) {
+			t.Errorf("group %s: expected %d files, got %d", hash, len(files), len(loaded[hash]))
+			continue
+		}
+
+		f := loaded[hash][0]
+		if f.Stats.Size() != files[0].Stats.Size() || !f.Stats.ModTime().Equal(mtime) {
+			t.Errorf("stats of %s were not preserved: %d %v", f.Path, f.Stats.Size(), f.Stats.ModTime())
+		}
+	}
+}
+
+func TestLoadResultsRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadResults(path); err == nil {
+		t.Errorf("expected an error for an unknown snapshot version")
+	}
+}
@@ -0,0 +1,35 @@
+package walkman
+
+import "sort"
+
+// Sorts the files of every group by path once the tree is walked, so that
+// walking the same tree twice gives the same results in the same order,
+// e.g for byte-identical reports to diff, or in tests. Groups themselves
+// have no order, range over Results.Hashes for one. The files of a
+// MemoryBackend given to WithResultBackend are sorted too.
+func WithDeterministicOrder() Option {
+	return func(w *Walkman) {
+		w.config.sorted = true
+	}
+}
+
+// Returns the hashes of the results, sorted.
+func (hashes Results) Hashes() []string {
+	return hashes.sortedKeys()
+}
+
+// Sorts the files of every group by path.
+func (hashes Results) sortFiles() {
+	for _, files := range hashes {
+		sort.SliceStable(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	}
+}
+
+// Sorts the files of the MemoryBackends of the walk.
+func (wm *Walkman) sortBackends() {
+	for _, backend := range wm.backends {
+		if memory, ok := backend.(*MemoryBackend); ok {
+			memory.Results().sortFiles()
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package walkman
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestDeterministicOrder(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		writeFile(t, dir, fmt.Sprintf("%c/same.txt", 'a'+i), "same", time.Now())
+	}
+
+	hashes, err := New(WithContentHash(), WithDeterministicOrder()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, files := range hashes {
+		if !sort.SliceIsSorted(files, func(i, j int) bool { return files[i].Path < files[j].Path }) {
+			t.Errorf("expected files sorted by path, got %v", files)
+		}
+	}
+
+	keys := hashes.Hashes()
+	if len(keys) != len(hashes) || !sort.StringsAreSorted(keys) {
+		t.Errorf("expected every hash in order, got %v", keys)
+	}
+}
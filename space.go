@@ -0,0 +1,50 @@
+package walkman
+
+import (
+	"io"
+	"path/filepath"
+)
+
+// Savings compares the space a plan was expected to free with what the
+// file systems it touched actually gained, see Plan.ExecuteMeasured.
+type Savings struct {
+	Expected int64 // bytes, see Plan.Reclaimable
+	Actual   int64 // growth of the free space of the file systems touched
+	Measured bool  // false if free space is unknown, e.g on this platform
+}
+
+// Like Execute, also measuring the free space of the file systems holding
+// the files of the plan before and after. Hard links and reflinks often free
+// less than expected, e.g when a duplicate already shared its blocks with
+// the kept copy or with a snapshot. Anything else writing to those file
+// systems in the meantime is counted too.
+func (p Plan) ExecuteMeasured(journal io.Writer) (int, Savings, error) {
+	savings := Savings{Expected: p.Reclaimable()}
+
+	before, measured := p.freeSpace()
+	done, err := p.Execute(journal)
+
+	if measured {
+		after, ok := p.freeSpace()
+		savings.Measured = ok
+
+		for volume, free := range after {
+			savings.Actual += free - before[volume]
+		}
+	}
+	return done, savings, err
+}
+
+// Returns the free bytes of every file system holding a target of the plan,
+// and whether all of them are known.
+func (p Plan) freeSpace() (map[string]int64, bool) {
+	free := make(map[string]int64)
+	for _, s := range p {
+		volume, n, err := diskSpace(filepath.Dir(s.Target.Path))
+		if err != nil {
+			return nil, false
+		}
+		free[volume] = n
+	}
+	return free, true
+}
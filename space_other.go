@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package walkman
+
+import "errors"
+
+// Free space is not known on this platform.
+func diskSpace(dir string) (string, int64, error) {
+	return "", 0, errors.ErrUnsupported
+}
@@ -0,0 +1,43 @@
+package walkman
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteMeasured(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	content := strings.Repeat("x", 1<<20)
+	keep := writeFile(t, dir, "keep.bin", content, now.Add(-time.Hour))
+	dup := writeFile(t, dir, "dup.bin", content, now)
+
+	plan := NewPlan(Results{"x": FileList{keep, dup}}, KeepOldest(), ActionDelete, "")
+
+	done, savings, err := plan.ExecuteMeasured(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if done != 1 {
+		t.Fatalf("expected 1 step applied, got %d", done)
+	}
+
+	if savings.Expected != int64(len(content)) {
+		t.Errorf("expected %d bytes to be freed, got %d", len(content), savings.Expected)
+	}
+
+	if _, err := os.Stat(dup.Path); !os.IsNotExist(err) {
+		t.Errorf("expected %s removed", dup.Path)
+	}
+
+	// Other processes write to the same file system,
+	// only check that it was measured where supported.
+	if _, _, err := diskSpace(dir); err == nil && !savings.Measured {
+		t.Error("expected free space measured")
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux || darwin || freebsd
+
+package walkman
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Returns the device holding dir and the bytes free on it
+// for unprivileged users.
+func diskSpace(dir string) (string, int64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(longPath(dir), &st); err != nil {
+		return "", 0, err
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(longPath(dir), &fs); err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprint(uint64(st.Dev)), int64(fs.Bavail) * int64(fs.Bsize), nil
+}
@@ -0,0 +1,24 @@
+package walkman
+
+import (
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// Returns the volume holding dir and the bytes free on it
+// for the current user.
+func diskSpace(dir string) (string, int64, error) {
+	path, err := syscall.UTF16PtrFromString(longPath(dir))
+	if err != nil {
+		return "", 0, err
+	}
+
+	var free uint64
+	if r, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&free)), 0, 0); r == 0 {
+		return "", 0, err
+	}
+	return filepath.VolumeName(dir), int64(free), nil
+}
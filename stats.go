@@ -0,0 +1,105 @@
+package walkman
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RunStats summarises the last walk of a Walkman.
+type RunStats struct {
+	FilesScanned int64         // files handed to the hasher
+	FilesReused  int64         // unchanged files whose hash came from a previous walk
+	FilesUnique  int64         // files left unhashed as no other file has their size, see WithSizeGrouping
+	FilesSkipped int64         // macOS metadata and files whose content is in the cloud
+	FilesKnown   int64         // files whose hash is known, see WithKnownHashes
+	BytesHashed  int64         // total size of those files
+	DirsScanned  int64         // directories read
+	DirsSkipped  int64         // hidden and skip listed directories
+	LinksSkipped int64         // symbolic links, and junctions on Windows, never followed
+	Errors       int64         // files and directories left out, see Walkman.Errors
+	FilesLocked  int64         // of those, files locked by another process, see WalkReport.Locked
+	Retries      int64         // failed attempts tried again, see WithRetry
+	Elapsed      time.Duration // wall time of the walk
+
+	// Time spent by workers in each phase, summed over all of them so
+	// it can be more than Elapsed. Together with IdleTime, it tells
+	// whether more or fewer workers would help.
+	WalkWorkers int           // workers reading directories, see WithWalkWorkers
+	HashWorkers int           // workers hashing files, see WithHashWorkers
+	AutoWorkers bool          // HashWorkers was picked by WithAutoWorkers, pin it with WithHashWorkers
+	DirTime     time.Duration // reading directories
+	HashTime    time.Duration // hashing files, extracting metadata and looking into archives
+	IdleTime    time.Duration // with nothing to do, workers times Elapsed less the time they were busy
+	IndexTime   time.Duration // updating the index once the tree was walked, see WithIndex
+}
+
+// Files hashed per second of the walk.
+func (s RunStats) FilesPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.FilesScanned) / s.Elapsed.Seconds()
+}
+
+// Megabytes (10^6 bytes) hashed per second of the walk.
+func (s RunStats) MBPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.BytesHashed) / 1e6 / s.Elapsed.Seconds()
+}
+
+// Returns statistics about the last walk.
+// Counters are updated while the walk is running,
+// Elapsed and IdleTime are only set once it returns.
+func (wm *Walkman) LastRunStats() RunStats {
+	_, hashWorkers := wm.hashLimits.load()
+
+	return RunStats{
+		FilesScanned: atomic.LoadInt64(&wm.stats.FilesScanned),
+		FilesReused:  atomic.LoadInt64(&wm.stats.FilesReused),
+		FilesUnique:  atomic.LoadInt64(&wm.stats.FilesUnique),
+		FilesSkipped: atomic.LoadInt64(&wm.stats.FilesSkipped),
+		BytesHashed:  atomic.LoadInt64(&wm.stats.BytesHashed),
+		DirsScanned:  atomic.LoadInt64(&wm.stats.DirsScanned),
+		DirsSkipped:  atomic.LoadInt64(&wm.stats.DirsSkipped),
+		LinksSkipped: atomic.LoadInt64(&wm.stats.LinksSkipped),
+		FilesKnown:   atomic.LoadInt64(&wm.stats.FilesKnown),
+		Errors:       atomic.LoadInt64(&wm.stats.Errors),
+		FilesLocked:  atomic.LoadInt64(&wm.stats.FilesLocked),
+		Retries:      atomic.LoadInt64(&wm.stats.Retries),
+		Elapsed:      loadDuration(&wm.stats.Elapsed),
+		WalkWorkers:  wm.walkWorkers,
+		HashWorkers:  hashWorkers,
+		AutoWorkers:  wm.config.autoWorkers,
+		DirTime:      loadDuration(&wm.stats.DirTime),
+		HashTime:     loadDuration(&wm.stats.HashTime),
+		IdleTime:     loadDuration(&wm.stats.IdleTime),
+		IndexTime:    loadDuration(&wm.stats.IndexTime),
+	}
+}
+
+func loadDuration(d *time.Duration) time.Duration {
+	return time.Duration(atomic.LoadInt64((*int64)(d)))
+}
+
+// Zeroes the counters in place, as LastRunStats may be reading them.
+func (s *RunStats) reset() {
+	for _, n := range []*int64{
+		&s.FilesScanned, &s.FilesReused, &s.FilesUnique, &s.FilesSkipped,
+		&s.FilesKnown, &s.BytesHashed, &s.DirsScanned, &s.DirsSkipped,
+		&s.LinksSkipped, &s.Errors, &s.FilesLocked, &s.Retries,
+		(*int64)(&s.Elapsed), (*int64)(&s.DirTime), (*int64)(&s.HashTime),
+		(*int64)(&s.IdleTime), (*int64)(&s.IndexTime),
+	} {
+		atomic.StoreInt64(n, 0)
+	}
+}
+
+// Returns how many workers of both pools are busy right now and how many
+// there are, e.g to export the utilization of a long running walk.
+func (wm *Walkman) Utilization() (busy, workers int) {
+	walkBusy, walkWorkers := wm.walkLimits.load()
+	hashBusy, hashWorkers := wm.hashLimits.load()
+	return walkBusy + hashBusy, walkWorkers + hashWorkers
+}
@@ -0,0 +1,58 @@
+package walkman
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// Stream is an alternate data stream of a file on NTFS,
+// see AlternateStreams.
+type Stream struct {
+	Name string // e.g Zone.Identifier
+	Size int64
+}
+
+// Returns the alternate data streams attached to the file at path, besides
+// its content, e.g the Zone.Identifier Windows adds to downloads or data
+// hidden there. A stream is read by opening path:name. Files have none
+// outside of NTFS.
+func AlternateStreams(path string) ([]Stream, error) {
+	return alternateStreams(path)
+}
+
+// Also hash the alternate data streams of files, for complete accounting
+// of the bytes on a volume or forensic work. Streams are reported as
+// path\to\file:name with File.Archive set to the file, and are never
+// touched by plans, like archive members. Empty streams are left out, and
+// so are streams of files whose hash was reused or of a unique size, see
+// WithPrevious and WithSizeGrouping.
+//
+// Only NTFS has alternate data streams, elsewhere this does nothing.
+func WithAlternateStreams() Option {
+	return func(w *Walkman) {
+		w.config.streams = true
+	}
+}
+
+// Hashes the alternate data streams of the file at path.
+func (wm *Walkman) hashStreams(path string, fi fs.FileInfo) {
+	if isRemote(path) {
+		return
+	}
+
+	streams, err := alternateStreams(path)
+	if err != nil {
+		wm.addError(fmt.Errorf("%s: %w", path, err))
+		return
+	}
+
+	for _, s := range streams {
+		if s.Size == 0 || wm.ctx.Err() != nil {
+			continue
+		}
+
+		name := path + ":" + s.Name
+		info := snapshotInfo{name: fi.Name() + ":" + s.Name, size: s.Size, mode: fi.Mode(), modTime: fi.ModTime()}
+		wm.hashChild(path, name, info, wm.opener(name))
+	}
+}
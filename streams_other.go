@@ -0,0 +1,7 @@
+//go:build !windows
+
+package walkman
+
+func alternateStreams(path string) ([]Stream, error) {
+	return nil, nil
+}
@@ -0,0 +1,57 @@
+package walkman
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	findFirstStream = syscall.NewLazyDLL("kernel32.dll").NewProc("FindFirstStreamW")
+	findNextStream  = syscall.NewLazyDLL("kernel32.dll").NewProc("FindNextStreamW")
+)
+
+// Win32 error codes ending the enumeration of streams.
+const (
+	errorHandleEOF        syscall.Errno = 38
+	errorInvalidParameter syscall.Errno = 87 // file systems without streams, e.g FAT
+)
+
+// WIN32_FIND_STREAM_DATA
+type findStreamData struct {
+	size int64
+	name [syscall.MAX_PATH + 36]uint16
+}
+
+func alternateStreams(path string) ([]Stream, error) {
+	name, err := syscall.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var data findStreamData
+	h, _, err := findFirstStream.Call(uintptr(unsafe.Pointer(name)), 0, uintptr(unsafe.Pointer(&data)), 0)
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		if err == errorHandleEOF || err == errorInvalidParameter {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer syscall.FindClose(syscall.Handle(h))
+
+	var streams []Stream
+	for {
+		// :name:$DATA, the content of the file is ::$DATA
+		stream := strings.TrimSuffix(strings.TrimPrefix(syscall.UTF16ToString(data.name[:]), ":"), ":$DATA")
+		if stream != "" {
+			streams = append(streams, Stream{Name: stream, Size: data.size})
+		}
+
+		if r, _, err := findNextStream.Call(h, uintptr(unsafe.Pointer(&data))); r == 0 {
+			if err == errorHandleEOF {
+				return streams, nil
+			}
+			return streams, err
+		}
+	}
+}
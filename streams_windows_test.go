@@ -0,0 +1,46 @@
+package walkman
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAlternateStreams(t *testing.T) {
+	dir := t.TempDir()
+	host := writeFile(t, dir, "host.txt", "host", time.Now())
+	other := writeFile(t, dir, "copy.txt", "hidden", time.Now())
+
+	if err := os.WriteFile(host.Path+":secret", []byte("hidden"), 0644); err != nil {
+		t.Skipf("no alternate data streams: %v", err)
+	}
+
+	streams, err := AlternateStreams(host.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(streams) != 1 || streams[0] != (Stream{Name: "secret", Size: 6}) {
+		t.Fatalf("expected the secret stream, got %v", streams)
+	}
+
+	hashes, err := New(WithContentHash(), WithAlternateStreams()).WalkContext(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, files := range hashes {
+		if len(files) != 2 {
+			continue
+		}
+
+		for _, f := range files {
+			if f.Path == host.Path+":secret" && f.Archive == host.Path {
+				return
+			}
+		}
+		t.Fatalf("expected the stream of %s along with %s, got %v", host.Path, other.Path, files)
+	}
+	t.Fatalf("expected the stream to duplicate %s, got %v", other.Path, hashes)
+}
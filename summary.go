@@ -0,0 +1,103 @@
+package walkman
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Bounds of Results.Histogram when none are given.
+var DefaultSizeBuckets = []int64{1 << 10, 1 << 20, 10 << 20, 100 << 20, 1 << 30}
+
+// SizeBucket sums the files of a size range, see Results.Histogram.
+type SizeBucket struct {
+	Min   int64 // smallest size in the bucket
+	Max   int64 // sizes are below it, math.MaxInt64 for the last bucket
+	Files int
+	Bytes int64
+}
+
+// ExtensionStats sums the files of an extension, see Results.ByExtension.
+type ExtensionStats struct {
+	Ext   string // lower case, without the dot, empty for files without one
+	Files int
+	Bytes int64
+}
+
+// Counts the files and their bytes by size, between the ascending bounds
+// of buckets, or DefaultSizeBuckets if there are none: the first bucket
+// holds the files smaller than the first bound and the last one those of
+// at least the last bound. Copies count as often as they are found.
+//
+// Members of archives, which are counted with the archive, directories
+// and links are left out, as by the other summaries.
+func (hashes Results) Histogram(buckets []int64) []SizeBucket {
+	if len(buckets) == 0 {
+		buckets = DefaultSizeBuckets
+	}
+
+	histogram := make([]SizeBucket, len(buckets)+1)
+	for i := range histogram {
+		if i > 0 {
+			histogram[i].Min = buckets[i-1]
+		}
+
+		histogram[i].Max = math.MaxInt64
+		if i < len(buckets) {
+			histogram[i].Max = buckets[i]
+		}
+	}
+
+	hashes.summarize(func(f File) {
+		size := f.Stats.Size()
+		i := sort.Search(len(buckets), func(i int) bool { return size < buckets[i] })
+		histogram[i].Files++
+		histogram[i].Bytes += size
+	})
+	return histogram
+}
+
+// Counts the files and their bytes by extension, largest first, see
+// Histogram.
+func (hashes Results) ByExtension() []ExtensionStats {
+	byExt := make(map[string]*ExtensionStats)
+
+	hashes.summarize(func(f File) {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(f.Path), "."))
+		if byExt[ext] == nil {
+			byExt[ext] = &ExtensionStats{Ext: ext}
+		}
+		byExt[ext].Files++
+		byExt[ext].Bytes += f.Stats.Size()
+	})
+
+	stats := make([]ExtensionStats, 0, len(byExt))
+	for _, s := range byExt {
+		stats = append(stats, *s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Bytes != stats[j].Bytes {
+			return stats[i].Bytes > stats[j].Bytes
+		}
+		return stats[i].Ext < stats[j].Ext
+	})
+	return stats
+}
+
+// Calls fn with every file the summaries count.
+func (hashes Results) summarize(fn func(f File)) {
+	for hash, files := range hashes {
+		alg := algorithmOf(hash)
+		if alg == AlgorithmLink || alg == AlgorithmDeadLink {
+			continue
+		}
+
+		for _, f := range files {
+			if f.Archive == "" && !f.Stats.IsDir() {
+				fn(f)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package walkman
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummaries(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.PDF", strings.Repeat("x", 10), time.Now())
+	writeFile(t, dir, "b.pdf", strings.Repeat("x", 100), time.Now())
+	writeFile(t, dir, "copy/b.pdf", strings.Repeat("x", 100), time.Now())
+	writeFile(t, dir, "c.jpg", strings.Repeat("x", 1000), time.Now())
+	writeFile(t, dir, "Makefile", "all:", time.Now())
+
+	hashes, err := New().Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []SizeBucket{
+		{Min: 0, Max: 10, Files: 1, Bytes: 4},
+		{Min: 10, Max: 1000, Files: 3, Bytes: 210},
+		{Min: 1000, Max: 1<<63 - 1, Files: 1, Bytes: 1000},
+	}
+	if got := hashes.Histogram([]int64{10, 1000}); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if got := hashes.Histogram(nil); len(got) != len(DefaultSizeBuckets)+1 || got[0].Files != 5 {
+		t.Errorf("expected the default buckets, got %+v", got)
+	}
+
+	wantExt := []ExtensionStats{
+		{Ext: "jpg", Files: 1, Bytes: 1000},
+		{Ext: "pdf", Files: 3, Bytes: 210},
+		{Ext: "", Files: 1, Bytes: 4},
+	}
+	if got := hashes.ByExtension(); !reflect.DeepEqual(got, wantExt) {
+		t.Errorf("expected %+v, got %+v", wantExt, got)
+	}
+}
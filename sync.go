@@ -0,0 +1,196 @@
+package walkman
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SyncAction is what a SyncPlan does to a path of the destination tree.
+type SyncAction int
+
+const (
+	SyncSkip   SyncAction = iota // the file is already there
+	SyncCopy                     // copy the file into place
+	SyncDelete                   // remove a file the source no longer has
+)
+
+func (a SyncAction) String() string {
+	switch a {
+	case SyncSkip:
+		return "skip"
+	case SyncCopy:
+		return "copy"
+	case SyncDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("SyncAction(%d)", int(a))
+}
+
+// SyncStep is a single operation of a SyncPlan.
+type SyncStep struct {
+	Action SyncAction
+	File   File   // file copied or skipped, or the file deleted
+	From   string // path File is copied from, empty for SyncDelete
+	To     string // path in the destination tree
+	Local  bool   // From lies in the destination, nothing is transferred
+}
+
+// SyncPlan makes a destination tree hold the same files as a source tree.
+// Nothing touches the file system until Execute is called.
+type SyncPlan []SyncStep
+
+// Builds the plan making the tree dstRoot, walked into dst, a copy of the
+// tree srcRoot, walked into src.
+//
+// Like rsync, but files are matched by hash: a file whose content is
+// already somewhere below dstRoot, e.g because it was renamed in the
+// source, is copied from there instead of from the source. Walk both
+// trees with WithContentHash so that hashes stand for content.
+//
+// Steps are sorted by destination path. Files grouped by size only, links,
+// archive members, remote files and those outside the roots are left out.
+func PlanSync(src, dst Results, srcRoot, dstRoot string) SyncPlan {
+	source := relFiles(src, srcRoot)
+	target := relFiles(dst, dstRoot)
+
+	// Where the content of each hash already is in the destination.
+	local := make(map[string]string)
+	for _, f := range target {
+		if _, ok := local[f.hash]; !ok {
+			local[f.hash] = f.file.Path
+		}
+	}
+
+	plan := SyncPlan{}
+	for rel, f := range source {
+		to := filepath.Join(dstRoot, rel)
+
+		if t, ok := target[rel]; ok && t.hash == f.hash {
+			plan = append(plan, SyncStep{Action: SyncSkip, File: f.file, From: f.file.Path, To: to})
+			continue
+		}
+
+		step := SyncStep{Action: SyncCopy, File: f.file, From: f.file.Path, To: to}
+		if path, ok := local[f.hash]; ok {
+			step.From, step.Local = path, true
+		}
+		plan = append(plan, step)
+	}
+
+	for rel, f := range target {
+		if _, ok := source[rel]; !ok {
+			plan = append(plan, SyncStep{Action: SyncDelete, File: f.file, To: f.file.Path})
+		}
+	}
+
+	sort.Slice(plan, func(i, j int) bool { return plan[i].To < plan[j].To })
+	return plan
+}
+
+// Returns the files of hashes by their path relative to root.
+func relFiles(hashes Results, root string) map[string]hashedFile {
+	files := make(map[string]hashedFile)
+	for hash, group := range hashes {
+		if listedOnly(algorithmOf(hash)) {
+			continue
+		}
+
+		for _, f := range onDisk(group) {
+			rel, err := filepath.Rel(root, f.Path)
+			if err != nil || !IsUnder(f.Path, root) {
+				continue
+			}
+			files[rel] = hashedFile{hash: hash, file: f}
+		}
+	}
+	return files
+}
+
+// Bytes read from the source tree once the plan is executed.
+func (p SyncPlan) Transfer() int64 {
+	var n int64
+	for _, s := range p {
+		if s.Action == SyncCopy && !s.Local {
+			n += s.File.Stats.Size()
+		}
+	}
+	return n
+}
+
+// Reports whether the destination already holds every file.
+func (p SyncPlan) Done() bool {
+	for _, s := range p {
+		if s.Action != SyncSkip {
+			return false
+		}
+	}
+	return true
+}
+
+func (s SyncStep) String() string {
+	switch s.Action {
+	case SyncDelete:
+		return fmt.Sprintf("delete %q", s.To)
+	default:
+		return fmt.Sprintf("%s %q -> %q", s.Action, s.From, s.To)
+	}
+}
+
+// Executes the plan, stopping at the first error.
+//
+// Every file is first copied next to its destination, then all of them are
+// renamed into place and only then are files deleted. Files swapped in the
+// source, or copied from a path that is later overwritten, thus always come
+// from the destination as it was when the plan was made.
+// Returns the number of copy and delete steps carried out.
+func (p SyncPlan) Execute() (int, error) {
+	var staged []SyncStep
+	defer func() {
+		for _, s := range staged {
+			os.Remove(s.To + ".walkman-tmp")
+		}
+	}()
+
+	for _, s := range p {
+		if s.Action != SyncCopy {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(s.To), 0755); err != nil {
+			return 0, err
+		}
+
+		tmp := s.To + ".walkman-tmp"
+		os.Remove(tmp) // left over by an interrupted run
+
+		if err := copyFile(s.From, tmp); err != nil {
+			return 0, fmt.Errorf("%s: %w", s, err)
+		}
+		staged = append(staged, s)
+	}
+
+	done := 0
+	for len(staged) > 0 {
+		s := staged[0]
+		if err := os.Rename(s.To+".walkman-tmp", s.To); err != nil {
+			return done, fmt.Errorf("%s: %w", s, err)
+		}
+		staged = staged[1:]
+		done++
+	}
+
+	for _, s := range p {
+		if s.Action != SyncDelete {
+			continue
+		}
+
+		if err := os.Remove(s.To); err != nil {
+			return done, fmt.Errorf("%s: %w", s, err)
+		}
+		done++
+	}
+
+	return done, nil
+}
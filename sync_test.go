@@ -0,0 +1,83 @@
+package walkman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanSync(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+
+	writeFile(t, src, "same.txt", "same", mtime)
+	writeFile(t, dst, "same.txt", "same", mtime)
+	writeFile(t, src, "new.txt", "new", mtime)
+	writeFile(t, src, "renamed/photo.jpg", "photo", mtime)
+	writeFile(t, dst, "photo.jpg", "photo", mtime)
+	writeFile(t, src, "changed.txt", "v2", mtime)
+	writeFile(t, dst, "changed.txt", "v1", mtime)
+
+	// swapped in the source
+	writeFile(t, src, "a.txt", "aaa", mtime)
+	writeFile(t, src, "b.txt", "bbb", mtime)
+	writeFile(t, dst, "a.txt", "bbb", mtime)
+	writeFile(t, dst, "b.txt", "aaa", mtime)
+
+	walk := func(root string) Results {
+		hashes, err := New(WithContentHash()).Walk(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hashes
+	}
+
+	plan := PlanSync(walk(src), walk(dst), src, dst)
+
+	want := map[string]SyncAction{
+		"a.txt":             SyncCopy,
+		"b.txt":             SyncCopy,
+		"changed.txt":       SyncCopy,
+		"new.txt":           SyncCopy,
+		"photo.jpg":         SyncDelete,
+		"renamed/photo.jpg": SyncCopy,
+		"same.txt":          SyncSkip,
+	}
+
+	if len(plan) != len(want) {
+		t.Fatalf("expected %d steps, got %v", len(want), plan)
+	}
+
+	for _, s := range plan {
+		rel, _ := filepath.Rel(dst, s.To)
+		if s.Action != want[rel] {
+			t.Errorf("expected %s %s, got %s", want[rel], rel, s)
+		}
+
+		local := rel == "a.txt" || rel == "b.txt" || rel == "renamed/photo.jpg"
+		if s.Action == SyncCopy && s.Local != local {
+			t.Errorf("expected %s local=%t, got %s", rel, local, s)
+		}
+	}
+
+	// changed.txt and new.txt
+	if n := plan.Transfer(); n != 5 {
+		t.Errorf("expected 5 bytes transferred, got %d", n)
+	}
+
+	if _, err := plan.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if again := PlanSync(walk(src), walk(dst), src, dst); !again.Done() {
+		t.Errorf("expected nothing left to do, got %v", again)
+	}
+
+	for name, content := range map[string]string{"a.txt": "aaa", "b.txt": "bbb", "renamed/photo.jpg": "photo"} {
+		data, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil || string(data) != content {
+			t.Errorf("expected %s to hold %q, got %q, %v", name, content, data, err)
+		}
+	}
+}
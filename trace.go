@@ -0,0 +1,65 @@
+package walkman
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Tracer starts the spans of walks, see WithTracer. It is small enough to
+// be adapted to any tracing library, e.g OpenTelemetry:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, walkman.Span) {
+//		kvs := make([]attribute.KeyValue, len(attrs))
+//		for i, a := range attrs {
+//			kvs[i] = attribute.String(a.Key, a.Value.String())
+//		}
+//		ctx, span := t.Tracer.Start(ctx, name, trace.WithAttributes(kvs...))
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) End(err error) {
+//		if err != nil {
+//			s.RecordError(err)
+//			s.SetStatus(codes.Error, err.Error())
+//		}
+//		s.Span.End()
+//	}
+type Tracer interface {
+	// Start starts a span named name as a child of the span in ctx,
+	// if any, and returns a context holding the new one.
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span is a unit of work started by a Tracer.
+type Span interface {
+	End(err error) // ends the span, which failed if err is not nil
+}
+
+// Trace walks with tracer, in spans named:
+//
+//   - walkman.Walk around a whole walk, a child of the span in the
+//     context given to WalkContext
+//   - walkman.Dir around the reading of each directory
+//   - walkman.Hash around the hashing of each file
+//
+// Nothing is traced by default.
+func WithTracer(tracer Tracer) Option {
+	return func(w *Walkman) {
+		w.tracer = tracer
+	}
+}
+
+// Starts a span with the tracer of wm, if any, returning the context
+// holding it and a function ending it.
+func (wm *Walkman) startSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, func(error)) {
+	if wm.tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	ctx, span := wm.tracer.Start(ctx, name, attrs...)
+	return ctx, span.End
+}
@@ -0,0 +1,91 @@
+package walkman
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSpan struct {
+	name, parent string
+	attrs        map[string]string
+	err          error
+	ended        bool
+}
+
+// Records the spans it starts.
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+type spanKey struct{}
+
+func (t *testTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	s := &testSpan{name: name, attrs: map[string]string{}}
+	if parent, ok := ctx.Value(spanKey{}).(*testSpan); ok {
+		s.parent = parent.name
+	}
+
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value.String()
+	}
+
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *testSpan) End(err error) {
+	s.err, s.ended = err, true
+}
+
+func TestWithTracer(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "docs/a.txt", "a", time.Now())
+	bad := writeFile(t, dir, "bad.txt", "b", time.Now())
+
+	errBroken := errors.New("broken")
+	hasher := func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		if path == bad.Path {
+			return "", errBroken
+		}
+		return NameSizeHasher(path, info, open)
+	}
+
+	tracer := &testTracer{}
+	ctx, parent := tracer.Start(context.Background(), "request")
+
+	if _, err := New(WithHasher(hasher), WithTracer(tracer)).WalkContext(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+	parent.End(nil)
+
+	count := map[string]int{}
+	for _, s := range tracer.spans {
+		count[s.name]++
+
+		if !s.ended {
+			t.Errorf("span %s %v never ended", s.name, s.attrs)
+		}
+
+		want := map[string]string{"request": "", "walkman.Walk": "request", "walkman.Dir": "walkman.Walk", "walkman.Hash": "walkman.Walk"}
+		if s.parent != want[s.name] {
+			t.Errorf("span %s is a child of %q, want %q", s.name, s.parent, want[s.name])
+		}
+
+		if s.name == "walkman.Hash" && (s.attrs["path"] == bad.Path) != errors.Is(s.err, errBroken) {
+			t.Errorf("span %s of %s ended with %v", s.name, s.attrs["path"], s.err)
+		}
+	}
+
+	if count["walkman.Walk"] != 1 || count["walkman.Dir"] != 2 || count["walkman.Hash"] != 2 {
+		t.Errorf("expected a walk, 2 directories and 2 files, got %v", count)
+	}
+}
@@ -0,0 +1,158 @@
+package walkman
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How often the throughput is measured by WithAutoWorkers.
+const defaultTuneInterval = time.Second
+
+// A throughput gain below this share does not justify more workers.
+const tuneGain = 0.1
+
+// Let the walk pick how many files are hashed at once. It starts with
+// two hash workers, as spinning disks want few readers, then doubles
+// them as long as doing so raises the throughput by a tenth, up to the
+// hash workers set by WithHashWorkers (or the default). Once a doubling
+// gains nothing the previous count is kept for the rest of the walk.
+//
+// RunStats.HashWorkers reports the count picked, which can be pinned
+// with WithHashWorkers for later walks of the same storage.
+func WithAutoWorkers() Option {
+	return func(w *Walkman) {
+		w.config.autoWorkers = true
+	}
+}
+
+// Adjusts the hash pool to the throughput of the storage until done is
+// closed, see WithAutoWorkers.
+func (wm *Walkman) autoTune(done <-chan struct{}) {
+	ticker := time.NewTicker(wm.tuneInterval)
+	defer ticker.Stop()
+
+	max := wm.hashWorkers
+	level := 2
+	if level > max {
+		level = max
+	}
+	wm.hashLimits.setLimit(level)
+
+	best, last, lastTick := 0.0, int64(0), time.Now()
+	previous := level // count before the last change
+	warm := false     // a window went by since the last change
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		// per second, as ticks come late on a busy machine
+		bytes, now := atomic.LoadInt64(&wm.stats.BytesHashed), time.Now()
+		rate := float64(bytes-last) / now.Sub(lastTick).Seconds()
+		last, lastTick = bytes, now
+
+		// Workers started under the previous count are still busy,
+		// or nothing is being hashed yet.
+		if !warm || rate == 0 {
+			warm = rate > 0
+			continue
+		}
+
+		if rate < best*(1+tuneGain) {
+			level = previous
+			wm.hashLimits.setLimit(level)
+			wm.logger.Info("picked hash workers", "workers", level)
+			return
+		}
+
+		if level == max {
+			wm.logger.Info("picked hash workers", "workers", level)
+			return
+		}
+
+		best, previous = rate, level
+		level *= 2
+		if level > max {
+			level = max
+		}
+		wm.hashLimits.setLimit(level)
+		warm = false
+	}
+}
+
+// Counting semaphore whose size may change while in use, see
+// WithAutoWorkers. It also sums the time its slots were available
+// for, to tell how long workers were idle.
+type limiter struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	busy  int
+	limit int
+
+	since time.Time     // of the last change of limit
+	spent time.Duration // slots times their duration before since
+}
+
+func newLimiter(n int) *limiter {
+	l := &limiter{limit: n, since: time.Now()}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// Waits for a free slot and takes it.
+func (l *limiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.busy >= l.limit {
+		l.cond.Wait()
+	}
+	l.busy++
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.busy--
+	l.cond.Signal()
+}
+
+// Changes the number of slots. Taken ones are not given back early
+// when there are fewer.
+func (l *limiter) setLimit(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.spent += time.Duration(l.limit) * now.Sub(l.since)
+	l.limit, l.since = n, now
+	l.cond.Broadcast()
+}
+
+// Starts summing the time of the slots anew.
+func (l *limiter) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.spent, l.since = 0, time.Now()
+}
+
+// Returns the slots times their duration since reset.
+func (l *limiter) capacity() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.spent + time.Duration(l.limit)*time.Since(l.since)
+}
+
+// Returns the slots taken and their number.
+func (l *limiter) load() (busy, limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.busy, l.limit
+}
@@ -0,0 +1,119 @@
+package walkman
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithAutoWorkers(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 800; i++ {
+		writeFile(t, dir, fmt.Sprintf("%03d.txt", i), strings.Repeat("x", 100), time.Now())
+	}
+
+	// Reads take a while but do not get in the way of one another, like
+	// on fast SSDs, or have to wait for each other, like on a spinning
+	// disk.
+	parallel := func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		time.Sleep(10 * time.Millisecond)
+		return NameSizeHasher(path, info, open)
+	}
+
+	var disk sync.Mutex
+	serial := func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		disk.Lock()
+		defer disk.Unlock()
+
+		time.Sleep(time.Millisecond)
+		return NameSizeHasher(path, info, open)
+	}
+
+	pick := func(hasher Hasher) RunStats {
+		wm := New(WithHasher(hasher), WithHashWorkers(8), WithAutoWorkers())
+		wm.tuneInterval = 100 * time.Millisecond
+
+		if _, err := wm.Walk(dir); err != nil {
+			t.Fatal(err)
+		}
+		return wm.LastRunStats()
+	}
+
+	if stats := pick(parallel); stats.HashWorkers != 8 || !stats.AutoWorkers {
+		t.Errorf("expected all 8 hash workers picked for parallel reads, got %d", stats.HashWorkers)
+	}
+
+	if stats := pick(serial); stats.HashWorkers >= 8 {
+		t.Errorf("expected fewer hash workers picked for serial reads, got %d", stats.HashWorkers)
+	}
+
+	if stats := New(WithHashWorkers(8)).LastRunStats(); stats.HashWorkers != 8 || stats.AutoWorkers {
+		t.Errorf("expected 8 pinned hash workers, got %d", stats.HashWorkers)
+	}
+}
+
+func TestWithAutoWorkersCapped(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 300; i++ {
+		writeFile(t, dir, fmt.Sprintf("%03d.txt", i), strings.Repeat("x", 100), time.Now())
+	}
+
+	// storage serving 4 reads at once and thrashing beyond, so 6 workers
+	// do worse than 4
+	var reads int32
+	hasher := func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		defer atomic.AddInt32(&reads, -1)
+
+		if atomic.AddInt32(&reads, 1) > 4 {
+			time.Sleep(60 * time.Millisecond)
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return NameSizeHasher(path, info, open)
+	}
+
+	// 2, 4, then 6 capped by the maximum, which loses over 4
+	wm := New(WithHasher(hasher), WithHashWorkers(6), WithAutoWorkers())
+	wm.tuneInterval = 200 * time.Millisecond
+
+	if _, err := wm.Walk(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := wm.LastRunStats(); stats.HashWorkers != 4 {
+		t.Errorf("expected 4 hash workers kept, got %d", stats.HashWorkers)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(1)
+	l.acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		l.acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired a slot beyond the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	l.setLimit(2)
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a raised limit did not free a slot")
+	}
+
+	if busy, limit := l.load(); busy != 2 || limit != 2 {
+		t.Errorf("expected 2 of 2 slots taken, got %d of %d", busy, limit)
+	}
+}
@@ -0,0 +1,108 @@
+package walkman
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Returned (wrapped) by JournalEntry.Undo when a file changed since the
+// step was carried out, so that putting it back could lose data.
+var ErrChangedSinceJournal = errors.New("walkman: file changed since the journal was written")
+
+// Reads the entries of a journal written by Plan.Execute, in the order
+// the steps were carried out.
+func ReadJournal(r io.Reader) ([]JournalEntry, error) {
+	var entries []JournalEntry
+
+	dec := json.NewDecoder(r)
+	for {
+		var e JournalEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return entries, fmt.Errorf("walkman: reading journal: %w", err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Undoes the step recorded by e. Moved files are moved back from the
+// quarantine; links, and deleted files too, are replaced by a copy of the
+// keeper as they had the same content, modification time included.
+//
+// Nothing is overwritten: undoing fails with fs.ErrExist if a deleted or
+// moved file is back, and with ErrChangedSinceJournal if a link no longer
+// points to the keeper or the keeper was modified since. Entries of a
+// journal should be undone last first.
+func (e JournalEntry) Undo() error {
+	if e.Action == ActionMove.String() {
+		if e.Dest == "" {
+			return errors.New("no destination for move")
+		}
+
+		if err := absent(e.Path); err != nil {
+			return err
+		}
+		return moveFile(e.Dest, e.Path)
+	}
+
+	keep, err := os.Stat(e.Keep)
+	if err != nil {
+		return err
+	}
+
+	if keep.ModTime().After(e.Time) {
+		return fmt.Errorf("%s: %w", e.Keep, ErrChangedSinceJournal)
+	}
+
+	switch e.Action {
+	case ActionDelete.String():
+		if err := absent(e.Path); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(e.Path), 0755); err != nil {
+			return err
+		}
+		return copyFile(e.Keep, e.Path)
+	case ActionHardlink.String():
+		fi, err := os.Lstat(e.Path)
+		if err != nil {
+			return err
+		}
+
+		if !os.SameFile(fi, keep) {
+			return fmt.Errorf("%s: %w", e.Path, ErrChangedSinceJournal)
+		}
+	case ActionSymlink.String():
+		target, err := os.Readlink(e.Path)
+		if err != nil {
+			return err
+		}
+
+		if abs, err := filepath.Abs(e.Keep); err != nil || target != abs {
+			return fmt.Errorf("%s: %w", e.Path, ErrChangedSinceJournal)
+		}
+	default:
+		return fmt.Errorf("unknown action %q", e.Action)
+	}
+
+	return replaceWith(e.Path, func(tmp string) error {
+		return copyFile(e.Keep, tmp)
+	})
+}
+
+// Fails with fs.ErrExist if something is at path.
+func absent(path string) error {
+	if _, err := os.Lstat(path); err == nil {
+		return &fs.PathError{Op: "restore", Path: path, Err: fs.ErrExist}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
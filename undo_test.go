@@ -0,0 +1,88 @@
+package walkman
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUndo(t *testing.T) {
+	for _, action := range []Action{ActionDelete, ActionHardlink, ActionSymlink, ActionMove} {
+		t.Run(action.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			then := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+			keep := writeFile(t, dir, "keep.txt", "same", then)
+			dup := writeFile(t, dir, "sub/dup.txt", "same", then)
+
+			plan := NewPlan(Results{"md5:x": {keep, dup}}, KeepOldest(), action, filepath.Join(dir, "quarantine"))
+
+			var journal bytes.Buffer
+			if _, err := plan.Execute(&journal); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := ReadJournal(&journal)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("expected 1 journal entry, got %v, %v", entries, err)
+			}
+
+			if err := entries[0].Undo(); err != nil {
+				t.Fatal(err)
+			}
+
+			fi, err := os.Lstat(dup.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keepInfo, _ := os.Stat(keep.Path)
+			if !fi.Mode().IsRegular() || os.SameFile(fi, keepInfo) {
+				t.Errorf("expected %s restored as a file of its own, got %v", dup.Path, fi.Mode())
+			}
+
+			if data, _ := os.ReadFile(dup.Path); string(data) != "same" || !fi.ModTime().Equal(then) {
+				t.Errorf("expected the content and time of the original, got %q at %v", data, fi.ModTime())
+			}
+
+			if err := entries[0].Undo(); err == nil {
+				t.Error("expected undoing twice to fail")
+			}
+		})
+	}
+}
+
+func TestUndoConflicts(t *testing.T) {
+	dir := t.TempDir()
+	then := time.Now().Add(-time.Hour)
+
+	keep := writeFile(t, dir, "keep.txt", "same", then)
+	moved := writeFile(t, dir, "moved.txt", "same", then)
+	linked := writeFile(t, dir, "linked.txt", "same", then)
+
+	var journal bytes.Buffer
+	plan := Plan{
+		{Action: ActionMove, Keep: keep, Target: moved, Dest: filepath.Join(dir, "quarantine", "moved.txt")},
+		{Action: ActionHardlink, Keep: keep, Target: linked},
+	}
+	if _, err := plan.Execute(&journal); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := ReadJournal(&journal)
+
+	// a new file took the place of the moved one
+	writeFile(t, dir, "moved.txt", "new", time.Now())
+	if err := entries[0].Undo(); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected fs.ErrExist, got %v", err)
+	}
+
+	// the keeper, and so the link, was written to since
+	writeFile(t, dir, "keep.txt", "changed", time.Now().Add(time.Hour))
+	if err := entries[1].Undo(); !errors.Is(err, ErrChangedSinceJournal) {
+		t.Errorf("expected ErrChangedSinceJournal, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package walkman
+
+// Only keep groups of duplicates, dropping files that turn out to have
+// no copy as soon as every file of their size was hashed, which takes far
+// less memory on trees of mostly distinct files. Implies WithSizeGrouping,
+// so files of a unique size are neither hashed nor kept.
+//
+// Empty files and links listed by WithEmpty and SymlinkRecord are kept.
+// Walks with it do not prune removed files from the index of WithIndex nor
+// record a summary in it, as they do not see every file, and it can not be
+// combined with WithResultBackend.
+func WithDuplicatesOnly() Option {
+	return func(w *Walkman) {
+		w.config.sizeFirst = true
+		w.config.duplicatesOnly = true
+	}
+}
+
+// Counts the files of size still to be collected, see WithDuplicatesOnly.
+func (wm *Walkman) expectSize(size int64, n int) {
+	wm.sizesMu.Lock()
+	defer wm.sizesMu.Unlock()
+
+	if wm.pending == nil {
+		wm.pending = make(map[int64]int)
+	}
+	wm.pending[size] = n
+}
+
+// Called by the collector once p was added to hashes. When it was the last
+// file of its size, the groups of that size holding a single file are
+// dropped; bySize keeps the hashes of each size seen so far.
+func (wm *Walkman) collected(hashes Results, bySize map[int64][]string, p pair) {
+	if !wm.config.duplicatesOnly || p.archive != "" || listedOnly(algorithmOf(p.hash)) {
+		return
+	}
+
+	size := p.info.Size()
+	if len(hashes[p.hash]) == 1 {
+		bySize[size] = append(bySize[size], p.hash)
+	}
+
+	wm.sizesMu.Lock()
+	left, ok := wm.pending[size]
+	if ok {
+		left--
+		wm.pending[size] = left
+	}
+	wm.sizesMu.Unlock()
+
+	if !ok || left > 0 {
+		return
+	}
+
+	for _, hash := range bySize[size] {
+		if len(hashes[hash]) == 1 {
+			delete(hashes, hash)
+		}
+	}
+	delete(bySize, size)
+}
+
+// Drops the groups holding a single file, but for empty files and links.
+// Catches those of files that could not be read, or were hashed although
+// their size is unique, e.g to look inside archives.
+func (hashes Results) dropSingletons() {
+	for hash, files := range hashes {
+		if len(files) < 2 && !listedOnly(algorithmOf(hash)) {
+			delete(hashes, hash)
+		}
+	}
+}
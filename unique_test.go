@@ -0,0 +1,60 @@
+package walkman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuplicatesOnly(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "same", time.Now())
+	writeFile(t, dir, "b/a.txt", "same", time.Now())
+	writeFile(t, dir, "c.txt", "diff", time.Now())
+	writeFile(t, dir, "d.txt", "unique size", time.Now())
+
+	wm := New(WithContentHash(), WithDuplicatesOnly())
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashes) != 1 {
+		t.Fatalf("expected only the group of a.txt, got %v", hashes)
+	}
+
+	for _, files := range hashes {
+		if len(files) != 2 {
+			t.Errorf("expected both copies of a.txt, got %v", files)
+		}
+	}
+
+	if stats := wm.LastRunStats(); stats.FilesUnique != 1 || stats.FilesScanned != 3 {
+		t.Errorf("expected 1 file of unique size and 3 hashed, got %+v", stats)
+	}
+}
+
+func TestDuplicatesOnlyDropsWhenSizeIsDone(t *testing.T) {
+	wm := New(WithDuplicatesOnly())
+	wm.expectSize(4, 3)
+
+	hashes := make(Results)
+	bySize := make(map[int64][]string)
+	info := snapshotInfo{size: 4}
+
+	add := func(hash, path string) {
+		p := pair{hash: hash, path: path, info: info}
+		hashes[hash] = append(hashes[hash], File{Path: path, Stats: info})
+		wm.collected(hashes, bySize, p)
+	}
+
+	add("md5:a", "/a")
+	add("md5:b", "/b")
+	if len(hashes) != 2 {
+		t.Fatalf("expected groups kept while files of their size are missing, got %v", hashes)
+	}
+
+	add("md5:a", "/a2")
+	if _, ok := hashes["md5:b"]; ok || len(hashes["md5:a"]) != 2 {
+		t.Errorf("expected md5:b dropped once every file of its size was in, got %v", hashes)
+	}
+}
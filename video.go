@@ -0,0 +1,205 @@
+package walkman
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FrameDecoder decodes frames of videos for VideoHasher,
+// e.g by running ffmpeg, see the ffmpeg package.
+type FrameDecoder interface {
+	// Frames returns n frames spread evenly over the video at path,
+	// read through open. Files that are not videos make it return
+	// ErrNotVideo.
+	Frames(path string, open func() (io.ReadCloser, error), n int) ([]image.Image, error)
+}
+
+// Returned by a FrameDecoder for files that are not videos.
+var ErrNotVideo = errors.New("walkman: not a video")
+
+// Hasher of videos by the perceptual hashes of frames sampled by dec,
+// e.g video:8f0e4c2a1b3d5e6f.0a1b2c3d4e5f6071... Files that are not
+// videos are hashed by content like WithContentHash.
+//
+// Re-encoded copies of a video get close rather than equal hashes,
+// group them with Results.SimilarVideos.
+//
+//	wm := walkman.New(walkman.WithHasher(walkman.VideoHasher(ffmpeg.Decoder{}, 10)))
+func VideoHasher(dec FrameDecoder, frames int) Hasher {
+	return func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		images, err := dec.Frames(path, open, frames)
+		if errors.Is(err, ErrNotVideo) {
+			return MD5ContentHasher(path, info, open)
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+
+		if len(images) == 0 {
+			return "", fmt.Errorf("%s: no frames decoded", path)
+		}
+
+		hashes := make([]string, len(images))
+		for i, img := range images {
+			hashes[i] = fmt.Sprintf("%016x", dHash(img))
+		}
+		return AlgorithmVideo + ":" + strings.Join(hashes, "."), nil
+	}
+}
+
+// Returns the difference hash of img: it is shrunk to 9x8 gray pixels
+// and each bit tells whether a pixel is brighter than its right neighbour.
+// Scaling, compression and small color shifts leave most bits alone.
+func dHash(img image.Image) uint64 {
+	var gray [8][9]uint64
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 9; x++ {
+			// average of the block of pixels shrunk into (x, y)
+			x0, x1 := bounds.Min.X+x*w/9, bounds.Min.X+(x+1)*w/9
+			y0, y1 := bounds.Min.Y+y*h/8, bounds.Min.Y+(y+1)*h/8
+			if x1 == x0 {
+				x1++
+			}
+			if y1 == y0 {
+				y1++
+			}
+
+			var sum, n uint64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					r, g, b, _ := img.At(px, py).RGBA()
+					sum += (299*uint64(r) + 587*uint64(g) + 114*uint64(b)) / 1000
+					n++
+				}
+			}
+			gray[y][x] = sum / n
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Merges the groups of videos hashed by VideoHasher whose frames look
+// alike, so that re-encoded, resized or trimmed copies end up together.
+//
+// Two videos look alike when at least half of the frames of one differ
+// by at most maxDistance bits (of 64) from a frame of the other; 10 is
+// a good start. Merged groups keep the smallest of their hashes, other
+// groups are left alone.
+func (hashes Results) SimilarVideos(maxDistance int) Results {
+	keys := []string{}
+	frames := map[string][]uint64{}
+	similar := make(Results)
+
+	for hash, files := range hashes {
+		if f, ok := parseVideoHash(hash); ok {
+			keys = append(keys, hash)
+			frames[hash] = f
+		} else {
+			similar[hash] = files
+		}
+	}
+
+	// union find over the videos, the root being the smallest hash
+	sort.Strings(keys)
+	root := make([]int, len(keys))
+	for i := range root {
+		root[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if root[i] != i {
+			root[i] = find(root[i])
+		}
+		return root[i]
+	}
+
+	for i := range keys {
+		for j := i + 1; j < len(keys); j++ {
+			if framesAlike(frames[keys[i]], frames[keys[j]], maxDistance) {
+				a, b := find(i), find(j)
+				if b < a {
+					a, b = b, a
+				}
+				root[b] = a
+			}
+		}
+	}
+
+	for i, hash := range keys {
+		key := keys[find(i)]
+		similar[key] = append(similar[key], hashes[hash]...)
+	}
+	return similar
+}
+
+// Returns the frame hashes of a hash made by VideoHasher.
+func parseVideoHash(hash string) ([]uint64, bool) {
+	if algorithmOf(hash) != AlgorithmVideo {
+		return nil, false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(hash, AlgorithmVideo+":"), ".")
+	frames := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 16, 64)
+		if err != nil {
+			return nil, false
+		}
+		frames[i] = n
+	}
+	return frames, true
+}
+
+// Reports whether at least half of the frames of the shorter of a and b
+// are within maxDistance bits of a frame of the other. Flat frames, e.g
+// black ones between scenes, hash to zero and tell nothing.
+func framesAlike(a, b []uint64, maxDistance int) bool {
+	a, b = withoutFlat(a), withoutFlat(b)
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	matched := 0
+	for _, x := range a {
+		for _, y := range b {
+			if bits.OnesCount64(x^y) <= maxDistance {
+				matched++
+				break
+			}
+		}
+	}
+	return len(a) > 0 && 2*matched >= len(a)
+}
+
+func withoutFlat(frames []uint64) []uint64 {
+	kept := make([]uint64, 0, len(frames))
+	for _, f := range frames {
+		if f != 0 {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,108 @@
+package walkman
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Decodes fake videos, text files reading "video SEED START LENGTH NOISE":
+// a video cut from START to START+LENGTH seconds of a clip changing scene
+// every second, with NOISE added to each pixel as re-encoding would.
+type fakeDecoder struct{}
+
+func (fakeDecoder) Frames(path string, open func() (io.ReadCloser, error), n int) ([]image.Image, error) {
+	file, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var seed, start, length, noise int
+	if _, err := fmt.Fscanf(file, "video %d %d %d %d", &seed, &start, &length, &noise); err != nil {
+		return nil, ErrNotVideo
+	}
+
+	frames := []image.Image{}
+	for i := 0; i < n; i++ {
+		at := float64(start) + float64(length)*(float64(i)+0.5)/float64(n)
+		frames = append(frames, testScene(int64(seed*1000+int(at)), noise))
+	}
+	return frames, nil
+}
+
+// Returns a frame of 9x8 blocks of random brightness.
+func testScene(scene int64, noise int) image.Image {
+	blocks := rand.New(rand.NewSource(scene))
+	pixels := rand.New(rand.NewSource(scene + 1))
+
+	img := image.NewGray(image.Rect(0, 0, 90, 80))
+	for by := 0; by < 8; by++ {
+		for bx := 0; bx < 9; bx++ {
+			gray := 20 + blocks.Intn(216)
+			for y := by * 10; y < by*10+10; y++ {
+				for x := bx * 10; x < bx*10+10; x++ {
+					v := gray
+					if noise > 0 {
+						v += pixels.Intn(2*noise+1) - noise
+					}
+					img.SetGray(x, y, color.Gray{Y: uint8(v)})
+				}
+			}
+		}
+	}
+	return img
+}
+
+func TestVideoHasher(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writeFile(t, dir, "movie.mkv", "video 1 0 20 0", now)
+	writeFile(t, dir, "movie-720p.mp4", "video 1 0 20 8", now)
+	writeFile(t, dir, "movie-trailer.mp4", "video 1 4 12 4", now)
+	writeFile(t, dir, "other.mkv", "video 2 0 20 0", now)
+	writeFile(t, dir, "a.txt", "not a video", now)
+	writeFile(t, dir, "b.txt", "not a video", now)
+
+	hashes, err := New(WithHasher(VideoHasher(fakeDecoder{}, 10))).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashes) != 5 {
+		t.Fatalf("expected the 4 videos and the text files apart, got %v", hashes)
+	}
+
+	groups := [][]string{}
+	for hash, files := range hashes.SimilarVideos(10) {
+		names := []string{}
+		for _, f := range files {
+			names = append(names, filepath.Base(f.Path))
+		}
+		sort.Strings(names)
+		groups = append(groups, names)
+
+		if len(files) == 2 && algorithmOf(hash) != AlgorithmMD5 {
+			t.Errorf("expected the text files to be hashed by content, got %s", hash)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+
+	want := [][]string{
+		{"a.txt", "b.txt"},
+		{"movie-720p.mp4", "movie-trailer.mp4", "movie.mkv"},
+		{"other.mkv"},
+	}
+
+	if fmt.Sprint(groups) != fmt.Sprint(want) {
+		t.Errorf("SimilarVideos grouped %v, want %v", groups, want)
+	}
+}
@@ -8,16 +8,20 @@
 package walkman
 
 import (
+	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"runtime"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Very big directories you may not control
@@ -39,68 +43,175 @@ var dirs_to_skip = []string{
 	"qt5",
 	"qt6",
 	"wasm32-unknown-unknown",
+
+	// kept by macOS on every volume
+	".Spotlight-V100",
+	".Trashes",
+	".fseventsd",
+	".DocumentRevisions-V100",
+	".TemporaryItems",
 }
 
-// harsher is a function that takes in a path to a file
-// uses some algorithm to generate a unique hash that can be used
+// Hasher uses some algorithm to generate a unique hash that can be used
 // to identify duplicate files.
 //
 // You can use the a concatenation of file's basename & size for speed
-// to match files with same name and size.
+// to match files with same name and size, or hash the content read
+// through open. Hashers that only look at path and info never call open,
+// which also works for files that are not on the local disk, e.g
+// members of an archive.
 //
-// For content hash, walkman.Md5ContentHasher
-type harsher func(path string) pair
+// Hashes should be prefixed with the name of their algorithm,
+// e.g md5:d41d8cd98f00b204e9800998ecf8427e. See WithContentHash.
+type Hasher func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error)
 
 type config struct {
-	verbose       bool
 	skip          []string
 	noDefaultSkip bool // Instructs walkman to not ignore any directories like .git, .venv,.env,AndroidStudioProjects, etc
+	archives      bool // descend into archives, see WithArchives
+	layers        bool // descend into image layers, see WithImageLayers
+	mime          bool // sniff content types, see WithMIME
+	sizeFirst     bool // only hash files of shared sizes, see WithSizeGrouping
+	autoWorkers   bool // tune the hash pool while walking, see WithAutoWorkers
+	hidden        bool // walk hidden directories, see WithIncludeHidden
+	xattrHash     bool // hash extended attributes too, see WithXattrHash
+	foldNames     bool // match names regardless of case, see WithCaseInsensitiveNames
+	empty         bool // list empty files and directories, see WithEmpty
+	sorted        bool // sort the files of groups, see WithDeterministicOrder
+
+	duplicatesOnly  bool // drop files without copies, see WithDuplicatesOnly
+	backupSemantics bool // open locked files with backup semantics, see WithBackupSemantics
+	streams         bool // hash alternate data streams, see WithAlternateStreams
+
+	skipRegex  []*regexp.Regexp // paths to skip, see WithSkipRegex
+	skipMarker string           // name of files marking folders to skip, see WithSkipMarker
 }
 
-type option func(*Walkman)
+// Option configures a Walkman, see New.
+type Option func(*Walkman)
 
 // Syncronises the filepath.WalkDir so that each subdir
 // is traversed in parraller by workers.
 //
-// limits acts as a counting semaphore.
+// walkLimits and hashLimits act as counting semaphores, one
+// for the workers reading directories and one for those hashing
+// files so that neither can starve the other.
 // All pairs are passed onto the results channel when all
 // workers are done.
 type Walkman struct {
-	workers int             // number of workers, default 4*runtime.GOMAXPROCS(0)
-	limits  chan bool       // counting semaphore channel
-	pairs   chan pair       // channel of pairs(hash to filepath)
-	result  chan results    // Channel of Results map
-	wg      *sync.WaitGroup // pointer because when wg is copied, it won't work.
-
-	config   *config // control verbosity and filtering operations
-	hashFunc harsher // defaults to walkman.NameHarsher
+	walkWorkers int             // workers reading directories, default twice the CPUs available
+	hashWorkers int             // workers hashing files, default twice the CPUs available
+	walkLimits  *limiter        // counting semaphore of walkWorkers
+	hashLimits  *limiter        // counting semaphore of hashWorkers, resized by WithAutoWorkers
+	pairs       chan pair       // channel of pairs(hash to filepath)
+	pairsBuffer int             // buffer of pairs, hashWorkers if negative, see WithChannelBuffer
+	result      chan Results    // Channel of Results map
+	wg          *sync.WaitGroup // pointer because when wg is copied, it won't work.
+	roots       []string        // directories of the current walk, see File.Root
+
+	config    *config // control filtering operations
+	hashFunc  Hasher  // defaults to NameSizeHasher
+	algorithm string  // name of hashFunc's algorithm, empty if unknown
+
+	previous Results             // results of an earlier walk, see WithPrevious
+	prev     map[string]prevFile // previous indexed by path
+	index    *Index              // persistent hashes, see WithIndex
+	mounts   []mount             // file systems walked instead of the disk, see WithFS
+
+	extractors []Extractor // fill File.Meta, see WithMetadata
+
+	watchInterval time.Duration  // polling interval of Watch
+	tuneInterval  time.Duration  // throughput window of WithAutoWorkers
+	mmapThreshold int64          // size from which files are mapped, 0 if never, see WithMmap
+	maxOpenFiles  int            // see WithMaxOpenFiles
+	maxFiles      int64          // see WithMaxFiles, 0 if unlimited
+	maxBytes      int64          // see WithMaxBytesHashed, 0 if unlimited
+	openFiles     *limiter       // counting semaphore of maxOpenFiles, nil if unlimited
+	hashOrder     HashOrder      // see WithHashOrder
+	preHooks      []PreFileHook  // see WithPreFileHook
+	postHooks     []PostFileHook // see WithPostFileHook
+	symlinks      SymlinkPolicy  // see WithSymlinks
+	known         KnownHashes    // see WithKnownHashes
+	knownPolicy   KnownPolicy    // what to do with known files
+	queue         hashQueue      // files waiting for a hash worker unless hashOrder is FIFO
+	retries       int            // see WithRetry
+	backoff       time.Duration  // wait before the first retry
+
+	mountConcurrency map[string]int // see WithPerMountConcurrency
+	mountLimits      []mountLimit   // readers of each limited directory during a walk
+
+	checkpoint         string        // see WithCheckpoint
+	checkpointInterval time.Duration // between checkpoints
+
+	backends   []ResultBackend // see WithResultBackend
+	flushEvery int             // files per flush, 0 if only once the walk ends
+	batched    int             // files added since the last flush, only touched by the collector
+
+	stats  *RunStats    // counters of the current walk, updated atomically
+	logger *slog.Logger // see WithLogger, discards by default
+	sink   func(Event)  // see WithEventSink
+	sinkMu sync.Mutex   // serialises calls to sink
+	tracer Tracer       // see WithTracer
+
+	sizes   map[int64][]sized // files by size, see WithSizeGrouping
+	pending map[int64]int     // files of each size left to collect, see WithDuplicatesOnly
+	sizesMu sync.Mutex        // guards sizes and pending while walking
+
+	ctx     context.Context // cancels the current walk
+	stop    func(error)     // cancels ctx, see WithMaxFiles
+	mu      sync.Mutex      // guards errs and skipped
+	errs    []error         // errors met below the root directories
+	skipped []SkippedDir    // see WalkReport
 }
 
 type pair struct {
-	hash string
-	path string
+	hash    string
+	path    string
+	info    fs.FileInfo // from the walk, so that files are only stat'd once
+	archive string      // archive holding the file, if any
+	mime    string
+	meta    *Meta
+	target  string // of links, see addLink
+	known   bool   // see WithKnownHashes
 }
 
 type File struct {
-	Path  string
-	Stats os.FileInfo
+	Path    string
+	Stats   os.FileInfo
+	Archive string // path of the archive holding the file, or of the file whose data stream it is, empty for regular files
+	MIME    string // content type, e.g image/jpeg, see WithMIME
+	Meta    *Meta  // metadata of the content, see WithMetadata
+	Owner   *Owner // nil if unknown, e.g on Windows
+	Target  string // destination of links, see SymlinkRecord
+	Known   bool   // the hash is known, see WithKnownHashes
+
+	Accessed time.Time // last access, zero if unknown, see Results.ColdDirs
+
+	// Root walked the file was found under and Path relative to it, so that
+	// files can be shown per root and moved along with it, see Results.Remount.
+	// Both are empty for files outside the roots, e.g loaded from an index.
+	Root    string
+	RelPath string
 }
 
-type fileList []File
-type results map[string]fileList
+type FileList []File
+type Results map[string]FileList
 
-func New(options ...option) *Walkman {
-	workers := 2 * runtime.GOMAXPROCS(0)
+func New(options ...Option) *Walkman {
+	workers := 2 * availableCPUs()
 
 	wm := &Walkman{
-		workers:  workers,
-		limits:   make(chan bool, workers),
-		pairs:    make(chan pair),
-		result:   make(chan results),
-		wg:       new(sync.WaitGroup),
-		hashFunc: nameHasher,
+		walkWorkers:  workers,
+		hashWorkers:  workers,
+		pairsBuffer:  -1,
+		tuneInterval: defaultTuneInterval,
+		result:       make(chan Results),
+		wg:           new(sync.WaitGroup),
+		stats:        new(RunStats),
+		logger:       slog.New(discardHandler{}),
+		hashFunc:     NameSizeHasher,
+		algorithm:    AlgorithmName,
 		config: &config{
-			verbose:       false,
 			skip:          dirs_to_skip,
 			noDefaultSkip: false,
 		},
@@ -110,34 +221,135 @@ func New(options ...option) *Walkman {
 		op(wm)
 	}
 
+	if wm.walkWorkers < 1 {
+		wm.walkWorkers = 1
+	}
+
+	if wm.hashWorkers < 1 {
+		wm.hashWorkers = 1
+	}
+
+	wm.walkLimits = newLimiter(wm.walkWorkers)
+	wm.hashLimits = newLimiter(wm.hashWorkers)
+
+	if wm.pairsBuffer < 0 {
+		wm.pairsBuffer = wm.hashWorkers
+	}
+	wm.pairs = make(chan pair, wm.pairsBuffer)
+
+	wm.queue.largest = wm.hashOrder == LargestFirst
+
+	if wm.config.xattrHash {
+		wm.hashFunc = xattrHasher(wm.hashFunc)
+		if wm.algorithm != "" {
+			wm.algorithm += "+xattr"
+		}
+	}
+
+	if wm.maxOpenFiles > 0 {
+		wm.openFiles = newLimiter(wm.maxOpenFiles)
+	}
+
 	return wm
 }
 
-// Pass this option to constructor to turn on verbose mode
-func Verbose() option {
+// Pass this option to constructor to turn on verbose mode, logging
+// everything to stderr. Shorthand for WithLogger at debug level.
+func Verbose() Option {
+	return WithLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
+}
+
+// Pass this function to constructor with extra folder names to skip.
+// Names may be glob patterns as in filepath.Match, e.g "cmake-build-*".
+func SkipDirs(dirs []string) Option {
 	return func(wm *Walkman) {
-		wm.config.verbose = true
+		wm.config.skip = append(wm.config.skip, dirs...)
 	}
 }
 
-// Pass this function to constructor with extra folder names to skip
-func SkipDirs(dirs []string) option {
+// Returns a copy of the folder names skipped by default.
+func DefaultSkipDirs() []string {
+	return append([]string(nil), dirs_to_skip...)
+}
+
+// Replaces the folder names to skip, default ones included, e.g with
+// DefaultSkipDirs less those to walk after all. Names may be glob
+// patterns like with SkipDirs, which adds to the list instead.
+func WithSkipList(dirs []string) Option {
 	return func(wm *Walkman) {
-		wm.config.skip = append(wm.config.skip, dirs...)
+		wm.config.skip = append([]string(nil), dirs...)
 	}
 }
 
-// Modify number of workers
-func WithWorkers(n int) option {
+// Modify number of workers, of both the pool reading directories
+// and the one hashing files. Both default to twice the CPUs available,
+// as limited by the CPU quota of the container on Linux.
+func WithWorkers(n int) Option {
 	return func(w *Walkman) {
-		w.workers = n
+		w.walkWorkers = n
+		w.hashWorkers = n
 	}
 }
 
-// modify the harsher function to uniquely idendify each file.
-func WithHasher(hashFunc harsher) option {
+// Modify the number of workers reading directories, e.g fewer
+// on a spinning disk where seeks dominate.
+func WithWalkWorkers(n int) Option {
+	return func(w *Walkman) {
+		w.walkWorkers = n
+	}
+}
+
+// Modify the number of workers hashing files, e.g more on
+// fast SSDs or when hashing is CPU bound.
+func WithHashWorkers(n int) Option {
+	return func(w *Walkman) {
+		w.hashWorkers = n
+	}
+}
+
+// Modify how many hashed files may wait for the goroutine collecting
+// them, one per hash worker by default, so that workers move on to the
+// next file rather than taking turns to hand theirs over. Zero makes
+// each worker wait for the collector, a negative n restores the default.
+func WithChannelBuffer(n int) Option {
+	return func(w *Walkman) {
+		w.pairsBuffer = n
+	}
+}
+
+// modify the Hasher function to uniquely idendify each file.
+func WithHasher(hashFunc Hasher) Option {
 	return func(w *Walkman) {
 		w.hashFunc = hashFunc
+		w.algorithm = ""
+	}
+}
+
+// Identify files by an md5 digest of their content instead
+// of their name and size. Slower, but exact.
+//
+// Files whose digest is known to their file system, like
+// objects in S3 uploaded in a single part, are not read.
+func WithContentHash() Option {
+	return func(w *Walkman) {
+		w.hashFunc = MD5ContentHasher
+		w.algorithm = AlgorithmMD5
+	}
+}
+
+// Identify files by their name alone, see NameOnlyHasher.
+func WithNameOnly() Option {
+	return func(w *Walkman) {
+		w.hashFunc = NameOnlyHasher
+		w.algorithm = AlgorithmNameOnly
+	}
+}
+
+// Identify files by their size alone, see SizeOnlyHasher.
+func WithSizeOnly() Option {
+	return func(w *Walkman) {
+		w.hashFunc = SizeOnlyHasher
+		w.algorithm = AlgorithmSizeOnly
 	}
 }
 
@@ -151,10 +363,11 @@ func slice_contains(s []string, v string) bool {
 	return false
 }
 
-// All folders are included with this option
-// except those otherwise specified for exclusion by the caller
-// by passing SkipDirs option to the constructor.
-func NoDefaultSkip() option {
+// All folders, and the files macOS leaves next to others, are
+// included with this option except those otherwise specified
+// for exclusion by the caller by passing SkipDirs option to
+// the constructor.
+func NoDefaultSkip() Option {
 	return func(w *Walkman) {
 		w.config.noDefaultSkip = true
 
@@ -171,31 +384,67 @@ func NoDefaultSkip() option {
 	}
 }
 
-// filename+size implementation of walkman.Hasher
-//
-// hash := fmt.Sprintf("%s-%d", basename, size)
+// Names of the built-in hash algorithms. Hashes are prefixed with the
+// algorithm that produced them, e.g md5:d41d8cd98f00b204e9800998ecf8427e,
+// so that results and snapshots describe themselves.
+const (
+	AlgorithmName       = "name"
+	AlgorithmNameFolded = "iname"    // names in lower case, see WithCaseInsensitiveNames
+	AlgorithmNameOnly   = "nameonly" // names without sizes, see NameOnlyHasher
+	AlgorithmSizeOnly   = "sizeonly" // sizes without names, see SizeOnlyHasher
+	AlgorithmMD5        = "md5"
+	AlgorithmVideo      = "video"    // perceptual hashes of frames, see VideoHasher
+	AlgorithmSize       = "size"     // files left unhashed, see WithSizeGrouping
+	AlgorithmEmpty      = "empty"    // empty files and directories, see WithEmpty
+	AlgorithmLink       = "link"     // symbolic links, see SymlinkRecord
+	AlgorithmDeadLink   = "deadlink" // links whose target is missing
+)
+
+// Matches files by base name and size, e.g name:report.pdf-1024, without
+// reading them. Quick, and right for copies of the same tree, but files
+// edited in place without changing size are taken for their old copies,
+// and renamed copies are missed.
 //
-// This the default harsher function
-func nameHasher(path string) pair {
-	b := filepath.Base(path)
+// This is the default Hasher.
+func NameSizeHasher(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+	return fmt.Sprintf("%s:%s-%d", AlgorithmName, filepath.Base(path), info.Size()), nil
+}
 
-	// Skip error for now
-	stat, err := os.Stat(path)
-	if err != nil {
-		log.Fatal(err)
-	}
+// Matches files by base name only, e.g nameonly:report.pdf, without reading
+// them. Finds every version of a file, whatever its content, so groups are
+// candidates to look at rather than duplicates to remove. See WithNameOnly.
+func NameOnlyHasher(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+	return fmt.Sprintf("%s:%s", AlgorithmNameOnly, filepath.Base(path)), nil
+}
 
-	fn := fmt.Sprintf("%s-%d", b, stat.Size())
+// Matches files by size only, e.g sizeonly:1024, without reading them.
+// The quickest triage there is: files of a unique size can not have a
+// duplicate, but large files of the same size usually are copies, whatever
+// their names. Small files of the same size seldom are. See WithSizeOnly.
+func SizeOnlyHasher(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+	return fmt.Sprintf("%s:%d", AlgorithmSizeOnly, info.Size()), nil
+}
 
-	return pair{hash: fn, path: path}
+// Implemented by the fs.FileInfo.Sys of file systems that know the md5
+// digest of a file without reading it, e.g object stores.
+type contentMD5er interface {
+	ContentMD5() (string, bool) // hex digest, if known
 }
 
-// md5 implementation of walkman.Hasher
-func md5ContentHasher(path string) pair {
-	file, err := os.Open(path)
+// Matches files by an md5 digest of their content, e.g md5:d41d8c...
+// Slower than the others as every file is read, but exact. Files whose
+// digest is known to their file system, like objects in S3 uploaded in a
+// single part, are not read. See WithContentHash.
+func MD5ContentHasher(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+	if s, ok := info.Sys().(contentMD5er); ok {
+		if sum, ok := s.ContentMD5(); ok {
+			return fmt.Sprintf("%s:%s", AlgorithmMD5, sum), nil
+		}
+	}
 
+	file, err := open()
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
 	defer file.Close()
@@ -203,10 +452,10 @@ func md5ContentHasher(path string) pair {
 	hash := md5.New() // fast & good enough for small directories
 
 	if _, err := io.Copy(hash, file); err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("%s: %w", path, err)
 	}
 
-	return pair{hash: fmt.Sprintf("%x", hash.Sum(nil)), path: path}
+	return fmt.Sprintf("%s:%x", AlgorithmMD5, hash.Sum(nil)), nil
 }
 
 // Recursively walks dir, calling processFile for regular files that are not empty.
@@ -214,68 +463,348 @@ func md5ContentHasher(path string) pair {
 // All subdirectories are walked in seperate go routines by
 // recursively calling searchTree on the subdirctories.
 // Returns a map of files or an error
+func (wm *Walkman) Walk(dir string) (Results, error) {
+	return wm.WalkContext(context.Background(), dir)
+}
+
+// Walks several root directories in a single run so that
+// duplicates are detected across all of them.
+//
+// Roots may not be nested inside one another, otherwise
+// every file below the inner root would be reported as
+// a duplicate of itself.
+//
+// Errors met below the roots (e.g unreadable subdirectories) do not stop
+// the walk, they are available from Errors once it completes.
+func (wm *Walkman) WalkDirs(dirs ...string) (Results, error) {
+	return wm.WalkContext(context.Background(), dirs...)
+}
+
+// Like WalkDirs but stops early when ctx is done.
 //
-// TODO: Add context cancellation
-func (wm *Walkman) Walk(dir string) (results, error) {
+// On cancellation the files hashed so far are returned
+// together with ctx.Err(), so callers can still make use
+// of partial results.
+func (wm *Walkman) WalkContext(ctx context.Context, dirs ...string) (Results, error) {
+	ctx, end := wm.startSpan(ctx, "walkman.Walk", slog.Any("roots", dirs))
+	hashes, err := wm.walk(ctx, dirs)
+	end(err)
+	return hashes, err
+}
+
+func (wm *Walkman) walk(ctx context.Context, dirs []string) (Results, error) {
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	wm.ctx, wm.stop = ctx, stop
+
+	start := time.Now()
+	wm.reset()
+	defer func() {
+		elapsed := time.Since(start)
+		atomic.StoreInt64((*int64)(&wm.stats.Elapsed), int64(elapsed))
+
+		walkIdle := int64(wm.walkLimits.capacity()) - atomic.LoadInt64((*int64)(&wm.stats.DirTime))
+		hashIdle := int64(wm.hashLimits.capacity()) - atomic.LoadInt64((*int64)(&wm.stats.HashTime))
+		if idle := walkIdle + hashIdle; idle > 0 {
+			atomic.StoreInt64((*int64)(&wm.stats.IdleTime), idle)
+		}
+	}()
+
+	if wm.config.autoWorkers {
+		done := make(chan struct{})
+		defer close(done)
+		go wm.autoTune(done)
+	}
+
+	if err := checkRoots(dirs); err != nil {
+		return Results{}, err
+	}
+
+	if err := wm.checkBackends(); err != nil {
+		return Results{}, err
+	}
+
+	wm.foldNames(dirs)
+
+	// a checkpoint only counts for this walk
+	previous := wm.previous
+	defer func() { wm.previous = previous }()
+
+	if err := wm.resumeCheckpoint(); err != nil {
+		return Results{}, err
+	}
+
+	if err := wm.indexPrevious(); err != nil {
+		return Results{}, err
+	}
+
+	roots := make([]string, len(dirs))
+	for i, dir := range dirs {
+		roots[i] = wm.cleanRoot(dir)
+
+		if err := wm.retry(func() error { _, err := wm.stat(roots[i]); return err }); err != nil {
+			return Results{}, err
+		}
+	}
+	dirs = roots
+	wm.roots = roots
+	wm.limitMounts(roots)
+
 	// we need another goroutine so we don't block here
 	go wm.collectHashes()
 
-	// multi-threaded walk of the directory tree; we need a
-	// waitGroup because we don't know how many to wait for
-	wm.wg.Add(1)
+	for _, dir := range dirs {
+		// multi-threaded walk of the directory tree; we need a
+		// waitGroup because we don't know how many to wait for
+		wm.wg.Add(1)
 
-	err := wm.searchTree(dir)
+		err := wm.searchTree(dir)
 
-	if err != nil {
-		return results{}, err
+		// Cancellation still waits for the workers below
+		// so that partial results can be handed back.
+		if err != nil && ctx.Err() == nil {
+			return Results{}, err
+		}
 	}
 
 	// we must close the paths channel so the workers stop
 	wm.wg.Wait()
 
+	// all sizes are known, hash the files that may have duplicates
+	if wm.config.sizeFirst {
+		wm.processSized()
+		wm.wg.Wait()
+	}
+
 	// by closing pairs we signal that all the hashes
 	// have been collected; we have to do it here AFTER
 	// all the workers are done
 	close(wm.pairs)
 
-	return <-wm.result, nil
+	hashes := <-wm.result
+	if wm.config.sorted {
+		hashes.sortFiles()
+		wm.sortBackends()
+	}
+
+	wm.updateIndex(dirs, hashes)
+
+	return hashes, walkErr(ctx)
+}
+
+// Clears what the last walk left behind, so that a Walkman may walk again
+// once a walk returned, though not twice at once.
+func (wm *Walkman) reset() {
+	wm.walkLimits.reset()
+	wm.hashLimits.reset()
+
+	wm.pairs = make(chan pair, wm.pairsBuffer)
+	wm.stats.reset()
+	wm.prev = nil
+	wm.sizes, wm.pending = nil, nil
+	wm.batched = 0
+
+	wm.mu.Lock()
+	wm.errs, wm.skipped = nil, nil
+	wm.mu.Unlock()
+}
+
+// Returns the errors met during the last walk for files
+// and directories that had to be left out.
+func (wm *Walkman) Errors() []error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	return append([]error(nil), wm.errs...)
+}
+
+func (wm *Walkman) addError(err error) {
+	atomic.AddInt64(&wm.stats.Errors, 1)
+	if IsLocked(err) {
+		atomic.AddInt64(&wm.stats.FilesLocked, 1)
+	}
+	wm.logger.Error("walk error", "err", err)
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		wm.emit(Event{Kind: EventError, Path: pathErr.Path, Err: err})
+	} else {
+		wm.emit(Event{Kind: EventError, Err: err})
+	}
+
+	wm.mu.Lock()
+	wm.errs = append(wm.errs, err)
+	wm.mu.Unlock()
+}
+
+// Returns an error if any two roots overlap.
+func checkRoots(dirs []string) error {
+	for i, a := range dirs {
+		for _, b := range dirs[i+1:] {
+			if IsUnder(a, b) || IsUnder(b, a) {
+				return fmt.Errorf("walkman: overlapping directories %q and %q", a, b)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the file of p, found while walking wm.roots.
+func (wm *Walkman) newFile(p pair) File {
+	file := File{Path: p.path, Stats: p.info, Archive: p.archive, MIME: p.mime, Meta: p.meta, Owner: ownerOf(p.info), Target: p.target, Known: p.known, Accessed: accessTime(p.info)}
+
+	for _, root := range wm.roots {
+		if rel, err := filepath.Rel(root, p.path); err == nil && IsUnder(p.path, root) {
+			file.Root, file.RelPath = root, rel
+			break
+		}
+	}
+	return file
+}
+
+// Reports whether path is dir or lies somewhere below it, by their
+// cleaned paths, so that /a/bc is not below /a/b but /a/b/..c is.
+func IsUnder(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
 }
 
 // worker processes each file in this routine by hasing file at path
 // sends on on the send-only channel pairs.
-func (wm *Walkman) processFile(path string) {
+// Files of a unique size are listed without hashing them.
+func (wm *Walkman) processFile(path string, fi fs.FileInfo, unique bool) {
 	defer wm.wg.Done()
 
 	// Wait on semaphore
-	wm.limits <- true
+	wm.hashLimits.acquire()
 
 	// Decrement counter when function completes
+	start := time.Now()
 	defer func() {
-		<-wm.limits
+		atomic.AddInt64((*int64)(&wm.stats.HashTime), int64(time.Since(start)))
+		wm.hashLimits.release()
 	}()
 
-	wm.pairs <- wm.hashFunc(path)
+	// Hash the first file waiting rather than our own, see queueFile
+	if wm.hashOrder != FIFO {
+		t := wm.queue.pop()
+		path, fi, unique = t.path, t.info, t.unique
+	}
+
+	// Walk was cancelled while we waited for our turn
+	if wm.ctx.Err() != nil {
+		return
+	}
+
+	if !wm.preFile(path, fi) {
+		return
+	}
+
+	// Unchanged since the previous walk
+	if p, ok := wm.reuse(path, fi); ok {
+		if wm.config.mime && p.mime == "" {
+			p.mime = newSniffer(wm.opener(path)).mime()
+		}
+
+		if p.meta == nil {
+			p.meta = wm.extract(path, wm.opener(path))
+		}
+
+		atomic.AddInt64(&wm.stats.FilesReused, 1)
+		wm.checkBudget()
+		wm.logger.Debug("reused hash", "path", path, "hash", p.hash)
+		wm.emit(Event{Kind: EventFileHashed, Path: path, Hash: p.hash, Reused: true})
+		wm.indexFile(path, p.hash, fi)
+		wm.postFile(path, fi, p.hash, nil)
+
+		known := wm.isKnown(path, p.hash)
+		if !known || wm.knownPolicy != KnownExclude {
+			wm.pairs <- pair{hash: p.hash, path: path, info: fi, mime: p.mime, meta: p.meta, known: known}
+		}
+		return
+	}
+
+	// known hashes could not be matched otherwise
+	if unique && wm.known == nil {
+		wm.processUnique(path, fi)
+		return
+	}
+
+	var hash, mime string
+	_, end := wm.startSpan(wm.ctx, "walkman.Hash", slog.String("path", path), slog.Int64("size", fi.Size()))
+	err := wm.retry(func() (err error) {
+		hash, mime, err = wm.hash(path, fi, wm.hashOpener(path, fi))
+		return err
+	})
+	end(err)
+
+	if err != nil {
+		wm.addError(err)
+		wm.postFile(path, fi, "", err)
+		return
+	}
+
+	atomic.AddInt64(&wm.stats.FilesScanned, 1)
+	atomic.AddInt64(&wm.stats.BytesHashed, fi.Size())
+	wm.checkBudget()
+	wm.logger.Debug("hashed file", "path", path, "hash", hash)
+	wm.emit(Event{Kind: EventFileHashed, Path: path, Hash: hash})
+
+	wm.indexFile(path, hash, fi)
+	wm.postFile(path, fi, hash, nil)
+
+	known := wm.isKnown(path, hash)
+	if !known || wm.knownPolicy != KnownExclude {
+		wm.pairs <- pair{hash: hash, path: path, info: fi, mime: mime, meta: wm.extract(path, wm.opener(path)), known: known}
+	}
+
+	if wm.descends(path) {
+		wm.hashArchive(path)
+	}
+
+	if wm.config.streams {
+		wm.hashStreams(path, fi)
+	}
 }
 
 // Loops over the pairs channel, appending all hashes to the results channel when done.
 // pairs chan: read only, results chan write-only.
 func (wm *Walkman) collectHashes() {
-	hashes := make(results)
+	if len(wm.backends) > 0 {
+		wm.result <- wm.collectBackend()
+		return
+	}
+
+	hashes := make(Results)
+	bySize := make(map[int64][]string) // see collected
+
+	checkpoint, stop := wm.checkpointTicker()
+	defer stop()
+
+	for {
+		select {
+		case p, ok := <-wm.pairs:
+			if !ok {
+				wm.endCheckpoint(hashes)
+				if wm.config.duplicatesOnly {
+					hashes.dropSingletons()
+				}
+				wm.result <- hashes
+				return
+			}
 
-	for p := range wm.pairs {
-		stats, err := os.Stat(p.path)
-		if err == nil {
 			// No need for locks/mutexes when writing.
 			// Channels guarantee proper syncronisation.
-			hashes[p.hash] = append(hashes[p.hash], File{Path: p.path, Stats: stats})
+			file := wm.newFile(p)
+			hashes[p.hash] = append(hashes[p.hash], file)
+			wm.collected(hashes, bySize, p)
+		case <-checkpoint:
+			wm.saveCheckpoint(hashes)
 		}
 	}
-
-	wm.result <- hashes
-}
-
-func log_skipped(name string) {
-	fmt.Printf("Skipping directory %q\n", name)
 }
 
 // Recursively walks dir, calling processFile for regular files
@@ -287,7 +816,13 @@ func log_skipped(name string) {
 func (wm *Walkman) searchTree(dirname string) error {
 	defer wm.wg.Done()
 
-	// Skips a folder if name in folders to skip
+	var readErr error // dirname could not be read, with WithRetry
+
+	var dirInfo fs.FileInfo // of dirname
+	entries := 0            // in dirname, to tell if it is empty
+
+	// Skips a folder if name in folders to skip, or matches one
+	// of them as a glob pattern
 	skipFolder := func(name string) bool {
 		var skip bool
 
@@ -296,29 +831,91 @@ func (wm *Walkman) searchTree(dirname string) error {
 				skip = true
 				break
 			}
+
+			if ok, _ := filepath.Match(i, name); ok {
+				skip = true
+				break
+			}
 		}
 		return skip
 	}
 
 	// filepath.WalkDirFunc more performant than filepath.WalkFunc
 	visitor := func(path string, d fs.DirEntry, err error) error {
-		if err != nil && err != os.ErrNotExist {
-			return err
+		if wm.ctx.Err() != nil {
+			return wm.ctx.Err()
+		}
+
+		if path != dirname {
+			entries++
+		}
+
+		// Record the error and carry on with the rest of the tree.
+		if err != nil {
+			// read dirname again, see below
+			if path == dirname && wm.retries > 0 {
+				readErr = err
+				return filepath.SkipDir
+			}
+
+			wm.addError(err)
+
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
 		fi, err := d.Info()
 		if err != nil {
-			return err
+			// removed since its directory was read
+			if !errors.Is(err, fs.ErrNotExist) {
+				wm.addError(err)
+			}
+			return nil
 		}
 
 		name := fi.Name()
+		if path == dirname {
+			dirInfo = fi
+		}
+
+		// Never follow links, see isLink
+		if isLink(fi) && path != dirname {
+			atomic.AddInt64(&wm.stats.LinksSkipped, 1)
+			wm.logger.Debug("skipping link", "path", path)
 
-		// Ignore hidden folders and wm.config.skip dirs
-		if fi.Mode().IsDir() && (strings.HasPrefix(name, ".") || skipFolder(name)) {
-			if wm.config.verbose {
-				log_skipped(name)
+			if wm.symlinks == SymlinkRecord {
+				wm.addLink(path, fi)
 			}
+			return nil
+		}
 
+		// Ignore hidden folders and wm.config.skip dirs, but not the
+		// roots, e.g a mounted file system whose root is named "."
+		if fi.Mode().IsDir() && path != dirname && skipFolder(name) {
+			wm.skipDir(path, SkipListed)
+			return filepath.SkipDir
+		}
+
+		if path != dirname && wm.config.skipMatch(path) {
+			if fi.Mode().IsDir() {
+				wm.skipDir(path, SkipPattern)
+				return filepath.SkipDir
+			}
+
+			atomic.AddInt64(&wm.stats.FilesSkipped, 1)
+			wm.logger.Debug("skipping file", "path", path)
+			return nil
+		}
+
+		if fi.Mode().IsDir() && path != dirname && !wm.config.hidden && isHidden(fi) {
+			wm.skipDir(path, SkipHidden)
+			return filepath.SkipDir
+		}
+
+		if fi.Mode().IsDir() && path != dirname && wm.marked(path) {
+			wm.skipDir(path, SkipMarked)
 			return filepath.SkipDir
 		}
 
@@ -327,63 +924,109 @@ func (wm *Walkman) searchTree(dirname string) error {
 			wm.wg.Add(1)
 
 			go wm.searchTree(path)
+			return filepath.SkipDir
+		}
 
-			if wm.config.verbose {
-				fmt.Printf("Processing subdirectory: %q\n", name)
+		// Nothing to hash, see WithEmpty
+		if fi.Mode().IsRegular() && fi.Size() == 0 {
+			if wm.config.empty && (wm.config.noDefaultSkip || !isMacNoise(name)) {
+				wm.addEmpty(path, fi)
 			}
-
-			return filepath.SkipDir
+			return nil
 		}
 
-		if fi.Mode().IsRegular() && fi.Size() > 0 {
-			wm.wg.Add(1)
-			go wm.processFile(path)
+		if fi.Mode().IsRegular() {
+			if (!wm.config.noDefaultSkip && isMacNoise(name)) || isDataless(fi) {
+				atomic.AddInt64(&wm.stats.FilesSkipped, 1)
+				wm.logger.Debug("skipping file", "path", path)
+				return nil
+			}
+
+			wm.emit(Event{Kind: EventFileQueued, Path: path})
 
-			if wm.config.verbose {
-				fmt.Printf("Processing file: %q\n", path)
+			if wm.config.sizeFirst {
+				wm.addSized(path, fi)
+				return nil
 			}
+
+			wm.queueFile(path, fi, false)
 		}
 
 		return nil
 	}
 
 	// Wait on semaphore
-	wm.limits <- true
+	wm.walkLimits.acquire()
 
 	// Decrement semaphore counter when function exits
+	start := time.Now()
 	defer func() {
-		<-wm.limits
+		atomic.AddInt64((*int64)(&wm.stats.DirTime), int64(time.Since(start)))
+		wm.walkLimits.release()
 	}()
 
-	return filepath.WalkDir(dirname, visitor)
+	atomic.AddInt64(&wm.stats.DirsScanned, 1)
+	wm.logger.Debug("entering directory", "path", dirname)
+	wm.emit(Event{Kind: EventEnterDir, Path: dirname})
+
+	_, end := wm.startSpan(wm.ctx, "walkman.Dir", slog.String("path", dirname))
+	err := wm.retry(func() error {
+		readErr, entries = nil, 0
+
+		release := wm.openSlot()
+		defer release()
+
+		if err := wm.walkDir(dirname, visitor); err != nil {
+			return err
+		}
+		return readErr
+	})
+
+	// dirname could not be read after all
+	if err != nil && err == readErr {
+		wm.addError(err)
+		err = nil
+	}
+
+	if err == nil && entries == 0 && dirInfo != nil && wm.config.empty {
+		wm.addEmpty(dirname, dirInfo)
+	}
+	end(err)
+	return err
 }
 
 // Path filter is called for each path in the map values
 // If it returns true, the path is included.
 type PathFilter func(file File) bool
 
+// Groups files anew by the key returned for each, e.g to find photos
+// taken at the same time whatever their content. Files with an empty
+// key are left out. Keys should be prefixed by what they are made of,
+// like hashes by their algorithm, e.g taken:2021-06-01T10:00:00Z.
+func (hashes Results) GroupBy(key func(file File) string) Results {
+	groups := make(Results)
+
+	for _, files := range hashes {
+		for _, file := range files {
+			if k := key(file); k != "" {
+				groups[k] = append(groups[k], file)
+			}
+		}
+	}
+	return groups
+}
+
 // Filter results based on file. Returns a copy of results.
 // Warning: This is potentially very expensive if filterFuncs are many
-// and doing a lot of work esp IO work.
-func (hashes results) Filter(filterFuncs ...PathFilter) results {
-	results := make(results)
+// and doing a lot of work esp IO work. Combine them with And, marking
+// those Expensive, so that they only run on files passing the others.
+func (hashes Results) Filter(filterFuncs ...PathFilter) Results {
+	results := make(Results)
 
 	for hash, files := range hashes {
 		// Loop through all duplicates
 		for _, file := range files {
-			include := true
-
-			// Apply filter for each path
-			for _, filterFunc := range filterFuncs {
-
-				// If we all any filter, we ignore this path
-				if !filterFunc(file) {
-					include = false
-					break
-				}
-			}
-
-			if include {
+			if included(file, filterFuncs) {
 				results[hash] = append(results[hash], file)
 			}
 		}
@@ -392,14 +1035,23 @@ func (hashes results) Filter(filterFuncs ...PathFilter) results {
 	return results
 }
 
-// Loops over the hashes map and flattens it into a slice of File objects.
-func (hashes results) ToSlice() fileList {
+// Reports whether file passes every filter, stopping at the first it fails.
+func included(file File, filterFuncs []PathFilter) bool {
+	for _, filterFunc := range filterFuncs {
+		if !filterFunc(file) {
+			return false
+		}
+	}
+	return true
+}
+
+// Loops over the hashes map and flattens it into a slice of File objects,
+// ordered by hash.
+func (hashes Results) ToSlice() FileList {
 	files := []File{}
 
-	for _, fl := range hashes {
-		for _, f := range fl {
-			files = append(files, File{Path: f.Path, Stats: f.Stats})
-		}
+	for _, hash := range hashes.sortedKeys() {
+		files = append(files, hashes[hash]...)
 	}
 
 	return files
@@ -1,7 +1,14 @@
 package walkman
 
 import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestSliceContains(t *testing.T) {
@@ -18,3 +25,387 @@ func TestSliceContains(t *testing.T) {
 		t.Errorf("Expected slice_contains to return false for dir: go-prog")
 	}
 }
+
+func TestIsUnder(t *testing.T) {
+	tests := []struct {
+		path, dir string
+		want      bool
+	}{
+		{"/a/b/c", "/a/b", true},
+		{"/a/b", "/a/b", true},
+		{"/a/bc", "/a/b", false},
+		{"/a", "/a/b", false},
+		{"/a/b/..c", "/a/b", true},
+		{"/a/b/../c", "/a/b", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsUnder(tt.path, tt.dir); got != tt.want {
+			t.Errorf("IsUnder(%q, %q) = %v, want %v", tt.path, tt.dir, got, tt.want)
+		}
+	}
+
+	if err := checkRoots([]string{"/a", "/a/b"}); err == nil {
+		t.Errorf("expected nested roots to be rejected")
+	}
+}
+
+func TestWalkContextCancelled(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := New().WalkContext(ctx, dir)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestLastRunStats(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "sub/b.txt", ".hidden/c.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wm := New()
+	if _, err := wm.Walk(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := wm.LastRunStats()
+	if stats.FilesScanned != 2 || stats.BytesHashed != 10 {
+		t.Errorf("expected 2 files and 10 bytes, got %d files and %d bytes", stats.FilesScanned, stats.BytesHashed)
+	}
+
+	if stats.DirsScanned != 2 || stats.DirsSkipped != 1 {
+		t.Errorf("expected 2 dirs scanned and 1 skipped, got %d and %d", stats.DirsScanned, stats.DirsSkipped)
+	}
+}
+
+func TestWithIncludeHidden(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ".profile", "a", time.Now())
+	hidden := writeFile(t, dir, ".cache/b.txt", "b", time.Now())
+	writeFile(t, dir, ".git/c.txt", "c", time.Now())
+
+	hashes, err := New().Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := hashes.ToSlice(); len(files) != 1 {
+		t.Errorf("expected only the hidden file outside hidden directories, got %v", files)
+	}
+
+	hashes, err = New(WithIncludeHidden()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashOf(t, hashes, hidden.Path)
+	if files := hashes.ToSlice(); len(files) != 3 {
+		t.Errorf("expected every file to be walked, got %v", files)
+	}
+}
+
+func TestMacNoise(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.txt", "a", time.Now())
+	for _, name := range []string{".DS_Store", "._a.txt", ".b.txt.icloud", ".Trashes/c.txt"} {
+		writeFile(t, dir, name, "noise", time.Now())
+	}
+
+	wm := New(WithIncludeHidden())
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := hashes.ToSlice(); len(files) != 1 || files[0].Path != a.Path {
+		t.Errorf("expected only %s, got %v", a.Path, files)
+	}
+
+	if skipped := wm.LastRunStats().FilesSkipped; skipped != 3 {
+		t.Errorf("expected 3 files skipped, got %d", skipped)
+	}
+
+	hashes, err = New(WithIncludeHidden(), NoDefaultSkip()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := hashes.ToSlice(); len(files) != 5 {
+		t.Errorf("expected every file with NoDefaultSkip, got %v", files)
+	}
+}
+
+func TestLinksSkipped(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "sub/a.txt", "a", time.Now())
+
+	// a loop back to the root and a second name for a file
+	if err := os.Symlink(dir, filepath.Join(dir, "sub", "loop")); err != nil {
+		t.Skip(err)
+	}
+
+	if err := os.Symlink(a.Path, filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	wm := New()
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := hashes.ToSlice(); len(files) != 1 || files[0].Path != a.Path {
+		t.Errorf("expected only %s, got %v", a.Path, files)
+	}
+
+	if skipped := wm.LastRunStats().LinksSkipped; skipped != 2 {
+		t.Errorf("expected 2 links skipped, got %d", skipped)
+	}
+}
+
+func TestSymlinkRecord(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.txt", "a", time.Now())
+
+	live, dead := filepath.Join(dir, "live"), filepath.Join(dir, "sub", "dead")
+	if err := os.Symlink(a.Path, live); err != nil {
+		t.Skip(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dead), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(filepath.Join(dir, "gone.txt"), dead); err != nil {
+		t.Fatal(err)
+	}
+
+	wm := New(WithSymlinks(SymlinkRecord))
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if links := hashes.Symlinks(); len(links) != 2 || links[0].Path != live || links[0].Target != a.Path {
+		t.Errorf("expected %s and %s recorded, got %v", live, dead, links)
+	}
+
+	broken := hashes.BrokenSymlinks()
+	if len(broken) != 1 || broken[0].Path != dead || broken[0].Target != filepath.Join(dir, "gone.txt") {
+		t.Errorf("expected only %s broken, got %v", dead, broken)
+	}
+
+	if skipped := wm.LastRunStats().LinksSkipped; skipped != 2 {
+		t.Errorf("expected recorded links not followed, got %d skipped", skipped)
+	}
+
+	snap := filepath.Join(t.TempDir(), "scan.json")
+	if err := hashes.Save(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadResults(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if broken := loaded.BrokenSymlinks(); len(broken) != 1 || broken[0].Target != filepath.Join(dir, "gone.txt") {
+		t.Errorf("expected the broken link kept in snapshots, got %v", broken)
+	}
+}
+
+func TestLastRunStatsTimings(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "hello", time.Now())
+	bad := writeFile(t, dir, "sub/bad.txt", "hello", time.Now())
+
+	hasher := func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		if path == bad.Path {
+			return "", errors.New("broken")
+		}
+		return MD5ContentHasher(path, info, open)
+	}
+
+	wm := New(WithWalkWorkers(1), WithHashWorkers(2), WithHasher(hasher))
+	if _, err := wm.Walk(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := wm.LastRunStats()
+	if stats.WalkWorkers != 1 || stats.HashWorkers != 2 || stats.Errors != 1 {
+		t.Errorf("expected 1 + 2 workers and 1 error, got %d + %d and %d", stats.WalkWorkers, stats.HashWorkers, stats.Errors)
+	}
+
+	if stats.DirTime <= 0 || stats.HashTime <= 0 || stats.FilesPerSecond() <= 0 || stats.MBPerSecond() <= 0 {
+		t.Errorf("expected time spent reading and hashing, got %+v", stats)
+	}
+
+	if busy := stats.DirTime + stats.HashTime + stats.IdleTime; busy > 3*stats.Elapsed+time.Millisecond {
+		t.Errorf("workers accounted for %s of %s", busy, 3*stats.Elapsed)
+	}
+}
+
+func TestSeparatePools(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a/1.txt", "a/b/2.txt", "a/b/c/3.txt", "d/4.txt"} {
+		writeFile(t, dir, name, name, time.Now())
+	}
+
+	// the only hash worker holds on until every directory was read,
+	// which a shared pool could never do
+	entered := make(chan struct{})
+	dirs := 0
+	sink := func(e Event) {
+		if e.Kind == EventEnterDir {
+			if dirs++; dirs == 5 {
+				close(entered)
+			}
+		}
+	}
+
+	hasher := func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		select {
+		case <-entered:
+		case <-time.After(5 * time.Second):
+			return "", errors.New("directories were not read while hashing")
+		}
+		return NameSizeHasher(path, info, open)
+	}
+
+	wm := New(WithWalkWorkers(1), WithHashWorkers(1), WithHasher(hasher), WithEventSink(sink))
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := wm.Errors(); len(errs) != 0 || len(hashes) != 4 {
+		t.Errorf("expected 4 files hashed, got %v and errors %v", hashes, errs)
+	}
+}
+
+// Holds on to the first file until told to go on.
+type stalledBackend struct {
+	release chan struct{}
+	hashes  Results
+}
+
+func (b *stalledBackend) Add(hash string, file File) error {
+	if b.hashes == nil {
+		<-b.release
+		b.hashes = make(Results)
+	}
+	b.hashes[hash] = append(b.hashes[hash], file)
+	return nil
+}
+
+func (b *stalledBackend) Groups(fn func(hash string, files FileList) error) error {
+	for hash, files := range b.hashes {
+		if err := fn(hash, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *stalledBackend) Flush() error { return nil }
+func (b *stalledBackend) Close() error { return nil }
+
+func TestWithChannelBuffer(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"1.txt", "2.txt", "3.txt", "4.txt"} {
+		writeFile(t, dir, name, name, time.Now())
+	}
+
+	// the collector is stuck on the first file, the only hash
+	// worker goes on as long as there is room in the buffer
+	backend := &stalledBackend{release: make(chan struct{})}
+	hashed := 0
+	sink := func(e Event) {
+		if e.Kind == EventFileHashed {
+			if hashed++; hashed == 4 {
+				close(backend.release)
+			}
+		}
+	}
+
+	wm := New(WithHashWorkers(1), WithChannelBuffer(3), WithResultBackend(backend), WithEventSink(sink))
+	if cap(wm.pairs) != 3 {
+		t.Fatalf("expected a buffer of 3, got %d", cap(wm.pairs))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := wm.Walk(dir); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		close(backend.release)
+		<-done
+		t.Fatal("hashing stalled with the collector")
+	}
+
+	if files := len(backend.hashes); files != 4 {
+		t.Errorf("expected 4 files collected, got %d", files)
+	}
+
+	if wm := New(WithHashWorkers(5)); cap(wm.pairs) != 5 {
+		t.Errorf("expected a buffer of one file per hash worker by default, got %d", cap(wm.pairs))
+	}
+}
+
+func TestQuickHashers(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a/report.pdf", "v1", time.Now())
+	writeFile(t, dir, "b/report.pdf", "version 2", time.Now())
+	writeFile(t, dir, "c/notes.txt", "v3", time.Now())
+
+	tests := []struct {
+		option Option
+		groups int
+	}{
+		{WithHasher(NameSizeHasher), 3},
+		{WithNameOnly(), 2},
+		{WithSizeOnly(), 2},
+	}
+
+	for _, tt := range tests {
+		wm := New(tt.option)
+		hashes, err := wm.Walk(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(hashes) != tt.groups {
+			t.Errorf("expected %d groups, got %v", tt.groups, hashes)
+		}
+	}
+
+	hashes, err := New(WithSizeOnly()).Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := hashes[AlgorithmSizeOnly+":2"]; len(files) != 2 {
+		t.Errorf("expected report.pdf and notes.txt of 2 bytes together, got %v", hashes)
+	}
+}
@@ -0,0 +1,33 @@
+package walkman
+
+// Bytes taken by the copies beyond the first of a group of identical files,
+// the size of one of them times the number of copies, 0 without copies.
+// Sort groups by it to show those worth looking at first.
+func (files FileList) WastedBytes() int64 {
+	if len(files) < 2 {
+		return 0
+	}
+	return files[0].Stats.Size() * int64(len(files)-1)
+}
+
+// Bytes freed by removing every file of the group but the one keep picks,
+// summing their sizes, so that groups of files that may differ in size,
+// e.g matched by name, are counted right. 0 if keep picks none, like NewPlan.
+func (files FileList) WastedBytesKeeping(keep KeepPolicy) int64 {
+	if len(files) < 2 {
+		return 0
+	}
+
+	k := keep(files)
+	if k < 0 {
+		return 0
+	}
+
+	var n int64
+	for i, f := range files {
+		if i != k {
+			n += f.Stats.Size()
+		}
+	}
+	return n
+}
@@ -0,0 +1,28 @@
+package walkman
+
+import "testing"
+
+func TestWastedBytes(t *testing.T) {
+	files := FileList{
+		{Path: "/b/report.pdf", Stats: snapshotInfo{size: 100}},
+		{Path: "/a/report.pdf", Stats: snapshotInfo{size: 300}},
+		{Path: "/c/report.pdf", Stats: snapshotInfo{size: 100}},
+	}
+
+	if n := files.WastedBytes(); n != 200 {
+		t.Errorf("expected 200 bytes wasted, got %d", n)
+	}
+
+	if n := files[:1].WastedBytes(); n != 0 {
+		t.Errorf("expected nothing wasted by a single file, got %d", n)
+	}
+
+	if n := files.WastedBytesKeeping(KeepShortestPath()); n != 200 {
+		t.Errorf("expected the 2 files but /a/report.pdf counted, got %d", n)
+	}
+
+	never := func(FileList) int { return -1 }
+	if n := files.WastedBytesKeeping(never); n != 0 {
+		t.Errorf("expected nothing freed when no file is kept, got %d", n)
+	}
+}
@@ -0,0 +1,138 @@
+package walkman
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// How often Watch walks the tree again unless WithWatchInterval is given.
+const defaultWatchInterval = 5 * time.Second
+
+// A new duplicate seen by Watch.
+type WatchEvent struct {
+	Hash       string
+	File       File     // the file that was added or changed
+	Duplicates FileList // files with the same hash, File excluded
+	Err        error    // set instead if a walk failed
+}
+
+// Sets how often Watch walks the tree again.
+func WithWatchInterval(d time.Duration) Option {
+	return func(w *Walkman) {
+		w.watchInterval = d
+	}
+}
+
+// Watches dir for files that duplicate another one, e.g a download
+// folder, until ctx is done.
+//
+// The tree is walked once up front, duplicates already present are not
+// reported. It is then polled, only hashing files added or changed since
+// the last walk, and an event is sent for every new file that has a
+// duplicate. Pass WithIndex to keep an index up to date as well.
+//
+// Every walk uses a Walkman created with options. The returned channel
+// is closed once ctx is done.
+func Watch(ctx context.Context, dir string, options ...Option) (<-chan WatchEvent, error) {
+	last, err := watchWalk(ctx, dir, nil, options)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := New(options...).watchInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	events := make(chan WatchEvent)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			hashes, err := watchWalk(ctx, dir, last, options)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				if !send(ctx, events, WatchEvent{Err: err}) {
+					return
+				}
+				continue
+			}
+
+			for _, e := range newDuplicates(last, hashes) {
+				if !send(ctx, events, e) {
+					return
+				}
+			}
+			last = hashes
+		}
+	}()
+
+	return events, nil
+}
+
+// Walks dir once, reusing the hashes of unchanged files from last.
+func watchWalk(ctx context.Context, dir string, last Results, options []Option) (Results, error) {
+	wm := New(options...)
+	if wm.algorithm != "" {
+		wm.previous = last
+	}
+	return wm.WalkContext(ctx, dir)
+}
+
+func send(ctx context.Context, events chan<- WatchEvent, e WatchEvent) bool {
+	select {
+	case events <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Returns an event for every file in a duplicate group of current
+// that was not in the same group before.
+func newDuplicates(before, current Results) []WatchEvent {
+	events := []WatchEvent{}
+
+	for hash, files := range current {
+		if len(files) < 2 {
+			continue
+		}
+
+		known := make(map[string]bool)
+		for _, f := range before[hash] {
+			known[f.Path] = true
+		}
+
+		for i, f := range files {
+			if known[f.Path] {
+				continue
+			}
+
+			others := make(FileList, 0, len(files)-1)
+			others = append(others, files[:i]...)
+			others = append(others, files[i+1:]...)
+			sortFiles(others)
+
+			events = append(events, WatchEvent{Hash: hash, File: f, Duplicates: others})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].File.Path < events[j].File.Path
+	})
+	return events
+}
@@ -0,0 +1,41 @@
+package walkman
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "hello", time.Now())
+	writeFile(t, dir, "b.txt", "hello", time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := Watch(ctx, dir, WithContentHash(), WithWatchInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a.txt and b.txt were there from the start and are not reported
+	copied := writeFile(t, dir, "copy.txt", "hello", time.Now())
+
+	e, ok := <-events
+	if !ok {
+		t.Fatal("watch stopped before reporting the copy")
+	}
+
+	if e.Err != nil {
+		t.Fatal(e.Err)
+	}
+
+	if e.File.Path != copied.Path || len(e.Duplicates) != 2 {
+		t.Errorf("expected %s with 2 duplicates, got %s with %v", copied.Path, e.File.Path, e.Duplicates)
+	}
+
+	cancel()
+	for range events {
+	}
+}
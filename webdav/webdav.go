@@ -0,0 +1,373 @@
+// Package webdav exposes a WebDAV server, e.g the files of a Nextcloud
+// or ownCloud account, as a read only fs.FS to be walked with
+// walkman.WithFS:
+//
+//	cloud := webdav.New(webdav.Config{
+//		URL:      "https://cloud.example.com",
+//		Username: "me",
+//		Password: os.Getenv("WEBDAV_PASSWORD"),
+//	})
+//
+//	wm := walkman.New(walkman.WithContentHash(), walkman.WithFS("davs://me@cloud.example.com", cloud))
+//	hashes, err := wm.WalkDirs("/home/me/Pictures", "davs://me@cloud.example.com/remote.php/dav/files/me/Photos")
+//
+// Names are paths on the server without their leading slash. Nextcloud
+// and ownCloud report the md5 checksum of files uploaded by their clients,
+// walkman.WithContentHash only downloads the others.
+//
+// Like walkman, it uses no external dependencies.
+package webdav
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config locates a server and the credentials to read it.
+type Config struct {
+	URL      string // of the server, e.g https://cloud.example.com
+	Username string // for basic authentication, none if empty
+	Password string
+
+	Client *http.Client // http.DefaultClient if nil
+}
+
+// FS is a WebDAV server. It is safe for concurrent use.
+type FS struct {
+	config Config
+	base   *url.URL
+}
+
+// Returns an FS reading the server of config.
+func New(config Config) (*FS, error) {
+	base, err := url.Parse(strings.TrimSuffix(config.URL, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("webdav: %s is not an http or https URL", config.URL)
+	}
+
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &FS{config: config, base: base}, nil
+}
+
+// Properties asked for every file.
+const propfind = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns">
+  <d:prop>
+    <d:resourcetype/>
+    <d:getcontentlength/>
+    <d:getlastmodified/>
+    <oc:checksums/>
+  </d:prop>
+</d:propfind>`
+
+// A 207 Multi-Status response to PROPFIND.
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				Collection *struct{} `xml:"DAV: resourcetype>collection"`
+				Length     int64     `xml:"DAV: getcontentlength"`
+				Modified   string    `xml:"DAV: getlastmodified"`
+				Checksums  []string  `xml:"http://owncloud.org/ns checksums>checksum"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// An error response of the server.
+type Error struct {
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("webdav: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Lets errors.Is match fs.ErrNotExist and fs.ErrPermission.
+func (e *Error) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return fs.ErrNotExist
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fs.ErrPermission
+	}
+	return nil
+}
+
+// Returns the path on the server of name, refusing invalid names.
+func remotePath(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join("/", name), nil
+}
+
+// Sends a request for the file at p, turning error responses into errors.
+func (fsys *FS) do(method, p string, header http.Header, body io.Reader) (*http.Response, error) {
+	u := *fsys.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + p
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	if fsys.config.Username != "" {
+		req.SetBasicAuth(fsys.config.Username, fsys.config.Password)
+	}
+
+	resp, err := fsys.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, &Error{StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// Returns the files at p, and its members if depth is 1, by path.
+func (fsys *FS) propfind(p string, depth string) (map[string]*fileInfo, error) {
+	header := http.Header{
+		"Depth":        {depth},
+		"Content-Type": {"application/xml; charset=utf-8"},
+	}
+
+	resp, err := fsys.do("PROPFIND", p, header, strings.NewReader(propfind))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("webdav: %s: %w", p, err)
+	}
+
+	// hrefs are below the path of the base URL
+	prefix := strings.TrimSuffix(fsys.base.Path, "/")
+
+	infos := map[string]*fileInfo{}
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+
+		name := path.Clean("/" + strings.TrimPrefix(href.Path, prefix))
+
+		for _, ps := range r.Propstats {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+
+			modTime, _ := http.ParseTime(ps.Prop.Modified)
+			infos[name] = &fileInfo{
+				name:    path.Base(name),
+				size:    ps.Prop.Length,
+				modTime: modTime,
+				dir:     ps.Prop.Collection != nil,
+				md5:     md5Checksum(ps.Prop.Checksums),
+			}
+		}
+	}
+	return infos, nil
+}
+
+// Returns the md5 digest among checksums like "SHA1:... MD5:...".
+func md5Checksum(checksums []string) string {
+	for _, c := range checksums {
+		for _, field := range strings.Fields(c) {
+			if strings.HasPrefix(strings.ToUpper(field), "MD5:") {
+				return strings.ToLower(field[4:])
+			}
+		}
+	}
+	return ""
+}
+
+// Returns the FileInfo of the named file.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	p, err := remotePath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := fsys.propfind(p, "0")
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	info, ok := infos[p]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: errors.New("webdav: missing from the response")}
+	}
+
+	if name == "." {
+		info.name = "."
+	}
+	return info, nil
+}
+
+// Reads the named directory, returning its entries sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := remotePath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := fsys.propfind(p, "1")
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	if self, ok := infos[p]; ok && !self.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	entries := []fs.DirEntry{}
+	for member, info := range infos {
+		if path.Dir(member) == p && member != p {
+			entries = append(entries, fs.FileInfoToDirEntry(info))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Opens the named file or directory.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.Unwrap(err)}
+	}
+
+	if info.IsDir() {
+		return &dir{fsys: fsys, name: name, info: info}, nil
+	}
+	return &file{fsys: fsys, name: name, info: info}, nil
+}
+
+// A file opened for reading. The content is only
+// requested on the first Read, so Stat is free.
+type file struct {
+	fsys *FS
+	name string
+	info fs.FileInfo
+	body io.ReadCloser
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.body == nil {
+		resp, err := f.fsys.do(http.MethodGet, path.Join("/", f.name), nil, nil)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.body = resp.Body
+	}
+	return f.body.Read(p)
+}
+
+func (f *file) Close() error {
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+// A directory opened with Open, read on the first ReadDir.
+type dir struct {
+	fsys    *FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+	md5     string
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() interface{}   { return fi }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// Returns the md5 digest in hex the server reported, if any.
+// Used by walkman.WithContentHash to skip downloading the file.
+func (fi *fileInfo) ContentMD5() (string, bool) {
+	return fi.md5, fi.md5 != ""
+}
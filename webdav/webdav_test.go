@@ -0,0 +1,149 @@
+package webdav
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const davRoot = "/remote.php/dav/files/me"
+
+// Serves files below davRoot from memory like Nextcloud would.
+type fakeServer struct {
+	files map[string]string // by name, directories are implied
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, _ := r.BasicAuth(); user != "me" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, davRoot), "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		content, ok := s.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, content)
+	case "PROPFIND":
+		if !s.isDir(name) {
+			if _, ok := s.files[name]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns">`)
+		s.writeResponse(w, name)
+
+		if r.Header.Get("Depth") == "1" && s.isDir(name) {
+			seen := map[string]bool{}
+			for f := range s.files {
+				if !strings.HasPrefix(f, dirPrefix(name)) {
+					continue
+				}
+
+				member := strings.TrimPrefix(f, dirPrefix(name))
+				if i := strings.Index(member, "/"); i >= 0 {
+					member = member[:i]
+				}
+
+				if !seen[member] {
+					seen[member] = true
+					s.writeResponse(w, path.Join(name, member))
+				}
+			}
+		}
+		fmt.Fprint(w, `</d:multistatus>`)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func dirPrefix(name string) string {
+	if name == "" {
+		return ""
+	}
+	return name + "/"
+}
+
+func (s *fakeServer) isDir(name string) bool {
+	for f := range s.files {
+		if name == "" || strings.HasPrefix(f, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fakeServer) writeResponse(w http.ResponseWriter, name string) {
+	href := (&url.URL{Path: path.Join(davRoot, name)}).EscapedPath()
+	modified := time.Unix(1600000000, 0).UTC().Format(http.TimeFormat)
+
+	fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop>`, href)
+	if s.isDir(name) {
+		fmt.Fprintf(w, `<d:resourcetype><d:collection/></d:resourcetype><d:getlastmodified>%s</d:getlastmodified>`, modified)
+	} else {
+		fmt.Fprintf(w, `<d:resourcetype/><d:getcontentlength>%d</d:getcontentlength><d:getlastmodified>%s</d:getlastmodified>`,
+			len(s.files[name]), modified)
+
+		if name == "a.txt" {
+			fmt.Fprint(w, `<oc:checksums><oc:checksum>SHA1:f572d396fae9206628714fb2ce00f72e94f2258f MD5:5D41402ABC4B2A76B9719D911017C592</oc:checksum></oc:checksums>`)
+		}
+	}
+	fmt.Fprint(w, `</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>`)
+
+	// servers report the properties they do not know separately
+	fmt.Fprint(w, `<d:propstat><d:prop><oc:checksums/></d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat></d:response>`)
+}
+
+func TestFS(t *testing.T) {
+	server := httptest.NewServer(&fakeServer{files: map[string]string{
+		"a.txt":                "hello",
+		"Photos/b.jpg":         "pixels",
+		"Photos/2021/c d.jpg":  "pixels",
+		"Photos/2021/ü+%.jpg":  "odd name",
+		"Documents/report.pdf": "numbers",
+	}})
+	defer server.Close()
+
+	fsys, err := New(Config{URL: server.URL + davRoot, Username: "me", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(fsys, "a.txt", "Photos/b.jpg", "Photos/2021/c d.jpg", "Photos/2021/ü+%.jpg", "Documents/report.pdf"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fsys.Stat("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum, ok := info.Sys().(*fileInfo).ContentMD5(); !ok || sum != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("ContentMD5() = %q, %v; want the md5 checksum", sum, ok)
+	}
+
+	if _, err := fsys.Stat("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("stat of a missing file: %v, want fs.ErrNotExist", err)
+	}
+
+	fsys.config.Password = "wrong"
+	if _, err := fsys.Stat("a.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("stat with a wrong password: %v, want fs.ErrPermission", err)
+	}
+}
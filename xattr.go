@@ -0,0 +1,78 @@
+package walkman
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// Extractor of the extended attributes of files into Meta.Xattrs,
+// including POSIX ACLs (system.posix_acl_access) and SELinux labels
+// (security.selinux). Only local files on Linux have any.
+func ExtractXattrs(path string, open func() (io.ReadCloser, error), meta *Meta) error {
+	if isRemote(path) {
+		return nil
+	}
+
+	attrs, err := readXattrs(longPath(path))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(attrs) > 0 {
+		meta.Xattrs = attrs
+	}
+	return nil
+}
+
+// Tell files apart by their extended attributes too, e.g when copies
+// with other security labels or ACLs are not duplicates. The hasher in
+// use is extended, and its algorithm named with +xattr, e.g
+// md5+xattr:9e107d9d...
+func WithXattrHash() Option {
+	return func(w *Walkman) {
+		w.config.xattrHash = true
+	}
+}
+
+// Hashes files with h, followed by a digest of their extended
+// attributes if they have any, see WithXattrHash.
+func xattrHasher(h Hasher) Hasher {
+	return func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) (string, error) {
+		hash, err := h(path, info, open)
+		if err != nil {
+			return "", err
+		}
+
+		if algorithm := algorithmOf(hash); algorithm != "" {
+			hash = algorithm + "+xattr" + hash[len(algorithm):]
+		}
+
+		if isRemote(path) {
+			return hash, nil
+		}
+
+		attrs, err := readXattrs(longPath(path))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+
+		if len(attrs) == 0 {
+			return hash, nil
+		}
+
+		names := make([]string, 0, len(attrs))
+		for name := range attrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		digest := md5.New()
+		for _, name := range names {
+			fmt.Fprintf(digest, "%d:%s%d:%s", len(name), name, len(attrs[name]), attrs[name])
+		}
+		return fmt.Sprintf("%s.%x", hash, digest.Sum(nil)), nil
+	}
+}
@@ -0,0 +1,65 @@
+package walkman
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+// Returns the extended attributes of the file at path by name,
+// none if its file system does not keep any.
+func readXattrs(path string) (map[string][]byte, error) {
+	names, err := xattrList(path)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+
+	attrs := make(map[string][]byte)
+	for _, name := range bytes.Split(bytes.TrimSuffix(names, []byte{0}), []byte{0}) {
+		value, err := xattrGet(path, string(name))
+		if errors.Is(err, syscall.ENODATA) {
+			continue // removed since listed
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		attrs[string(name)] = value
+	}
+	return attrs, nil
+}
+
+func xattrList(path string) ([]byte, error) {
+	for {
+		size, err := syscall.Listxattr(path, nil)
+		if err != nil || size == 0 {
+			if errors.Is(err, syscall.ENOTSUP) {
+				err = nil
+			}
+			return nil, err
+		}
+
+		buf := make([]byte, size)
+		n, err := syscall.Listxattr(path, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue // grew since its size was asked for
+		}
+		return buf[:n], err
+	}
+}
+
+func xattrGet(path, name string) ([]byte, error) {
+	for {
+		size, err := syscall.Getxattr(path, name, nil)
+		if err != nil || size == 0 {
+			return []byte{}, err
+		}
+
+		buf := make([]byte, size)
+		n, err := syscall.Getxattr(path, name, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue
+		}
+		return buf[:n], err
+	}
+}
@@ -0,0 +1,44 @@
+package walkman
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestXattrs(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.txt", "same", time.Now())
+	b := writeFile(t, dir, "b.txt", "same", time.Now())
+	c := writeFile(t, dir, "c.txt", "same", time.Now())
+
+	for _, path := range []string{a.Path, b.Path} {
+		if err := syscall.Setxattr(path, "user.label", []byte("secret"), 0); err != nil {
+			t.Skipf("no extended attributes here: %v", err)
+		}
+	}
+
+	wm := New(WithContentHash(), WithXattrHash(), WithMetadata(ExtractXattrs))
+	hashes, err := wm.Walk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hashOf(t, hashes, a.Path) != hashOf(t, hashes, b.Path) || hashOf(t, hashes, a.Path) == hashOf(t, hashes, c.Path) {
+		t.Errorf("expected only the files labelled alike to match, got %v", hashes)
+	}
+
+	if alg := hashes.Algorithm(); alg != "md5+xattr" {
+		t.Errorf("expected md5+xattr hashes, got %q", alg)
+	}
+
+	for _, f := range hashes[hashOf(t, hashes, a.Path)] {
+		if f.Meta == nil || string(f.Meta.Xattrs["user.label"]) != "secret" {
+			t.Errorf("expected the label of %s in its metadata, got %+v", f.Path, f.Meta)
+		}
+	}
+
+	if f := hashes[hashOf(t, hashes, c.Path)][0]; f.Meta != nil {
+		t.Errorf("expected no metadata for %s, got %+v", f.Path, f.Meta)
+	}
+}
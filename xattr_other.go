@@ -0,0 +1,8 @@
+//go:build !linux
+
+package walkman
+
+// Extended attributes are only read on Linux.
+func readXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}